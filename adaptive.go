@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// adaptive goes back to the shortest interval min when the output changes,
+// and doubles the interval up to max after every steadyRuns runs in a row
+// which left it unchanged. A nil adaptive keeps the interval.
+type adaptive struct {
+	sync.Mutex
+
+	min        time.Duration
+	max        time.Duration
+	steadyRuns int
+
+	current   time.Duration
+	unchanged int
+
+	// onChange is called after the interval changed.
+	onChange func()
+}
+
+func newAdaptive(min, max time.Duration, steadyRuns int) *adaptive {
+	return &adaptive{min: min, max: max, steadyRuns: steadyRuns, current: min}
+}
+
+// record adapts the interval to whether the output of the finished run is
+// the same as that of the run before. Skipped runs, runs whose hook failed
+// and the first run do not count either way.
+func (a *adaptive) record(s *Snapshot) {
+	if a == nil || s.skipped || s.hookFailed {
+		return
+	}
+
+	before := s.before
+	for before != nil && (before.skipped || before.hookFailed) {
+		before = before.before
+	}
+
+	if before == nil {
+		return
+	}
+
+	a.Lock()
+	previous := a.current
+
+	if bytes.Equal(s.result, before.output().result) {
+		a.unchanged++
+
+		if a.unchanged >= a.steadyRuns {
+			a.unchanged = 0
+			a.current *= 2
+
+			if a.current > a.max {
+				a.current = a.max
+			}
+		}
+	} else {
+		a.unchanged = 0
+		a.current = a.min
+	}
+
+	changed := a.current != previous
+	a.Unlock()
+
+	if changed && a.onChange != nil {
+		a.onChange()
+	}
+}
+
+// interval returns the interval to wait before the next run.
+func (a *adaptive) interval(interval time.Duration) time.Duration {
+	if a == nil {
+		return interval
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	return a.current
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptive(t *testing.T) {
+	a := newAdaptive(time.Second, 5*time.Second, 2)
+
+	var changes int
+	a.onChange = func() { changes++ }
+
+	var last *Snapshot
+
+	run := func(output string) {
+		last = &Snapshot{result: []byte(output), before: last}
+		a.record(last)
+	}
+
+	// The first run has nothing to compare with.
+	run("a")
+	assert.Equal(t, time.Second, a.interval(time.Second))
+
+	run("a")
+	assert.Equal(t, time.Second, a.interval(time.Second))
+
+	run("a")
+	assert.Equal(t, 2*time.Second, a.interval(time.Second))
+
+	run("a")
+	run("a")
+	assert.Equal(t, 4*time.Second, a.interval(time.Second))
+
+	run("a")
+	run("a")
+	assert.Equal(t, 5*time.Second, a.interval(time.Second))
+
+	// Skipped runs and runs whose hook failed count neither way.
+	last = &Snapshot{before: last, skipped: true}
+	a.record(last)
+	last = &Snapshot{before: last, hookFailed: true}
+	a.record(last)
+	assert.Equal(t, 5*time.Second, a.interval(time.Second))
+
+	run("b")
+	assert.Equal(t, time.Second, a.interval(time.Second))
+	assert.Equal(t, 4, changes)
+
+	var disabled *adaptive
+
+	disabled.record(last)
+	assert.Equal(t, 3*time.Second, disabled.interval(3*time.Second))
+}
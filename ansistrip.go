@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+type ansiState int
+
+const (
+	ansiStateText ansiState = iota
+	ansiStateEscape
+	ansiStateIntermediate
+	ansiStateCSI
+	ansiStateString
+	ansiStateStringEscape
+)
+
+// ansiStripper drops the escape sequences of what is written to it: CSI
+// sequences such as colors and cursor movements, OSC sequences such as
+// titles, the other string sequences and two byte escapes. A sequence may
+// be split across writes. A sequence which breaks off keeps the byte which
+// broke it, and a string sequence never goes past the end of a line.
+type ansiStripper struct {
+	w     io.Writer
+	state ansiState
+	out   []byte
+}
+
+func newANSIStripper(w io.Writer) *ansiStripper {
+	return &ansiStripper{w: w}
+}
+
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	s.out = s.out[:0]
+
+	for _, c := range p {
+		s.feed(c)
+	}
+
+	if len(s.out) > 0 {
+		if _, err := s.w.Write(s.out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+//nolint:cyclop
+func (s *ansiStripper) feed(c byte) {
+	const esc = 0x1b
+
+	switch s.state {
+	case ansiStateText:
+		if c == esc {
+			s.state = ansiStateEscape
+		} else {
+			s.out = append(s.out, c)
+		}
+	case ansiStateEscape:
+		switch {
+		case c == '[':
+			s.state = ansiStateCSI
+		case c == ']' || c == 'P' || c == 'X' || c == '^' || c == '_':
+			s.state = ansiStateString
+		case c >= 0x20 && c <= 0x2f:
+			s.state = ansiStateIntermediate
+		case c >= 0x30 && c <= 0x7e:
+			s.state = ansiStateText
+		default:
+			s.breakOff(c)
+		}
+	case ansiStateIntermediate:
+		switch {
+		case c >= 0x20 && c <= 0x2f:
+		case c >= 0x30 && c <= 0x7e:
+			s.state = ansiStateText
+		default:
+			s.breakOff(c)
+		}
+	case ansiStateCSI:
+		switch {
+		case c >= 0x20 && c <= 0x3f:
+		case c >= 0x40 && c <= 0x7e:
+			s.state = ansiStateText
+		default:
+			s.breakOff(c)
+		}
+	case ansiStateString:
+		switch c {
+		case '\a':
+			s.state = ansiStateText
+		case esc:
+			s.state = ansiStateStringEscape
+		case '\n':
+			s.breakOff(c)
+		}
+	case ansiStateStringEscape:
+		if c == '\\' {
+			s.state = ansiStateText
+
+			return
+		}
+
+		// Not a terminator, so the string ended without one.
+		s.state = ansiStateEscape
+		s.feed(c)
+	}
+}
+
+// breakOff ends a sequence at a byte which cannot be part of it. ESC starts
+// another sequence, anything else is kept.
+func (s *ansiStripper) breakOff(c byte) {
+	s.state = ansiStateText
+	s.feed(c)
+}
+
+// stripANSI returns the output without its escape sequences.
+func stripANSI(b []byte) []byte {
+	var out bytes.Buffer
+
+	_, _ = newANSIStripper(&out).Write(b)
+
+	return out.Bytes()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "a\tb\n", want: "a\tb\n"},
+		{name: "sgr", in: "\x1b[1;32mok\x1b[0m\n", want: "ok\n"},
+		{name: "cursor", in: "\x1b[2J\x1b[H\x1b[?25lhi\x1b[K", want: "hi"},
+		{name: "osc with bel", in: "\x1b]0;title\aa", want: "a"},
+		{name: "osc with st", in: "\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\", want: "link"},
+		{name: "charset", in: "\x1b(Bx\x1b7y\x1b8", want: "xy"},
+		{name: "utf8", in: "\x1b[31mé✓\x1b[m", want: "é✓"},
+		{name: "broken csi keeps the byte", in: "\x1b[31\nnext", want: "\nnext"},
+		{name: "escape before control", in: "a\x1b\nb", want: "a\nb"},
+		{name: "escape in csi", in: "\x1b[3\x1b[1mx", want: "x"},
+		{name: "unterminated osc stops at the line", in: "\x1b]0;title\nout\n", want: "\nout\n"},
+		{name: "osc ended by another escape", in: "\x1b]0;t\x1b[1mx", want: "x"},
+		{name: "trailing escape", in: "a\x1b", want: "a"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(stripANSI([]byte(tt.in))))
+		})
+	}
+}
+
+func TestANSIStripperSplitWrites(t *testing.T) {
+	in := "\x1b[1;32mok\x1b[0m \x1b]0;title\x1b\\done\n"
+
+	// Every split of the input gives the same output.
+	for i := 0; i <= len(in); i++ {
+		var b bytes.Buffer
+
+		s := newANSIStripper(&b)
+		_, _ = s.Write([]byte(in[:i]))
+		_, _ = s.Write([]byte(in[i:]))
+
+		assert.Equal(t, "ok done\n", b.String(), "split at %d", i)
+	}
+}
+
+func TestSnapshotStripANSI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	command := `printf '\033[32mgreen\033[0m\n'; printf '\033[31merr\033[0m\n' >&2`
+
+	s := NewSnapshot(0, command, nil, runOptions{shell: "sh", stripANSI: true}, nil, make(chan struct{}))
+	require.NoError(t, s.run(make(chan int64, 1)))
+
+	assert.Equal(t, "green\n", string(s.result))
+	assert.Equal(t, "err\n", string(s.errorResult))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// backoff doubles the interval for every consecutive failed run up to max,
+// and goes back to the interval on the first success. A nil backoff keeps
+// the interval.
+type backoff struct {
+	sync.Mutex
+
+	max      time.Duration
+	failures int
+
+	// onChange is called after the number of failures changed.
+	onChange func()
+}
+
+func newBackoff(max time.Duration) *backoff {
+	return &backoff{max: max}
+}
+
+// record counts the finished run as a failure if it exited with non-zero or
+// could not start. Skipped runs do not count either way.
+func (b *backoff) record(s *Snapshot) {
+	if b == nil || s.skipped {
+		return
+	}
+
+	failed := s.err != nil || s.exitCode != 0
+
+	b.Lock()
+	before := b.failures
+
+	if failed {
+		b.failures++
+	} else {
+		b.failures = 0
+	}
+
+	changed := b.failures != before
+	b.Unlock()
+
+	if changed && b.onChange != nil {
+		b.onChange()
+	}
+}
+
+// interval returns the interval to wait before the next run.
+func (b *backoff) interval(interval time.Duration) time.Duration {
+	if b == nil {
+		return interval
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	d := interval
+	for i := 0; i < b.failures && d < b.max; i++ {
+		d *= 2
+	}
+
+	if d > b.max {
+		d = b.max
+	}
+
+	if d < interval {
+		d = interval
+	}
+
+	return d
+}
+
+func (b *backoff) failureCount() int {
+	if b == nil {
+		return 0
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	return b.failures
+}
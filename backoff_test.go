@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Minute)
+
+	var changes int
+	b.onChange = func() { changes++ }
+
+	failed := &Snapshot{exitCode: 1}
+	broken := &Snapshot{err: errors.New("exec: not found")}
+	ok := &Snapshot{}
+
+	assert.Equal(t, 10*time.Second, b.interval(10*time.Second))
+
+	b.record(failed)
+	assert.Equal(t, 20*time.Second, b.interval(10*time.Second))
+
+	b.record(broken)
+	b.record(&Snapshot{exitCode: 1, skipped: true})
+	assert.Equal(t, 40*time.Second, b.interval(10*time.Second))
+	assert.Equal(t, 2, b.failureCount())
+
+	b.record(failed)
+	b.record(failed)
+	assert.Equal(t, time.Minute, b.interval(10*time.Second))
+	assert.Equal(t, 2*time.Minute, b.interval(2*time.Minute))
+
+	b.record(ok)
+	b.record(ok)
+	assert.Equal(t, 10*time.Second, b.interval(10*time.Second))
+	assert.Equal(t, 5, changes)
+
+	var disabled *backoff
+
+	disabled.record(failed)
+	assert.Equal(t, 10*time.Second, disabled.interval(10*time.Second))
+}
+
+func TestClockSnapshotBackoff(t *testing.T) {
+	interval := 20 * time.Millisecond
+	b := newBackoff(time.Hour)
+
+	c := ClockSnapshot(time.Now().UnixNano(), fakeNewSnap, interval, 0, OverlapPolicySkip, func() {}, b, nil, nil)
+
+	var ids []int64
+
+	timeout := time.After(40 * interval)
+
+	for len(ids) < 4 {
+		select {
+		case s := <-c:
+			s.exitCode = 1
+			close(s.finish)
+
+			ids = append(ids, s.id)
+		case <-timeout:
+			t.Fatalf("only %d runs", len(ids))
+		}
+	}
+
+	// Every failure doubles the gap, leaving out ticks in between.
+	for i, want := range []int64{2, 4, 8} {
+		gap := ids[i+1] - ids[i]
+		assert.InDelta(t, want*int64(interval/time.Millisecond), gap, float64(interval/time.Millisecond)/2, "gap %d", i)
+	}
+}
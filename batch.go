@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// batchRecord is a run as --batch-format json writes it, one per line.
+type batchRecord struct {
+	Time     time.Time `json:"time"`
+	ExitCode int       `json:"exit_code"`
+	Duration float64   `json:"duration"`
+	Changed  bool      `json:"changed"`
+	Output   string    `json:"output"`
+	Stderr   string    `json:"stderr,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Due and Drift are when the run was meant to start and how many
+	// seconds late it did, if it keeps to a schedule.
+	Due   *time.Time `json:"due,omitempty"`
+	Drift *float64   `json:"drift,omitempty"`
+}
+
+// newBatchRecord returns the record of the run.
+func newBatchRecord(s *Snapshot, changed bool) batchRecord {
+	r := batchRecord{
+		Time:     s.start,
+		ExitCode: s.exitCode,
+		Duration: s.end.Sub(s.start).Seconds(),
+		Changed:  changed,
+		Output:   string(s.result),
+		Stderr:   string(s.errorResult),
+	}
+
+	if s.err != nil && s.exitCode <= 0 {
+		r.Error = s.err.Error()
+	}
+
+	if drift, due := s.drift(); due {
+		seconds := drift.Seconds()
+		r.Due, r.Drift = &s.due, &seconds
+	}
+
+	return r
+}
+
+// formatBatchEntry returns the run as --batch writes it, a block like the
+// log file has, or a line of JSON.
+func formatBatchEntry(s *Snapshot, changed, asJSON bool) ([]byte, error) {
+	if !asJSON {
+		drift, due := s.drift()
+		fields := []string{"changed=" + strconv.FormatBool(changed)}
+		if due {
+			fields = append(fields, "drift="+formatDrift(drift))
+		}
+
+		return formatLogEntry(s, fields...), nil
+	}
+
+	b, err := json.Marshal(newBatchRecord(s, changed))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// runBatch runs the command on the interval of the config without showing
+// it, writing every run to w until interrupted, the schedule ends or a
+// trigger fires with --trigger-exit. It returns the latest finished run.
+//
+//nolint:funlen,cyclop
+func runBatch(conf *config, w io.Writer) (*Snapshot, error) {
+	command := conf.runtime.commands[0]
+
+	newSnap := func(id int64, before *Snapshot, finish chan<- struct{}) *Snapshot {
+		return NewSnapshot(id, command.cmd, command.args, newRunOptions(conf), before, finish)
+	}
+
+	var b *backoff
+	if conf.general.backoff {
+		b = newBackoff(conf.general.backoffMax)
+	}
+
+	var logFile *outputLog
+
+	if conf.general.logFile != "" {
+		var err error
+
+		logFile, err = openOutputLog(conf.general.logFile, conf.general.logMaxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		defer func() { _ = logFile.Close() }()
+	}
+
+	var a *adaptive
+	if conf.runtime.mode == ViddyIntervalModeAdaptive {
+		a = newAdaptive(conf.runtime.interval, conf.general.adaptiveMax, conf.general.adaptiveSteadyRuns)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	defer signal.Stop(interrupt)
+
+	var (
+		drift  driftStats
+		missed int64
+	)
+
+	onSkip := func() { atomic.AddInt64(&missed, 1) }
+
+	if conf.general.debug {
+		defer func() {
+			drift.missed = atomic.LoadInt64(&missed)
+			if drift.runs > 0 || drift.missed > 0 {
+				fmt.Fprintln(os.Stderr, "drift:", drift)
+			}
+		}()
+	}
+
+	queue := newSnapshotQueue(conf, time.Now().UnixNano(), newSnap, onSkip, func(time.Time) {},
+		b, a, newSteppedInterval(conf.runtime.steps, time.Now()), nil)
+	finished := make(chan int64)
+
+	var latest *Snapshot
+
+	notifier := newNotifier(conf.general.notifyCooldown)
+
+	for {
+		var (
+			s  *Snapshot
+			ok bool
+		)
+
+		select {
+		case <-interrupt:
+			return latest, nil
+		case s, ok = <-queue:
+			if !ok {
+				return latest, nil
+			}
+		}
+
+		go func() { _ = s.run(finished) }()
+
+		select {
+		case <-finished:
+		case <-interrupt:
+			// The run is cut short, so it is left out. It gets the same time
+			// to stop as when viddy quits.
+			s.terminate()
+
+			select {
+			case <-finished:
+			case <-time.After(conf.general.killTimeout):
+				s.kill()
+				<-finished
+			}
+
+			return latest, nil
+		}
+
+		if s.skipped {
+			continue
+		}
+
+		latest = s
+
+		if d, ok := s.drift(); ok {
+			drift.add(d)
+		}
+
+		var (
+			changed bool
+			fired   bool
+		)
+
+		if !s.hookFailed {
+			_ = s.compareFromBefore()
+			changed = s.diffBase != nil && s.diffAdditionCount+s.diffDeletionCount > 0
+
+			var previous []byte
+			if s.diffBase != nil {
+				previous = s.diffBase.result
+			}
+
+			_, fired = findTriggerMatch(conf.general.triggers, s.result, previous, s.diffBase != nil)
+
+			// Only the previous run is compared with, the older ones can go.
+			s.before, s.diffBase = nil, nil
+		}
+
+		entry, err := formatBatchEntry(s, changed, conf.runtime.batchJSON)
+		if err != nil {
+			return latest, err
+		}
+
+		if _, err := w.Write(entry); err != nil {
+			return latest, err
+		}
+
+		if logFile != nil {
+			if err := logFile.write(s); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+
+		if s.hookErr != nil {
+			fmt.Fprintln(os.Stderr, s.hookErr)
+		}
+
+		if s.pipeErr != nil {
+			fmt.Fprintln(os.Stderr, s.pipeErr)
+		}
+
+		if changed && conf.general.onChange != "" {
+			if err := runHook("on-change", conf.general.onChange, s); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+
+		// Sending takes a while, which the runs do not wait for.
+		if n, ok := runNotification(conf.general.notify, s, changed); ok && notifier.allow() {
+			go func() {
+				if _, err := notifier.notify(n); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}()
+		}
+
+		if fired && conf.general.triggerExit {
+			return latest, nil
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBatchEntry(t *testing.T) {
+	start := time.Date(2021, 9, 4, 12, 0, 0, 0, time.UTC)
+	s := &Snapshot{
+		start:       start,
+		end:         start.Add(1500 * time.Millisecond),
+		result:      []byte("a\nb"),
+		errorResult: []byte("oops\n"),
+		exitCode:    2,
+		err:         errors.New("exit status 2"),
+	}
+
+	entry, err := formatBatchEntry(s, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, "==> 2021-09-04T12:00:00.000Z exit=2 duration=1.5s changed=true <==\na\nb\n", string(entry))
+
+	entry, err = formatBatchEntry(s, true, true)
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":"2021-09-04T12:00:00Z","exit_code":2,"duration":1.5,"changed":true,`+
+		`"output":"a\nb","stderr":"oops\n"}`+"\n", string(entry))
+
+	// A run due at a particular time tells how late it started.
+	s.due = start.Add(-25 * time.Millisecond)
+
+	entry, err = formatBatchEntry(s, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, "==> 2021-09-04T12:00:00.000Z exit=2 duration=1.5s changed=false drift=+25ms <==\na\nb\n", string(entry))
+
+	entry, err = formatBatchEntry(s, false, true)
+	require.NoError(t, err)
+	assert.Contains(t, string(entry), `"due":"2021-09-04T11:59:59.975Z","drift":0.025}`)
+}
+
+func TestRunBatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	conf := &config{
+		runtime: runtimeConfig{
+			commands:  []commandSpec{{cmd: "echo", args: []string{"ERROR"}}},
+			interval:  10 * time.Millisecond,
+			mode:      ViddyIntervalModeSequential,
+			batchJSON: true,
+		},
+		general: general{
+			shell:         "sh",
+			overlapPolicy: OverlapPolicySkip,
+			triggers:      []*regexp.Regexp{regexp.MustCompile("ERROR")},
+			triggerExit:   true,
+		},
+	}
+
+	var out bytes.Buffer
+
+	latest, err := runBatch(conf, &out)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, 0, latest.exitStatus())
+
+	// The trigger fires on the first run.
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 1)
+
+	var r batchRecord
+
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &r))
+	assert.Equal(t, "ERROR\n", r.Output)
+	assert.False(t, r.Changed)
+}
+
+func TestRunBatchOnce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	conf := &config{
+		runtime: runtimeConfig{
+			commands: []commandSpec{{cmd: "echo", args: []string{"preview;", "exit", "3"}}},
+			interval: time.Hour,
+			mode:     ViddyIntervalModeOnce,
+		},
+		general: general{
+			shell:         "sh",
+			overlapPolicy: OverlapPolicySkip,
+		},
+	}
+
+	var out bytes.Buffer
+
+	// It returns after the single run, without waiting for the interval.
+	latest, err := runBatch(conf, &out)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, 3, latest.exitStatus())
+	assert.Equal(t, 1, strings.Count(out.String(), "preview"))
+}
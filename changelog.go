@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// changeEntry is a snapshot whose output changed or whose exit status
+// flipped since the one before, counting the lines like the header does.
+type changeEntry struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Added      int       `json:"added"`
+	Removed    int       `json:"removed"`
+	Modified   int       `json:"modified"`
+	ExitBefore int       `json:"exit_before"`
+	Exit       int       `json:"exit"`
+}
+
+// newChangeEntry returns the entry of the snapshot once it was compared,
+// and false if nothing changed since the one before.
+func newChangeEntry(s *Snapshot) (changeEntry, bool) {
+	if s.diffBase == nil {
+		return changeEntry{}, false
+	}
+
+	e := changeEntry{ID: s.id, Time: s.start, ExitBefore: s.diffBase.exitStatus(), Exit: s.exitStatus()}
+	if s.lines != nil {
+		e.Added, e.Removed, e.Modified = s.lines.stats.added, s.lines.stats.removed, s.lines.stats.modified
+	}
+
+	changed := s.diffAdditionCount+s.diffDeletionCount > 0
+
+	return e, changed || e.Exit != e.ExitBefore
+}
+
+// summary describes the change in a line, like "+3 −1 ~0 lines, exit 0→1".
+func (e changeEntry) summary() string {
+	var parts []string
+
+	if stats := (diffStats{added: e.Added, removed: e.Removed, modified: e.Modified}); !stats.isZero() {
+		parts = append(parts, stats.String()+" lines")
+	}
+
+	if e.Exit != e.ExitBefore {
+		parts = append(parts, fmt.Sprintf("exit %d→%d", e.ExitBefore, e.Exit))
+	}
+
+	if len(parts) == 0 {
+		return "changed"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// changeLog is the list of changes, sorted by id, which grows as the
+// snapshots are compared.
+type changeLog struct {
+	sync.Mutex
+
+	entries []changeEntry
+}
+
+// add records the entry in its place, since runs may finish out of order.
+func (l *changeLog) add(e changeEntry) {
+	l.Lock()
+	defer l.Unlock()
+
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].ID >= e.ID })
+	if i < len(l.entries) && l.entries[i].ID == e.ID {
+		l.entries[i] = e
+
+		return
+	}
+
+	l.entries = append(l.entries, changeEntry{})
+	copy(l.entries[i+1:], l.entries[i:])
+	l.entries[i] = e
+}
+
+func (l *changeLog) ids() []int64 {
+	l.Lock()
+	defer l.Unlock()
+
+	ids := make([]int64, len(l.entries))
+	for i, e := range l.entries {
+		ids[i] = e.ID
+	}
+
+	return ids
+}
+
+func (l *changeLog) entry(id int64) (changeEntry, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].ID >= id })
+	if i == len(l.entries) || l.entries[i].ID != id {
+		return changeEntry{}, false
+	}
+
+	return l.entries[i], true
+}
+
+// list returns the entries, most recent first.
+func (l *changeLog) list() []changeEntry {
+	l.Lock()
+	defer l.Unlock()
+
+	list := make([]changeEntry, len(l.entries))
+	for i, e := range l.entries {
+		list[len(l.entries)-1-i] = e
+	}
+
+	return list
+}
+
+// prune forgets the entries of the snapshots before the id.
+func (l *changeLog) prune(id int64) {
+	l.Lock()
+	defer l.Unlock()
+
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].ID >= id })
+	l.entries = append([]changeEntry(nil), l.entries[i:]...)
+}
+
+// changeLogWidth is the width of the change log besides its times.
+const changeLogWidth = 32
+
+func (v *Viddy) formatChangeLogRow(id int64) string {
+	e, ok := v.changeLog.entry(id)
+	if !ok {
+		return ""
+	}
+
+	return v.times.format(e.Time, shortTimeLayout) + " " + e.summary()
+}
+
+func (v *Viddy) ShowChangeLog(b bool) {
+	v.showChangeLog = b
+	v.arrange()
+}
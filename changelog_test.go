@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChangeEntry(t *testing.T) {
+	a := &Snapshot{id: 1000, result: []byte("a\nb\nc\n"), completed: true}
+	b := &Snapshot{id: 2000, result: []byte("a\nB\nc\nd\n"), completed: true, before: a}
+	c := &Snapshot{id: 3000, result: []byte("a\nB\nc\nd\n"), completed: true, before: b, exitCode: 1}
+	d := &Snapshot{id: 4000, result: []byte("a\nB\nc\nd\n"), completed: true, before: c, exitCode: 1}
+
+	for _, s := range []*Snapshot{a, b, c, d} {
+		require.NoError(t, s.compareFromBefore())
+	}
+
+	_, ok := newChangeEntry(a)
+	assert.False(t, ok, "the first run has nothing to change from")
+
+	e, ok := newChangeEntry(b)
+	assert.True(t, ok)
+	assert.Equal(t, "+1 −0 ~1 lines", e.summary())
+
+	e, ok = newChangeEntry(c)
+	assert.True(t, ok)
+	assert.Equal(t, "exit 0→1", e.summary())
+
+	_, ok = newChangeEntry(d)
+	assert.False(t, ok)
+}
+
+func TestChangeEntrySummary(t *testing.T) {
+	e := changeEntry{Added: 3, Removed: 1, ExitBefore: 0, Exit: 1}
+	assert.Equal(t, "+3 −1 ~0 lines, exit 0→1", e.summary())
+}
+
+func TestChangeLog(t *testing.T) {
+	var l changeLog
+
+	// Runs may finish out of order.
+	l.add(changeEntry{ID: 3000})
+	l.add(changeEntry{ID: 1000})
+	l.add(changeEntry{ID: 2000, Added: 1})
+	l.add(changeEntry{ID: 2000, Added: 2})
+
+	assert.Equal(t, []int64{1000, 2000, 3000}, l.ids())
+
+	e, ok := l.entry(2000)
+	assert.True(t, ok)
+	assert.Equal(t, 2, e.Added)
+
+	_, ok = l.entry(2500)
+	assert.False(t, ok)
+
+	l.prune(2000)
+	assert.Equal(t, []changeEntry{{ID: 3000}, {ID: 2000, Added: 2}}, l.list())
+}
@@ -0,0 +1,94 @@
+package main
+
+// changeHunksOf returns the hunks of the lines of the snapshot which the diff
+// highlights, or of all the changes accumulated so far if permanent.
+func changeHunksOf(s *Snapshot, permanent bool) []changeHunk {
+	if !s.diffPrepared {
+		return nil
+	}
+
+	if permanent {
+		return changeHunks(s.permanentMask().changedLines())
+	}
+
+	if s.lines == nil {
+		return nil
+	}
+
+	return changeHunks(s.lines.changedLines())
+}
+
+// adjacentChange returns the hunk to go to from the current one, or from the
+// line at the top of the body when on none, and whether it went around past
+// the last or the first hunk.
+func adjacentChange(hunks []changeHunk, current, top int, forward bool) (int, bool) {
+	if current < 0 || current >= len(hunks) {
+		if forward {
+			for i, h := range hunks {
+				if h.start >= top {
+					return i, false
+				}
+			}
+
+			return 0, true
+		}
+
+		for i := len(hunks) - 1; i >= 0; i-- {
+			if hunks[i].start < top {
+				return i, false
+			}
+		}
+
+		return len(hunks) - 1, true
+	}
+
+	if forward {
+		if current+1 == len(hunks) {
+			return 0, true
+		}
+
+		return current + 1, false
+	}
+
+	if current == 0 {
+		return len(hunks) - 1, true
+	}
+
+	return current - 1, false
+}
+
+// goToChange scrolls the body so that the next or the previous hunk of
+// changes is in the middle, and the status tells which one it is.
+func (v *Viddy) goToChange(forward bool) {
+	if !v.isShowDiff {
+		v.setMessage("Diff is off")
+
+		return
+	}
+
+	if len(v.hunks) == 0 {
+		v.setMessage("No changes")
+
+		return
+	}
+
+	row, column := v.bodyView.GetScrollOffset()
+	top := v.originalLine(v.bodyView.lineOf(row))
+
+	i, wrapped := adjacentChange(v.hunks, v.hunk, top, forward)
+	v.hunk = i
+
+	h := v.hunks[i]
+	start := v.bodyView.rowOf(v.displayRow(h.start))
+	end := v.bodyView.rowOf(v.displayRow(h.end-1) + 1)
+	_, _, _, height := v.bodyView.GetInnerRect()
+
+	v.bodyView.ScrollTo(centerRows(start, end, height, v.bodyView.rowCount()), column)
+
+	switch {
+	case wrapped && forward:
+		v.setMessage("Past the last change, back to the first")
+	case wrapped:
+		v.setMessage("Past the first change, back to the last")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjacentChange(t *testing.T) {
+	hunks := []changeHunk{{start: 3, end: 5}, {start: 10, end: 11}, {start: 20, end: 24}}
+
+	tests := []struct {
+		name        string
+		current     int
+		top         int
+		forward     bool
+		want        int
+		wantWrapped bool
+	}{
+		{name: "first from the top", current: -1, top: 0, forward: true, want: 0},
+		{name: "first below the top", current: -1, top: 4, forward: true, want: 1},
+		{name: "none below the top", current: -1, top: 21, forward: true, want: 0, wantWrapped: true},
+		{name: "last above the top", current: -1, top: 15, forward: false, want: 1},
+		{name: "none above the top", current: -1, top: 0, forward: false, want: 2, wantWrapped: true},
+		{name: "next", current: 0, top: 0, forward: true, want: 1},
+		{name: "past the last", current: 2, top: 0, forward: true, want: 0, wantWrapped: true},
+		{name: "previous", current: 2, top: 0, forward: false, want: 1},
+		{name: "past the first", current: 0, top: 0, forward: false, want: 2, wantWrapped: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, wrapped := adjacentChange(hunks, tt.current, tt.top, tt.forward)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantWrapped, wrapped)
+		})
+	}
+}
+
+func TestChangeHunksOf(t *testing.T) {
+	before := &Snapshot{result: []byte("a\nb\nc\nd\n"), completed: true}
+	assert.NoError(t, before.compareFromBefore())
+
+	s := &Snapshot{result: []byte("a\nB\nc\nD\n"), completed: true, before: before}
+	assert.Nil(t, changeHunksOf(s, false))
+
+	assert.NoError(t, s.compareFromBefore())
+	assert.Equal(t, []changeHunk{{start: 1, end: 2}, {start: 3, end: 4}}, changeHunksOf(s, false))
+}
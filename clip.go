@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// clipMarker is drawn over the last column of lines which go on past the
+// right edge of the body.
+const clipMarker = '…'
+
+var regionTag = regexp.MustCompile(`\["[a-zA-Z0-9_,;: \-\.]*"\]`)
+
+// lineWidths returns how wide every line of the text for a text view is on
+// screen, without its tags and with the tabs expanded like tview does.
+func lineWidths(text string) []int {
+	lines := strings.Split(text, "\n")
+	widths := make([]int, len(lines))
+
+	for i, line := range lines {
+		line = regionTag.ReplaceAllString(line, "")
+		line = strings.ReplaceAll(line, "\t", strings.Repeat(" ", tview.TabSize))
+		widths[i] = tview.TaggedStringWidth(line)
+	}
+
+	return widths
+}
+
+// drawClipMarkers marks the rows of the text view, which does not wrap, whose
+// line is cut off on the right. The marker is only drawn on screen, so it is
+// never searched or compared.
+func drawClipMarkers(screen tcell.Screen, view *tview.TextView, widths []int, color tcell.Color) {
+	x, y, width, height := view.GetInnerRect()
+	if width <= 0 {
+		return
+	}
+
+	row, column := view.GetScrollOffset()
+	if row < 0 {
+		row = 0
+	}
+
+	for i := 0; i < height && row+i < len(widths); i++ {
+		if widths[row+i]-column <= width {
+			continue
+		}
+
+		_, _, style, _ := screen.GetContent(x+width-1, y+i)
+		screen.SetContent(x+width-1, y+i, clipMarker, nil, style.Foreground(color))
+	}
+}
+
+// truncationNotice tells how much of the output of the snapshot was kept, or
+// returns "" if none was cut off.
+func truncationNotice(s *Snapshot) string {
+	if s == nil || s.totalLines == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("output truncated: showing %d of %d lines", len(splitLines(string(s.result))), s.totalLines)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineWidths(t *testing.T) {
+	text := "[red]abc[-:-:-]\n[\"trigger\"]de[\"\"]\na\tb\n日本"
+
+	assert.Equal(t, []int{3, 2, 2 + tview.TabSize, 4}, lineWidths(text))
+}
+
+func TestDrawClipMarkers(t *testing.T) {
+	text := "short\nthis line is too long\nexactly10!\n"
+
+	view := tview.NewTextView().SetWrap(false).SetDynamicColors(true)
+	view.SetText(text)
+	view.SetRect(0, 0, 10, 3)
+
+	screen := tcell.NewSimulationScreen("")
+	assert.NoError(t, screen.Init())
+	screen.SetSize(10, 3)
+
+	view.Draw(screen)
+	drawClipMarkers(screen, view, lineWidths(text), tcell.ColorYellow)
+
+	lastColumn := func(y int) rune {
+		r, _, _, _ := screen.GetContent(9, y)
+
+		return r
+	}
+
+	assert.Equal(t, ' ', lastColumn(0))
+	assert.Equal(t, clipMarker, lastColumn(1))
+	assert.Equal(t, '!', lastColumn(2))
+
+	// Scrolled to the right, the end of the long line fits.
+	view.ScrollTo(0, 12)
+	view.Draw(screen)
+	drawClipMarkers(screen, view, lineWidths(text), tcell.ColorYellow)
+	assert.NotEqual(t, clipMarker, lastColumn(1))
+}
+
+func TestTruncationNotice(t *testing.T) {
+	assert.Empty(t, truncationNotice(&Snapshot{result: []byte("a\nb\n")}))
+	assert.Equal(t, "output truncated: showing 2 of 5813 lines",
+		truncationNotice(&Snapshot{result: []byte("a\nb\n"), totalLines: 5813}))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var errNoClipboard = errors.New(
+	"no clipboard found: install wl-copy, xclip, xsel or pbcopy, or use a terminal which supports OSC 52")
+
+type clipboardError struct {
+	command string
+	err     error
+}
+
+func (e clipboardError) Error() string {
+	return fmt.Sprintf("%s could not copy: %v", e.command, e.err)
+}
+
+func (e clipboardError) Unwrap() error {
+	return e.err
+}
+
+// clipboardCommand is a program which copies its stdin to the clipboard.
+type clipboardCommand struct {
+	name string
+	args []string
+}
+
+// clipboardCommands returns the programs which may copy to the clipboard on
+// the system, in the order they are tried.
+func clipboardCommands(goos string, getenv func(string) string) []clipboardCommand {
+	switch goos {
+	case "darwin":
+		return []clipboardCommand{{name: "pbcopy"}}
+	case "windows":
+		return []clipboardCommand{{name: "clip"}}
+	}
+
+	var commands []clipboardCommand
+
+	if getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, clipboardCommand{name: "wl-copy"})
+	}
+
+	if getenv("DISPLAY") != "" {
+		commands = append(commands,
+			clipboardCommand{name: "xclip", args: []string{"-selection", "clipboard"}},
+			clipboardCommand{name: "xsel", args: []string{"--clipboard", "--input"}},
+		)
+	}
+
+	return commands
+}
+
+// osc52Terminals are the terminals, by $TERM or $TERM_PROGRAM, which are
+// known to let programs set the clipboard with OSC 52.
+var osc52Terminals = []string{"kitty", "alacritty", "foot", "wezterm", "iterm", "ghostty", "contour"}
+
+// supportsOSC52 tells whether the terminal is known to take OSC 52. tmux and
+// screen only pass it on if they are set up to, so they are not.
+func supportsOSC52(getenv func(string) string) bool {
+	if getenv("TMUX") != "" || strings.HasPrefix(getenv("TERM"), "screen") {
+		return false
+	}
+
+	names := strings.ToLower(getenv("TERM") + " " + getenv("TERM_PROGRAM"))
+	for _, t := range osc52Terminals {
+		if strings.Contains(names, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// osc52 returns the escape sequence which sets the clipboard to b.
+func osc52(b []byte) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString(b) + "\a"
+}
+
+// clipboardTimeout is how long a program may take to copy.
+const clipboardTimeout = 2 * time.Second
+
+// clipboard copies to the system clipboard through the terminal with OSC 52
+// if it supports it, or else through the first program which is installed.
+type clipboard struct {
+	goos     string
+	getenv   func(string) string
+	lookPath func(string) (string, error)
+
+	// terminal is where OSC 52 is written. It must not be written while the
+	// screen is drawn.
+	terminal io.Writer
+}
+
+func newClipboard() *clipboard {
+	return &clipboard{
+		goos:     runtime.GOOS,
+		getenv:   os.Getenv,
+		lookPath: exec.LookPath,
+		terminal: os.Stdout,
+	}
+}
+
+// copy copies b to the clipboard and returns the way it did.
+func (c *clipboard) copy(b []byte) (string, error) {
+	if supportsOSC52(c.getenv) {
+		return c.copyOSC52(b)
+	}
+
+	for _, command := range clipboardCommands(c.goos, c.getenv) {
+		if _, err := c.lookPath(command.name); err != nil {
+			continue
+		}
+
+		return command.name, runClipboardCommand(command, b)
+	}
+
+	// Over SSH the terminal is the only way to the clipboard of the user.
+	if c.getenv("SSH_TTY") != "" {
+		return c.copyOSC52(b)
+	}
+
+	return "", errNoClipboard
+}
+
+func (c *clipboard) copyOSC52(b []byte) (string, error) {
+	_, err := io.WriteString(c.terminal, osc52(b))
+
+	return "OSC 52", err
+}
+
+func runClipboardCommand(command clipboardCommand, b []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+
+	// Its output is left alone, since xclip keeps it open while it holds the
+	// clipboard.
+	cmd := exec.CommandContext(ctx, command.name, command.args...)
+	cmd.Stdin = bytes.NewReader(b)
+
+	if err := cmd.Run(); err != nil {
+		return clipboardError{command: command.name, err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupportsOSC52(t *testing.T) {
+	for _, tt := range []struct {
+		env  map[string]string
+		want bool
+	}{
+		{env: map[string]string{"TERM": "xterm-kitty"}, want: true},
+		{env: map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app"}, want: true},
+		{env: map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "WezTerm"}, want: true},
+		{env: map[string]string{"TERM": "xterm-256color"}, want: false},
+		{env: map[string]string{"TERM": "screen-256color"}, want: false},
+		{env: map[string]string{"TERM": "xterm-kitty", "TMUX": "/tmp/tmux-1000/default,1,0"}, want: false},
+	} {
+		assert.Equal(t, tt.want, supportsOSC52(mapGetenv(tt.env)), tt.env)
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	assert.Equal(t, "\x1b]52;c;aGVsbG8K\a", osc52([]byte("hello\n")))
+}
+
+func TestClipboardCopy(t *testing.T) {
+	clipboardFor := func(goos string, env map[string]string, installed ...string) (*clipboard, *bytes.Buffer) {
+		var terminal bytes.Buffer
+
+		return &clipboard{
+			goos:   goos,
+			getenv: mapGetenv(env),
+			lookPath: func(name string) (string, error) {
+				for _, i := range installed {
+					if i == name {
+						return "/bin/true", nil
+					}
+				}
+
+				return "", exec.ErrNotFound
+			},
+			terminal: &terminal,
+		}, &terminal
+	}
+
+	c, terminal := clipboardFor("linux", map[string]string{"TERM": "foot", "DISPLAY": ":0"}, "xclip")
+	via, err := c.copy([]byte("hello\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "OSC 52", via)
+	assert.Equal(t, osc52([]byte("hello\n")), terminal.String())
+
+	c, _ = clipboardFor("linux", map[string]string{}, "xclip")
+	_, err = c.copy([]byte("hello\n"))
+	assert.True(t, errors.Is(err, errNoClipboard))
+
+	c, terminal = clipboardFor("linux", map[string]string{"SSH_TTY": "/dev/pts/1"})
+	via, err = c.copy([]byte("hello\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "OSC 52", via)
+	assert.NotEmpty(t, terminal.String())
+
+	assert.Equal(t, []clipboardCommand{{name: "pbcopy"}}, clipboardCommands("darwin", mapGetenv(nil)))
+	assert.Equal(t, []string{"wl-copy", "xclip", "xsel"}, commandNames(clipboardCommands("linux",
+		mapGetenv(map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}))))
+}
+
+func TestRunClipboardCommand(t *testing.T) {
+	if _, err := exec.LookPath("false"); err != nil {
+		t.Skip("requires false")
+	}
+
+	err := runClipboardCommand(clipboardCommand{name: "false"}, []byte("hello\n"))
+	assert.EqualError(t, err, "false could not copy: exit status 1")
+}
+
+func mapGetenv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func commandNames(commands []clipboardCommand) []string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+
+	return names
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const commandEditorLabel = "Command: "
+
+// activeCommand returns the command the runs to come execute.
+func (v *Viddy) activeCommand() commandSpec {
+	v.RLock()
+	defer v.RUnlock()
+
+	return v.command
+}
+
+// setCommand makes the runs to come execute the command line of the editor
+// instead, keeping the history. The first snapshot of the new command is
+// marked on the timeline once it finished.
+func (v *Viddy) setCommand(line string) {
+	line = strings.TrimSpace(line)
+
+	before := strings.Join(v.activeCommand().line(), " ")
+	if line == "" || line == before {
+		return
+	}
+
+	// The runs join the command and its arguments for the shell, so the
+	// line is taken as it is.
+	v.Lock()
+	v.command = commandSpec{cmd: line}
+	v.Unlock()
+
+	v.log(levelInfo, "command edited", "before", before, "after", line)
+
+	v.setMessage(fmt.Sprintf("Watching %q from the next run", line))
+}
+
+// ranCommand returns the command line the snapshot ran, if it is not the one
+// the runs execute now, or "".
+func (v *Viddy) ranCommand(id int64) string {
+	s := v.getSnapShot(id)
+	if s == nil {
+		return ""
+	}
+
+	if line := s.commandLine(); line != strings.Join(v.activeCommand().line(), " ") {
+		return line
+	}
+
+	return ""
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRanCommand(t *testing.T) {
+	v := &Viddy{command: commandSpec{cmd: "ls", args: []string{"-l"}}}
+
+	for _, s := range []*Snapshot{
+		{id: 0, command: "ls", args: []string{"-l"}},
+		{id: 1000, command: "ls -la"},
+	} {
+		v.snapshots.Store(s.id, s)
+		v.idList = append(v.idList, s.id)
+	}
+
+	assert.Equal(t, "", v.ranCommand(0))
+	assert.Equal(t, "ls -la", v.ranCommand(1000))
+	assert.Equal(t, "", v.ranCommand(2000))
+
+	// Once the command is edited, the earlier snapshots ran another one.
+	v.command = commandSpec{cmd: "ls -la"}
+	assert.Equal(t, "ls -l", v.ranCommand(0))
+	assert.Equal(t, "", v.ranCommand(1000))
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+type completionShellError struct {
+	shell string
+}
+
+func (e completionShellError) Error() string {
+	return fmt.Sprintf("cannot complete for shell %q, use bash, zsh or fish", e.shell)
+}
+
+// completionScripts write the completion script of each shell.
+var completionScripts = map[string]func([]completionFlag) string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// completionKinds are the flags taking values which can be completed other
+// than from a list.
+var completionKinds = map[string]string{
+	"after-each":  "command",
+	"before-each": "command",
+	"chdir":       "dir",
+	"cmd":         "command",
+	"config":      "file",
+	"input":       "file",
+	"log-file":    "file",
+	"on-change":   "command",
+	"session":     "file",
+	"shell":       "command",
+	"ssh":         "host",
+}
+
+// completionFlag is a flag as the completion scripts see it.
+type completionFlag struct {
+	name      string
+	shorthand string
+	usage     string
+
+	// value is true if the flag takes a value, which may be the next word.
+	// optional is true if it only takes one after "=".
+	value    bool
+	optional bool
+
+	repeatable bool
+	values     []string
+	kind       string
+}
+
+// completionFlags returns the documented flags.
+func completionFlags(flagSet *pflag.FlagSet) []completionFlag {
+	var flags []completionFlag
+
+	flagSet.VisitAll(func(f *pflag.Flag) {
+		if f.Usage == "" {
+			return
+		}
+
+		isBool := f.Value.Type() == "bool"
+
+		flags = append(flags, completionFlag{
+			name:       f.Name,
+			shorthand:  f.Shorthand,
+			usage:      f.Usage,
+			value:      !isBool && f.NoOptDefVal == "",
+			optional:   !isBool && f.NoOptDefVal != "",
+			repeatable: strings.HasSuffix(f.Value.Type(), "Array"),
+			values:     completionValues(f.Name),
+			kind:       completionKinds[f.Name],
+		})
+	})
+
+	return flags
+}
+
+// completionValues returns the values of the flags which take one of a list.
+func completionValues(name string) []string {
+	switch name {
+	case "differences":
+		return []string{"true", "false", "permanent",
+			string(DiffGranularityChar), string(DiffGranularityWord), string(DiffGranularityLine)}
+	case "batch-format":
+		return []string{"text", "json"}
+	case "notify":
+		return []string{string(NotifyModeChange), string(NotifyModeError), string(NotifyModeBoth)}
+	case "split":
+		return []string{string(SplitHorizontal), string(SplitVertical)}
+	case "theme":
+		names := make([]string, 0, len(themePresets))
+		for name := range themePresets {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return names
+	case "completion":
+		return []string{"bash", "zsh", "fish"}
+	}
+
+	return nil
+}
+
+// completionScript returns the completion script of the shell for the flags.
+func completionScript(shell string, flagSet *pflag.FlagSet) string {
+	return completionScripts[shell](completionFlags(flagSet))
+}
+
+// valueFlagNames returns the flags which take the next word as their value.
+func valueFlagNames(flags []completionFlag) []string {
+	var names []string
+
+	for _, f := range flags {
+		if !f.value {
+			continue
+		}
+
+		names = append(names, "--"+f.name)
+		if f.shorthand != "" {
+			names = append(names, "-"+f.shorthand)
+		}
+	}
+
+	return names
+}
+
+func bashCompletion(flags []completionFlag) string {
+	var words []string
+
+	var values strings.Builder
+
+	for _, f := range flags {
+		names := []string{"--" + f.name}
+		if f.shorthand != "" {
+			names = append(names, "-"+f.shorthand)
+		}
+
+		words = append(words, names...)
+
+		if !f.value && !f.optional {
+			continue
+		}
+
+		var reply string
+
+		switch {
+		case len(f.values) > 0:
+			reply = fmt.Sprintf(`COMPREPLY=($(compgen -W "%s" -- "$cur"))`, strings.Join(f.values, " "))
+		case f.kind == "file":
+			reply = `COMPREPLY=($(compgen -f -- "$cur"))`
+		case f.kind == "dir":
+			reply = `COMPREPLY=($(compgen -d -- "$cur"))`
+		case f.kind == "command":
+			reply = `COMPREPLY=($(compgen -c -- "$cur"))`
+		case f.kind == "host":
+			reply = `COMPREPLY=($(compgen -A hostname -- "$cur"))`
+		}
+
+		// Optional values only come after "=", which bash splits off.
+		if f.optional {
+			names = names[:1]
+		}
+
+		fmt.Fprintf(&values, "\t%s)\n", strings.Join(names, "|"))
+
+		if reply != "" {
+			fmt.Fprintf(&values, "\t\t%s\n", reply)
+		}
+
+		values.WriteString("\t\treturn\n\t\t;;\n")
+	}
+
+	return fmt.Sprintf(`# bash completion for viddy, written by viddy --completion bash
+
+_viddy() {
+	local cur prev i
+	cur=${COMP_WORDS[COMP_CWORD]}
+	prev=${COMP_WORDS[COMP_CWORD-1]}
+
+	# The first word which is neither a flag nor its value starts the command.
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		%s)
+			if [[ ${COMP_WORDS[i+1]} == = ]]; then
+				((i += 2))
+			else
+				((i++))
+			fi
+			;;
+		=)
+			((i++))
+			;;
+		-*)
+			;;
+		*)
+			if declare -F _command_offset >/dev/null; then
+				_command_offset "$i"
+			else
+				COMPREPLY=($(compgen -f -- "$cur"))
+			fi
+			return
+			;;
+		esac
+	done
+
+	# bash splits "--flag=value" into three words.
+	if [[ $cur == = ]]; then
+		cur=
+	elif [[ $prev == = ]]; then
+		prev=${COMP_WORDS[COMP_CWORD-2]}
+	fi
+
+	case $prev in
+%s	esac
+
+	case $cur in
+	-*)
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+		;;
+	*)
+		COMPREPLY=($(compgen -c -- "$cur"))
+		;;
+	esac
+}
+
+complete -F _viddy viddy
+`, strings.Join(valueFlagNames(flags), "|"), values.String(), strings.Join(words, " "))
+}
+
+func zshCompletion(flags []completionFlag) string {
+	var b strings.Builder
+
+	b.WriteString("#compdef viddy\n\n# zsh completion for viddy, written by viddy --completion zsh\n\n")
+	b.WriteString("_arguments -s -S -A '-*' \\\n")
+
+	for _, f := range flags {
+		description := zshDescription(f.usage)
+
+		repeat := ""
+		if f.repeatable {
+			repeat = "*"
+		}
+
+		action := " "
+
+		switch {
+		case len(f.values) > 0:
+			action = "(" + strings.Join(f.values, " ") + ")"
+		case f.kind == "file":
+			action = "_files"
+		case f.kind == "dir":
+			action = "_files -/"
+		case f.kind == "command":
+			action = "_command_names -e"
+		case f.kind == "host":
+			action = "_hosts"
+		}
+
+		switch {
+		case f.value:
+			fmt.Fprintf(&b, "\t'%s--%s=[%s]:%s:%s' \\\n", repeat, f.name, description, f.name, action)
+
+			if f.shorthand != "" {
+				fmt.Fprintf(&b, "\t'%s-%s+[%s]:%s:%s' \\\n", repeat, f.shorthand, description, f.name, action)
+			}
+		case f.optional:
+			fmt.Fprintf(&b, "\t'%s--%s=-[%s]::%s:%s' \\\n", repeat, f.name, description, f.name, action)
+
+			if f.shorthand != "" {
+				fmt.Fprintf(&b, "\t'%s-%s[%s]' \\\n", repeat, f.shorthand, description)
+			}
+		default:
+			fmt.Fprintf(&b, "\t'%s--%s[%s]' \\\n", repeat, f.name, description)
+
+			if f.shorthand != "" {
+				fmt.Fprintf(&b, "\t'%s-%s[%s]' \\\n", repeat, f.shorthand, description)
+			}
+		}
+	}
+
+	b.WriteString("\t'(-)1:command:_command_names -e' \\\n")
+	b.WriteString("\t'*::arguments:_normal'\n")
+
+	return b.String()
+}
+
+// zshDescription escapes the usage for a description of _arguments within
+// single quotes.
+func zshDescription(usage string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`, "'", `'\''`).Replace(usage)
+}
+
+func fishCompletion(flags []completionFlag) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `# fish completion for viddy, written by viddy --completion fish
+
+# __fish_viddy_command_start prints where the command starts, which is the
+# first word that is neither a flag nor its value.
+function __fish_viddy_command_start
+	set -l tokens (commandline -opc)
+	set -l value 0
+	for i in (seq 2 (count $tokens))
+		if test $value = 1
+			set value 0
+			continue
+		end
+		switch $tokens[$i]
+			case %s
+				set value 1
+			case '-*'
+			case '*'
+				echo $i
+				return 0
+		end
+	end
+	return 1
+end
+
+function __fish_viddy_complete_command
+	set -l start (__fish_viddy_command_start)
+	set -l tokens (commandline -opc)
+	complete -C (string join -- ' ' (string escape -- $tokens[$start..-1]) (commandline -ct))
+end
+
+complete -c viddy -f
+complete -c viddy -n 'not __fish_viddy_command_start' -a '(__fish_complete_command)'
+complete -c viddy -n '__fish_viddy_command_start' -a '(__fish_viddy_complete_command)'
+
+`, strings.Join(valueFlagNames(flags), " "))
+
+	for _, f := range flags {
+		line := "complete -c viddy -n 'not __fish_viddy_command_start'"
+
+		if f.shorthand != "" && !f.optional {
+			line += " -s " + f.shorthand
+		}
+
+		line += " -l " + f.name
+
+		if f.value || f.optional {
+			switch {
+			case len(f.values) > 0:
+				line += " -xa '" + strings.Join(f.values, " ") + "'"
+			case f.kind == "file":
+				line += " -rF"
+			case f.kind == "dir":
+				line += " -xa '(__fish_complete_directories (commandline -ct))'"
+			case f.kind == "command":
+				line += " -xa '(__fish_complete_command)'"
+			case f.kind == "host":
+				line += " -xa '(__fish_print_hostnames)'"
+			default:
+				line += " -x"
+			}
+		}
+
+		fmt.Fprintf(&b, "%s -d %s\n", line, fishQuote(f.usage))
+
+		// The shorthand of a flag with an optional value takes none.
+		if f.shorthand != "" && f.optional {
+			fmt.Fprintf(&b, "complete -c viddy -n 'not __fish_viddy_command_start' -s %s -d %s\n", f.shorthand, fishQuote(f.usage))
+		}
+	}
+
+	return b.String()
+}
+
+// fishQuote quotes the string for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
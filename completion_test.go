@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionFlags(t *testing.T) {
+	flags := map[string]completionFlag{}
+	for _, f := range completionFlags(newFlagSet()) {
+		flags[f.name] = f
+	}
+
+	// Undocumented flags are left out.
+	assert.NotContains(t, flags, "debug")
+
+	assert.True(t, flags["interval"].value)
+	assert.Equal(t, "n", flags["interval"].shorthand)
+	assert.False(t, flags["pty"].value)
+	assert.True(t, flags["differences"].optional)
+	assert.Equal(t, []string{"true", "false", "permanent", "char", "word", "line"}, flags["differences"].values)
+	assert.True(t, flags["env"].repeatable)
+	assert.Equal(t, "dir", flags["chdir"].kind)
+	assert.Contains(t, flags["theme"].values, "nord")
+}
+
+func TestCompletionScript(t *testing.T) {
+	flags := completionFlags(newFlagSet())
+
+	for shell := range completionScripts {
+		shell := shell
+		t.Run(shell, func(t *testing.T) {
+			script := completionScript(shell, newFlagSet())
+
+			for _, f := range flags {
+				assert.True(t, strings.Contains(script, "--"+f.name) || strings.Contains(script, "-l "+f.name), f.name)
+			}
+
+			// Check the syntax where the shell is installed.
+			path, err := exec.LookPath(shell)
+			if err != nil {
+				return
+			}
+
+			file := filepath.Join(t.TempDir(), "viddy."+shell)
+			require.NoError(t, os.WriteFile(file, []byte(script), 0o600))
+
+			out, err := exec.Command(path, "-n", file).CombinedOutput()
+			assert.NoError(t, err, string(out))
+		})
+	}
+}
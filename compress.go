@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	// compactInterval is how often the snapshots which got old are compressed.
+	compactInterval = 5 * time.Second
+	// prefetchRadius is how many snapshots on either side of the one shown
+	// are decompressed ahead, and kept so.
+	prefetchRadius = 8
+	// keyframeEvery is how many compressed snapshots share a keyframe.
+	keyframeEvery = 64
+)
+
+var errPackedOutput = errors.New("compressed output is corrupt")
+
+// snapshotOutput is what a snapshot keeps of the output of its run.
+type snapshotOutput struct {
+	result      []byte
+	errorResult []byte
+	raw         []byte
+}
+
+func (o snapshotOutput) size() int {
+	return len(o.result) + len(o.errorResult) + len(o.raw)
+}
+
+// packOutput compresses the output. The runs of a watched command mostly
+// print the same, so it is compressed against the output of a keyframe,
+// which makes it little more than the difference.
+func packOutput(o snapshotOutput, dict []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	w, err := flate.NewWriterDict(&b, flate.DefaultCompression, dict)
+	if err != nil {
+		return nil, err
+	}
+
+	// raw tells apart no --pipe filter from one which printed nothing.
+	hasRaw := byte(0)
+	if o.raw != nil {
+		hasRaw = 1
+	}
+
+	if _, err := w.Write([]byte{hasRaw}); err != nil {
+		return nil, err
+	}
+
+	for _, field := range [][]byte{o.result, o.errorResult, o.raw} {
+		var n [binary.MaxVarintLen64]byte
+		if _, err := w.Write(n[:binary.PutUvarint(n[:], uint64(len(field)))]); err != nil {
+			return nil, err
+		}
+
+		if _, err := w.Write(field); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// unpackOutput decompresses what packOutput compressed against the same
+// dictionary.
+func unpackOutput(packed, dict []byte) (snapshotOutput, error) {
+	r := flate.NewReaderDict(bytes.NewReader(packed), dict)
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return snapshotOutput{}, err
+	}
+
+	if len(b) == 0 {
+		return snapshotOutput{}, errPackedOutput
+	}
+
+	hasRaw := b[0] == 1
+	b = b[1:]
+
+	fields := make([][]byte, 3)
+	for i := range fields {
+		n, read := binary.Uvarint(b)
+		if read <= 0 || uint64(len(b)-read) < n {
+			return snapshotOutput{}, errPackedOutput
+		}
+
+		fields[i] = b[read : read+int(n)]
+		b = b[read+int(n):]
+	}
+
+	o := snapshotOutput{result: fields[0], errorResult: fields[1]}
+	if hasRaw {
+		o.raw = fields[2]
+	}
+
+	return o, nil
+}
+
+// output returns what the snapshot keeps of the output, decompressed if it
+// is compressed. It is safe to call while the snapshot is being compressed.
+func (s *Snapshot) output() snapshotOutput {
+	s.Lock()
+	packed, dict := s.packed, s.dict
+	o := snapshotOutput{result: s.result, errorResult: s.errorResult, raw: s.raw}
+	s.Unlock()
+
+	if packed == nil {
+		return o
+	}
+
+	var d []byte
+	if dict != nil {
+		d = dict.output().result
+	}
+
+	o, err := unpackOutput(packed, d)
+	if err != nil {
+		// It was compressed here, so only a bug gets here.
+		return snapshotOutput{errorResult: []byte(err.Error() + "\n")}
+	}
+
+	return o
+}
+
+func (s *Snapshot) isPacked() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.packed != nil
+}
+
+// unpackedSnapshot is a compressed snapshot decompressed, with the diff that
+// was dropped along with its output computed again.
+type unpackedSnapshot struct {
+	s     *Snapshot
+	out   snapshotOutput
+	diff  []diffmatchpatch.Diff
+	lines *lineMap
+}
+
+// unpack decompresses the snapshot which was compared with base. It does not
+// change it, so that it can run outside of the event loop.
+func (s *Snapshot) unpack(base *Snapshot) *unpackedSnapshot {
+	u := &unpackedSnapshot{s: s, out: s.output()}
+
+	before, beforeHashes := "", lineHashes
+	if base != nil {
+		before, beforeHashes = base.text(), base.hashLines
+	}
+
+	text := expandTabs(string(u.out.result), s.opts.tabWidth)
+	u.diff, u.lines = diffOutputs(before, text, beforeHashes, lineHashes, s.opts.diffNormalize, s.opts.diffGranularity)
+
+	return u
+}
+
+// restore keeps the snapshot uncompressed again. The mask and the counts of
+// the diff were kept all along, so that a reset of the permanent diff holds.
+func (u *unpackedSnapshot) restore() {
+	s := u.s
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.packed == nil {
+		return
+	}
+
+	s.result, s.errorResult, s.raw = u.out.result, u.out.errorResult, u.out.raw
+	s.packed, s.dict = nil, nil
+	s.diff, s.lines = u.diff, u.lines
+}
+
+// packedSnapshot is the compressed output of a snapshot, waiting to replace
+// it in the event loop.
+type packedSnapshot struct {
+	s      *Snapshot
+	packed []byte
+	dict   *Snapshot
+	size   int
+}
+
+// apply replaces the output of the snapshot, and its diff which is computed
+// again from it when it is restored.
+func (p packedSnapshot) apply() {
+	s := p.s
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.packed, s.dict = p.packed, p.dict
+	s.result, s.errorResult, s.raw = nil, nil, nil
+	s.diff, s.lines, s.hashes = nil, nil, nil
+}
+
+// compactor compresses the snapshots which got old. It is only used by the
+// goroutine of compactHistory.
+type compactor struct {
+	// keyframe is the snapshot which the following ones are compressed
+	// against, and keyframeOutput its output.
+	keyframe       *Snapshot
+	keyframeOutput []byte
+	uses           int
+
+	saved int
+}
+
+// pack compresses the output of the snapshot, against the keyframe unless it
+// becomes one. A keyframe is never compressed against another, so that
+// decompressing takes at most two steps.
+func (c *compactor) pack(s *Snapshot) (packedSnapshot, error) {
+	o := s.output()
+
+	if !s.isKeyframe && (c.keyframe == nil || c.uses >= keyframeEvery) {
+		s.isKeyframe = true
+		c.keyframe, c.keyframeOutput, c.uses = s, o.result, 0
+	}
+
+	p := packedSnapshot{s: s, size: o.size()}
+
+	var dict []byte
+	if !s.isKeyframe {
+		p.dict, dict = c.keyframe, c.keyframeOutput
+		c.uses++
+	}
+
+	var err error
+	p.packed, err = packOutput(o, dict)
+
+	return p, err
+}
+
+// compactHistory compresses the snapshots older than the newest
+// compressAfter ones every compactInterval, except those around the one shown.
+func (v *Viddy) compactHistory() {
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		v.compact()
+	}
+}
+
+func (v *Viddy) compact() {
+	v.RLock()
+	ids := v.idList
+	v.RUnlock()
+
+	var packed []packedSnapshot
+
+	for i := 0; i < len(ids)-v.compressAfter; i++ {
+		s := v.getSnapShot(ids[i])
+		if s == nil || !s.isSettled() || !s.diffPrepared || s.incompressible || s.isPacked() {
+			continue
+		}
+
+		p, err := v.compactor.pack(s)
+		if err != nil {
+			v.log(levelWarn, "snapshot not compressed", "id", s.id, "error", err)
+
+			continue
+		}
+
+		// Short outputs do not make up for the headers of the compression.
+		if len(p.packed) >= p.size {
+			s.incompressible = true
+
+			continue
+		}
+
+		packed = append(packed, p)
+	}
+
+	if len(packed) == 0 {
+		return
+	}
+
+	count, before, after := 0, 0, 0
+
+	v.app.QueueUpdate(func() {
+		for _, p := range packed {
+			if v.keepsUnpacked(p.s.id) {
+				continue
+			}
+
+			p.apply()
+			count++
+			before += p.size
+			after += len(p.packed)
+		}
+	})
+
+	if count == 0 {
+		return
+	}
+
+	v.compactor.saved += before - after
+	v.log(levelInfo, "snapshots compressed", "count", count, "bytes", before, "compressed", after,
+		"saved_total", v.compactor.saved)
+}
+
+// keepsUnpacked tells whether the snapshot is near the one shown or compared
+// with, so that moving around them does not wait for them to be
+// decompressed. In the event loop.
+func (v *Viddy) keepsUnpacked(id int64) bool {
+	if v.compareBase != nil && v.compareBase.id == id {
+		return true
+	}
+
+	if id == v.renderedID || id == v.currentID {
+		return true
+	}
+
+	v.RLock()
+	defer v.RUnlock()
+
+	current, i := v.indexOf(v.currentID), v.indexOf(id)
+	if current < 0 || i < 0 {
+		return false
+	}
+
+	return i >= current-prefetchRadius && i <= current+prefetchRadius
+}
+
+// indexOf returns where the id is in the sorted list of ids, or -1.
+func (v *Viddy) indexOf(id int64) int {
+	i := sort.Search(len(v.idList), func(i int) bool { return v.idList[i] >= id })
+	if i == len(v.idList) || v.idList[i] != id {
+		return -1
+	}
+
+	return i
+}
+
+// unpackShown decompresses the snapshot about to be shown, and those around
+// it in the background. In the event loop.
+func (v *Viddy) unpackShown(s *Snapshot) {
+	if s.isPacked() {
+		s.unpack(s.diffBase).restore()
+	}
+
+	if v.compressAfter == 0 {
+		return
+	}
+
+	v.RLock()
+	i := v.indexOf(s.id)
+
+	var neighbours []*Snapshot
+
+	for j := i - prefetchRadius; i >= 0 && j <= i+prefetchRadius; j++ {
+		if j < 0 || j >= len(v.idList) || j == i {
+			continue
+		}
+
+		if n := v.getSnapShot(v.idList[j]); n != nil && n.isPacked() {
+			neighbours = append(neighbours, n)
+		}
+	}
+	v.RUnlock()
+
+	if len(neighbours) == 0 {
+		return
+	}
+
+	bases := make([]*Snapshot, len(neighbours))
+	for j, n := range neighbours {
+		bases[j] = n.diffBase
+	}
+
+	go func() {
+		unpacked := make([]*unpackedSnapshot, len(neighbours))
+		for j, n := range neighbours {
+			unpacked[j] = n.unpack(bases[j])
+		}
+
+		v.app.QueueUpdate(func() {
+			for _, u := range unpacked {
+				u.restore()
+			}
+		})
+	}()
+}
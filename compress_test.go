@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackOutput(t *testing.T) {
+	dict := []byte(strings.Repeat("NAME READY STATUS\nweb 1/1 Running\n", 20))
+
+	for _, o := range []snapshotOutput{
+		{result: []byte("web 1/1 Running\n"), errorResult: []byte("warning\n")},
+		{result: []byte("filtered\n"), raw: []byte{}},
+		{},
+	} {
+		packed, err := packOutput(o, dict)
+		assert.NoError(t, err)
+
+		got, err := unpackOutput(packed, dict)
+		assert.NoError(t, err)
+		assert.Equal(t, string(o.result), string(got.result))
+		assert.Equal(t, string(o.errorResult), string(got.errorResult))
+		assert.Equal(t, o.raw != nil, got.raw != nil)
+	}
+
+	// Near the keyframe, the output takes little more than its difference.
+	o := snapshotOutput{result: append(append([]byte{}, dict...), "db 0/1 Pending\n"...)}
+	alone, err := packOutput(o, nil)
+	assert.NoError(t, err)
+
+	against, err := packOutput(o, dict)
+	assert.NoError(t, err)
+	assert.Less(t, len(against), len(alone))
+
+	_, err = unpackOutput([]byte("not flate"), nil)
+	assert.Error(t, err)
+}
+
+func TestCompactorRestoresSnapshots(t *testing.T) {
+	th := theme{diffChangedBackground: tcell.ColorGreen}
+
+	var snapshots []*Snapshot
+
+	var before *Snapshot
+
+	for i := 0; i < keyframeEvery+2; i++ {
+		s := &Snapshot{
+			id:        int64(i),
+			result:    []byte(fmt.Sprintf("uptime %d\nload 0.%d\n", i, i%3)),
+			completed: true,
+			before:    before,
+		}
+		assert.NoError(t, s.compareFromBefore())
+
+		snapshots = append(snapshots, s)
+		before = s
+	}
+
+	render := func(s *Snapshot) string {
+		var b strings.Builder
+		assert.NoError(t, s.render(&b, true, false, nil, th))
+
+		return b.String()
+	}
+
+	want := make([]string, len(snapshots))
+	for i, s := range snapshots {
+		want[i] = render(s)
+	}
+
+	var c compactor
+
+	for _, s := range snapshots {
+		p, err := c.pack(s)
+		assert.NoError(t, err)
+		p.apply()
+	}
+
+	assert.True(t, snapshots[0].isKeyframe)
+	assert.True(t, snapshots[keyframeEvery+1].isKeyframe)
+	assert.Equal(t, snapshots[0], snapshots[1].dict)
+	assert.Nil(t, snapshots[2].result)
+	assert.Equal(t, "uptime 2\nload 0.2\n", string(snapshots[2].output().result))
+
+	for i, s := range snapshots {
+		s.unpack(s.diffBase).restore()
+		assert.False(t, s.isPacked())
+		assert.Equal(t, want[i], render(s))
+	}
+}
@@ -3,8 +3,15 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 	"unicode"
 
@@ -16,8 +23,44 @@ import (
 )
 
 var (
-	errNoCommand        = errors.New("command is required")
-	errIntervalTooSmall = errors.New("interval too small")
+	errNoCommand          = errors.New("command is required")
+	errDifferences        = errors.New(`differences must be true, false, "permanent", "char", "word" or "line"`)
+	errDiffGranularity    = errors.New(`diff_granularity must be "char", "word" or "line"`)
+	errChangesContext     = errors.New("changes_context must not be negative")
+	errTabWidth           = errors.New("tab_width must be at least 1")
+	errScrollOff          = errors.New("scroll_off must not be negative")
+	errAdaptiveSteadyRuns = errors.New("adaptive_steady_runs must be at least 1")
+	errMaxLines           = errors.New("max_lines must not be negative")
+	errCompressAfter      = errors.New("compress_after must not be negative")
+	errOverlapPolicy      = errors.New(`overlap_policy must be one of "skip", "wait" or "kill"`)
+	errStderr             = errors.New(`stderr must be one of "separate", "interleave" or "hide"`)
+	errPtyNotSupported    = errors.New("--pty is not supported on windows")
+	errPlaybackSpeed      = errors.New(`playback_speed must be snapshots per second such as "4" or a speed-up such as "10x"`)
+	errScheduleInterval   = errors.New("--schedule cannot be used with -n")
+	errScheduleBackoff    = errors.New("--backoff cannot be used with --schedule")
+	errAdaptiveMode       = errors.New("--adaptive cannot be used with --precise, --clockwork or --schedule")
+	errAdaptiveSteps      = errors.New("--adaptive cannot be used with a stepped interval")
+	errEmptyCommand       = errors.New(`command is required on both sides of "---"`)
+	errSplit              = errors.New(`split must be "horizontal" or "vertical"`)
+	errSessionCommands    = errors.New("--session cannot be used with several commands")
+	errLogFileCommands    = errors.New("--log-file cannot be used with several commands")
+	errBatchCommands      = errors.New("--batch cannot be used with several commands")
+	errBatchFormat        = errors.New(`--batch-format must be "text" or "json"`)
+	errDifferencesAgainst = errors.New(`differences_against must be a number of runs such as 60 or a duration such as "5m"`)
+	errInputCapture       = errors.New("--input cannot be used with --stdin-capture")
+	errInputPty           = errors.New("--input and --stdin-capture cannot be used with --pty")
+	errNotify             = errors.New(`notify must be one of "change", "error" or "both"`)
+	errListen             = errors.New(`--listen must be a unix socket such as "unix:/tmp/viddy.sock"`)
+	errListenCommands     = errors.New("--listen cannot be used with several commands")
+	errStreamPolicy       = errors.New(`stream_policy must be "live" or "cut"`)
+	errFileCommand        = errors.New("--file cannot be used with a command")
+	errFileRemote         = errors.New("--file cannot be used with --ssh")
+	errLoadCommand        = errors.New("--load cannot be used with a command, it shows the one of the file")
+	errLoadBatch          = errors.New("--load cannot be used with --batch")
+	errLoadSession        = errors.New("--load cannot be used with --session")
+	errHistoryCommands    = errors.New("--history-file cannot be used with several commands")
+	errExecRemote         = errors.New("--exec cannot be used with --ssh, whose commands go through the remote shell")
+	errExecCommand        = errors.New("--exec needs a command")
 )
 
 type config struct {
@@ -25,188 +68,2060 @@ type config struct {
 	general general
 	theme   theme
 	keymap  keymapping
+
+	warnings []string
+
+	// fallbacks are the keys of the config file which could not be used,
+	// with the reason, so their defaults are used instead.
+	fallbacks map[string]string
+}
+
+func (c *config) addFallback(key, reason string) {
+	if c.fallbacks == nil {
+		c.fallbacks = map[string]string{}
+	}
+
+	c.fallbacks[key] = reason
+}
+
+type runtimeConfig struct {
+	commands     []commandSpec
+	interval     time.Duration
+	steps        []intervalStep // nil unless the interval steps up over time, interval being the first
+	jitter       time.Duration
+	mode         ViddyIntervalMode
+	schedule     *cronSchedule
+	remote       *remoteHost
+	host         string
+	chdir        string
+	configFile   string
+	sessionForce bool
+	help         bool
+	version      bool
+	showConfig   bool
+	completion   string
+	batch        bool
+	batchJSON    bool
+
+	// input is the file fed to the runs on stdin, and stdin what viddy read
+	// on its own stdin with stdinCapture, which main does.
+	input        string
+	stdinCapture bool
+	stdin        []byte
+
+	// listen is the path of the unix socket the control server listens on,
+	// if any.
+	listen string
+
+	// file is read on every run instead of running a command, if not empty.
+	file string
+
+	// exec runs the commands without a shell.
+	exec bool
+
+	// load is the history file or session looked back at instead of running
+	// a command, if not empty.
+	load string
+
+	// rule is the rule of the config file which matched the command, if
+	// any, out of rules.
+	rule  *rule
+	rules int
+
+	times timeFormat
+
+	// startupLog records how the config was resolved, for the debug log.
+	// It is only kept with general.debug.
+	startupLog []logRecord
+	debugLog   *debugLog
+}
+
+// commandSpec is a command to watch, each in a pane of its own.
+type commandSpec struct {
+	cmd  string
+	args []string
+}
+
+func (c commandSpec) line() []string {
+	return append([]string{c.cmd}, c.args...)
+}
+
+type general struct {
+	shell              string
+	shellOptions       []string
+	debug              bool
+	log                string
+	logLevel           logLevel
+	differences        bool
+	permanentDiff      bool
+	differencesAgainst diffOffset
+	diffNormalize      diffNormalize
+	diffGranularity    DiffGranularity
+	changesOnly        bool
+	sideBySide         bool
+	changesContext     int
+	noTitle            bool
+	// titleTemplateText is shown in the header instead of the command,
+	// parsed as titleTemplate.
+	titleTemplateText  string
+	titleTemplate      *template.Template
+	noWrap             bool
+	showLineAge        bool
+	tabWidth           int
+	stickyScroll       bool
+	scrollOff          int
+	searchIgnoreCase   bool
+	showHost           bool
+	maxConcurrentRuns  int
+	overlapPolicy      OverlapPolicy
+	stderr             StderrMode
+	pty                bool
+	stripANSI          bool
+	env                []envVar
+	mouse              bool
+	forceTruecolor     bool
+	timeMachineStep    time.Duration
+	playbackSpeed      playbackSpeed
+	scrubAcceleration  []int
+	showSnapshotList   bool
+	showChangeLog      bool
+	showTimeline       bool
+	pollWhileSuspended bool
+	pollInTimeMachine  bool
+	confirmDestructive bool
+	onChange           string
+	beforeEach         string
+	afterEach          string
+	pipe               string
+	triggers           []*regexp.Regexp
+	highlights         []highlightRule
+	triggerExit        bool
+	exitCode           bool
+	logFile            string
+	logMaxSize         int64
+	maxLines           int
+	maxBytes           int64
+	keepFor            time.Duration
+	compressAfter      int
+	flashOnChange      bool
+	flashDuration      time.Duration
+	killTimeout        time.Duration
+	streamGrace        time.Duration
+	streamPolicy       StreamPolicy
+	notify             NotifyMode
+	notifyCooldown     time.Duration
+	timeFormat         string
+	timeZone           string
+	backoff            bool
+	backoffMax         time.Duration
+
+	adaptiveMax        time.Duration
+	adaptiveSteadyRuns int
+	strictConfig       bool
+	sessionFile        string
+	historyFile        string
+	ssh                string
+	split              SplitLayout
+	statusItems        []StatusItem
+}
+
+type theme struct {
+	tview.Theme
+	diffMoved             tcell.Color
+	diffAdded             tcell.Color
+	diffRemoved           tcell.Color
+	diffChangedBackground tcell.Color
+	diffChangedForeground tcell.Color
+	clipMarker            tcell.Color
+	stderrText            tcell.Color
+	errorText             tcell.Color
+	flash                 tcell.Color
+}
+
+type KeyStroke struct {
+	Key     tcell.Key
+	Rune    rune
+	ModMask tcell.ModMask
+}
+
+type keymapping struct {
+	toggleTimeMachine            map[KeySequence]struct{}
+	goToPastOnTimeMachine        map[KeySequence]struct{}
+	goToFutureOnTimeMachine      map[KeySequence]struct{}
+	goToMorePastOnTimeMachine    map[KeySequence]struct{}
+	goToMoreFutureOnTimeMachine  map[KeySequence]struct{}
+	goToNowOnTimeMachine         map[KeySequence]struct{}
+	goToOldestOnTimeMachine      map[KeySequence]struct{}
+	goToTimeOnTimeMachine        map[KeySequence]struct{}
+	backDurationOnTimeMachine    map[KeySequence]struct{}
+	forwardDurationOnTimeMachine map[KeySequence]struct{}
+	increaseStepOnTimeMachine    map[KeySequence]struct{}
+	decreaseStepOnTimeMachine    map[KeySequence]struct{}
+	playOnTimeMachine            map[KeySequence]struct{}
+	markOnTimeMachine            map[KeySequence]struct{}
+	compareOnTimeMachine         map[KeySequence]struct{}
+	togglePollOnTimeMachine      map[KeySequence]struct{}
+
+	quit               map[KeySequence]struct{}
+	toggleSuspend      map[KeySequence]struct{}
+	toggleDiff         map[KeySequence]struct{}
+	resetDiff          map[KeySequence]struct{}
+	clearHistory       map[KeySequence]struct{}
+	toggleChangesOnly  map[KeySequence]struct{}
+	toggleSideBySide   map[KeySequence]struct{}
+	toggleWrap         map[KeySequence]struct{}
+	toggleLineAge      map[KeySequence]struct{}
+	editCommand        map[KeySequence]struct{}
+	toggleHeader       map[KeySequence]struct{}
+	toggleHelp         map[KeySequence]struct{}
+	toggleLog          map[KeySequence]struct{}
+	toggleSnapshotList map[KeySequence]struct{}
+	toggleChangeLog    map[KeySequence]struct{}
+	toggleStderr       map[KeySequence]struct{}
+	toggleRaw          map[KeySequence]struct{}
+	yank               map[KeySequence]struct{}
+	yankVisible        map[KeySequence]struct{}
+	shell              map[KeySequence]struct{}
+	focusNextPane      map[KeySequence]struct{}
+	search             map[KeySequence]struct{}
+	toggleSearchCase   map[KeySequence]struct{}
+	toggleSearchWord   map[KeySequence]struct{}
+	scrollUp           map[KeySequence]struct{}
+	scrollDown         map[KeySequence]struct{}
+	scrollLeft         map[KeySequence]struct{}
+	scrollRight        map[KeySequence]struct{}
+	pageUp             map[KeySequence]struct{}
+	pageDown           map[KeySequence]struct{}
+	scrollToTop        map[KeySequence]struct{}
+	scrollToBottom     map[KeySequence]struct{}
+	nextChange         map[KeySequence]struct{}
+	previousChange     map[KeySequence]struct{}
+
+	toggleBookmark   map[KeySequence]struct{}
+	nextBookmark     map[KeySequence]struct{}
+	previousBookmark map[KeySequence]struct{}
+	clearBookmarks   map[KeySequence]struct{}
+
+	increaseInterval map[KeySequence]struct{}
+	decreaseInterval map[KeySequence]struct{}
+	togglePause      map[KeySequence]struct{}
+}
+
+// keymapBinding names the keys of an action for reporting.
+type keymapBinding struct {
+	name string
+	keys map[KeySequence]struct{}
+}
+
+// generalBindings are the actions available everywhere.
+func (k *keymapping) generalBindings() []keymapBinding {
+	return []keymapBinding{
+		{name: "keymap.toggle_timemachine", keys: k.toggleTimeMachine},
+		{name: "keymap.quit", keys: k.quit},
+		{name: "keymap.toggle_suspend", keys: k.toggleSuspend},
+		{name: "keymap.toggle_diff", keys: k.toggleDiff},
+		{name: "keymap.reset_diff", keys: k.resetDiff},
+		{name: "keymap.clear_history", keys: k.clearHistory},
+		{name: "keymap.toggle_changes_only", keys: k.toggleChangesOnly},
+		{name: "keymap.toggle_side_by_side", keys: k.toggleSideBySide},
+		{name: "keymap.toggle_wrap", keys: k.toggleWrap},
+		{name: "keymap.toggle_line_age", keys: k.toggleLineAge},
+		{name: "keymap.edit_command", keys: k.editCommand},
+		{name: "keymap.toggle_header", keys: k.toggleHeader},
+		{name: "keymap.toggle_help", keys: k.toggleHelp},
+		{name: "keymap.toggle_log", keys: k.toggleLog},
+		{name: "keymap.toggle_snapshot_list", keys: k.toggleSnapshotList},
+		{name: "keymap.toggle_change_log", keys: k.toggleChangeLog},
+		{name: "keymap.toggle_stderr", keys: k.toggleStderr},
+		{name: "keymap.toggle_raw", keys: k.toggleRaw},
+		{name: "keymap.yank", keys: k.yank},
+		{name: "keymap.yank_visible", keys: k.yankVisible},
+		{name: "keymap.shell", keys: k.shell},
+		{name: "keymap.focus_next_pane", keys: k.focusNextPane},
+		{name: "keymap.search", keys: k.search},
+		{name: "keymap.toggle_search_case", keys: k.toggleSearchCase},
+		{name: "keymap.toggle_search_word", keys: k.toggleSearchWord},
+		{name: "keymap.scroll_up", keys: k.scrollUp},
+		{name: "keymap.scroll_down", keys: k.scrollDown},
+		{name: "keymap.scroll_left", keys: k.scrollLeft},
+		{name: "keymap.scroll_right", keys: k.scrollRight},
+		{name: "keymap.page_up", keys: k.pageUp},
+		{name: "keymap.page_down", keys: k.pageDown},
+		{name: "keymap.scroll_to_top", keys: k.scrollToTop},
+		{name: "keymap.scroll_to_bottom", keys: k.scrollToBottom},
+		{name: "keymap.next_change", keys: k.nextChange},
+		{name: "keymap.previous_change", keys: k.previousChange},
+		{name: "keymap.toggle_bookmark", keys: k.toggleBookmark},
+		{name: "keymap.next_bookmark", keys: k.nextBookmark},
+		{name: "keymap.previous_bookmark", keys: k.previousBookmark},
+		{name: "keymap.clear_bookmarks", keys: k.clearBookmarks},
+		{name: "keymap.increase_interval", keys: k.increaseInterval},
+		{name: "keymap.decrease_interval", keys: k.decreaseInterval},
+		{name: "keymap.toggle_pause", keys: k.togglePause},
+	}
+}
+
+// timeMachineBindings are the actions available in time machine mode.
+// They take precedence over the general ones, so they can reuse their keys.
+func (k *keymapping) timeMachineBindings() []keymapBinding {
+	return []keymapBinding{
+		{name: "keymap.timemachine_go_to_past", keys: k.goToPastOnTimeMachine},
+		{name: "keymap.timemachine_go_to_future", keys: k.goToFutureOnTimeMachine},
+		{name: "keymap.timemachine_go_to_more_past", keys: k.goToMorePastOnTimeMachine},
+		{name: "keymap.timemachine_go_to_more_future", keys: k.goToMoreFutureOnTimeMachine},
+		{name: "keymap.timemachine_go_to_now", keys: k.goToNowOnTimeMachine},
+		{name: "keymap.timemachine_go_to_oldest", keys: k.goToOldestOnTimeMachine},
+		{name: "keymap.timemachine_go_to_time", keys: k.goToTimeOnTimeMachine},
+		{name: "keymap.timemachine_back_duration", keys: k.backDurationOnTimeMachine},
+		{name: "keymap.timemachine_forward_duration", keys: k.forwardDurationOnTimeMachine},
+		{name: "keymap.timemachine_increase_step", keys: k.increaseStepOnTimeMachine},
+		{name: "keymap.timemachine_decrease_step", keys: k.decreaseStepOnTimeMachine},
+		{name: "keymap.timemachine_play", keys: k.playOnTimeMachine},
+		{name: "keymap.timemachine_mark", keys: k.markOnTimeMachine},
+		{name: "keymap.timemachine_compare", keys: k.compareOnTimeMachine},
+		{name: "keymap.timemachine_toggle_poll", keys: k.togglePollOnTimeMachine},
+	}
+}
+
+// findKeymapConflicts reports keys bound to more than one of the actions.
+func findKeymapConflicts(bindings []keymapBinding) []string {
+	owners := map[KeySequence]string{}
+
+	var conflicts []string
+
+	for _, b := range bindings {
+		seqs := make([]KeySequence, 0, len(b.keys))
+		for seq := range b.keys {
+			seqs = append(seqs, seq)
+		}
+
+		sort.Slice(seqs, func(i, j int) bool {
+			return formatKeySequence(seqs[i]) < formatKeySequence(seqs[j])
+		})
+
+		for _, seq := range seqs {
+			if owner, ok := owners[seq]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s is bound to both %s and %s", formatKeySequence(seq), owner, b.name))
+
+				continue
+			}
+
+			owners[seq] = b.name
+		}
+	}
+
+	return conflicts
+}
+
+// essentialKeymaps are the actions which cannot be done otherwise, with what
+// is lost when they are unbound.
+var essentialKeymaps = map[string]string{
+	"keymap.quit": "viddy can only be stopped by a signal",
+}
+
+func findUnboundKeymaps(bindings []keymapBinding) []string {
+	var unbound []string
+
+	for _, b := range bindings {
+		if lost, ok := essentialKeymaps[b.name]; ok && len(b.keys) == 0 {
+			unbound = append(unbound, fmt.Sprintf("%s is unbound, so %s", b.name, lost))
+		}
+	}
+
+	return unbound
+}
+
+// ctrlPunctuation are the characters of the control keys after Ctrl-Z.
+const ctrlPunctuation = `\]^_`
+
+// keyStrokeFromEvent describes the event the same way ParseKeyStroke describes keys.
+func keyStrokeFromEvent(event *tcell.EventKey) KeyStroke {
+	key, r, mod := event.Key(), event.Rune(), event.Modifiers()
+
+	if key != tcell.KeyRune {
+		r = 0
+	}
+
+	// Backspace, Tab and Enter share their codes with Ctrl-H, Ctrl-I and Ctrl-M,
+	// and only tell them apart by the modifier.
+	typeable := mod&tcell.ModCtrl == 0 &&
+		(key == tcell.KeyBackspace || key == tcell.KeyTab || key == tcell.KeyEnter)
+
+	switch {
+	case key == tcell.KeyRune:
+		// The case of the rune already tells whether Shift was held.
+		mod &^= tcell.ModShift
+	case key == tcell.KeyBacktab:
+		return KeyStroke{Key: tcell.KeyTab, ModMask: mod | tcell.ModShift}
+	case key == tcell.KeyBackspace && typeable:
+		// Some terminals send BS rather than DEL for Backspace.
+		key = tcell.KeyBackspace2
+	case typeable:
+	case key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlZ:
+		// tcell reports Ctrl-letter as a control key, ParseKeyStroke as a
+		// modified rune. With Alt held, tcell leaves out ModCtrl.
+		return KeyStroke{Key: tcell.KeyRune, Rune: rune('a' + key - tcell.KeyCtrlA), ModMask: mod | tcell.ModCtrl}
+	case key >= tcell.KeyCtrlBackslash && key <= tcell.KeyCtrlUnderscore:
+		return KeyStroke{Key: tcell.KeyRune, Rune: rune(ctrlPunctuation[key-tcell.KeyCtrlBackslash]), ModMask: mod | tcell.ModCtrl}
+	case key == tcell.KeyNUL:
+		mod |= tcell.ModCtrl
+	}
+
+	return KeyStroke{Key: key, Rune: r, ModMask: mod}
+}
+
+// newFlagSet defines the flags. Everything after the first argument which is
+// not a flag belongs to the command.
+func newFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("", pflag.ExitOnError)
+
+	// runtimeConfig
+	flagSet.StringP("interval", "n", "2s", "seconds to wait between updates")
+	flagSet.String("jitter", "0", "delay runs by a random duration shorter than this")
+	flagSet.BoolP("precise", "p", false, "attempt run command in precise intervals")
+	flagSet.BoolP("clockwork", "c", false, "run command in precise intervals forcibly")
+	flagSet.Bool("adaptive", false, "go back to the interval when the output changes, and double it while it does not")
+	flagSet.String("adaptive-max", "", `longest interval in adaptive mode (default "1m")`)
+	flagSet.String("schedule", "", "run command on a cron schedule instead of at intervals")
+	flagSet.BoolP("help", "h", false, "display this help and exit")
+	flagSet.BoolP("version", "v", false, "output version information and exit")
+	flagSet.Bool("show-config", false, "print the effective configuration and exit")
+	flagSet.String("completion", "", "print the completion script of the shell (bash, zsh or fish) and exit")
+	flagSet.String("chdir", "", "working directory of the command")
+	flagSet.String("config", "", "path of the config file")
+	flagSet.String("profile", "", "use the profile of the config file")
+	flagSet.StringArray("cmd", nil, "watch the command line in a pane of its own")
+	flagSet.BoolP("exec", "x", false, "run the command directly instead of through the shell")
+
+	// general
+	flagSet.StringP("differences", "d", "false",
+		`highlight changes between updates, all changes so far if "permanent", or by "char", "word" or "line"`)
+	flagSet.Lookup("differences").NoOptDefVal = "true"
+	flagSet.String("differences-against", "", "highlight changes since the run N runs ago, or the duration ago such as 5m")
+	flagSet.String("diff-normalize", "", "ignore some differences when looking for changes, such as trim_trailing_space,ignore_case")
+	flagSet.Bool("changes-only", false, "show only the lines which changed since the previous run")
+	flagSet.Bool("side-by-side", false, "show the previous run beside the current one")
+	flagSet.BoolP("no-title", "t", false, "turn off header")
+	flagSet.String("title", "",
+		"show this in the header instead of the command, with placeholders such as {command} and {exit_code}")
+	flagSet.Bool("no-wrap", false, "cut long lines off instead of wrapping them")
+	flagSet.Int("tab-width", defaultTabWidth, "columns between tab stops")
+	flagSet.Bool("debug", false, "")
+	flagSet.String("shell", "", fmt.Sprintf("shell (default %q)", defaultShell))
+	flagSet.String("shell-options", "", "additional shell options")
+	flagSet.Bool("pty", false, "run command in a pseudo-terminal")
+	flagSet.Bool("no-color", false, "strip ANSI escape sequences from the output")
+	flagSet.Bool("no-mouse", false, "turn off mouse support")
+	flagSet.String("theme", "", "color theme preset")
+	flagSet.StringArray("env", nil, "set environment variable of the command (KEY=VALUE, or KEY to unset)")
+	flagSet.String("on-change", "", "run the command through the shell when the output changes")
+	flagSet.String("before-each", "", "run the command through the shell before every run, skipping the run if it fails")
+	flagSet.String("after-each", "", "run the command through the shell after every run")
+	flagSet.String("pipe", "", "show the output of the command through the shell filter, such as 'jq .items'")
+	flagSet.StringArray("trigger", nil, "ring the bell when the regular expression starts matching the output")
+	flagSet.Bool("trigger-exit", false, "exit when a trigger fires")
+	flagSet.StringArray("highlight", nil, "color the matches of the regular expression in every run (REGEX[:color])")
+	flagSet.Bool("exit-code", false, "exit with the exit status of the latest run")
+	flagSet.Bool("once", false, "run the command a single time and show it until quit, or print it with --batch")
+	flagSet.String("log-file", "", "append the output of every run to the file")
+	flagSet.Int("max-lines", 0, "keep only the first lines of the output of every run")
+	flagSet.String("max-bytes", "", "keep only the first bytes of the output of every run, such as 1MB")
+	flagSet.String("keep-for", "", "drop the snapshots older than the duration from the history, such as 2h")
+	flagSet.Bool("flash-on-change", false, "flash the header when the output changes")
+	flagSet.String("notify", "", `send a desktop notification when the output changes, the command fails or "both"`)
+	flagSet.Bool("backoff", false, "double the interval after every consecutive failure")
+	flagSet.String("backoff-max", "", `maximum interval when backing off (default "5m")`)
+	flagSet.String("ssh", "", "run the command on the host over SSH ([user@]host[:port])")
+	flagSet.String("session", "", "save the history to the file and restore it on the next start")
+	flagSet.String("history-file", "", "append every run to the file, for --load to look back at")
+	flagSet.String("load", "", "look back at the runs of a history file or session in the time machine, without running anything")
+	flagSet.Bool("session-force", false, "restore the session even if it was saved for another command")
+	flagSet.String("split", "", `lay out the panes of several commands "horizontal" or "vertical"`)
+	flagSet.Bool("batch", false, "write every run to stdout instead of showing it")
+	flagSet.String("batch-format", "text", `format of --batch, "text" or "json"`)
+	flagSet.String("input", "", "feed the file to the stdin of the command on every run")
+	flagSet.String("file", "", "watch the contents of the file instead of the output of a command")
+	flagSet.Bool("stdin-capture", false, "read the stdin of viddy once and feed it to the command on every run")
+	flagSet.String("listen", "", "serve the latest run and control verbs over HTTP on a unix socket (unix:PATH)")
+
+	flagSet.SetInterspersed(false)
+
+	return flagSet
+}
+
+//nolint:funlen,cyclop
+func newConfig(v *viper.Viper, args []string) (*config, error) {
+	// VIDDY_GENERAL_SHELL overrides general.shell and so on, flags still win.
+	v.SetEnvPrefix("viddy")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	flagSet := newFlagSet()
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var conf config
+
+	conf.runtime.help, _ = flagSet.GetBool("help")
+	conf.runtime.version, _ = flagSet.GetBool("version")
+	conf.runtime.showConfig, _ = flagSet.GetBool("show-config")
+
+	if shell, _ := flagSet.GetString("completion"); shell != "" {
+		if _, ok := completionScripts[shell]; !ok {
+			return &conf, completionShellError{shell: shell}
+		}
+
+		conf.runtime.completion = shell
+	}
+
+	if path, _ := flagSet.GetString("config"); path != "" {
+		v.SetConfigFile(path)
+
+		if err := v.ReadInConfig(); err != nil {
+			return &conf, configFileError{path: path, err: err}
+		}
+	}
+
+	conf.runtime.configFile = v.ConfigFileUsed()
+
+	// Before the profile is merged into general, so that its keys are
+	// reported where they were written.
+	conf.general.strictConfig = v.GetBool("general.strict_config")
+
+	unknown := findUnknownConfigKeys(v)
+	if len(unknown) > 0 && conf.general.strictConfig {
+		return &conf, unknownConfigKeysError(unknown)
+	}
+
+	for _, k := range unknown {
+		conf.warnings = append(conf.warnings, k.String())
+	}
+
+	rest := flagSet.Args()
+	cmdLines, _ := flagSet.GetStringArray("cmd")
+
+	profileName, _ := flagSet.GetString("profile")
+	if profileName == "" && len(rest) > 0 && strings.HasPrefix(rest[0], "@") {
+		profileName = strings.TrimPrefix(rest[0], "@")
+		rest = rest[1:]
+	}
+
+	var prof profile
+
+	if profileName != "" {
+		var err error
+
+		prof, err = getProfile(v, profileName)
+		if err != nil {
+			return &conf, err
+		}
+	}
+
+	rules, err := getRules(v)
+	if err != nil {
+		return &conf, err
+	}
+
+	// The command line is complete once the profile is known.
+	conf.runtime.rule = matchRule(rules, ruleCommandLine(append(prof.command(), rest...), cmdLines))
+	conf.runtime.rules = len(rules)
+
+	// Where the values come from is told in the debug log.
+	sources := newConfigSources(v, flagSet, profileName, prof, conf.runtime.rules, conf.runtime.rule)
+
+	prof = prof.withRule(conf.runtime.rule)
+
+	if len(prof) > 0 {
+		if err := v.MergeConfigMap(map[string]interface{}{"general": prof.general()}); err != nil {
+			return nil, err
+		}
+	}
+
+	v.SetDefault("general.time_zone", "Local")
+
+	conf.general.timeFormat = v.GetString("general.time_format")
+	conf.general.timeZone = v.GetString("general.time_zone")
+
+	conf.runtime.times, err = parseTimeFormat(conf.general.timeFormat, conf.general.timeZone)
+	if err != nil {
+		return &conf, err
+	}
+
+	intervalStr, _ := flagSet.GetString("interval")
+	if value, ok := prof["interval"]; ok && !flagSet.Changed("interval") {
+		intervalStr = cast.ToString(value)
+	}
+
+	steps, err := parseIntervalSteps("interval", intervalStr)
+	if err != nil {
+		return &conf, err
+	}
+
+	if steps != nil {
+		conf.runtime.interval, conf.runtime.steps = steps[0].interval, steps
+	} else if conf.runtime.interval, err = parseInterval("interval", intervalStr); err != nil {
+		return &conf, err
+	}
+
+	jitterStr, _ := flagSet.GetString("jitter")
+	if value, ok := prof["jitter"]; ok && !flagSet.Changed("jitter") {
+		jitterStr = cast.ToString(value)
+	}
+
+	conf.runtime.jitter, err = parseInterval("jitter", jitterStr)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.runtime.mode = ViddyIntervalModeSequential
+	if prof.flag(flagSet, "precise") {
+		conf.runtime.mode = ViddyIntervalModePrecise
+	}
+
+	if prof.flag(flagSet, "clockwork") {
+		conf.runtime.mode = ViddyIntervalModeClockwork
+	}
+
+	if prof.flag(flagSet, "adaptive") {
+		if steps != nil {
+			return &conf, errAdaptiveSteps
+		}
+
+		if conf.runtime.mode != ViddyIntervalModeSequential {
+			return &conf, errAdaptiveMode
+		}
+
+		conf.runtime.mode = ViddyIntervalModeAdaptive
+	}
+
+	scheduleExpr, _ := flagSet.GetString("schedule")
+	if value, ok := prof["schedule"]; ok && !flagSet.Changed("schedule") && !flagSet.Changed("interval") {
+		scheduleExpr = cast.ToString(value)
+	}
+
+	if flagSet.Changed("schedule") && flagSet.Changed("interval") {
+		return &conf, errScheduleInterval
+	}
+
+	if scheduleExpr != "" {
+		conf.runtime.schedule, err = parseSchedule(scheduleExpr)
+		if err != nil {
+			return &conf, err
+		}
+
+		if conf.runtime.mode == ViddyIntervalModeAdaptive {
+			return &conf, errAdaptiveMode
+		}
+
+		conf.runtime.mode = ViddyIntervalModeSchedule
+	}
+
+	// A single run needs no interval, whatever the mode would be.
+	if once, _ := flagSet.GetBool("once"); once {
+		conf.runtime.mode = ViddyIntervalModeOnce
+	}
+
+	if err := v.BindPFlag("general.debug", flagSet.Lookup("debug")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.shell", flagSet.Lookup("shell")); err != nil {
+		return nil, err
+	}
+
+	v.SetDefault("general.shell", defaultShell)
+
+	if err := v.BindPFlag("general.shell_options", flagSet.Lookup("shell-options")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.differences", flagSet.Lookup("differences")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.no_title", flagSet.Lookup("no-title")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.title_template", flagSet.Lookup("title")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.pty", flagSet.Lookup("pty")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.differences_against", flagSet.Lookup("differences-against")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.strip_ansi", flagSet.Lookup("no-color")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.on_change", flagSet.Lookup("on-change")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.notify", flagSet.Lookup("notify")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.before_each", flagSet.Lookup("before-each")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.after_each", flagSet.Lookup("after-each")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.pipe", flagSet.Lookup("pipe")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.log_file", flagSet.Lookup("log-file")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.max_lines", flagSet.Lookup("max-lines")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.max_bytes", flagSet.Lookup("max-bytes")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.keep_for", flagSet.Lookup("keep-for")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.flash_on_change", flagSet.Lookup("flash-on-change")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.changes_only", flagSet.Lookup("changes-only")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.side_by_side", flagSet.Lookup("side-by-side")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.no_wrap", flagSet.Lookup("no-wrap")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.tab_width", flagSet.Lookup("tab-width")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.backoff", flagSet.Lookup("backoff")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.backoff_max", flagSet.Lookup("backoff-max")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.session_file", flagSet.Lookup("session")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.history_file", flagSet.Lookup("history-file")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.ssh", flagSet.Lookup("ssh")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.split", flagSet.Lookup("split")); err != nil {
+		return nil, err
+	}
+
+	if err := v.BindPFlag("general.diff_normalize", flagSet.Lookup("diff-normalize")); err != nil {
+		return nil, err
+	}
+
+	conf.general.debug = v.GetBool("general.debug")
+
+	v.SetDefault("general.log", defaultDebugLogPath())
+	v.SetDefault("general.log_level", levelDebug.String())
+	conf.general.log = v.GetString("general.log")
+
+	conf.general.logLevel, err = parseLogLevel(v.GetString("general.log_level"))
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.shell = v.GetString("general.shell")
+	diffStr, _ := flagSet.GetString("differences")
+	if value, ok := prof["differences"]; ok && !flagSet.Changed("differences") {
+		diffStr = cast.ToString(value)
+	}
+
+	var granularity DiffGranularity
+
+	conf.general.differences, conf.general.permanentDiff, granularity, err = parseDifferences(diffStr)
+	if err != nil {
+		return &conf, err
+	}
+
+	v.SetDefault("general.diff_granularity", string(DiffGranularityChar))
+
+	g, ok := parseDiffGranularity(v.GetString("general.diff_granularity"))
+	if !ok {
+		return &conf, errDiffGranularity
+	}
+
+	conf.general.diffGranularity = g
+
+	// -d=word and the like win over the config.
+	if granularity != "" {
+		conf.general.diffGranularity = granularity
+	}
+
+	v.SetDefault("general.differences_against", "1")
+
+	conf.general.differencesAgainst, err = parseDiffOffset(v.GetString("general.differences_against"))
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.diffNormalize, err = parseDiffNormalize(v.Get("general.diff_normalize"))
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.noTitle = prof.flag(flagSet, "no-title")
+
+	conf.general.titleTemplateText = v.GetString("general.title_template")
+
+	conf.general.titleTemplate, err = parseTitleTemplate(conf.general.titleTemplateText)
+	if err != nil {
+		return &conf, err
+	}
+
+	v.SetDefault("general.show_host", true)
+	conf.general.showHost = v.GetBool("general.show_host")
+	conf.general.maxConcurrentRuns = v.GetInt("general.max_concurrent_runs")
+	conf.general.pty = v.GetBool("general.pty")
+	conf.general.stripANSI = v.GetBool("general.strip_ansi")
+
+	conf.general.forceTruecolor = v.GetBool("general.force_truecolor")
+	conf.general.showSnapshotList = v.GetBool("general.show_snapshot_list")
+	conf.general.showChangeLog = v.GetBool("general.show_change_log")
+
+	v.SetDefault("general.show_timeline", true)
+	conf.general.showTimeline = v.GetBool("general.show_timeline")
+
+	v.SetDefault("general.poll_while_suspended", true)
+	conf.general.pollWhileSuspended = v.GetBool("general.poll_while_suspended")
+
+	v.SetDefault("general.poll_in_timemachine", true)
+	conf.general.pollInTimeMachine = v.GetBool("general.poll_in_timemachine")
+
+	v.SetDefault("general.confirm_destructive", true)
+	conf.general.confirmDestructive = v.GetBool("general.confirm_destructive")
+
+	conf.general.onChange = v.GetString("general.on_change")
+	conf.general.beforeEach = v.GetString("general.before_each")
+	conf.general.afterEach = v.GetString("general.after_each")
+	conf.general.pipe = v.GetString("general.pipe")
+	conf.general.logFile = v.GetString("general.log_file")
+	conf.general.sessionFile = v.GetString("general.session_file")
+	conf.general.historyFile = v.GetString("general.history_file")
+	conf.general.ssh = v.GetString("general.ssh")
+	conf.runtime.sessionForce, _ = flagSet.GetBool("session-force")
+
+	v.SetDefault("general.mouse", true)
+	conf.general.mouse = v.GetBool("general.mouse")
+
+	if ok, _ := flagSet.GetBool("no-mouse"); ok {
+		conf.general.mouse = false
+	}
+
+	if conf.general.pty && runtime.GOOS == "windows" {
+		return &conf, errPtyNotSupported
+	}
+
+	v.SetDefault("general.overlap_policy", string(OverlapPolicySkip))
+	conf.general.overlapPolicy = OverlapPolicy(v.GetString("general.overlap_policy"))
+
+	switch conf.general.overlapPolicy {
+	case OverlapPolicySkip, OverlapPolicyWait, OverlapPolicyKill:
+	default:
+		return &conf, errOverlapPolicy
+	}
+
+	conf.general.stderr, err = parseStderrMode(v)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.split, err = parseSplitLayout(v)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.statusItems, err = parseStatusItemsConfig(v)
+	if err != nil {
+		return &conf, err
+	}
+
+	v.SetDefault("general.timemachine_step", "1m")
+
+	conf.general.timeMachineStep, err = parseGeneralDuration(v, "timemachine_step", false)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.changesOnly = v.GetBool("general.changes_only")
+	conf.general.sideBySide = v.GetBool("general.side_by_side")
+	conf.general.noWrap = v.GetBool("general.no_wrap")
+	conf.general.showLineAge = v.GetBool("general.show_line_age")
+
+	v.SetDefault("general.sticky_scroll", true)
+	conf.general.stickyScroll = v.GetBool("general.sticky_scroll")
+	conf.general.scrollOff = v.GetInt("general.scroll_off")
+	conf.general.searchIgnoreCase = v.GetBool("general.search_ignore_case")
+
+	if conf.general.scrollOff < 0 {
+		return &conf, errScrollOff
+	}
+
+	conf.general.changesContext = v.GetInt("general.changes_context")
+	if conf.general.changesContext < 0 {
+		return &conf, errChangesContext
+	}
+
+	v.SetDefault("general.compress_after", 1000)
+
+	conf.general.compressAfter = v.GetInt("general.compress_after")
+	if conf.general.compressAfter < 0 {
+		return &conf, errCompressAfter
+	}
+
+	conf.general.tabWidth = v.GetInt("general.tab_width")
+	if conf.general.tabWidth < 1 {
+		return &conf, errTabWidth
+	}
+
+	conf.general.backoff = v.GetBool("general.backoff")
+	if conf.general.backoff && conf.runtime.mode == ViddyIntervalModeSchedule {
+		return &conf, errScheduleBackoff
+	}
+
+	v.SetDefault("general.backoff_max", "5m")
+
+	conf.general.backoffMax, err = parseGeneralDuration(v, "backoff_max", false)
+	if err != nil {
+		return &conf, err
+	}
+
+	if err := v.BindPFlag("general.adaptive_max", flagSet.Lookup("adaptive-max")); err != nil {
+		return nil, err
+	}
+
+	conf.general.adaptiveMax, conf.general.adaptiveSteadyRuns, err = parseAdaptive(v, conf.runtime.interval,
+		conf.runtime.mode == ViddyIntervalModeAdaptive)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.flashOnChange = v.GetBool("general.flash_on_change")
+
+	v.SetDefault("general.flash_duration", "500ms")
+
+	conf.general.flashDuration, err = parseGeneralDuration(v, "flash_duration", false)
+	if err != nil {
+		return &conf, err
+	}
+
+	v.SetDefault("general.kill_timeout", "2s")
+
+	conf.general.killTimeout, err = parseGeneralDuration(v, "kill_timeout", true)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.streamGrace, conf.general.streamPolicy, err = parseStream(v)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.notify, conf.general.notifyCooldown, err = parseNotify(v)
+	if err != nil {
+		return &conf, err
+	}
+
+	v.SetDefault("general.playback_speed", "4")
+
+	conf.general.playbackSpeed, err = parsePlaybackSpeed(v.GetString("general.playback_speed"))
+	if err != nil {
+		return &conf, err
+	}
+
+	v.SetDefault("general.scrub_acceleration", defaultScrubAcceleration)
+
+	conf.general.scrubAcceleration, err = parseScrubAcceleration(v.Get("general.scrub_acceleration"))
+	if err != nil {
+		return &conf, err
+	}
+
+	if err := v.BindPFlag("color.preset", flagSet.Lookup("theme")); err != nil {
+		return nil, err
+	}
+
+	if err := applyThemePreset(v, v.GetString("color.preset")); err != nil {
+		return &conf, err
+	}
+
+	colors := colorReader{v: v}
+
+	conf.theme.Theme = tview.Theme{
+		PrimitiveBackgroundColor:    colors.get("color.background", tcell.ColorDefault),
+		ContrastBackgroundColor:     colors.get("color.contrast_background", tcell.ColorDefault),
+		MoreContrastBackgroundColor: colors.get("color.more_contrast_background", tcell.ColorDefault),
+		BorderColor:                 colors.get("color.border", tcell.ColorDefault),
+		TitleColor:                  colors.get("color.title", tcell.ColorDefault),
+		GraphicsColor:               colors.get("color.graphics", tcell.ColorDefault),
+		PrimaryTextColor:            colors.get("color.text", tcell.ColorDefault),
+		SecondaryTextColor:          colors.get("color.secondary_text", tcell.ColorDefault),
+		TertiaryTextColor:           colors.get("color.tertiary_text", tcell.ColorDefault),
+		InverseTextColor:            colors.get("color.inverse_text", tcell.ColorDefault),
+		ContrastSecondaryTextColor:  colors.get("color.contrast_secondary_text", tcell.ColorDefault),
+	}
+	conf.theme.diffMoved = colors.get("color.diff_moved", tcell.ColorDefault)
+	conf.theme.diffAdded = colors.get("color.diff_added", tcell.ColorGreen)
+	conf.theme.diffRemoved = colors.get("color.diff_removed", tcell.ColorDefault)
+	conf.theme.diffChangedBackground = colors.get("color.diff_changed_background", tcell.ColorGreen)
+	conf.theme.diffChangedForeground = colors.get("color.diff_changed_foreground", tcell.ColorDefault)
+	conf.theme.clipMarker = colors.get("color.clip_marker", tcell.ColorDefault)
+	conf.theme.stderrText = colors.get("color.stderr_text", tcell.ColorRed)
+	conf.theme.errorText = colors.get("color.error_text", tcell.ColorOrangeRed)
+	conf.theme.flash = colors.get("color.flash", tcell.ColorYellow)
+	conf.warnings = append(conf.warnings, colors.warnings...)
+	conf.fallbacks = colors.fallbacks
+
+	keymaps := keymapReader{v: v, trace: conf.general.debug}
+
+	conf.keymap.toggleTimeMachine = keymaps.get("keymap.toggle_timemachine",
+		map[KeySequence]struct{}{mustParseKeymap("Space"): {}})
+	conf.keymap.goToPastOnTimeMachine = keymaps.get("keymap.timemachine_go_to_past",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-J"): {}})
+	conf.keymap.goToFutureOnTimeMachine = keymaps.get("keymap.timemachine_go_to_future",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-K"): {}})
+	conf.keymap.goToMorePastOnTimeMachine = keymaps.get("keymap.timemachine_go_to_more_past",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-F"): {}})
+	conf.keymap.goToMoreFutureOnTimeMachine = keymaps.get("keymap.timemachine_go_to_more_future",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-B"): {}})
+	conf.keymap.goToNowOnTimeMachine = keymaps.get("keymap.timemachine_go_to_now",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-N"): {}})
+	conf.keymap.goToOldestOnTimeMachine = keymaps.get("keymap.timemachine_go_to_oldest",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-O"): {}})
+	conf.keymap.goToTimeOnTimeMachine = keymaps.get("keymap.timemachine_go_to_time",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-T"): {}})
+	conf.keymap.backDurationOnTimeMachine = keymaps.get("keymap.timemachine_back_duration",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-H"): {}})
+	conf.keymap.forwardDurationOnTimeMachine = keymaps.get("keymap.timemachine_forward_duration",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-L"): {}})
+	conf.keymap.increaseStepOnTimeMachine = keymaps.get("keymap.timemachine_increase_step",
+		map[KeySequence]struct{}{mustParseKeymap("+"): {}})
+	conf.keymap.decreaseStepOnTimeMachine = keymaps.get("keymap.timemachine_decrease_step",
+		map[KeySequence]struct{}{mustParseKeymap("-"): {}})
+	conf.keymap.playOnTimeMachine = keymaps.get("keymap.timemachine_play",
+		map[KeySequence]struct{}{mustParseKeymap("p"): {}})
+	conf.keymap.markOnTimeMachine = keymaps.get("keymap.timemachine_mark",
+		map[KeySequence]struct{}{mustParseKeymap("a"): {}})
+	conf.keymap.compareOnTimeMachine = keymaps.get("keymap.timemachine_compare",
+		map[KeySequence]struct{}{mustParseKeymap("c"): {}})
+	conf.keymap.togglePollOnTimeMachine = keymaps.get("keymap.timemachine_toggle_poll",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-P"): {}})
+
+	conf.keymap.quit = keymaps.get("keymap.quit",
+		map[KeySequence]struct{}{mustParseKeymap("Ctrl-C"): {}})
+	conf.keymap.toggleSuspend = keymaps.get("keymap.toggle_suspend",
+		map[KeySequence]struct{}{mustParseKeymap("s"): {}})
+	conf.keymap.toggleDiff = keymaps.get("keymap.toggle_diff",
+		map[KeySequence]struct{}{mustParseKeymap("d"): {}})
+	conf.keymap.resetDiff = keymaps.get("keymap.reset_diff",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-D"): {}})
+	conf.keymap.clearHistory = keymaps.get("keymap.clear_history",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-X"): {}})
+	conf.keymap.toggleChangesOnly = keymaps.get("keymap.toggle_changes_only",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-C"): {}})
+	conf.keymap.toggleSideBySide = keymaps.get("keymap.toggle_side_by_side",
+		map[KeySequence]struct{}{mustParseKeymap("v"): {}})
+	conf.keymap.toggleWrap = keymaps.get("keymap.toggle_wrap",
+		map[KeySequence]struct{}{mustParseKeymap("w"): {}})
+	conf.keymap.toggleLineAge = keymaps.get("keymap.toggle_line_age",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-A"): {}})
+	conf.keymap.editCommand = keymaps.get("keymap.edit_command",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-E"): {}})
+	conf.keymap.toggleHeader = keymaps.get("keymap.toggle_header",
+		map[KeySequence]struct{}{mustParseKeymap("t"): {}})
+	conf.keymap.toggleHelp = keymaps.get("keymap.toggle_help",
+		map[KeySequence]struct{}{mustParseKeymap("?"): {}})
+	conf.keymap.toggleLog = keymaps.get("keymap.toggle_log",
+		map[KeySequence]struct{}{mustParseKeymap("x"): {}})
+	conf.keymap.toggleSnapshotList = keymaps.get("keymap.toggle_snapshot_list",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-S"): {}})
+	conf.keymap.toggleChangeLog = keymaps.get("keymap.toggle_change_log",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-W"): {}})
+	conf.keymap.toggleStderr = keymaps.get("keymap.toggle_stderr",
+		map[KeySequence]struct{}{mustParseKeymap("e"): {}})
+	conf.keymap.toggleRaw = keymaps.get("keymap.toggle_raw",
+		map[KeySequence]struct{}{mustParseKeymap("r"): {}})
+	conf.keymap.yank = keymaps.get("keymap.yank",
+		map[KeySequence]struct{}{mustParseKeymap("y"): {}})
+	conf.keymap.yankVisible = keymaps.get("keymap.yank_visible",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-Y"): {}})
+	conf.keymap.shell = keymaps.get("keymap.shell",
+		map[KeySequence]struct{}{mustParseKeymap("!"): {}})
+	conf.keymap.focusNextPane = keymaps.get("keymap.focus_next_pane",
+		map[KeySequence]struct{}{mustParseKeymap("Tab"): {}})
+	conf.keymap.search = keymaps.get("keymap.search",
+		map[KeySequence]struct{}{mustParseKeymap("/"): {}})
+	conf.keymap.toggleSearchCase = keymaps.get("keymap.toggle_search_case",
+		map[KeySequence]struct{}{mustParseKeymap("Alt-C"): {}})
+	conf.keymap.toggleSearchWord = keymaps.get("keymap.toggle_search_word",
+		map[KeySequence]struct{}{mustParseKeymap("Alt-W"): {}})
+	conf.keymap.scrollUp = keymaps.get("keymap.scroll_up",
+		map[KeySequence]struct{}{mustParseKeymap("k"): {}, mustParseKeymap("Up"): {}})
+	conf.keymap.scrollDown = keymaps.get("keymap.scroll_down",
+		map[KeySequence]struct{}{mustParseKeymap("j"): {}, mustParseKeymap("Down"): {}})
+	conf.keymap.scrollLeft = keymaps.get("keymap.scroll_left",
+		map[KeySequence]struct{}{mustParseKeymap("h"): {}, mustParseKeymap("Left"): {}})
+	conf.keymap.scrollRight = keymaps.get("keymap.scroll_right",
+		map[KeySequence]struct{}{mustParseKeymap("l"): {}, mustParseKeymap("Right"): {}})
+	conf.keymap.pageUp = keymaps.get("keymap.page_up",
+		map[KeySequence]struct{}{mustParseKeymap("Ctrl-B"): {}, mustParseKeymap("PgUp"): {}})
+	conf.keymap.pageDown = keymaps.get("keymap.page_down",
+		map[KeySequence]struct{}{mustParseKeymap("Ctrl-F"): {}, mustParseKeymap("PgDn"): {}})
+	conf.keymap.scrollToTop = keymaps.get("keymap.scroll_to_top",
+		map[KeySequence]struct{}{mustParseKeymap("g"): {}, mustParseKeymap("Home"): {}})
+	conf.keymap.scrollToBottom = keymaps.get("keymap.scroll_to_bottom",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-G"): {}, mustParseKeymap("End"): {}})
+	conf.keymap.nextChange = keymaps.get("keymap.next_change",
+		map[KeySequence]struct{}{mustParseKeymap("Ctrl-N"): {}})
+	conf.keymap.previousChange = keymaps.get("keymap.previous_change",
+		map[KeySequence]struct{}{mustParseKeymap("Ctrl-P"): {}})
+
+	conf.keymap.toggleBookmark = keymaps.get("keymap.toggle_bookmark",
+		map[KeySequence]struct{}{mustParseKeymap("m"): {}})
+	conf.keymap.nextBookmark = keymaps.get("keymap.next_bookmark",
+		map[KeySequence]struct{}{mustParseKeymap("]"): {}})
+	conf.keymap.previousBookmark = keymaps.get("keymap.previous_bookmark",
+		map[KeySequence]struct{}{mustParseKeymap("["): {}})
+	conf.keymap.clearBookmarks = keymaps.get("keymap.clear_bookmarks",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-M"): {}})
+
+	// The time machine keeps these keys to change its step.
+	conf.keymap.increaseInterval = keymaps.get("keymap.increase_interval",
+		map[KeySequence]struct{}{mustParseKeymap("+"): {}})
+	conf.keymap.decreaseInterval = keymaps.get("keymap.decrease_interval",
+		map[KeySequence]struct{}{mustParseKeymap("-"): {}})
+	conf.keymap.togglePause = keymaps.get("keymap.toggle_pause",
+		map[KeySequence]struct{}{mustParseKeymap("Shift-Z"): {}})
+
+	conf.warnings = append(conf.warnings, findKeymapConflicts(conf.keymap.generalBindings())...)
+	conf.warnings = append(conf.warnings, findKeymapConflicts(conf.keymap.timeMachineBindings())...)
+	conf.warnings = append(conf.warnings, findUnboundKeymaps(conf.keymap.generalBindings())...)
+
+	for _, ke := range keymaps.errs {
+		conf.addFallback(ke.key, ke.err.Error())
+	}
+
+	// The config is shown with the defaults used in place of the keymaps.
+	if len(keymaps.errs) > 0 && !conf.runtime.showConfig {
+		return &conf, keymaps.errs
+	}
+
+	conf.general.shellOptions, err = splitShellWords(v.GetString("general.shell_options"))
+	if err != nil {
+		return &conf, err
+	}
+
+	envArgs, _ := flagSet.GetStringArray("env")
+
+	conf.general.env, err = getEnv(v, "general.env", envArgs)
+	if err != nil {
+		return &conf, err
+	}
+
+	triggerArgs, _ := flagSet.GetStringArray("trigger")
+
+	conf.general.triggers, err = compileTriggers(triggerArgs)
+	if err != nil {
+		return &conf, err
+	}
+
+	highlightArgs, _ := flagSet.GetStringArray("highlight")
+
+	conf.general.highlights, err = getHighlights(v, "general.highlight", highlightArgs)
+	if err != nil {
+		return &conf, err
+	}
+
+	conf.general.triggerExit, _ = flagSet.GetBool("trigger-exit")
+	conf.general.exitCode, _ = flagSet.GetBool("exit-code")
+	conf.runtime.batch, _ = flagSet.GetBool("batch")
+
+	switch format, _ := flagSet.GetString("batch-format"); format {
+	case "text":
+	case "json":
+		conf.runtime.batchJSON = true
+	default:
+		return &conf, errBatchFormat
+	}
+
+	conf.runtime.input, _ = flagSet.GetString("input")
+	conf.runtime.stdinCapture, _ = flagSet.GetBool("stdin-capture")
+
+	if conf.runtime.input != "" && conf.runtime.stdinCapture {
+		return &conf, errInputCapture
+	}
+
+	// The command reads the terminal in a pty.
+	if conf.general.pty && (conf.runtime.input != "" || conf.runtime.stdinCapture) {
+		return &conf, errInputPty
+	}
+
+	conf.runtime.file, _ = flagSet.GetString("file")
+	conf.runtime.exec, _ = flagSet.GetBool("exec")
+	conf.runtime.load, _ = flagSet.GetString("load")
+
+	if listen, _ := flagSet.GetString("listen"); listen != "" {
+		conf.runtime.listen = strings.TrimPrefix(listen, "unix:")
+		if conf.runtime.listen == listen || conf.runtime.listen == "" {
+			return &conf, errListen
+		}
+	}
+
+	if size := v.GetString("general.log_max_size"); size != "" {
+		conf.general.logMaxSize, err = parseSize(size)
+		if err != nil {
+			return &conf, sizeError{key: "general.log_max_size", value: size}
+		}
+	}
+
+	conf.general.maxLines = v.GetInt("general.max_lines")
+	if conf.general.maxLines < 0 {
+		return &conf, errMaxLines
+	}
+
+	if size := v.GetString("general.max_bytes"); size != "" {
+		conf.general.maxBytes, err = parseSize(size)
+		if err != nil {
+			return &conf, sizeError{key: "general.max_bytes", value: size}
+		}
+	}
+
+	if keepFor := v.GetString("general.keep_for"); keepFor != "" {
+		d, err := parseInterval("keep_for", keepFor)
+		if err != nil {
+			return &conf, err
+		}
+
+		if d < 0 {
+			return &conf, durationError{key: "keep_for", value: keepFor, reason: "must not be negative"}
+		}
+
+		conf.general.keepFor = d
+	}
+
+	dir, _ := flagSet.GetString("chdir")
+	if value, ok := prof["chdir"]; ok && !flagSet.Changed("chdir") {
+		dir = cast.ToString(value)
+	}
+
+	// The directory of a remote command is on the host.
+	if dir != "" && conf.general.ssh != "" {
+		conf.runtime.chdir = dir
+	} else if dir != "" {
+		chdir, err := resolveChdir(dir)
+		if err != nil {
+			return &conf, err
+		}
+
+		conf.runtime.chdir = chdir
+	}
+
+	if conf.general.ssh != "" {
+		conf.runtime.remote, err = newRemoteHost(conf.general.ssh)
+		if err != nil {
+			return &conf, err
+		}
+	}
+
+	shortest := shortestInterval(conf.runtime.steps, conf.runtime.interval)
+
+	if shortest < minInterval {
+		return &conf, durationError{
+			key: "interval", value: intervalStr, reason: fmt.Sprintf("is less than the minimum of %s", minInterval),
+		}
+	}
+
+	if conf.runtime.jitter < 0 {
+		return &conf, durationError{key: "jitter", value: jitterStr, reason: "must not be negative"}
+	}
+
+	// A schedule has no interval to stay within.
+	if conf.runtime.mode != ViddyIntervalModeSchedule && conf.runtime.jitter >= shortest {
+		reason := fmt.Sprintf("must be shorter than the interval of %s", shortest)
+
+		return &conf, durationError{key: "jitter", value: jitterStr, reason: reason}
+	}
+
+	// Arguments after the profile are appended to its command.
+	if command := prof.command(); len(command) > 0 {
+		rest = append(command, rest...)
+	}
+
+	commands, err := splitCommands(rest)
+	if err != nil {
+		return &conf, err
+	}
+
+	for _, line := range cmdLines {
+		if strings.TrimSpace(line) == "" {
+			return &conf, errEmptyCommand
+		}
+
+		commands = append(commands, commandSpec{cmd: line})
+	}
+
+	if conf.runtime.file != "" {
+		switch {
+		case len(commands) > 0:
+			return &conf, errFileCommand
+		case conf.runtime.remote != nil:
+			return &conf, errFileRemote
+		}
+
+		// The file stands for the command, which is what the header shows.
+		commands = []commandSpec{{cmd: conf.runtime.file, args: []string{}}}
+	}
+
+	if conf.runtime.exec && conf.runtime.remote != nil {
+		return &conf, errExecRemote
+	}
+
+	if conf.runtime.load != "" {
+		switch {
+		case len(commands) > 0:
+			return &conf, errLoadCommand
+		case conf.runtime.batch:
+			return &conf, errLoadBatch
+		case conf.general.sessionFile != "":
+			return &conf, errLoadSession
+		}
+
+		// The command of the file takes its place once it is read.
+		commands = []commandSpec{{cmd: conf.runtime.load, args: []string{}}}
+	}
+
+	if len(commands) == 0 && !conf.runtime.showConfig {
+		return &conf, errNoCommand
+	}
+
+	if len(commands) > 1 && conf.general.sessionFile != "" {
+		return &conf, errSessionCommands
+	}
+
+	if len(commands) > 1 && conf.general.logFile != "" {
+		return &conf, errLogFileCommands
+	}
+
+	if len(commands) > 1 && conf.general.historyFile != "" {
+		return &conf, errHistoryCommands
+	}
+
+	if len(commands) > 1 && conf.runtime.batch {
+		return &conf, errBatchCommands
+	}
+
+	if len(commands) > 1 && conf.runtime.listen != "" {
+		return &conf, errListenCommands
+	}
+
+	conf.runtime.commands = commands
+
+	if conf.general.debug {
+		conf.runtime.startupLog = append(sources.records(v), keymaps.records...)
+	}
+
+	return &conf, nil
+}
+
+// commandSeparator separates the commands of the panes on the command line.
+const commandSeparator = "---"
+
+// splitCommands splits the arguments into a command for each pane.
+func splitCommands(args []string) ([]commandSpec, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	var (
+		commands []commandSpec
+		start    int
+	)
+
+	for i := 0; i <= len(args); i++ {
+		if i < len(args) && args[i] != commandSeparator {
+			continue
+		}
+
+		if i == start {
+			return nil, errEmptyCommand
+		}
+
+		commands = append(commands, commandSpec{cmd: args[start], args: args[start+1 : i : i]})
+		start = i + 1
+	}
+
+	return commands, nil
+}
+
+// profile is a [profiles.<name>] section of the config file. Besides the
+// command it takes the flags and the keys of the general section.
+type profile map[string]interface{}
+
+// profileKeys are the keys of a profile which are not general keys.
+var profileKeys = map[string]struct{}{
+	"command":     {},
+	"interval":    {},
+	"jitter":      {},
+	"precise":     {},
+	"adaptive":    {},
+	"clockwork":   {},
+	"schedule":    {},
+	"chdir":       {},
+	"differences": {},
+	"no_title":    {},
+}
+
+type profileError struct {
+	name      string
+	available []string
+}
+
+func (e profileError) Error() string {
+	if len(e.available) == 0 {
+		return fmt.Sprintf("unknown profile %q, the config file defines no profiles", e.name)
+	}
+
+	return fmt.Sprintf("unknown profile %q, available profiles are %s", e.name, strings.Join(e.available, ", "))
+}
+
+func getProfile(v *viper.Viper, name string) (profile, error) {
+	profiles := v.GetStringMap("profiles")
+
+	// viper lower cases the keys.
+	if p, ok := profiles[strings.ToLower(name)].(map[string]interface{}); ok {
+		return p, nil
+	}
+
+	available := make([]string, 0, len(profiles))
+	for n := range profiles {
+		available = append(available, n)
+	}
+
+	sort.Strings(available)
+
+	return nil, profileError{name: name, available: available}
+}
+
+// general returns the keys of the general section set by the profile.
+func (p profile) general() map[string]interface{} {
+	general := map[string]interface{}{}
+
+	for key, value := range p {
+		if _, ok := profileKeys[key]; !ok {
+			general[key] = value
+		}
+	}
+
+	return general
+}
+
+// flag returns the boolean flag, falling back to the profile unless it was passed.
+func (p profile) flag(flagSet *pflag.FlagSet, name string) bool {
+	if value, ok := p[strings.ReplaceAll(name, "-", "_")]; ok && !flagSet.Changed(name) {
+		return cast.ToBool(value)
+	}
+
+	b, _ := flagSet.GetBool(name)
+
+	return b
+}
+
+// command returns the command of the profile, either a string or a list of words.
+func (p profile) command() []string {
+	switch value := p["command"].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{value}
+	default:
+		return cast.ToStringSlice(value)
+	}
+}
+
+type sizeError struct {
+	key   string
+	value string
+}
+
+func (e sizeError) Error() string {
+	return fmt.Sprintf(`%s: cannot parse size %q, use bytes or a unit such as "500KB", "10MB" or "1GB"`, e.key, e.value)
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
+
+// parseSize parses a number of bytes with an optional binary unit, such as "10MB".
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	unit := int64(1)
+
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.bytes
+
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 0 {
+		return 0, fmt.Errorf("negative size %q", s)
+	}
+
+	return int64(n * float64(unit)), nil
 }
 
-type runtimeConfig struct {
-	cmd      string
-	args     []string
-	interval time.Duration
-	mode     ViddyIntervalMode
-	help     bool
-	version  bool
+// parseDifferences parses the value of --differences into whether to show
+// the diff, and whether to accumulate it. A granularity shows the diff at
+// it, and is returned too.
+func parseDifferences(value string) (bool, bool, DiffGranularity, error) {
+	switch strings.ToLower(value) {
+	case "permanent":
+		return true, true, "", nil
+	case "", "false":
+		return false, false, "", nil
+	case "true":
+		return true, false, "", nil
+	}
+
+	if g, ok := parseDiffGranularity(value); ok {
+		return true, false, g, nil
+	}
+
+	return false, false, "", errDifferences
 }
 
-type general struct {
-	shell        string
-	shellOptions string
-	debug        bool
-	differences  bool
-	noTitle      bool
+// minInterval is the shortest interval between runs.
+const minInterval = 10 * time.Millisecond
+
+type durationError struct {
+	key    string
+	value  string
+	reason string
 }
 
-type theme struct {
-	tview.Theme
+func (e durationError) Error() string {
+	if e.reason == "" {
+		return fmt.Sprintf(`%s: cannot parse %q, use seconds such as "2" or "0.5", or a duration such as "500ms" or "1m30s"`,
+			e.key, e.value)
+	}
+
+	return fmt.Sprintf("%s: %q %s", e.key, e.value, e.reason)
 }
 
-type KeyStroke struct {
-	Key     tcell.Key
-	Rune    rune
-	ModMask tcell.ModMask
+// parseInterval parses the value of the key as seconds or as a duration.
+func parseInterval(key, intervalStr string) (time.Duration, error) {
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil {
+		intervalFloat, err := strconv.ParseFloat(intervalStr, 64)
+		if err != nil || math.IsNaN(intervalFloat) || math.IsInf(intervalFloat, 0) ||
+			math.Abs(intervalFloat) > float64(math.MaxInt64/int64(time.Second)) {
+			return 0, durationError{key: key, value: intervalStr}
+		}
+
+		interval = time.Duration(intervalFloat * float64(time.Second))
+	}
+
+	return interval, nil
 }
 
-type keymapping struct {
-	toggleTimeMachine           map[KeyStroke]struct{}
-	goToPastOnTimeMachine       map[KeyStroke]struct{}
-	goToFutureOnTimeMachine     map[KeyStroke]struct{}
-	goToMorePastOnTimeMachine   map[KeyStroke]struct{}
-	goToMoreFutureOnTimeMachine map[KeyStroke]struct{}
-	goToNowOnTimeMachine        map[KeyStroke]struct{}
-	goToOldestOnTimeMachine     map[KeyStroke]struct{}
+// parseGeneralDuration reads the duration of general.key, which must be
+// positive, or only not negative if zero is allowed.
+func parseGeneralDuration(v *viper.Viper, key string, allowZero bool) (time.Duration, error) {
+	value := v.GetString("general." + key)
+
+	d, err := parseInterval(key, value)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case allowZero && d < 0:
+		return 0, durationError{key: key, value: value, reason: "must not be negative"}
+	case !allowZero && d <= 0:
+		return 0, durationError{key: key, value: value, reason: "must be positive"}
+	}
+
+	return d, nil
 }
 
-//nolint:funlen,cyclop
-func newConfig(v *viper.Viper, args []string) (*config, error) {
-	flagSet := pflag.NewFlagSet("", pflag.ExitOnError)
+func parseStderrMode(v *viper.Viper) (StderrMode, error) {
+	v.SetDefault("general.stderr", string(StderrModeSeparate))
 
-	// runtimeConfig
-	flagSet.StringP("interval", "n", "2s", "seconds to wait between updates")
-	flagSet.BoolP("precise", "p", false, "attempt run command in precise intervals")
-	flagSet.BoolP("clockwork", "c", false, "run command in precise intervals forcibly")
-	flagSet.BoolP("help", "h", false, "display this help and exit")
-	flagSet.BoolP("version", "v", false, "output version information and exit")
+	mode := StderrMode(v.GetString("general.stderr"))
 
-	// general
-	flagSet.BoolP("differences", "d", false, "highlight changes between updates")
-	flagSet.BoolP("no-title", "t", false, "turn off header")
-	flagSet.Bool("debug", false, "")
-	flagSet.String("shell", "", "shell (default \"sh\")")
-	flagSet.String("shell-options", "", "additional shell options")
+	switch mode {
+	case StderrModeSeparate, StderrModeInterleave, StderrModeHide:
+		return mode, nil
+	}
 
-	flagSet.SetInterspersed(false)
+	return mode, errStderr
+}
 
-	if err := flagSet.Parse(args); err != nil {
-		return nil, err
+func parseSplitLayout(v *viper.Viper) (SplitLayout, error) {
+	v.SetDefault("general.split", string(SplitHorizontal))
+
+	split := SplitLayout(v.GetString("general.split"))
+
+	switch split {
+	case SplitHorizontal, SplitVertical:
+		return split, nil
 	}
 
-	var conf config
+	return split, errSplit
+}
 
-	intervalStr, _ := flagSet.GetString("interval")
+func parseStatusItemsConfig(v *viper.Viper) ([]StatusItem, error) {
+	statusItems := make([]string, 0, len(defaultStatusItems))
+	for _, item := range defaultStatusItems {
+		statusItems = append(statusItems, string(item))
+	}
 
-	interval, err := parseInterval(intervalStr)
+	v.SetDefault("general.status_items", statusItems)
+
+	return parseStatusItems(v.Get("general.status_items"))
+}
+
+// parseAdaptive reads how far the adaptive interval backs off, which is no
+// shorter than the interval in adaptive mode, and after how many runs.
+func parseAdaptive(v *viper.Viper, interval time.Duration, adaptive bool) (time.Duration, int, error) {
+	v.SetDefault("general.adaptive_max", "1m")
+	v.SetDefault("general.adaptive_steady_runs", 3)
+
+	adaptiveMaxStr := v.GetString("general.adaptive_max")
+
+	max, err := parseInterval("adaptive_max", adaptiveMaxStr)
 	if err != nil {
-		return nil, err
+		return 0, 0, err
 	}
 
-	conf.runtime.interval = interval
+	if max < interval && adaptive {
+		reason := fmt.Sprintf("must not be shorter than the interval of %s", interval)
 
-	conf.runtime.mode = ViddyIntervalModeSequential
-	if ok, _ := flagSet.GetBool("precise"); ok {
-		conf.runtime.mode = ViddyIntervalModePrecise
+		return 0, 0, durationError{key: "adaptive_max", value: adaptiveMaxStr, reason: reason}
 	}
 
-	if ok, _ := flagSet.GetBool("clockwork"); ok {
-		conf.runtime.mode = ViddyIntervalModeClockwork
+	steadyRuns := v.GetInt("general.adaptive_steady_runs")
+	if steadyRuns < 1 {
+		return max, steadyRuns, errAdaptiveSteadyRuns
 	}
 
-	conf.runtime.help, _ = flagSet.GetBool("help")
-	conf.runtime.version, _ = flagSet.GetBool("version")
+	return max, steadyRuns, nil
+}
 
-	if err := v.BindPFlag("general.debug", flagSet.Lookup("debug")); err != nil {
-		return nil, err
-	}
+func parseStream(v *viper.Viper) (time.Duration, StreamPolicy, error) {
+	// 0 leaves it to the interval.
+	v.SetDefault("general.stream_grace", "0")
+	v.SetDefault("general.stream_policy", string(StreamPolicyLive))
 
-	if err := v.BindPFlag("general.shell", flagSet.Lookup("shell")); err != nil {
-		return nil, err
+	grace, err := parseGeneralDuration(v, "stream_grace", true)
+	if err != nil {
+		return 0, "", err
 	}
 
-	v.SetDefault("general.shell", "sh")
+	policy := StreamPolicy(v.GetString("general.stream_policy"))
 
-	if err := v.BindPFlag("general.shell_options", flagSet.Lookup("shell-options")); err != nil {
-		return nil, err
+	switch policy {
+	case StreamPolicyLive, StreamPolicyCut:
+		return grace, policy, nil
 	}
 
-	if err := v.BindPFlag("general.differences", flagSet.Lookup("differences")); err != nil {
-		return nil, err
+	return grace, policy, errStreamPolicy
+}
+
+func parseNotify(v *viper.Viper) (NotifyMode, time.Duration, error) {
+	v.SetDefault("general.notify_cooldown", "30s")
+
+	mode := NotifyMode(v.GetString("general.notify"))
+
+	switch mode {
+	case NotifyModeOff, NotifyModeChange, NotifyModeError, NotifyModeBoth:
+	default:
+		return mode, 0, errNotify
 	}
 
-	if err := v.BindPFlag("general.no_title", flagSet.Lookup("no-title")); err != nil {
-		return nil, err
+	cooldown, err := parseGeneralDuration(v, "notify_cooldown", true)
+	if err != nil {
+		return mode, 0, err
 	}
 
-	conf.general.debug = v.GetBool("general.debug")
-	conf.general.shell = v.GetString("general.shell")
-	conf.general.shellOptions = v.GetString("general.shell_options")
-	conf.general.differences, _ = flagSet.GetBool("differences")
-	conf.general.noTitle, _ = flagSet.GetBool("no-title")
+	return mode, cooldown, nil
+}
 
-	conf.theme.Theme = tview.Theme{
-		PrimitiveBackgroundColor:    tcell.GetColor(v.GetString("color.background")),
-		ContrastBackgroundColor:     tcell.GetColor(v.GetString("color.contrast_background")),
-		MoreContrastBackgroundColor: tcell.GetColor(v.GetString("color.more_contrast_background")),
-		BorderColor:                 tcell.GetColor(v.GetString("color.border")),
-		TitleColor:                  tcell.GetColor(v.GetString("color.title")),
-		GraphicsColor:               tcell.GetColor(v.GetString("color.graphics")),
-		PrimaryTextColor:            tcell.GetColor(v.GetString("color.text")),
-		SecondaryTextColor:          tcell.GetColor(v.GetString("color.secondary_text")),
-		TertiaryTextColor:           tcell.GetColor(v.GetString("color.tertiary_text")),
-		InverseTextColor:            tcell.GetColor(v.GetString("color.inverse_text")),
-		ContrastSecondaryTextColor:  tcell.GetColor(v.GetString("color.contrast_secondary_text")),
-	}
-
-	conf.keymap.toggleTimeMachine = getKeymapDefault(v, "keymap.toggle_timemachine",
-		map[KeyStroke]struct{}{mustParseKeymap(" "): {}})
-	conf.keymap.goToPastOnTimeMachine = getKeymapDefault(v, "keymap.timemachine_go_to_past",
-		map[KeyStroke]struct{}{mustParseKeymap("Shift-J"): {}})
-	conf.keymap.goToFutureOnTimeMachine = getKeymapDefault(v, "keymap.timemachine_go_to_future",
-		map[KeyStroke]struct{}{mustParseKeymap("Shift-K"): {}})
-	conf.keymap.goToMorePastOnTimeMachine = getKeymapDefault(v, "keymap.timemachine_go_to_more_past",
-		map[KeyStroke]struct{}{mustParseKeymap("Shift-F"): {}})
-	conf.keymap.goToMoreFutureOnTimeMachine = getKeymapDefault(v, "keymap.timemachine_go_to_more_future",
-		map[KeyStroke]struct{}{mustParseKeymap("Shift-B"): {}})
-	conf.keymap.goToNowOnTimeMachine = getKeymapDefault(v, "keymap.timemachine_go_to_now",
-		map[KeyStroke]struct{}{mustParseKeymap("Shift-N"): {}})
-	conf.keymap.goToOldestOnTimeMachine = getKeymapDefault(v, "keymap.timemachine_go_to_oldest",
-		map[KeyStroke]struct{}{mustParseKeymap("Shift-O"): {}})
-
-	if conf.runtime.interval < 10*time.Millisecond {
-		return &conf, errIntervalTooSmall
+type configFileError struct {
+	path string
+	err  error
+}
+
+func (e configFileError) Error() string {
+	return fmt.Sprintf("cannot read config file %q: %s", e.path, e.err)
+}
+
+func (e configFileError) Unwrap() error {
+	return e.err
+}
+
+type shellWordsError struct {
+	column int
+	reason string
+}
+
+func (e shellWordsError) Error() string {
+	return fmt.Sprintf("cannot parse shell options: %s at column %d", e.reason, e.column)
+}
+
+// splitShellWords splits the string into words like a POSIX shell does,
+// honoring single quotes, double quotes and backslash escapes.
+//
+//nolint:cyclop
+func splitShellWords(str string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		quoteAt int
+		escaped bool
+		escAt   int
+	)
+
+	column := 0
+
+	for _, c := range str {
+		column++
+
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", c) {
+				word.WriteRune('\\')
+			}
+
+			word.WriteRune(c)
+
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, escAt, inWord = true, column, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, quoteAt, inWord = c, column, true
+		case unicode.IsSpace(c):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+
+			inWord = true
+		}
 	}
 
-	rest := flagSet.Args()
+	switch {
+	case escaped:
+		return nil, shellWordsError{column: escAt, reason: "trailing backslash"}
+	case quote == '\'':
+		return nil, shellWordsError{column: quoteAt, reason: "unterminated single quote"}
+	case quote == '"':
+		return nil, shellWordsError{column: quoteAt, reason: "unterminated double quote"}
+	}
 
-	if len(rest) == 0 {
-		return &conf, errNoCommand
+	if inWord {
+		words = append(words, word.String())
 	}
 
-	conf.runtime.cmd = rest[0]
-	conf.runtime.args = rest[1:]
+	return words, nil
+}
 
-	return &conf, nil
+type chdirError struct {
+	path   string
+	reason string
 }
 
-func parseInterval(intervalStr string) (time.Duration, error) {
-	interval, err := time.ParseDuration(intervalStr)
+func (e chdirError) Error() string {
+	return fmt.Sprintf("cannot change directory to %q: %s", e.path, e.reason)
+}
+
+// resolveChdir makes the directory absolute and checks that it exists.
+func resolveChdir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
 	if err != nil {
-		intervalFloat, err := strconv.ParseFloat(intervalStr, 64)
+		return "", err
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", chdirError{path: dir, reason: "no such directory"}
+	}
+
+	if !info.IsDir() {
+		return "", chdirError{path: dir, reason: "not a directory"}
+	}
+
+	return abs, nil
+}
+
+// envVar is an environment variable to set for the command, or to remove from it.
+type envVar struct {
+	key   string
+	value string
+	unset bool
+}
+
+type envError struct {
+	arg string
+}
+
+func (e envError) Error() string {
+	return fmt.Sprintf("invalid environment variable %q: expected KEY=VALUE or KEY", e.arg)
+}
+
+func parseEnv(arg string) (envVar, error) {
+	key, value, found := arg, "", false
+	if i := strings.Index(arg, "="); i >= 0 {
+		key, value, found = arg[:i], arg[i+1:], true
+	}
+
+	if key == "" || strings.ContainsAny(key, " \t\n\x00") {
+		return envVar{}, envError{arg: arg}
+	}
+
+	return envVar{key: key, value: value, unset: !found}, nil
+}
+
+// getEnv reads environment variables from the config file, followed by the ones given by flags.
+// The config file accepts a list of KEY=VALUE strings, or a table. Config keys are
+// case-insensitive, so the names in a table are upper-cased.
+func getEnv(v *viper.Viper, key string, args []string) ([]envVar, error) {
+	var entries []string
+
+	switch value := v.Get(key).(type) {
+	case nil:
+	case map[string]interface{}:
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			entries = append(entries, strings.ToUpper(name)+"="+cast.ToString(value[name]))
+		}
+	default:
+		list, err := cast.ToStringSliceE(value)
 		if err != nil {
-			return 0, err
+			return nil, envError{arg: cast.ToString(value)}
 		}
 
-		interval = time.Duration(intervalFloat * float64(time.Second))
+		entries = append(entries, list...)
 	}
 
-	return interval, nil
+	entries = append(entries, args...)
+
+	var vars []envVar
+
+	for _, entry := range entries {
+		ev, err := parseEnv(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		vars = append(vars, ev)
+	}
+
+	return vars, nil
+}
+
+// colorReader reads colors, collecting warnings for the names it does not know.
+type colorReader struct {
+	v         *viper.Viper
+	warnings  []string
+	fallbacks map[string]string
+}
+
+func (r *colorReader) get(key string, d tcell.Color) tcell.Color {
+	name := r.v.GetString(key)
+
+	switch {
+	case name == "":
+		return d
+	case strings.EqualFold(name, "default"):
+		return tcell.ColorDefault
+	}
+
+	c, ok := parseColor(name)
+	if !ok {
+		r.warnings = append(r.warnings, fmt.Sprintf("%s: unknown color %q, using the default", key, name))
+
+		if r.fallbacks == nil {
+			r.fallbacks = map[string]string{}
+		}
+
+		r.fallbacks[key] = fmt.Sprintf("unknown color %q", name)
+
+		return d
+	}
+
+	return c
+}
+
+// parseColor accepts W3C color names in any case, "#rrggbb", "#rgb" and "0xrrggbb".
+// Terminals without true color get the nearest color of their palette from tcell.
+func parseColor(name string) (tcell.Color, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if c, ok := tcell.ColorNames[name]; ok {
+		return c, true
+	}
+
+	var hex string
+
+	switch {
+	case strings.HasPrefix(name, "#") && len(name) == 4:
+		for _, c := range name[1:] {
+			hex += strings.Repeat(string(c), 2)
+		}
+	case strings.HasPrefix(name, "#") && len(name) == 7:
+		hex = name[1:]
+	case strings.HasPrefix(name, "0x") && len(name) == 8:
+		hex = name[2:]
+	default:
+		return tcell.ColorDefault, false
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return tcell.ColorDefault, false
+	}
+
+	return tcell.NewHexColor(int32(v)), true
+}
+
+// keymapError is a keymap entry of the config file which cannot be used.
+type keymapError struct {
+	key string
+	err error
+}
+
+type keymapErrors []keymapError
+
+func (e keymapErrors) Error() string {
+	var b strings.Builder
+
+	b.WriteString("invalid keymap in config file:\n")
+
+	for _, ke := range e {
+		fmt.Fprintf(&b, "  %s: %s\n", ke.key, ke.err)
+	}
+
+	b.WriteString(`keys look like "j", "Shift-J", "Ctrl-Alt-Up" or "Space", and "g g" presses keys in order`)
+
+	return b.String()
+}
+
+// keymapReader reads keymaps, collecting the entries it cannot parse.
+type keymapReader struct {
+	v    *viper.Viper
+	errs keymapErrors
+
+	// trace records which keys every action got, and from where.
+	trace   bool
+	records []logRecord
 }
 
-func getKeymapDefault(v *viper.Viper, key string, d map[KeyStroke]struct{}) map[KeyStroke]struct{} {
-	keymap, err := getKeymap(v, key)
+func (r *keymapReader) get(key string, d map[KeySequence]struct{}) map[KeySequence]struct{} {
+	keymap, err := getKeymap(r.v, key)
+
+	var notFound cannotFindKeyError
+	if errors.As(err, &notFound) {
+		r.record(key, d, "default")
+
+		return d
+	}
+
 	if err != nil {
+		r.errs = append(r.errs, keymapError{key: key, err: err})
+
 		return d
 	}
 
+	r.record(key, keymap, "config")
+
 	return keymap
 }
 
+func (r *keymapReader) record(key string, keys map[KeySequence]struct{}, source string) {
+	if r.trace {
+		r.records = append(r.records, logRecord{
+			level: levelDebug,
+			msg:   "keymap",
+			attrs: []interface{}{"action", key, "keys", keysToString(keys), "source", source},
+		})
+	}
+}
+
 type cannotFindKeyError struct {
 	key string
 }
@@ -215,41 +2130,80 @@ func (e cannotFindKeyError) Error() string {
 	return fmt.Sprintf("could not find the key: %q", e.key)
 }
 
-func getKeymap(v *viper.Viper, key string) (map[KeyStroke]struct{}, error) {
+func getKeymap(v *viper.Viper, key string) (map[KeySequence]struct{}, error) {
 	value := v.Get(key)
 	if value == nil {
 		return nil, cannotFindKeyError{key: key}
 	}
 
 	if k, err := cast.ToStringE(value); err == nil {
-		key, err := ParseKeyStroke(k)
+		// An empty value or "none" unbinds the action, like an empty list.
+		if strings.TrimSpace(k) == "" || strings.EqualFold(k, "none") {
+			return map[KeySequence]struct{}{}, nil
+		}
+
+		seq, err := ParseKeySequence(k)
 		if err != nil {
 			return nil, err
 		}
 
-		return map[KeyStroke]struct{}{key: {}}, nil
+		return map[KeySequence]struct{}{seq: {}}, nil
+	}
+
+	// Every item is an alternative, either a string like "g g" or a list of keys.
+	if items, ok := value.([]interface{}); ok {
+		m := map[KeySequence]struct{}{}
+
+		for _, item := range items {
+			seq, err := parseKeymapItem(item)
+			if err != nil {
+				return nil, err
+			}
+
+			m[seq] = struct{}{}
+		}
+
+		return m, nil
 	}
 
 	if keys, err := cast.ToStringSliceE(value); err == nil {
-		m := map[KeyStroke]struct{}{}
+		m := map[KeySequence]struct{}{}
 
 		for _, k := range keys {
-			key, err := ParseKeyStroke(k)
+			seq, err := ParseKeySequence(k)
 			if err != nil {
 				return nil, err
 			}
 
-			m[key] = struct{}{}
+			m[seq] = struct{}{}
 		}
 
 		return m, nil
 	}
 
-	return nil, nil
+	return nil, parseKeyStrokeError{key: fmt.Sprint(value), reason: "expected a key or a list of keys"}
+}
+
+func parseKeymapItem(item interface{}) (KeySequence, error) {
+	if keys, ok := item.([]interface{}); ok {
+		strs, err := cast.ToStringSliceE(keys)
+		if err != nil {
+			return KeySequence{}, err
+		}
+
+		return parseKeyStrokes(strs, strings.Join(strs, " "))
+	}
+
+	k, err := cast.ToStringE(item)
+	if err != nil {
+		return KeySequence{}, err
+	}
+
+	return ParseKeySequence(k)
 }
 
-func mustParseKeymap(key string) KeyStroke {
-	keymap, err := ParseKeyStroke(key)
+func mustParseKeymap(key string) KeySequence {
+	keymap, err := ParseKeySequence(key)
 	if err != nil {
 		panic(err)
 	}
@@ -258,80 +2212,174 @@ func mustParseKeymap(key string) KeyStroke {
 }
 
 type parseKeyStrokeError struct {
-	key string
+	key    string
+	reason string
 }
 
 func (e parseKeyStrokeError) Error() string {
-	return fmt.Sprintf("connot parse key: %q", e.key)
+	return fmt.Sprintf("cannot parse key %q: %s", e.key, e.reason)
 }
 
 // ParseKeyStroke parse string describing key.
 func ParseKeyStroke(key string) (KeyStroke, error) {
 	if len(key) == 0 {
-		return KeyStroke{}, parseKeyStrokeError{key: key}
+		return KeyStroke{}, parseKeyStrokeError{key: key, reason: "empty key"}
 	}
 
-	var mod tcell.ModMask
+	var (
+		mod   tcell.ModMask
+		shift bool
+	)
 
-	if strings.HasPrefix(key, "Ctrl-") {
-		mod |= tcell.ModCtrl
-		key = strings.TrimPrefix(key, "Ctrl-")
-	}
+	rest := key
+
+	// Modifiers may come in any order and case.
+	hasModifier := func(name string) bool {
+		if len(rest) <= len(name) || !strings.EqualFold(rest[:len(name)], name) {
+			return false
+		}
+
+		rest = rest[len(name):]
 
-	if strings.HasPrefix(key, "Alt-") {
-		mod |= tcell.ModAlt
-		key = strings.TrimPrefix(key, "Alt-")
+		return true
 	}
 
-	if strings.HasPrefix(key, "Shift-") {
-		key = strings.TrimPrefix(key, "Shift-")
+	for {
+		switch {
+		case hasModifier("Ctrl-"):
+			mod |= tcell.ModCtrl
 
-		if k, err := keyOf(key); err == nil {
-			mod |= tcell.ModShift
+			continue
+		case hasModifier("Alt-"):
+			mod |= tcell.ModAlt
+
+			continue
+		case hasModifier("Shift-"):
+			shift = true
 
-			return KeyStroke{
-				Key:     k,
-				ModMask: mod,
-			}, nil
+			continue
 		}
 
-		k := []rune(key)[0]
+		break
+	}
+
+	if stroke, ok := namedKey(rest); ok {
+		if shift {
+			mod |= tcell.ModShift
+		}
 
-		return KeyStroke{
-			Key:     tcell.KeyRune,
-			Rune:    unicode.ToUpper(k),
-			ModMask: mod,
-		}, nil
+		stroke.ModMask = mod
+
+		// Terminals send Ctrl-Space as NUL.
+		if stroke.Key == tcell.KeyRune && stroke.Rune == ' ' && mod&tcell.ModCtrl != 0 {
+			stroke = KeyStroke{Key: tcell.KeyNUL, ModMask: mod}
+		}
+
+		return stroke, nil
 	}
 
-	if k, err := keyOf(key); err == nil {
-		return KeyStroke{
-			Key:     k,
-			ModMask: mod,
-		}, nil
+	runes := []rune(rest)
+	if len(runes) != 1 {
+		if i := strings.LastIndex(rest, "-"); i > 0 {
+			return KeyStroke{}, parseKeyStrokeError{key: key, reason: fmt.Sprintf("unknown modifier %q", rest[:i])}
+		}
+
+		return KeyStroke{}, parseKeyStrokeError{
+			key:    key,
+			reason: fmt.Sprintf("unknown key %q, expected a single character or one of %s", rest, keyNameList()),
+		}
 	}
 
-	k := []rune(key)[0]
+	r := unicode.ToLower(runes[0])
+	if shift {
+		r = unicode.ToUpper(runes[0])
+	}
 
 	return KeyStroke{
 		Key:     tcell.KeyRune,
-		Rune:    unicode.ToLower(k),
+		Rune:    r,
 		ModMask: mod,
 	}, nil
 }
 
-type keyNotFoundError struct{}
+// keyNames are the names of keys which are not typed as a single character.
+// formatKeyStroke uses them too, so the first name of a key is its canonical one.
+var keyNames = []struct {
+	name   string
+	stroke KeyStroke
+}{
+	{name: "Space", stroke: KeyStroke{Key: tcell.KeyRune, Rune: ' '}},
+	{name: "Enter", stroke: KeyStroke{Key: tcell.KeyEnter}},
+	{name: "Return", stroke: KeyStroke{Key: tcell.KeyEnter}},
+	{name: "Tab", stroke: KeyStroke{Key: tcell.KeyTab}},
+	{name: "Esc", stroke: KeyStroke{Key: tcell.KeyEsc}},
+	{name: "Escape", stroke: KeyStroke{Key: tcell.KeyEsc}},
+	{name: "Backspace", stroke: KeyStroke{Key: tcell.KeyBackspace2}},
+	{name: "Delete", stroke: KeyStroke{Key: tcell.KeyDelete}},
+	{name: "Del", stroke: KeyStroke{Key: tcell.KeyDelete}},
+	{name: "Insert", stroke: KeyStroke{Key: tcell.KeyInsert}},
+	{name: "Home", stroke: KeyStroke{Key: tcell.KeyHome}},
+	{name: "End", stroke: KeyStroke{Key: tcell.KeyEnd}},
+	{name: "PgUp", stroke: KeyStroke{Key: tcell.KeyPgUp}},
+	{name: "PageUp", stroke: KeyStroke{Key: tcell.KeyPgUp}},
+	{name: "PgDn", stroke: KeyStroke{Key: tcell.KeyPgDn}},
+	{name: "PageDown", stroke: KeyStroke{Key: tcell.KeyPgDn}},
+	{name: "Up", stroke: KeyStroke{Key: tcell.KeyUp}},
+	{name: "Down", stroke: KeyStroke{Key: tcell.KeyDown}},
+	{name: "Left", stroke: KeyStroke{Key: tcell.KeyLeft}},
+	{name: "Right", stroke: KeyStroke{Key: tcell.KeyRight}},
+	{name: "F1", stroke: KeyStroke{Key: tcell.KeyF1}},
+	{name: "F2", stroke: KeyStroke{Key: tcell.KeyF2}},
+	{name: "F3", stroke: KeyStroke{Key: tcell.KeyF3}},
+	{name: "F4", stroke: KeyStroke{Key: tcell.KeyF4}},
+	{name: "F5", stroke: KeyStroke{Key: tcell.KeyF5}},
+	{name: "F6", stroke: KeyStroke{Key: tcell.KeyF6}},
+	{name: "F7", stroke: KeyStroke{Key: tcell.KeyF7}},
+	{name: "F8", stroke: KeyStroke{Key: tcell.KeyF8}},
+	{name: "F9", stroke: KeyStroke{Key: tcell.KeyF9}},
+	{name: "F10", stroke: KeyStroke{Key: tcell.KeyF10}},
+	{name: "F11", stroke: KeyStroke{Key: tcell.KeyF11}},
+	{name: "F12", stroke: KeyStroke{Key: tcell.KeyF12}},
+}
+
+// namedKey looks up the key by name, ignoring case. Names of tcell are accepted as well.
+func namedKey(name string) (KeyStroke, bool) {
+	for _, n := range keyNames {
+		if strings.EqualFold(n.name, name) {
+			return n.stroke, true
+		}
+	}
+
+	for k, n := range tcell.KeyNames {
+		if n == name {
+			return KeyStroke{Key: k}, true
+		}
+	}
 
-func (k keyNotFoundError) Error() string {
-	return "not found"
+	return KeyStroke{}, false
 }
 
-func keyOf(key string) (tcell.Key, error) {
-	for k, name := range tcell.KeyNames {
-		if name == key {
-			return k, nil
+// keyName is the canonical name of the key, if it has one.
+func keyName(stroke KeyStroke) (string, bool) {
+	stroke.ModMask = 0
+	if stroke.Key == tcell.KeyNUL {
+		stroke = KeyStroke{Key: tcell.KeyRune, Rune: ' '}
+	}
+
+	for _, n := range keyNames {
+		if n.stroke == stroke {
+			return n.name, true
 		}
 	}
 
-	return 0, keyNotFoundError{}
+	return "", false
+}
+
+func keyNameList() string {
+	names := make([]string, 0, len(keyNames))
+	for _, n := range keyNames {
+		names = append(names, n.name)
+	}
+
+	return strings.Join(names, ", ")
 }
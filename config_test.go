@@ -2,6 +2,11 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
 	"testing"
 	"time"
 
@@ -15,19 +20,48 @@ import (
 func Test_newConfig(t *testing.T) {
 	defaultConfig := config{
 		runtime: runtimeConfig{
-			cmd:      "",
-			args:     nil,
 			interval: 2 * time.Second,
 			mode:     ViddyIntervalModeSequential,
 			help:     false,
 			version:  false,
+			times:    timeFormat{loc: time.Local},
 		},
 		general: general{
-			shell:        "sh",
-			shellOptions: "",
-			differences:  false,
-			noTitle:      false,
-			debug:        false,
+			shell:              defaultShell,
+			shellOptions:       nil,
+			differences:        false,
+			diffGranularity:    DiffGranularityChar,
+			differencesAgainst: diffOffset{runs: 1},
+			noTitle:            false,
+			debug:              false,
+			log:                defaultDebugLogPath(),
+			maxConcurrentRuns:  0,
+			overlapPolicy:      OverlapPolicySkip,
+			stderr:             StderrModeSeparate,
+			pty:                false,
+			env:                nil,
+			mouse:              true,
+			showHost:           true,
+			showTimeline:       true,
+			pollWhileSuspended: true,
+			pollInTimeMachine:  true,
+			confirmDestructive: true,
+			tabWidth:           8,
+			stickyScroll:       true,
+			timeMachineStep:    time.Minute,
+			playbackSpeed:      playbackSpeed{rate: 4},
+			scrubAcceleration:  []int{1, 2, 5, 10, 25},
+			backoffMax:         5 * time.Minute,
+			adaptiveMax:        time.Minute,
+			adaptiveSteadyRuns: 3,
+			compressAfter:      1000,
+			split:              SplitHorizontal,
+			statusItems:        defaultStatusItems,
+			timeZone:           "Local",
+			flashDuration:      500 * time.Millisecond,
+			killTimeout:        2 * time.Second,
+			streamPolicy:       StreamPolicyLive,
+			notifyCooldown:     30 * time.Second,
 		},
 		theme: theme{
 			Theme: tview.Theme{
@@ -43,160 +77,2307 @@ func Test_newConfig(t *testing.T) {
 				InverseTextColor:            0,
 				ContrastSecondaryTextColor:  0,
 			},
+			diffAdded:             tcell.ColorGreen,
+			diffChangedBackground: tcell.ColorGreen,
+			stderrText:            tcell.ColorRed,
+			errorText:             tcell.ColorOrangeRed,
+			flash:                 tcell.ColorYellow,
 		},
 		keymap: keymapping{
-			toggleTimeMachine:           map[KeyStroke]struct{}{mustParseKeymap(" "): {}},
-			goToPastOnTimeMachine:       map[KeyStroke]struct{}{mustParseKeymap("Shift-J"): {}},
-			goToFutureOnTimeMachine:     map[KeyStroke]struct{}{mustParseKeymap("Shift-K"): {}},
-			goToMorePastOnTimeMachine:   map[KeyStroke]struct{}{mustParseKeymap("Shift-F"): {}},
-			goToMoreFutureOnTimeMachine: map[KeyStroke]struct{}{mustParseKeymap("Shift-B"): {}},
-			goToNowOnTimeMachine:        map[KeyStroke]struct{}{mustParseKeymap("Shift-N"): {}},
-			goToOldestOnTimeMachine:     map[KeyStroke]struct{}{mustParseKeymap("Shift-O"): {}},
+			toggleTimeMachine:            map[KeySequence]struct{}{mustParseKeymap("Space"): {}},
+			goToPastOnTimeMachine:        map[KeySequence]struct{}{mustParseKeymap("Shift-J"): {}},
+			goToFutureOnTimeMachine:      map[KeySequence]struct{}{mustParseKeymap("Shift-K"): {}},
+			goToMorePastOnTimeMachine:    map[KeySequence]struct{}{mustParseKeymap("Shift-F"): {}},
+			goToMoreFutureOnTimeMachine:  map[KeySequence]struct{}{mustParseKeymap("Shift-B"): {}},
+			goToNowOnTimeMachine:         map[KeySequence]struct{}{mustParseKeymap("Shift-N"): {}},
+			goToOldestOnTimeMachine:      map[KeySequence]struct{}{mustParseKeymap("Shift-O"): {}},
+			goToTimeOnTimeMachine:        map[KeySequence]struct{}{mustParseKeymap("Shift-T"): {}},
+			backDurationOnTimeMachine:    map[KeySequence]struct{}{mustParseKeymap("Shift-H"): {}},
+			forwardDurationOnTimeMachine: map[KeySequence]struct{}{mustParseKeymap("Shift-L"): {}},
+			increaseStepOnTimeMachine:    map[KeySequence]struct{}{mustParseKeymap("+"): {}},
+			decreaseStepOnTimeMachine:    map[KeySequence]struct{}{mustParseKeymap("-"): {}},
+			playOnTimeMachine:            map[KeySequence]struct{}{mustParseKeymap("p"): {}},
+			markOnTimeMachine:            map[KeySequence]struct{}{mustParseKeymap("a"): {}},
+			compareOnTimeMachine:         map[KeySequence]struct{}{mustParseKeymap("c"): {}},
+			togglePollOnTimeMachine:      map[KeySequence]struct{}{mustParseKeymap("Shift-P"): {}},
+
+			quit:               map[KeySequence]struct{}{mustParseKeymap("Ctrl-C"): {}},
+			toggleSuspend:      map[KeySequence]struct{}{mustParseKeymap("s"): {}},
+			toggleDiff:         map[KeySequence]struct{}{mustParseKeymap("d"): {}},
+			resetDiff:          map[KeySequence]struct{}{mustParseKeymap("Shift-D"): {}},
+			clearHistory:       map[KeySequence]struct{}{mustParseKeymap("Shift-X"): {}},
+			toggleChangesOnly:  map[KeySequence]struct{}{mustParseKeymap("Shift-C"): {}},
+			toggleSideBySide:   map[KeySequence]struct{}{mustParseKeymap("v"): {}},
+			toggleWrap:         map[KeySequence]struct{}{mustParseKeymap("w"): {}},
+			toggleLineAge:      map[KeySequence]struct{}{mustParseKeymap("Shift-A"): {}},
+			editCommand:        map[KeySequence]struct{}{mustParseKeymap("Shift-E"): {}},
+			toggleHeader:       map[KeySequence]struct{}{mustParseKeymap("t"): {}},
+			toggleHelp:         map[KeySequence]struct{}{mustParseKeymap("?"): {}},
+			toggleLog:          map[KeySequence]struct{}{mustParseKeymap("x"): {}},
+			toggleSnapshotList: map[KeySequence]struct{}{mustParseKeymap("Shift-S"): {}},
+			toggleChangeLog:    map[KeySequence]struct{}{mustParseKeymap("Shift-W"): {}},
+			toggleStderr:       map[KeySequence]struct{}{mustParseKeymap("e"): {}},
+			toggleRaw:          map[KeySequence]struct{}{mustParseKeymap("r"): {}},
+			yank:               map[KeySequence]struct{}{mustParseKeymap("y"): {}},
+			yankVisible:        map[KeySequence]struct{}{mustParseKeymap("Shift-Y"): {}},
+			shell:              map[KeySequence]struct{}{mustParseKeymap("!"): {}},
+			focusNextPane:      map[KeySequence]struct{}{mustParseKeymap("Tab"): {}},
+			search:             map[KeySequence]struct{}{mustParseKeymap("/"): {}},
+			toggleSearchCase:   map[KeySequence]struct{}{mustParseKeymap("Alt-C"): {}},
+			toggleSearchWord:   map[KeySequence]struct{}{mustParseKeymap("Alt-W"): {}},
+			scrollUp:           map[KeySequence]struct{}{mustParseKeymap("k"): {}, mustParseKeymap("Up"): {}},
+			scrollDown:         map[KeySequence]struct{}{mustParseKeymap("j"): {}, mustParseKeymap("Down"): {}},
+			scrollLeft:         map[KeySequence]struct{}{mustParseKeymap("h"): {}, mustParseKeymap("Left"): {}},
+			scrollRight:        map[KeySequence]struct{}{mustParseKeymap("l"): {}, mustParseKeymap("Right"): {}},
+			pageUp:             map[KeySequence]struct{}{mustParseKeymap("Ctrl-B"): {}, mustParseKeymap("PgUp"): {}},
+			pageDown:           map[KeySequence]struct{}{mustParseKeymap("Ctrl-F"): {}, mustParseKeymap("PgDn"): {}},
+			scrollToTop:        map[KeySequence]struct{}{mustParseKeymap("g"): {}, mustParseKeymap("Home"): {}},
+			scrollToBottom:     map[KeySequence]struct{}{mustParseKeymap("Shift-G"): {}, mustParseKeymap("End"): {}},
+			nextChange:         map[KeySequence]struct{}{mustParseKeymap("Ctrl-N"): {}},
+			previousChange:     map[KeySequence]struct{}{mustParseKeymap("Ctrl-P"): {}},
+
+			toggleBookmark:   map[KeySequence]struct{}{mustParseKeymap("m"): {}},
+			nextBookmark:     map[KeySequence]struct{}{mustParseKeymap("]"): {}},
+			previousBookmark: map[KeySequence]struct{}{mustParseKeymap("["): {}},
+			clearBookmarks:   map[KeySequence]struct{}{mustParseKeymap("Shift-M"): {}},
+
+			increaseInterval: map[KeySequence]struct{}{mustParseKeymap("+"): {}},
+			decreaseInterval: map[KeySequence]struct{}{mustParseKeymap("-"): {}},
+			togglePause:      map[KeySequence]struct{}{mustParseKeymap("Shift-Z"): {}},
+		},
+	}
+
+	// uncolored is the config as newConfig leaves it when it stops at an
+	// error before reading the colors and the key mappings.
+	uncolored := func(c config) config {
+		c.theme, c.keymap = theme{}, keymapping{}
+
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		configFile string
+		args       []string
+		want       config
+		expErr     error
+	}{
+		{
+			name:       "default",
+			configFile: "",
+			args:       []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+
+				return c
+			}(),
+		},
+		{
+			name:       "help",
+			configFile: "",
+			args:       []string{"-h"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.help = true
+
+				return c
+			}(),
+			expErr: errNoCommand,
+		},
+		{
+			name:       "version",
+			configFile: "",
+			args:       []string{"-v"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.version = true
+
+				return c
+			}(),
+			expErr: errNoCommand,
+		},
+		{
+			name:       "interval in watch mode",
+			configFile: "",
+			args:       []string{"-n", "0.5", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.interval = 500 * time.Millisecond
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "interval in go mode",
+			configFile: "",
+			args:       []string{"-n", "500ms", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.interval = 500 * time.Millisecond
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "permanent differences",
+			configFile: "",
+			args:       []string{"--differences=permanent", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.differences = true
+				c.general.permanentDiff = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "differences against runs",
+			configFile: "",
+			args:       []string{"-d", "--differences-against", "60", "df"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "df", args: []string{}}}
+				c.general.differences = true
+				c.general.differencesAgainst = diffOffset{runs: 60}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "differences against duration",
+			configFile: "[general]\ndifferences_against = \"5m\"",
+			args:       []string{"df"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "df", args: []string{}}}
+				c.general.differencesAgainst = diffOffset{duration: 5 * time.Minute}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "invalid differences against",
+			configFile: "",
+			args:       []string{"--differences-against", "0", "df"},
+			want: config{
+				runtime: defaultConfig.runtime,
+				general: general{
+					shell:           defaultShell,
+					log:             defaultDebugLogPath(),
+					timeZone:        "Local",
+					diffGranularity: DiffGranularityChar,
+				},
+			},
+			expErr: errDifferencesAgainst,
+		},
+		{
+			name:       "file",
+			configFile: "",
+			args:       []string{"--file", "/proc/meminfo"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.file = "/proc/meminfo"
+				c.runtime.commands = []commandSpec{{cmd: "/proc/meminfo", args: []string{}}}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "exec",
+			configFile: "",
+			args:       []string{"-x", "printf", "%s\\n", "a b"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.exec = true
+				c.runtime.commands = []commandSpec{{cmd: "printf", args: []string{"%s\\n", "a b"}}}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "load",
+			configFile: "",
+			args:       []string{"--load", "pods.history"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.load = "pods.history"
+				c.runtime.commands = []commandSpec{{cmd: "pods.history", args: []string{}}}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "load with a command",
+			configFile: "",
+			args:       []string{"--load", "pods.history", "kubectl", "get", "pods"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.load = "pods.history"
+
+				return c
+			}(),
+			expErr: errLoadCommand,
+		},
+		{
+			name:       "history file",
+			configFile: "",
+			args:       []string{"--history-file", "pods.history", "kubectl"},
+			want: func() config {
+				c := defaultConfig
+				c.general.historyFile = "pods.history"
+				c.runtime.commands = []commandSpec{{cmd: "kubectl", args: []string{}}}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "file with a command",
+			configFile: "",
+			args:       []string{"--file", "/proc/meminfo", "cat", "/proc/meminfo"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.file = "/proc/meminfo"
+
+				return c
+			}(),
+			expErr: errFileCommand,
+		},
+		{
+			name:       "differences by word",
+			configFile: "[general]\ndiff_granularity = \"line\"",
+			args:       []string{"-d=word", "free", "-m"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "free", args: []string{"-m"}}}
+				c.general.differences = true
+				c.general.diffGranularity = DiffGranularityWord
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "diff granularity",
+			configFile: "[general]\ndiff_granularity = \"line\"",
+			args:       []string{"-d", "free"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "free", args: []string{}}}
+				c.general.differences = true
+				c.general.diffGranularity = DiffGranularityLine
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "unknown diff granularity",
+			configFile: "[general]\ndiff_granularity = \"sentence\"",
+			args:       []string{"free"},
+			want: config{
+				runtime: defaultConfig.runtime,
+				general: general{
+					shell:    defaultShell,
+					log:      defaultDebugLogPath(),
+					timeZone: "Local",
+				},
+			},
+			expErr: errDiffGranularity,
+		},
+		{
+			name:       "diff normalize flag",
+			configFile: "",
+			args:       []string{"--diff-normalize", "trim_trailing_space,ignore_case", "df"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "df", args: []string{}}}
+				c.general.diffNormalize = diffNormalize{trimTrailingSpace: true, ignoreCase: true}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "diff normalize list",
+			configFile: "[general]\ndiff_normalize = [\"collapse_whitespace\", \"ignore_blank_lines\"]",
+			args:       []string{"df"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "df", args: []string{}}}
+				c.general.diffNormalize = diffNormalize{collapseWhitespace: true, ignoreBlankLines: true}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "unknown diff normalize option",
+			configFile: "",
+			args:       []string{"--diff-normalize", "ignore_dates", "df"},
+			want: config{
+				runtime: defaultConfig.runtime,
+				general: general{
+					shell:              defaultShell,
+					log:                defaultDebugLogPath(),
+					timeZone:           "Local",
+					diffGranularity:    DiffGranularityChar,
+					differencesAgainst: diffOffset{runs: 1},
+				},
+			},
+			expErr: diffNormalizeError{option: "ignore_dates"},
+		},
+		{
+			name:       "differences without value",
+			configFile: "",
+			args:       []string{"-d", "ls", "-l"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{"-l"}}}
+				c.general.differences = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "invalid differences",
+			configFile: "",
+			args:       []string{"--differences=always", "ls"},
+			want: config{
+				runtime: defaultConfig.runtime,
+				general: general{
+					shell:    defaultShell,
+					log:      defaultDebugLogPath(),
+					timeZone: "Local",
+				},
+			},
+			expErr: errDifferences,
+		},
+		{
+			name: "changes only",
+			configFile: `
+[general]
+changes_context = 2
+`,
+			args: []string{"--changes-only", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.changesOnly = true
+				c.general.changesContext = 2
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "negative changes context",
+			configFile: `
+[general]
+changes_context = -1
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.changesContext = -1
+				c.general.compressAfter = 0
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errChangesContext,
+		},
+		{
+			name: "jump to the top on every run",
+			configFile: `
+[general]
+sticky_scroll = false
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.stickyScroll = false
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "search ignoring case",
+			configFile: `
+[general]
+search_ignore_case = true
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.searchIgnoreCase = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "scroll margin",
+			configFile: `
+[general]
+scroll_off = 3
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.scrollOff = 3
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "negative scroll margin",
+			configFile: `
+[general]
+scroll_off = -1
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.scrollOff = -1
+				c.general.compressAfter = 0
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errScrollOff,
+		},
+		{
+			name:       "tab width",
+			configFile: "",
+			args:       []string{"--tab-width", "4", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.tabWidth = 4
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid tab width",
+			configFile: `
+[general]
+tab_width = 0
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errTabWidth,
+		},
+		{
+			name:       "jitter",
+			configFile: "",
+			args:       []string{"-n", "5", "--jitter", "1.5", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.interval = 5 * time.Second
+				c.runtime.jitter = 1500 * time.Millisecond
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "jitter as long as the interval",
+			configFile: "",
+			args:       []string{"--jitter", "2s", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.jitter = 2 * time.Second
+
+				return c
+			}(),
+			expErr: durationError{key: "jitter", value: "2s", reason: "must be shorter than the interval of 2s"},
+		},
+		{
+			name:       "negative jitter",
+			configFile: "",
+			args:       []string{"--jitter", "-1s", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.jitter = -time.Second
+
+				return c
+			}(),
+			expErr: durationError{key: "jitter", value: "-1s", reason: "must not be negative"},
+		},
+		{
+			name:       "zero interval",
+			configFile: "",
+			args:       []string{"-n", "0", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.interval = 0
+
+				return c
+			}(),
+			expErr: durationError{key: "interval", value: "0", reason: "is less than the minimum of 10ms"},
+		},
+		{
+			name:       "negative interval",
+			configFile: "",
+			args:       []string{"-n", "-5", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.interval = -5 * time.Second
+
+				return c
+			}(),
+			expErr: durationError{key: "interval", value: "-5", reason: "is less than the minimum of 10ms"},
+		},
+		{
+			name:       "stepped interval",
+			configFile: "",
+			args:       []string{"-n", "1s:1m,5:10m,1.5m", "--clockwork", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.mode = ViddyIntervalModeClockwork
+				c.runtime.interval = time.Second
+				c.runtime.steps = []intervalStep{
+					{interval: time.Second, until: time.Minute},
+					{interval: 5 * time.Second, until: 10 * time.Minute},
+					{interval: 90 * time.Second},
+				}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "stepped interval shorter than the minimum",
+			configFile: "",
+			args:       []string{"-n", "1s:1m,5ms", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.interval = time.Second
+				c.runtime.steps = []intervalStep{{interval: time.Second, until: time.Minute}, {interval: 5 * time.Millisecond}}
+
+				return c
+			}(),
+			expErr: durationError{key: "interval", value: "1s:1m,5ms", reason: "is less than the minimum of 10ms"},
+		},
+		{
+			name:       "adaptive with a stepped interval",
+			configFile: "",
+			args:       []string{"--adaptive", "-n", "1s:1m,30s", "ls"},
+			want: config{
+				runtime: runtimeConfig{
+					interval: time.Second,
+					steps:    []intervalStep{{interval: time.Second, until: time.Minute}, {interval: 30 * time.Second}},
+					mode:     ViddyIntervalModeSequential,
+					times:    timeFormat{loc: time.Local},
+				},
+				general: general{
+					timeZone: "Local",
+				},
+			},
+			expErr: errAdaptiveSteps,
+		},
+		{
+			name:       "schedule",
+			configFile: "",
+			args:       []string{"--schedule", "*/5 * * * *", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.mode = ViddyIntervalModeSchedule
+				c.runtime.schedule, _ = parseSchedule("*/5 * * * *")
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "schedule with interval",
+			configFile: "",
+			args:       []string{"-n", "5", "--schedule", "*/5 * * * *", "ls"},
+			want: config{
+				runtime: runtimeConfig{
+					interval: 5 * time.Second,
+					mode:     ViddyIntervalModeSequential,
+					times:    timeFormat{loc: time.Local},
+				},
+				general: general{
+					timeZone: "Local",
+				},
+			},
+			expErr: errScheduleInterval,
+		},
+		{
+			name:       "invalid schedule",
+			configFile: "",
+			args:       []string{"--schedule", "*/5 * *", "ls"},
+			want: config{
+				runtime: defaultConfig.runtime,
+				general: general{
+					timeZone: "Local",
+				},
+			},
+			expErr: scheduleError{expr: "*/5 * *", reason: "expected 5 or 6 fields, got 3"},
+		},
+		{
+			name: "set shell on config",
+			configFile: `
+[general]
+shell = "zsh"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.shell = "zsh"
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "max concurrent runs",
+			configFile: `
+[general]
+max_concurrent_runs = 4
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.maxConcurrentRuns = 4
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "overlap policy",
+			configFile: `
+[general]
+overlap_policy = "kill"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.overlapPolicy = OverlapPolicyKill
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "time machine step",
+			configFile: `
+[general]
+timemachine_step = "10m"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.timeMachineStep = 10 * time.Minute
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid time machine step",
+			configFile: `
+[general]
+timemachine_step = "-1m"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.timeMachineStep = 0
+				c.general.stickyScroll = false
+				c.general.compressAfter = 0
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: durationError{key: "timemachine_step", value: "-1m", reason: "must be positive"},
+		},
+		{
+			name: "playback speed",
+			configFile: `
+[general]
+playback_speed = "10x"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.playbackSpeed = playbackSpeed{rate: 10, compressed: true}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid playback speed",
+			configFile: `
+[general]
+playback_speed = "fast"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errPlaybackSpeed,
+		},
+		{
+			name: "scrub acceleration",
+			configFile: `
+[general]
+scrub_acceleration = [1, 3, 9]
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.scrubAcceleration = []int{1, 3, 9}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid scrub acceleration",
+			configFile: `
+[general]
+scrub_acceleration = [1, 0]
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: scrubAccelerationError{value: "0"},
+		},
+		{
+			name: "show snapshot list",
+			configFile: `
+[general]
+show_snapshot_list = true
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.showSnapshotList = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "hide timeline",
+			configFile: `
+[general]
+show_timeline = false
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.showTimeline = false
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "on change",
+			configFile: "",
+			args:       []string{"--on-change", "notify-send changed", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.onChange = "notify-send changed"
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "trigger",
+			configFile: "",
+			args:       []string{"--trigger", "Error", "--trigger", "Crash(LoopBackOff)?", "--trigger-exit", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.triggers = []*regexp.Regexp{regexp.MustCompile("(?m)Error"), regexp.MustCompile("(?m)Crash(LoopBackOff)?")}
+				c.general.triggerExit = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "highlight",
+			configFile: `
+[general]
+highlight = ["Running:green"]
+`,
+			args: []string{"--highlight", "ERROR|FATAL", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.highlights = []highlightRule{
+					{spec: "ERROR|FATAL", pattern: regexp.MustCompile("ERROR|FATAL"), color: tcell.ColorRed},
+					{spec: "Running:green", pattern: regexp.MustCompile("Running"), color: tcell.ColorGreen},
+				}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "invalid highlight color",
+			configFile: "",
+			args:       []string{"--highlight", "ERROR:rde", "ls"},
+			want:       defaultConfig,
+			expErr: highlightError{
+				spec: "ERROR:rde",
+				err:  errors.New(`unknown color "rde", a regexp with ":" needs a color after it`),
+			},
+		},
+		{
+			name: "max lines and bytes",
+			configFile: `
+[general]
+max_bytes = "1MB"
+`,
+			args: []string{"--max-lines", "10000", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.maxLines = 10000
+				c.general.maxBytes = 1 << 20
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "time format",
+			configFile: `
+[general]
+time_format = "Kitchen"
+time_zone = "UTC"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.times = timeFormat{layout: time.Kitchen, loc: time.UTC}
+				c.general.timeFormat = "Kitchen"
+				c.general.timeZone = "UTC"
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid time format",
+			configFile: `
+[general]
+time_format = "HH:MM:SS"
+`,
+			args: []string{"ls"},
+			want: config{
+				general: general{
+					timeFormat: "HH:MM:SS",
+					timeZone:   "Local",
+				},
+			},
+			expErr: timeFormatError{
+				key:    "general.time_format",
+				value:  "HH:MM:SS",
+				reason: `is not a layout of the reference time "Mon Jan 2 15:04:05 MST 2006"`,
+			},
+		},
+		{
+			name: "invalid time zone",
+			configFile: `
+[general]
+time_zone = "Mars/Olympus_Mons"
+`,
+			args: []string{"ls"},
+			want: config{
+				general: general{
+					timeZone: "Mars/Olympus_Mons",
+				},
+			},
+			expErr: timeFormatError{key: "general.time_zone", value: "Mars/Olympus_Mons", reason: "is not a known time zone"},
+		},
+		{
+			name: "keep for",
+			configFile: `
+[general]
+keep_for = "2h"
+`,
+			args: []string{"--keep-for", "90", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.keepFor = 90 * time.Second
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "negative keep for",
+			configFile: "",
+			args:       []string{"--keep-for", "-1h", "ls"},
+			want:       defaultConfig,
+			expErr:     durationError{key: "keep_for", value: "-1h", reason: "must not be negative"},
+		},
+		{
+			name: "compress after",
+			configFile: `
+[general]
+compress_after = 0
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.compressAfter = 0
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "negative compress after",
+			configFile: `
+[general]
+compress_after = -1
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.compressAfter = -1
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errCompressAfter,
+		},
+		{
+			name: "debug log",
+			configFile: `
+[general]
+log = "/tmp/viddy.log"
+log_level = "WARN"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.log = "/tmp/viddy.log"
+				c.general.logLevel = levelWarn
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid log level",
+			configFile: `
+[general]
+log_level = "verbose"
+`,
+			args: []string{"ls"},
+			want: config{
+				runtime: defaultConfig.runtime,
+				general: general{
+					log:      defaultDebugLogPath(),
+					timeZone: "Local",
+				},
+			},
+			expErr: errLogLevel,
+		},
+		{
+			name:       "pipe",
+			configFile: "",
+			args:       []string{"--pipe", "jq .items", "kubectl", "get", "pods", "-o", "json"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "kubectl", args: []string{"get", "pods", "-o", "json"}}}
+				c.general.pipe = "jq .items"
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "flash on change",
+			configFile: `
+[general]
+flash_duration = "1s"
+
+[color]
+flash = "fuchsia"
+`,
+			args: []string{"--flash-on-change", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.flashOnChange = true
+				c.general.flashDuration = time.Second
+				c.theme.flash = tcell.ColorFuchsia
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "zero flash duration",
+			configFile: `
+[general]
+flash_duration = "0"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: durationError{key: "flash_duration", value: "0", reason: "must be positive"},
+		},
+		{
+			name: "kill timeout",
+			configFile: `
+[general]
+kill_timeout = "0"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.killTimeout = 0
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "notify",
+			configFile: `
+[general]
+notify_cooldown = "5m"
+`,
+			args: []string{"--notify", "both", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.notify = NotifyModeBoth
+				c.general.notifyCooldown = 5 * time.Minute
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "invalid notify",
+			configFile: "",
+			args:       []string{"--notify", "always", "ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.notify = "always"
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errNotify,
+		},
+		{
+			name:       "negative notify cooldown",
+			configFile: "[general]\nnotify_cooldown = \"-1s\"",
+			args:       []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: durationError{key: "notify_cooldown", value: "-1s", reason: "must not be negative"},
+		},
+		{
+			name: "stream policy",
+			configFile: `
+[general]
+stream_grace = "10s"
+stream_policy = "cut"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.streamGrace = 10 * time.Second
+				c.general.streamPolicy = StreamPolicyCut
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "invalid stream policy",
+			configFile: "[general]\nstream_policy = \"follow\"",
+			args:       []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.streamPolicy = "follow"
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errStreamPolicy,
+		},
+		{
+			name: "negative kill timeout",
+			configFile: `
+[general]
+kill_timeout = "-1s"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: durationError{key: "kill_timeout", value: "-1s", reason: "must not be negative"},
+		},
+		{
+			name:       "exit code",
+			configFile: "",
+			args:       []string{"--exit-code", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.exitCode = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "unknown completion shell",
+			configFile: "",
+			args:       []string{"--completion", "tcsh"},
+			want:       config{},
+			expErr:     completionShellError{shell: "tcsh"},
+		},
+		{
+			name:       "invalid trigger",
+			configFile: "",
+			args:       []string{"--trigger", "(unclosed", "ls"},
+			want:       defaultConfig,
+			expErr: triggerError{pattern: "(unclosed", err: &syntax.Error{
+				Code: syntax.ErrMissingParen,
+				Expr: "(unclosed",
+			}},
+		},
+		{
+			name: "log file",
+			configFile: `
+[general]
+log_max_size = "10MB"
+`,
+			args: []string{"--log-file", "/tmp/viddy.log", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.logFile = "/tmp/viddy.log"
+				c.general.logMaxSize = 10 << 20
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid log max size",
+			configFile: `
+[general]
+log_max_size = "lots"
+`,
+			args:   []string{"ls"},
+			want:   defaultConfig,
+			expErr: sizeError{key: "general.log_max_size", value: "lots"},
+		},
+		{
+			name:       "backoff",
+			configFile: "",
+			args:       []string{"--backoff", "--backoff-max", "90s", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.backoff = true
+				c.general.backoffMax = 90 * time.Second
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid backoff max",
+			configFile: `
+[general]
+backoff_max = "-1m"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: durationError{key: "backoff_max", value: "-1m", reason: "must be positive"},
+		},
+		{
+			name:       "backoff with schedule",
+			configFile: "",
+			args:       []string{"--backoff", "--schedule", "@hourly", "ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.runtime.mode = ViddyIntervalModeSchedule
+				c.runtime.schedule, _ = parseSchedule("@hourly")
+				c.general.backoff = true
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errScheduleBackoff,
+		},
+		{
+			name:       "once",
+			configFile: "",
+			args:       []string{"--once", "-n", "5", "--precise", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.mode = ViddyIntervalModeOnce
+				c.runtime.interval = 5 * time.Second
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "adaptive",
+			configFile: `
+[general]
+adaptive_steady_runs = 5
+`,
+			args: []string{"--adaptive", "--adaptive-max", "10m", "-n", "1", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.mode = ViddyIntervalModeAdaptive
+				c.runtime.interval = time.Second
+				c.general.adaptiveMax = 10 * time.Minute
+				c.general.adaptiveSteadyRuns = 5
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "adaptive with precise",
+			configFile: "",
+			args:       []string{"--adaptive", "--precise", "ls"},
+			want: config{
+				runtime: runtimeConfig{
+					interval: 2 * time.Second,
+					mode:     ViddyIntervalModePrecise,
+					times:    timeFormat{loc: time.Local},
+				},
+				general: general{
+					timeZone: "Local",
+				},
+			},
+			expErr: errAdaptiveMode,
+		},
+		{
+			name:       "adaptive max shorter than the interval",
+			configFile: "",
+			args:       []string{"--adaptive", "--adaptive-max", "1s", "ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.runtime.mode = ViddyIntervalModeAdaptive
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: durationError{key: "adaptive_max", value: "1s", reason: "must not be shorter than the interval of 2s"},
+		},
+		{
+			name:       "side by side",
+			configFile: "",
+			args:       []string{"--side-by-side", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.sideBySide = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "no wrap",
+			configFile: "",
+			args:       []string{"--no-wrap", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.noWrap = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "show line age",
+			configFile: "[general]\nshow_line_age = true",
+			args:       []string{"kubectl", "get", "pods"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "kubectl", args: []string{"get", "pods"}}}
+				c.general.showLineAge = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "hooks around every run",
+			configFile: "",
+			args:       []string{"--before-each", "./login.sh", "--after-each", "echo done", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.beforeEach = "./login.sh"
+				c.general.afterEach = "echo done"
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "several commands",
+			configFile: "",
+			args:       []string{"-n", "5", "kubectl", "get", "pods", "---", "kubectl", "get", "events"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.interval = 5 * time.Second
+				c.runtime.commands = []commandSpec{
+					{cmd: "kubectl", args: []string{"get", "pods"}},
+					{cmd: "kubectl", args: []string{"get", "events"}},
+				}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "commands in panes side by side",
+			configFile: `
+[general]
+split = "vertical"
+`,
+			args: []string{"--cmd", "df -h", "--cmd", "free -m"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "df -h"}, {cmd: "free -m"}}
+				c.general.split = SplitVertical
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "empty command between separators",
+			configFile: "",
+			args:       []string{"ls", "---", "---", "date"},
+			want:       defaultConfig,
+			expErr:     errEmptyCommand,
+		},
+		{
+			name: "stderr",
+			configFile: `
+[general]
+stderr = "interleave"
+
+[color]
+stderr_text = "yellow"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.stderr = StderrModeInterleave
+				c.theme.stderrText = tcell.ColorYellow
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid stderr",
+			configFile: `
+[general]
+stderr = "merge"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.stderr = "merge"
+				c.general.split = ""
+				c.general.statusItems = nil
+				c.general.timeMachineStep = 0
+				c.general.stickyScroll = false
+				c.general.compressAfter = 0
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errStderr,
+		},
+		{
+			name:       "invalid split",
+			configFile: "",
+			args:       []string{"--split", "diagonal", "ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.split = "diagonal"
+				c.general.statusItems = nil
+				c.general.timeMachineStep = 0
+				c.general.stickyScroll = false
+				c.general.compressAfter = 0
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: errSplit,
+		},
+		{
+			name: "status items",
+			configFile: `
+[general]
+status_items = ["Diff", "running"]
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.statusItems = []StatusItem{StatusItemDiff, StatusItemRunning}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "no status items",
+			configFile: `
+[general]
+status_items = []
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.statusItems = []StatusItem{}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "unknown status item",
+			configFile: `
+[general]
+status_items = ["mode", "clock"]
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := uncolored(defaultConfig)
+				c.general.statusItems = nil
+				c.general.timeMachineStep = 0
+				c.general.stickyScroll = false
+				c.general.compressAfter = 0
+				c.general.tabWidth = 0
+				c.general.backoffMax = 0
+				c.general.adaptiveMax = 0
+				c.general.adaptiveSteadyRuns = 0
+				c.general.flashDuration = 0
+				c.general.killTimeout = 0
+				c.general.streamPolicy = ""
+				c.general.notifyCooldown = 0
+				c.general.playbackSpeed = playbackSpeed{}
+				c.general.scrubAcceleration = nil
+
+				return c
+			}(),
+			expErr: statusItemError{item: "clock"},
+		},
+		{
+			name:       "session with several commands",
+			configFile: "",
+			args:       []string{"--session", "/tmp/viddy.session", "ls", "---", "date"},
+			want: func() config {
+				c := defaultConfig
+				c.general.sessionFile = "/tmp/viddy.session"
+
+				return c
+			}(),
+			expErr: errSessionCommands,
+		},
+		{
+			name:       "batch",
+			configFile: "",
+			args:       []string{"--batch", "--batch-format", "json", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.batch = true
+				c.runtime.batchJSON = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "invalid batch format",
+			configFile: "",
+			args:       []string{"--batch", "--batch-format", "yaml", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.batch = true
+
+				return c
+			}(),
+			expErr: errBatchFormat,
+		},
+		{
+			name:       "batch with several commands",
+			configFile: "",
+			args:       []string{"--batch", "ls", "---", "date"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.batch = true
+
+				return c
+			}(),
+			expErr: errBatchCommands,
+		},
+		{
+			name:       "input",
+			configFile: "",
+			args:       []string{"--input", "query.sql", "--", "psql", "-f", "-"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "psql", args: []string{"-f", "-"}}}
+				c.runtime.input = "query.sql"
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "stdin capture",
+			configFile: "",
+			args:       []string{"--stdin-capture", "jq", ".summary"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "jq", args: []string{".summary"}}}
+				c.runtime.stdinCapture = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "input with stdin capture",
+			configFile: "",
+			args:       []string{"--input", "query.sql", "--stdin-capture", "cat"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.input = "query.sql"
+				c.runtime.stdinCapture = true
+
+				return c
+			}(),
+			expErr: errInputCapture,
+		},
+		{
+			name:       "input in a pty",
+			configFile: "",
+			args:       []string{"--pty", "--stdin-capture", "cat"},
+			want: func() config {
+				c := defaultConfig
+				c.general.pty = true
+				c.runtime.stdinCapture = true
+
+				return c
+			}(),
+			expErr: errInputPty,
+		},
+		{
+			name:       "listen",
+			configFile: "",
+			args:       []string{"--listen", "unix:/tmp/viddy.sock", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.listen = "/tmp/viddy.sock"
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "listen on a port",
+			configFile: "",
+			args:       []string{"--listen", "localhost:8080", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.listen = "localhost:8080"
+
+				return c
+			}(),
+			expErr: errListen,
+		},
+		{
+			name:       "pty",
+			configFile: "",
+			args:       []string{"--pty", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.pty = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "no color",
+			configFile: "",
+			args:       []string{"--no-color", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.stripANSI = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "strip ansi on config",
+			configFile: `
+[general]
+strip_ansi = true
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.stripANSI = true
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "mouse off on config",
+			configFile: `
+[general]
+mouse = false
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.mouse = false
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "no mouse",
+			configFile: "",
+			args:       []string{"--no-mouse", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.mouse = false
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "chdir",
+			configFile: "",
+			args:       []string{"--chdir", ".", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.runtime.chdir, _ = os.Getwd()
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "chdir to missing directory",
+			configFile: "",
+			args:       []string{"--chdir", "no-such-dir", "ls"},
+			want:       defaultConfig,
+			expErr:     chdirError{path: "no-such-dir", reason: "no such directory"},
+		},
+		{
+			name:       "chdir to file",
+			configFile: "",
+			args:       []string{"--chdir", "config.go", "ls"},
+			want:       defaultConfig,
+			expErr:     chdirError{path: "config.go", reason: "not a directory"},
+		},
+		{
+			name: "env",
+			configFile: `
+[general.env]
+pager = "less"
+editor = "vim"
+`,
+			args: []string{"--env", "KUBECONFIG=/tmp/kc", "--env", "PAGER=", "--env", "LANG", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.env = []envVar{
+					{key: "EDITOR", value: "vim"},
+					{key: "PAGER", value: "less"},
+					{key: "KUBECONFIG", value: "/tmp/kc"},
+					{key: "PAGER", value: ""},
+					{key: "LANG", unset: true},
+				}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "env list",
+			configFile: `
+[general]
+env = ["http_proxy=http://proxy:8080"]
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.env = []envVar{{key: "http_proxy", value: "http://proxy:8080"}}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "malformed env",
+			configFile: "",
+			args:       []string{"--env", "=value", "ls"},
+			want:       defaultConfig,
+			expErr:     envError{arg: "=value"},
+		},
+		{
+			name:       "shell options",
+			configFile: "",
+			args:       []string{"--shell-options", "--init-file '/path with space/rc'", "ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.shellOptions = []string{"--init-file", "/path with space/rc"}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name:       "broken shell options",
+			configFile: "",
+			args:       []string{"--shell-options", `-o "pipefail`, "ls"},
+			want:       defaultConfig,
+			expErr:     shellWordsError{column: 4, reason: "unterminated double quote"},
+		},
+		{
+			name: "key mapping",
+			configFile: `
+[keymap]
+toggle_timemachine = "a"
+timemachine_go_to_past = "Down"
+timemachine_go_to_future = "Up"
+timemachine_go_to_more_past = "Shift-Down"
+timemachine_go_to_more_future = "Shift-Up"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+
+				c.keymap.toggleTimeMachine = map[KeySequence]struct{}{{{
+					Key:  tcell.KeyRune,
+					Rune: 'a',
+				}}: {}}
+				c.keymap.goToPastOnTimeMachine = map[KeySequence]struct{}{{{
+					Key: tcell.KeyDown,
+				}}: {}}
+				c.keymap.goToFutureOnTimeMachine = map[KeySequence]struct{}{{{
+					Key: tcell.KeyUp,
+				}}: {}}
+				c.keymap.goToMorePastOnTimeMachine = map[KeySequence]struct{}{{{
+					Key:     tcell.KeyDown,
+					ModMask: tcell.ModShift,
+				}}: {}}
+				c.keymap.goToMoreFutureOnTimeMachine = map[KeySequence]struct{}{{{
+					Key:     tcell.KeyUp,
+					ModMask: tcell.ModShift,
+				}}: {}}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "conflicting key mapping",
+			configFile: `
+[keymap]
+toggle_diff = "q"
+quit = "q"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+
+				c.keymap.toggleDiff = map[KeySequence]struct{}{mustParseKeymap("q"): {}}
+				c.keymap.quit = map[KeySequence]struct{}{mustParseKeymap("q"): {}}
+				c.warnings = []string{"q is bound to both keymap.quit and keymap.toggle_diff"}
+
+				return c
+			}(),
+			expErr: nil,
+		},
+		{
+			name: "invalid key mapping",
+			configFile: `
+[keymap]
+timemachine_go_to_past = "Shfit-J"
+scroll_up = ["k", "Ctrl+Space"]
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.fallbacks = map[string]string{
+					"keymap.timemachine_go_to_past": `cannot parse key "Shfit-J": unknown modifier "Shfit"`,
+					"keymap.scroll_up": `cannot parse key "Ctrl+Space": unknown key "Ctrl+Space", ` +
+						`expected a single character or one of ` + keyNameList(),
+				}
+
+				return c
+			}(),
+			expErr: keymapErrors{
+				{key: "keymap.timemachine_go_to_past", err: parseKeyStrokeError{key: "Shfit-J", reason: `unknown modifier "Shfit"`}},
+				{key: "keymap.scroll_up", err: parseKeyStrokeError{
+					key: "Ctrl+Space", reason: `unknown key "Ctrl+Space", expected a single character or one of ` + keyNameList(),
+				}},
+			},
 		},
-	}
+		{
+			name: "unbinding keys",
+			configFile: `
+[keymap]
+toggle_timemachine = []
+toggle_diff = ""
+toggle_header = "none"
+quit = "None"
+`,
+			args: []string{"ls"},
+			want: func() config {
+				c := defaultConfig
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
 
-	tests := []struct {
-		name       string
-		configFile string
-		args       []string
-		want       config
-		expErr     error
-	}{
+				c.keymap.toggleTimeMachine = map[KeySequence]struct{}{}
+				c.keymap.toggleDiff = map[KeySequence]struct{}{}
+				c.keymap.toggleHeader = map[KeySequence]struct{}{}
+				c.keymap.quit = map[KeySequence]struct{}{}
+				c.warnings = []string{"keymap.quit is unbound, so viddy can only be stopped by a signal"}
+
+				return c
+			}(),
+			expErr: nil,
+		},
 		{
-			name:       "default",
-			configFile: "",
-			args:       []string{"ls"},
+			name: "key sequence mapping",
+			configFile: `
+[keymap]
+scroll_to_top = ["g g", "Home"]
+toggle_diff = [[",", "d"]]
+`,
+			args: []string{"ls"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.cmd = "ls"
-				c.runtime.args = []string{}
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+
+				c.keymap.scrollToTop = map[KeySequence]struct{}{mustParseKeymap("g g"): {}, mustParseKeymap("Home"): {}}
+				c.keymap.toggleDiff = map[KeySequence]struct{}{mustParseKeymap(", d"): {}}
 
 				return c
 			}(),
+			expErr: nil,
 		},
 		{
-			name:       "help",
-			configFile: "",
-			args:       []string{"-h"},
+			name: "color",
+			configFile: `
+[color]
+background = "black"
+text = "white"
+`,
+			args: []string{"ls"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.help = true
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+
+				c.theme.PrimitiveBackgroundColor = tcell.ColorBlack
+				c.theme.PrimaryTextColor = tcell.ColorWhite
 
 				return c
 			}(),
-			expErr: errNoCommand,
+			expErr: nil,
 		},
 		{
-			name:       "version",
-			configFile: "",
-			args:       []string{"-v"},
+			name: "profile",
+			configFile: `
+[profiles.pods]
+command = ["kubectl", "get", "pods"]
+interval = "5s"
+differences = true
+shell = "bash"
+`,
+			args: []string{"@pods", "-A"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.version = true
+				c.runtime.commands = []commandSpec{{cmd: "kubectl", args: []string{"get", "pods", "-A"}}}
+				c.runtime.interval = 5 * time.Second
+				c.general.differences = true
+				c.general.shell = "bash"
 
 				return c
 			}(),
-			expErr: errNoCommand,
+			expErr: nil,
 		},
 		{
-			name:       "interval in watch mode",
-			configFile: "",
-			args:       []string{"-n", "0.5", "ls"},
+			name: "profile overridden by flags",
+			configFile: `
+[profiles.disk]
+command = "df -h"
+interval = 10
+no_title = true
+precise = true
+`,
+			args: []string{"-n", "1s", "--no-title=false", "--profile", "disk"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.cmd = "ls"
-				c.runtime.args = []string{}
-				c.runtime.interval = 500 * time.Millisecond
+				c.runtime.commands = []commandSpec{{cmd: "df -h", args: []string{}}}
+				c.runtime.interval = time.Second
+				c.runtime.mode = ViddyIntervalModePrecise
 
 				return c
 			}(),
 			expErr: nil,
 		},
 		{
-			name:       "interval in go mode",
-			configFile: "",
-			args:       []string{"-n", "500ms", "ls"},
+			name: "unknown config key",
+			configFile: `
+[colour]
+background = "black"
+`,
+			args: []string{"ls"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.cmd = "ls"
-				c.runtime.args = []string{}
-				c.runtime.interval = 500 * time.Millisecond
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.warnings = []string{`unknown config key "colour.background", did you mean "color.background"?`}
 
 				return c
 			}(),
 			expErr: nil,
 		},
 		{
-			name: "set shell on config",
+			name: "unknown config key with strict config",
 			configFile: `
 [general]
-shell = "zsh"
+strict_config = true
+
+[colour]
+background = "black"
+`,
+			args: []string{"ls"},
+			want: config{
+				general: general{
+					strictConfig: true,
+				},
+			},
+			expErr: unknownConfigKeysError{{key: "colour.background", suggestion: "color.background"}},
+		},
+		{
+			name: "unknown profile",
+			configFile: `
+[profiles.pods]
+command = "kubectl get pods"
+
+[profiles.disk]
+command = "df -h"
+`,
+			args:   []string{"@nodes"},
+			want:   config{},
+			expErr: profileError{name: "nodes", available: []string{"disk", "pods"}},
+		},
+		{
+			name: "theme preset",
+			configFile: `
+[color]
+preset = "light"
+text = "navy"
 `,
 			args: []string{"ls"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.cmd = "ls"
-				c.runtime.args = []string{}
-				c.general.shell = "zsh"
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+
+				c.theme.Theme = tview.Theme{
+					PrimitiveBackgroundColor:    tcell.ColorWhite,
+					ContrastBackgroundColor:     tcell.ColorSilver,
+					MoreContrastBackgroundColor: tcell.ColorLightGray,
+					BorderColor:                 tcell.ColorGray,
+					TitleColor:                  tcell.ColorBlack,
+					GraphicsColor:               tcell.ColorBlack,
+					PrimaryTextColor:            tcell.ColorNavy,
+					SecondaryTextColor:          tcell.ColorNavy,
+					TertiaryTextColor:           tcell.ColorDarkGreen,
+					InverseTextColor:            tcell.ColorWhite,
+					ContrastSecondaryTextColor:  tcell.ColorDarkBlue,
+				}
+				c.theme.diffAdded = tcell.NewHexColor(0xc6efce)
+				c.theme.diffRemoved = tcell.NewHexColor(0xffc7ce)
+				c.theme.diffChangedBackground = tcell.NewHexColor(0xffeb9c)
+				c.theme.diffChangedForeground = tcell.ColorBlack
+				c.theme.diffMoved = tcell.NewHexColor(0xbdd7ee)
+				c.theme.stderrText = tcell.ColorMaroon
+				c.theme.errorText = tcell.ColorRed
+				c.theme.flash = tcell.NewHexColor(0xffeb9c)
 
 				return c
 			}(),
 			expErr: nil,
 		},
 		{
-			name: "key mapping",
+			name:       "unknown theme preset",
+			configFile: "",
+			args:       []string{"--theme", "solarized", "ls"},
+			want:       uncolored(defaultConfig),
+			expErr:     themePresetError{name: "solarized"},
+		},
+		{
+			name: "hex colors",
 			configFile: `
-[keymap]
-toggle_timemachine = "a"
-timemachine_go_to_past = "Down"
-timemachine_go_to_future = "Up"
-timemachine_go_to_more_past = "Shift-Down"
-timemachine_go_to_more_future = "Shift-Up"
+[general]
+force_truecolor = true
+
+[color]
+background = "#1e1e2e"
+text = "0xCDD6F4"
+border = "#abc"
+title = "DarkSlateGray"
 `,
 			args: []string{"ls"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.cmd = "ls"
-				c.runtime.args = []string{}
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
+				c.general.forceTruecolor = true
 
-				c.keymap.toggleTimeMachine = map[KeyStroke]struct{}{{
-					Key:  tcell.KeyRune,
-					Rune: 'a',
-				}: {}}
-				c.keymap.goToPastOnTimeMachine = map[KeyStroke]struct{}{{
-					Key: tcell.KeyDown,
-				}: {}}
-				c.keymap.goToFutureOnTimeMachine = map[KeyStroke]struct{}{{
-					Key: tcell.KeyUp,
-				}: {}}
-				c.keymap.goToMorePastOnTimeMachine = map[KeyStroke]struct{}{{
-					Key:     tcell.KeyDown,
-					ModMask: tcell.ModShift,
-				}: {}}
-				c.keymap.goToMoreFutureOnTimeMachine = map[KeyStroke]struct{}{{
-					Key:     tcell.KeyUp,
-					ModMask: tcell.ModShift,
-				}: {}}
+				c.theme.PrimitiveBackgroundColor = tcell.NewHexColor(0x1e1e2e)
+				c.theme.PrimaryTextColor = tcell.NewHexColor(0xcdd6f4)
+				c.theme.BorderColor = tcell.NewHexColor(0xaabbcc)
+				c.theme.TitleColor = tcell.ColorDarkSlateGray
 
 				return c
 			}(),
 			expErr: nil,
 		},
 		{
-			name: "color",
+			name: "diff colors",
 			configFile: `
 [color]
-background = "black"
-text = "white"
+diff_added = "blue"
+diff_removed = "red"
+diff_changed_background = "default"
+diff_changed_foreground = "gren"
 `,
 			args: []string{"ls"},
 			want: func() config {
 				c := defaultConfig
-				c.runtime.cmd = "ls"
-				c.runtime.args = []string{}
+				c.runtime.commands = []commandSpec{{cmd: "ls", args: []string{}}}
 
-				c.theme.PrimitiveBackgroundColor = tcell.ColorBlack
-				c.theme.PrimaryTextColor = tcell.ColorWhite
+				c.theme.diffAdded = tcell.ColorBlue
+				c.theme.diffRemoved = tcell.ColorRed
+				c.theme.diffChangedBackground = tcell.ColorDefault
+				c.warnings = []string{`color.diff_changed_foreground: unknown color "gren", using the default`}
+				c.fallbacks = map[string]string{"color.diff_changed_foreground": `unknown color "gren"`}
 
 				return c
 			}(),
@@ -217,11 +2398,121 @@ text = "white"
 	}
 }
 
+func TestNewConfigWithEnv(t *testing.T) {
+	configFile := `
+[general]
+shell = "zsh"
+max_concurrent_runs = 2
+
+[color]
+background = "white"
+text = "black"
+
+[keymap]
+toggle_timemachine = "Ctrl-T"
+toggle_diff = "e"
+`
+
+	newViper := func() *viper.Viper {
+		v := viper.New()
+		v.SetConfigType("toml")
+		assert.NoError(t, v.ReadConfig(bytes.NewBufferString(configFile)))
+
+		return v
+	}
+
+	t.Setenv("VIDDY_GENERAL_SHELL", "bash")
+	t.Setenv("VIDDY_COLOR_BACKGROUND", "black")
+	t.Setenv("VIDDY_KEYMAP_TOGGLE_TIMEMACHINE", "Ctrl-G g")
+
+	conf, err := newConfig(newViper(), []string{"ls"})
+	assert.NoError(t, err)
+
+	// env over config file
+	assert.Equal(t, "bash", conf.general.shell)
+	assert.Equal(t, tcell.ColorBlack, conf.theme.PrimitiveBackgroundColor)
+	assert.Equal(t, map[KeySequence]struct{}{mustParseKeymap("Ctrl-G g"): {}}, conf.keymap.toggleTimeMachine)
+
+	// config file without env
+	assert.Equal(t, 2, conf.general.maxConcurrentRuns)
+	assert.Equal(t, tcell.ColorBlack, conf.theme.PrimaryTextColor)
+	assert.Equal(t, map[KeySequence]struct{}{mustParseKeymap("e"): {}}, conf.keymap.toggleDiff)
+
+	// flag over env
+	conf, err = newConfig(newViper(), []string{"--shell", "fish", "ls"})
+	assert.NoError(t, err)
+	assert.Equal(t, "fish", conf.general.shell)
+
+	t.Setenv("VIDDY_KEYMAP_TOGGLE_TIMEMACHINE", "Shfit-T")
+
+	_, err = newConfig(newViper(), []string{"ls"})
+	assert.Equal(t, keymapErrors{{
+		key: "keymap.toggle_timemachine",
+		err: parseKeyStrokeError{key: "Shfit-T", reason: `unknown modifier "Shfit"`},
+	}}, err)
+}
+
+func TestNewConfigWithConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.toml")
+	assert.NoError(t, os.WriteFile(good, []byte("[general]\nshell = \"zsh\"\n"), 0o600))
+
+	broken := filepath.Join(dir, "broken.toml")
+	assert.NoError(t, os.WriteFile(broken, []byte("[general\nshell = \"zsh\"\n"), 0o600))
+
+	newViper := func() *viper.Viper {
+		v := viper.New()
+		v.SetConfigType("toml")
+		assert.NoError(t, v.ReadConfig(bytes.NewBufferString("[general]\nshell = \"fish\"\n")))
+
+		return v
+	}
+
+	conf, err := newConfig(newViper(), []string{"--config", good, "ls"})
+	assert.NoError(t, err)
+	assert.Equal(t, "zsh", conf.general.shell)
+	assert.Equal(t, good, conf.runtime.configFile)
+
+	_, err = newConfig(newViper(), []string{"--config", filepath.Join(dir, "missing.toml"), "ls"})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.IsType(t, configFileError{}, err)
+
+	_, err = newConfig(newViper(), []string{"--config", broken, "ls"})
+	assert.IsType(t, configFileError{}, err)
+}
+
+func TestNewConfigWithTitle(t *testing.T) {
+	newViper := func(configFile string) *viper.Viper {
+		v := viper.New()
+		v.SetConfigType("toml")
+		assert.NoError(t, v.ReadConfig(bytes.NewBufferString(configFile)))
+
+		return v
+	}
+
+	conf, err := newConfig(newViper(""), []string{"ls"})
+	assert.NoError(t, err)
+	assert.Nil(t, conf.general.titleTemplate)
+
+	conf, err = newConfig(newViper("[general]\ntitle_template = \"{command} on {host}\""), []string{"--title", "pods", "ls"})
+	assert.NoError(t, err)
+	assert.Equal(t, "pods", conf.general.titleTemplateText)
+	assert.Equal(t, "pods", renderTitle(conf.general.titleTemplate, titleFields{command: "ls"}))
+
+	conf, err = newConfig(newViper("[general]\ntitle_template = \"{command} on {host}\""), []string{"ls"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ls on box", renderTitle(conf.general.titleTemplate, titleFields{command: "ls", host: "box"}))
+
+	_, err = newConfig(newViper(""), []string{"--title", "{cmd}", "ls"})
+	assert.IsType(t, titleTemplateError{}, err)
+}
+
 func TestParseKeyStroke(t *testing.T) {
 	tests := []struct {
 		key     string
 		want    KeyStroke
-		wantErr bool
+		wantErr error
 	}{
 		{
 			key: "Shift-j",
@@ -263,12 +2554,270 @@ func TestParseKeyStroke(t *testing.T) {
 				ModMask: tcell.ModShift | tcell.ModCtrl,
 			},
 		},
+		{
+			key: "Shift-Ctrl-Up",
+			want: KeyStroke{
+				Key:     tcell.KeyUp,
+				Rune:    0,
+				ModMask: tcell.ModShift | tcell.ModCtrl,
+			},
+		},
+		{
+			key: "Ctrl--",
+			want: KeyStroke{
+				Key:     tcell.KeyRune,
+				Rune:    '-',
+				ModMask: tcell.ModCtrl,
+			},
+		},
+		{
+			key: "Ctrl-Space",
+			want: KeyStroke{
+				Key:     tcell.KeyNUL,
+				Rune:    0,
+				ModMask: tcell.ModCtrl,
+			},
+		},
+		{
+			key: "Alt-enter",
+			want: KeyStroke{
+				Key:     tcell.KeyEnter,
+				Rune:    0,
+				ModMask: tcell.ModAlt,
+			},
+		},
+		{
+			key: "PAGEDOWN",
+			want: KeyStroke{
+				Key:     tcell.KeyPgDn,
+				Rune:    0,
+				ModMask: 0,
+			},
+		},
+		{
+			key:     "",
+			wantErr: parseKeyStrokeError{key: "", reason: "empty key"},
+		},
+		{
+			key:     "Shfit-J",
+			wantErr: parseKeyStrokeError{key: "Shfit-J", reason: `unknown modifier "Shfit"`},
+		},
+		{
+			key: "Ctrl+Space",
+			wantErr: parseKeyStrokeError{
+				key: "Ctrl+Space", reason: `unknown key "Ctrl+Space", expected a single character or one of ` + keyNameList(),
+			},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.key, func(t *testing.T) {
 			got, err := ParseKeyStroke(tt.key)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatKeyStroke(t *testing.T) {
+	var keys []string
+
+	for _, n := range keyNames {
+		keys = append(keys, n.name, "Ctrl-"+n.name, "Alt-Shift-"+n.name)
+	}
+
+	keys = append(keys, "j", "Shift-J", "Ctrl-F", "Alt-Shift-X", "?", "Ctrl-Alt-Shift-Up")
+
+	for _, key := range keys {
+		key := key
+		t.Run(key, func(t *testing.T) {
+			stroke, err := ParseKeyStroke(key)
 			assert.NoError(t, err)
+
+			got, err := ParseKeyStroke(formatKeyStroke(stroke))
+			assert.NoError(t, err)
+			assert.Equal(t, stroke, got)
+		})
+	}
+
+	assert.Equal(t, "Enter", formatKeyStroke(mustParseKeymap("return")[0]))
+	assert.Equal(t, "Ctrl-Space", formatKeyStroke(mustParseKeymap("Ctrl-Space")[0]))
+	assert.Equal(t, "Shift-G", formatKeyStroke(mustParseKeymap("Shift-g")[0]))
+	assert.Equal(t, "Ctrl-F", formatKeyStroke(mustParseKeymap("Ctrl-F")[0]))
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		name string
+		want tcell.Color
+		ok   bool
+	}{
+		{name: "red", want: tcell.ColorRed, ok: true},
+		{name: "RebeccaPurple", want: tcell.ColorRebeccaPurple, ok: true},
+		{name: "#1e1e2e", want: tcell.NewHexColor(0x1e1e2e), ok: true},
+		{name: "#fff", want: tcell.NewHexColor(0xffffff), ok: true},
+		{name: "0x00FF00", want: tcell.NewHexColor(0x00ff00), ok: true},
+		{name: "#12345g", want: tcell.ColorDefault, ok: false},
+		{name: "0x123", want: tcell.ColorDefault, ok: false},
+		{name: "gren", want: tcell.ColorDefault, ok: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseColor(tt.name)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		str     string
+		want    []string
+		wantErr error
+	}{
+		{str: "", want: nil},
+		{str: "   ", want: nil},
+		{str: "-o pipefail -e", want: []string{"-o", "pipefail", "-e"}},
+		{str: "--init-file '/path with space/rc'", want: []string{"--init-file", "/path with space/rc"}},
+		{str: `--rc "/path with space/rc"`, want: []string{"--rc", "/path with space/rc"}},
+		{str: `a\ b`, want: []string{"a b"}},
+		{str: `"say \"hi\""`, want: []string{`say "hi"`}},
+		{str: `"keep \n"`, want: []string{`keep \n`}},
+		{str: `'it'\''s'`, want: []string{"it's"}},
+		{str: `'' ""`, want: []string{"", ""}},
+		{str: `pre'fix'"ed"`, want: []string{"prefixed"}},
+		{str: `'open`, wantErr: shellWordsError{column: 1, reason: "unterminated single quote"}},
+		{str: `a "open`, wantErr: shellWordsError{column: 3, reason: "unterminated double quote"}},
+		{str: `a\`, wantErr: shellWordsError{column: 2, reason: "trailing backslash"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.str, func(t *testing.T) {
+			got, err := splitShellWords(tt.str)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeyStrokeFromEvent(t *testing.T) {
+	tests := []struct {
+		key   string
+		event *tcell.EventKey
+	}{
+		{key: "j", event: tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone)},
+		{key: "Shift-G", event: tcell.NewEventKey(tcell.KeyRune, 'G', tcell.ModNone)},
+		{key: "Up", event: tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)},
+		{key: "Ctrl-F", event: tcell.NewEventKey(tcell.KeyCtrlF, 6, tcell.ModCtrl)},
+		{key: "Ctrl-C", event: tcell.NewEventKey(tcell.KeyRune, 3, tcell.ModNone)},
+		{key: "Ctrl-Space", event: tcell.NewEventKey(tcell.KeyRune, 0, tcell.ModNone)},
+		{key: "Space", event: tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone)},
+		{key: "Enter", event: tcell.NewEventKey(tcell.KeyRune, '\r', tcell.ModNone)},
+		{key: "Tab", event: tcell.NewEventKey(tcell.KeyRune, '\t', tcell.ModNone)},
+		{key: "Shift-Tab", event: tcell.NewEventKey(tcell.KeyBacktab, 0, tcell.ModNone)},
+		{key: "Backspace", event: tcell.NewEventKey(tcell.KeyRune, 0x7f, tcell.ModNone)},
+		{key: "Backspace", event: tcell.NewEventKey(tcell.KeyRune, '\b', tcell.ModNone)},
+		{key: "F5", event: tcell.NewEventKey(tcell.KeyF5, 0, tcell.ModNone)},
+		{key: "Ctrl-d", event: tcell.NewEventKey(tcell.KeyCtrlD, 4, tcell.ModCtrl)},
+		{key: "Ctrl-d", event: tcell.NewEventKey(tcell.KeyCtrlD, 4, tcell.ModNone)},
+		{key: "ctrl-d", event: tcell.NewEventKey(tcell.KeyRune, 4, tcell.ModNone)},
+		{key: "CTRL-D", event: tcell.NewEventKey(tcell.KeyRune, 'd', tcell.ModCtrl)},
+		{key: "Ctrl-Alt-d", event: tcell.NewEventKey(tcell.KeyRune, 4, tcell.ModAlt)},
+		{key: "Alt-Ctrl-d", event: tcell.NewEventKey(tcell.KeyCtrlD, 4, tcell.ModCtrl|tcell.ModAlt)},
+		{key: "Ctrl-Alt-d", event: tcell.NewEventKey(tcell.KeyRune, 'd', tcell.ModCtrl|tcell.ModAlt)},
+		{key: "Ctrl-Shift-d", event: tcell.NewEventKey(tcell.KeyRune, 'D', tcell.ModCtrl|tcell.ModShift)},
+		{key: "Alt-d", event: tcell.NewEventKey(tcell.KeyRune, 'd', tcell.ModAlt)},
+		{key: "Alt-Shift-d", event: tcell.NewEventKey(tcell.KeyRune, 'D', tcell.ModAlt)},
+		{key: "Shift-Alt-d", event: tcell.NewEventKey(tcell.KeyRune, 'D', tcell.ModAlt|tcell.ModShift)},
+		{key: "Alt-1", event: tcell.NewEventKey(tcell.KeyRune, '1', tcell.ModAlt)},
+		{key: "Ctrl-\\", event: tcell.NewEventKey(tcell.KeyCtrlBackslash, 28, tcell.ModCtrl)},
+		{key: "Ctrl-]", event: tcell.NewEventKey(tcell.KeyRune, 29, tcell.ModNone)},
+		{key: "Ctrl-_", event: tcell.NewEventKey(tcell.KeyCtrlUnderscore, 31, tcell.ModCtrl)},
+		{key: "Ctrl-Alt-Space", event: tcell.NewEventKey(tcell.KeyRune, 0, tcell.ModAlt)},
+		{key: "Ctrl-I", event: tcell.NewEventKey(tcell.KeyTab, 9, tcell.ModCtrl)},
+		{key: "Alt-Tab", event: tcell.NewEventKey(tcell.KeyTab, 9, tcell.ModAlt)},
+		{key: "Alt-Enter", event: tcell.NewEventKey(tcell.KeyEnter, 13, tcell.ModAlt)},
+		{key: "Alt-Backspace", event: tcell.NewEventKey(tcell.KeyBackspace, 8, tcell.ModAlt)},
+		{key: "Alt-Backspace", event: tcell.NewEventKey(tcell.KeyBackspace2, 0x7f, tcell.ModAlt)},
+		{key: "Ctrl-Left", event: tcell.NewEventKey(tcell.KeyLeft, 0, tcell.ModCtrl)},
+		{key: "Alt-Up", event: tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModAlt)},
+		{key: "Shift-Down", event: tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModShift)},
+		{key: "Ctrl-Shift-Up", event: tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModCtrl|tcell.ModShift)},
+		{key: "Shift-Ctrl-Up", event: tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModShift|tcell.ModCtrl)},
+		{key: "Ctrl-Alt-Right", event: tcell.NewEventKey(tcell.KeyRight, 0, tcell.ModAlt|tcell.ModCtrl)},
+		{key: "Alt-Shift-Ctrl-Left", event: tcell.NewEventKey(tcell.KeyLeft, 0, tcell.ModAlt|tcell.ModShift|tcell.ModCtrl)},
+		{key: "Ctrl-PgDn", event: tcell.NewEventKey(tcell.KeyPgDn, 0, tcell.ModCtrl)},
+		{key: "Alt-PgUp", event: tcell.NewEventKey(tcell.KeyPgUp, 0, tcell.ModAlt)},
+		{key: "Ctrl-Home", event: tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModCtrl)},
+		{key: "Shift-End", event: tcell.NewEventKey(tcell.KeyEnd, 0, tcell.ModShift)},
+		{key: "Alt-Delete", event: tcell.NewEventKey(tcell.KeyDelete, 0, tcell.ModAlt)},
+		{key: "Ctrl-F5", event: tcell.NewEventKey(tcell.KeyF5, 0, tcell.ModCtrl)},
+		{key: "Alt-Shift-Tab", event: tcell.NewEventKey(tcell.KeyBacktab, 0, tcell.ModAlt)},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, mustParseKeymap(tt.key)[0], keyStrokeFromEvent(tt.event))
+		})
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "2", want: 2 * time.Second},
+		{s: "0.5", want: 500 * time.Millisecond},
+		{s: "500ms", want: 500 * time.Millisecond},
+		{s: "1m30s", want: 90 * time.Second},
+		{s: "-5", want: -5 * time.Second},
+		{s: "2ss", wantErr: true},
+		{s: "", wantErr: true},
+		{s: "inf", wantErr: true},
+		{s: "NaN", wantErr: true},
+		{s: "1e300", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseInterval("interval", tt.s)
+			if tt.wantErr {
+				assert.Equal(t, durationError{key: "interval", value: tt.s}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.EqualError(t, durationError{key: "interval", value: "2ss"},
+		`interval: cannot parse "2ss", use seconds such as "2" or "0.5", or a duration such as "500ms" or "1m30s"`)
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{s: "1024", want: 1024},
+		{s: "500KB", want: 500 << 10},
+		{s: "10mb", want: 10 << 20},
+		{s: "1.5G", want: 3 << 29},
+		{s: "2 MB", want: 2 << 20},
+		{s: "100B", want: 100},
+		{s: "lots", wantErr: true},
+		{s: "-1MB", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseSize(tt.s)
+			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.want, got)
 		})
 	}
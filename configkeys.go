@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// generalKeys are the keys of the general section. Profiles take them as well.
+var generalKeys = []string{
+	"adaptive_max",
+	"adaptive_steady_runs",
+	"after_each",
+	"backoff",
+	"backoff_max",
+	"before_each",
+	"changes_context",
+	"changes_only",
+	"compress_after",
+	"confirm_destructive",
+	"debug",
+	"diff_granularity",
+	"diff_normalize",
+	"differences",
+	"differences_against",
+	"env",
+	"flash_duration",
+	"flash_on_change",
+	"force_truecolor",
+	"highlight",
+	"history_file",
+	"keep_for",
+	"kill_timeout",
+	"log",
+	"log_file",
+	"log_level",
+	"log_max_size",
+	"max_bytes",
+	"max_concurrent_runs",
+	"max_lines",
+	"mouse",
+	"no_title",
+	"no_wrap",
+	"notify",
+	"notify_cooldown",
+	"on_change",
+	"overlap_policy",
+	"pipe",
+	"playback_speed",
+	"poll_in_timemachine",
+	"poll_while_suspended",
+	"pty",
+	"scroll_off",
+	"scrub_acceleration",
+	"search_ignore_case",
+	"session_file",
+	"shell",
+	"shell_options",
+	"show_host",
+	"show_line_age",
+	"side_by_side",
+	"show_change_log",
+	"show_snapshot_list",
+	"show_timeline",
+	"split",
+	"status_items",
+	"stderr",
+	"sticky_scroll",
+	"strip_ansi",
+	"ssh",
+	"strict_config",
+	"stream_grace",
+	"stream_policy",
+	"tab_width",
+	"time_format",
+	"time_zone",
+	"timemachine_step",
+	"title_template",
+}
+
+// colorKeys are the keys of the color section.
+var colorKeys = []string{
+	"background",
+	"border",
+	"clip_marker",
+	"contrast_background",
+	"contrast_secondary_text",
+	"diff_added",
+	"diff_changed_background",
+	"diff_changed_foreground",
+	"diff_moved",
+	"diff_removed",
+	"error_text",
+	"flash",
+	"graphics",
+	"inverse_text",
+	"more_contrast_background",
+	"preset",
+	"secondary_text",
+	"stderr_text",
+	"tertiary_text",
+	"text",
+	"title",
+}
+
+// knownConfigKeys returns the keys of the config file outside of profiles.
+func knownConfigKeys() map[string]struct{} {
+	known := map[string]struct{}{}
+
+	for _, key := range generalKeys {
+		known["general."+key] = struct{}{}
+	}
+
+	for _, key := range colorKeys {
+		known["color."+key] = struct{}{}
+	}
+
+	var k keymapping
+	for _, b := range append(k.generalBindings(), k.timeMachineBindings()...) {
+		known[b.name] = struct{}{}
+	}
+
+	return known
+}
+
+// isKnownKey tells whether the key or a table containing it is known, since
+// tables such as general.env have keys of their own.
+func isKnownKey(known map[string]struct{}, key string) bool {
+	for {
+		if _, ok := known[key]; ok {
+			return true
+		}
+
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return false
+		}
+
+		key = key[:i]
+	}
+}
+
+type unknownConfigKey struct {
+	key        string
+	suggestion string
+}
+
+func (k unknownConfigKey) String() string {
+	if k.suggestion == "" {
+		return fmt.Sprintf("unknown config key %q", k.key)
+	}
+
+	return fmt.Sprintf("unknown config key %q, did you mean %q?", k.key, k.suggestion)
+}
+
+type unknownConfigKeysError []unknownConfigKey
+
+func (e unknownConfigKeysError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("unknown keys in config file:\n")
+
+	for _, k := range e {
+		fmt.Fprintf(&b, "  %s\n", k)
+	}
+
+	b.WriteString("remove them, or set general.strict_config = false to only warn about them")
+
+	return b.String()
+}
+
+// findUnknownConfigKeys returns the keys of the config file which viddy
+// does not read, sorted.
+func findUnknownConfigKeys(v *viper.Viper) []unknownConfigKey {
+	known := knownConfigKeys()
+
+	profileKnown := map[string]struct{}{}
+	for key := range profileKeys {
+		profileKnown[key] = struct{}{}
+	}
+
+	for _, key := range generalKeys {
+		profileKnown[key] = struct{}{}
+	}
+
+	ruleKnown := map[string]struct{}{}
+	for key := range profileKnown {
+		if key != "command" {
+			ruleKnown[key] = struct{}{}
+		}
+	}
+
+	for key := range ruleKeys {
+		ruleKnown[key] = struct{}{}
+	}
+
+	keys := v.AllKeys()
+	sort.Strings(keys)
+
+	var unknown []unknownConfigKey
+
+	for _, key := range keys {
+		if key == "rules" {
+			unknown = append(unknown, findUnknownRuleKeys(v, ruleKnown)...)
+
+			continue
+		}
+
+		if strings.HasPrefix(key, "profiles.") {
+			parts := strings.SplitN(key, ".", 3)
+			if len(parts) < 3 || isKnownKey(profileKnown, parts[2]) {
+				continue
+			}
+
+			prefix := parts[0] + "." + parts[1] + "."
+			unknown = append(unknown, unknownConfigKey{
+				key:        key,
+				suggestion: suggestKey(parts[2], profileKnown, prefix),
+			})
+
+			continue
+		}
+
+		if !isKnownKey(known, key) {
+			unknown = append(unknown, unknownConfigKey{key: key, suggestion: suggestKey(key, known, "")})
+		}
+	}
+
+	return unknown
+}
+
+// findUnknownRuleKeys returns the keys of the rules which viddy does not
+// read, named after the number of their rule. Malformed rules are left to
+// getRules.
+func findUnknownRuleKeys(v *viper.Viper, known map[string]struct{}) []unknownConfigKey {
+	entries, err := ruleEntries(v)
+	if err != nil {
+		return nil
+	}
+
+	var unknown []unknownConfigKey
+
+	for i, entry := range entries {
+		keys := make([]string, 0, len(entry))
+		for key := range entry {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		prefix := fmt.Sprintf("rules.%d.", i+1)
+
+		for _, key := range keys {
+			if !isKnownKey(known, key) {
+				unknown = append(unknown, unknownConfigKey{key: prefix + key, suggestion: suggestKey(key, known, prefix)})
+			}
+		}
+	}
+
+	return unknown
+}
+
+// maxSuggestionDistance is how many edits away a key may be to be suggested.
+const maxSuggestionDistance = 3
+
+// suggestKey returns the closest of the known keys with the prefix, or "" if
+// none is close enough.
+func suggestKey(key string, known map[string]struct{}, prefix string) string {
+	candidates := make([]string, 0, len(known))
+	for k := range known {
+		candidates = append(candidates, k)
+	}
+
+	sort.Strings(candidates)
+
+	best, bestDistance := "", maxSuggestionDistance+1
+
+	for _, candidate := range candidates {
+		if d := editDistance(key, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	if best == "" {
+		return ""
+	}
+
+	return prefix + best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func minInt(first int, rest ...int) int {
+	m := first
+
+	for _, n := range rest {
+		if n < m {
+			m = n
+		}
+	}
+
+	return m
+}
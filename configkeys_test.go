@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "color", b: "color", want: 0},
+		{a: "colour", b: "color", want: 1},
+		{a: "", b: "abc", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "timemachine_goto_past", b: "timemachine_go_to_past", want: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, editDistance(tt.a, tt.b))
+			assert.Equal(t, tt.want, editDistance(tt.b, tt.a))
+		})
+	}
+}
+
+func TestFindUnknownConfigKeys(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("toml")
+	assert.NoError(t, v.ReadConfig(bytes.NewBufferString(`
+theme = "dark"
+
+[general]
+shell = "zsh"
+shel_options = "-l"
+env = { KUBECONFIG = "/tmp/kc" }
+
+[colour]
+background = "black"
+
+[keymap]
+timemachine_goto_past = "Down"
+quit = "q"
+
+[profiles.pods]
+command = "kubectl get pods"
+intervall = "5s"
+pty = true
+
+[[rules]]
+match = "^kubectl"
+differences = true
+
+[[rules]]
+match = "^aws"
+command = "aws s3 ls"
+intervall = "10s"
+`)))
+
+	assert.Equal(t, []unknownConfigKey{
+		{key: "colour.background", suggestion: "color.background"},
+		{key: "general.shel_options", suggestion: "general.shell_options"},
+		{key: "keymap.timemachine_goto_past", suggestion: "keymap.timemachine_go_to_past"},
+		{key: "profiles.pods.intervall", suggestion: "profiles.pods.interval"},
+		{key: "rules.2.command"},
+		{key: "rules.2.intervall", suggestion: "rules.2.interval"},
+		{key: "theme"},
+	}, findUnknownConfigKeys(v))
+}
+
+func TestKnownConfigKeys(t *testing.T) {
+	known := knownConfigKeys()
+
+	var k keymapping
+	assert.Equal(t, len(generalKeys)+len(colorKeys)+len(k.generalBindings())+len(k.timeMachineBindings()), len(known))
+
+	for _, preset := range themePresets {
+		for key := range preset {
+			assert.Contains(t, known, "color."+key)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configSources tells where the config comes from for the debug log: the
+// config file, the profile, the rule, the flags, and for every key of the
+// general section the places which set it, the first one taking precedence.
+type configSources struct {
+	configFile string
+	profile    string
+	rules      int
+	rule       *rule
+	flags      []string
+	keys       map[string][]string
+}
+
+// newConfigSources looks at the sources before the profile and the rule are
+// merged into the config, which tells the keys of the config file apart.
+func newConfigSources(v *viper.Viper, flagSet *pflag.FlagSet, profileName string, prof profile,
+	rules int, r *rule,
+) *configSources {
+	s := &configSources{
+		configFile: v.ConfigFileUsed(),
+		profile:    profileName,
+		rules:      rules,
+		rule:       r,
+		keys:       map[string][]string{},
+	}
+
+	flagSet.Visit(func(f *pflag.Flag) {
+		s.flags = append(s.flags, "--"+f.Name)
+	})
+
+	// InConfig only knows the sections themselves.
+	var inFile map[string]interface{}
+	if v.InConfig("general") {
+		inFile = cast.ToStringMap(v.Get("general"))
+	}
+
+	for _, key := range generalKeys {
+		var from []string
+
+		if f := flagSet.Lookup(strings.ReplaceAll(key, "_", "-")); f != nil && f.Changed {
+			from = append(from, "flag --"+f.Name)
+		}
+
+		env := "VIDDY_GENERAL_" + strings.ToUpper(key)
+		if _, ok := os.LookupEnv(env); ok {
+			from = append(from, "env "+env)
+		}
+
+		if _, ok := prof[key]; ok {
+			from = append(from, "profile @"+profileName)
+		}
+
+		if r != nil {
+			if _, ok := r.values[key]; ok {
+				from = append(from, r.String())
+			}
+		}
+
+		if _, ok := inFile[key]; ok {
+			from = append(from, "config file")
+		}
+
+		if len(from) > 0 {
+			s.keys[key] = from
+		}
+	}
+
+	return s
+}
+
+// records returns the records of the sources, with the values the config
+// ended up with.
+func (s *configSources) records(v *viper.Viper) []logRecord {
+	configFile := s.configFile
+	if configFile == "" {
+		configFile = "none"
+	}
+
+	records := []logRecord{{level: levelInfo, msg: "config file", attrs: []interface{}{"path", configFile}}}
+
+	if s.profile != "" {
+		records = append(records, logRecord{level: levelInfo, msg: "profile", attrs: []interface{}{"name", s.profile}})
+	}
+
+	if rule := describeRule(s.rules, s.rule); rule != "" {
+		records = append(records, logRecord{level: levelInfo, msg: "rules", attrs: []interface{}{"result", rule}})
+	}
+
+	if len(s.flags) > 0 {
+		records = append(records, logRecord{level: levelDebug, msg: "flags", attrs: []interface{}{"given", strings.Join(s.flags, " ")}})
+	}
+
+	for _, key := range generalKeys {
+		from, ok := s.keys[key]
+		if !ok {
+			continue
+		}
+
+		attrs := []interface{}{"key", "general." + key, "value", v.Get("general." + key), "from", from[0]}
+		if len(from) > 1 {
+			attrs = append(attrs, "overrides", strings.Join(from[1:], ", "))
+		}
+
+		records = append(records, logRecord{level: levelDebug, msg: "config value", attrs: attrs})
+	}
+
+	return records
+}
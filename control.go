@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// controlRecord is the latest run as the control server reports it.
+type controlRecord struct {
+	ID int64 `json:"id"`
+	batchRecord
+}
+
+// controlHistory is the length of the history and its changes, most
+// recent first, as the control server reports them.
+type controlHistory struct {
+	Length  int           `json:"length"`
+	Changes []changeEntry `json:"changes"`
+}
+
+// controlTarget is what the control server reads and drives. Its methods
+// must return right away, since the server never waits for the UI.
+type controlTarget interface {
+	historyLength() int
+	changeLogEntries() []changeEntry
+	setPaused(paused bool)
+	runNow()
+}
+
+// controlServer serves the latest run and a few verbs over HTTP on a unix
+// socket, which only the user may connect to:
+//
+//	GET  /snapshot  the latest run as JSON, like --batch-format json writes it
+//	GET  /history   the number of snapshots in the history and the change log
+//	POST /pause     stop starting runs, like keymap.toggle_pause
+//	POST /resume    start them again
+//	POST /run       run the command now, besides the interval
+type controlServer struct {
+	target controlTarget
+	server *http.Server
+
+	mu     sync.Mutex
+	latest *controlRecord
+}
+
+// controlReadTimeout limits how long a client may take to send its request.
+const controlReadTimeout = 5 * time.Second
+
+// listenControl starts serving on the unix socket at the path, which is
+// removed again by close.
+func listenControl(path string, target controlTarget) (*controlServer, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = l.Close()
+
+		return nil, err
+	}
+
+	c := &controlServer{target: target}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/snapshot", c.handleSnapshot)
+	mux.HandleFunc("/history", c.handleHistory)
+	mux.HandleFunc("/pause", c.handleVerb(func() { target.setPaused(true) }))
+	mux.HandleFunc("/resume", c.handleVerb(func() { target.setPaused(false) }))
+	mux.HandleFunc("/run", c.handleVerb(target.runNow))
+
+	c.server = &http.Server{Handler: mux, ReadHeaderTimeout: controlReadTimeout}
+
+	go func() {
+		_ = c.server.Serve(l)
+	}()
+
+	return c, nil
+}
+
+// publish records the run if it is newer than the latest one. A nil server
+// ignores it.
+func (c *controlServer) publish(s *Snapshot, changed bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.latest != nil && !s.start.After(c.latest.Time) {
+		return
+	}
+
+	c.latest = &controlRecord{ID: s.id, batchRecord: newBatchRecord(s, changed)}
+}
+
+// close stops serving, which removes the socket.
+func (c *controlServer) close() error {
+	if c == nil {
+		return nil
+	}
+
+	return c.server.Close()
+}
+
+func (c *controlServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	c.mu.Lock()
+	latest := c.latest
+	c.mu.Unlock()
+
+	if latest == nil {
+		http.Error(w, "no run finished yet", http.StatusNotFound)
+
+		return
+	}
+
+	writeControlJSON(w, latest)
+}
+
+func (c *controlServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	writeControlJSON(w, controlHistory{Length: c.target.historyLength(), Changes: c.target.changeLogEntries()})
+}
+
+func (c *controlServer) handleVerb(run func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		run()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeControlJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(b, '\n'))
+}
+
+func (v *Viddy) historyLength() int {
+	v.RLock()
+	defer v.RUnlock()
+
+	return len(v.idList)
+}
+
+func (v *Viddy) changeLogEntries() []changeEntry {
+	return v.changeLog.list()
+}
+
+// setPaused pauses or resumes the runs of the pane from another goroutine.
+func (v *Viddy) setPaused(paused bool) {
+	go v.app.QueueUpdateDraw(func() { v.pauseCommand(paused) })
+}
+
+// runNow asks for a run right away, unless one was asked for already.
+func (v *Viddy) runNow() {
+	select {
+	case v.runNowQueue <- struct{}{}:
+	default:
+	}
+}
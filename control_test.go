@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeControlTarget struct {
+	length  int
+	changes []changeEntry
+	paused  []bool
+	runs    int
+}
+
+func (f *fakeControlTarget) historyLength() int              { return f.length }
+func (f *fakeControlTarget) changeLogEntries() []changeEntry { return f.changes }
+func (f *fakeControlTarget) setPaused(paused bool)           { f.paused = append(f.paused, paused) }
+func (f *fakeControlTarget) runNow()                         { f.runs++ }
+
+func TestControlServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "viddy.sock")
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	target := &fakeControlTarget{length: 3, changes: []changeEntry{{ID: 2000, Time: start, Added: 1, Exit: 1}}}
+
+	c, err := listenControl(path, target)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+
+	do := func(method, endpoint string) (int, string) {
+		req, err := http.NewRequestWithContext(context.Background(), method, "http://viddy"+endpoint, nil)
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp.StatusCode, string(b)
+	}
+
+	code, _ := do(http.MethodGet, "/snapshot")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	c.publish(&Snapshot{id: 2000, start: start, end: start.Add(time.Second), result: []byte("pod-a Running\n")}, true)
+	c.publish(&Snapshot{id: 1000, start: start.Add(-time.Minute), result: []byte("older\n")}, false)
+
+	code, body := do(http.MethodGet, "/snapshot")
+	assert.Equal(t, http.StatusOK, code)
+
+	var got controlRecord
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, int64(2000), got.ID)
+	assert.True(t, got.Changed)
+	assert.Equal(t, "pod-a Running\n", got.Output)
+
+	_, body = do(http.MethodGet, "/history")
+	assert.JSONEq(t, `{"length":3,"changes":[{"id":2000,"time":"2021-09-01T12:00:00Z",`+
+		`"added":1,"removed":0,"modified":0,"exit_before":0,"exit":1}]}`, body)
+
+	for _, endpoint := range []string{"/pause", "/resume", "/run"} {
+		code, _ = do(http.MethodPost, endpoint)
+		assert.Equal(t, http.StatusNoContent, code, endpoint)
+	}
+
+	assert.Equal(t, []bool{true, false}, target.paused)
+	assert.Equal(t, 1, target.runs)
+
+	code, _ = do(http.MethodGet, "/run")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	require.NoError(t, c.close())
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
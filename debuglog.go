@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adrg/xdg"
+)
+
+var errLogLevel = errors.New(`log_level must be one of "debug", "info", "warn" or "error"`)
+
+// logLevel is how important a record of the debug log is.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = map[logLevel]string{
+	levelDebug: "debug",
+	levelInfo:  "info",
+	levelWarn:  "warn",
+	levelError: "error",
+}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for level, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+
+	return levelDebug, errLogLevel
+}
+
+// defaultDebugLogPath is where the debug log goes unless general.log says
+// otherwise.
+func defaultDebugLogPath() string {
+	return filepath.Join(xdg.StateHome, "viddy", "debug.log")
+}
+
+// logRecord is an event with attributes, which are pairs of keys and values.
+type logRecord struct {
+	level logLevel
+	msg   string
+	attrs []interface{}
+}
+
+// logBufferSize is how many records wait to be written before the next are
+// dropped.
+const logBufferSize = 1024
+
+// debugLog writes records to a file in the background, so that logging never
+// waits for the disk. Records which do not fit into the buffer are dropped
+// and counted instead. A nil debugLog logs nothing.
+type debugLog struct {
+	path  string
+	level logLevel
+
+	mu      sync.RWMutex
+	closed  bool
+	records chan []byte
+	done    chan struct{}
+	dropped int64
+
+	now func() time.Time
+}
+
+// openDebugLog appends the records of the level and above to the file,
+// creating it and its directory if needed.
+func openDebugLog(path string, level logLevel) (*debugLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &debugLog{
+		path:    path,
+		level:   level,
+		records: make(chan []byte, logBufferSize),
+		done:    make(chan struct{}),
+		now:     time.Now,
+	}
+
+	go func() {
+		defer close(l.done)
+
+		for b := range l.records {
+			_, _ = f.Write(b)
+		}
+
+		if dropped := atomic.LoadInt64(&l.dropped); dropped > 0 {
+			_, _ = f.Write(formatLogRecord(l.now(), logRecord{
+				level: levelWarn,
+				msg:   "dropped records",
+				attrs: []interface{}{"count", dropped},
+			}))
+		}
+
+		_ = f.Close()
+	}()
+
+	return l, nil
+}
+
+// enabled tells whether records of the level are written.
+func (l *debugLog) enabled(level logLevel) bool {
+	return l != nil && level >= l.level
+}
+
+// log queues the record, unless its level is below that of the log. It
+// returns the formatted record, or nil if it is left out.
+func (l *debugLog) log(r logRecord) []byte {
+	if !l.enabled(r.level) {
+		return nil
+	}
+
+	b := formatLogRecord(l.now(), r)
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.closed {
+		return b
+	}
+
+	select {
+	case l.records <- b:
+	default:
+		atomic.AddInt64(&l.dropped, 1)
+	}
+
+	return b
+}
+
+// Close writes the records which are left and closes the file. Records
+// logged afterwards are dropped.
+func (l *debugLog) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.records)
+	}
+	l.mu.Unlock()
+
+	<-l.done
+
+	return nil
+}
+
+// formatLogRecord writes the record as a line of key=value pairs, such as
+//
+//	time=2024-03-01T14:35:00.000Z level=info msg="run finished" id=3 exit_code=0
+func formatLogRecord(t time.Time, r logRecord) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("time=")
+	b.WriteString(t.Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteString(" level=")
+	b.WriteString(r.level.String())
+	b.WriteString(" msg=")
+	b.WriteString(logValue(r.msg))
+
+	for i := 0; i < len(r.attrs); i += 2 {
+		key := fmt.Sprint(r.attrs[i])
+
+		var value interface{} = "!MISSING"
+		if i+1 < len(r.attrs) {
+			value = r.attrs[i+1]
+		}
+
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(logValue(value))
+	}
+
+	b.WriteByte('\n')
+
+	return b.Bytes()
+}
+
+// logValue quotes the value if it would not read as a single one.
+func logValue(value interface{}) string {
+	var s string
+
+	switch v := value.(type) {
+	case time.Duration:
+		s = v.String()
+	case time.Time:
+		s = v.Format("2006-01-02T15:04:05.000Z07:00")
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.IndexFunc(s, needsQuote) >= 0 {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+func needsQuote(r rune) bool {
+	return r <= ' ' || r == '"' || r == '=' || r == '\\' || r == 0x7f
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatLogRecord(t *testing.T) {
+	at := time.Date(2024, 3, 1, 14, 35, 0, 0, time.UTC)
+
+	got := formatLogRecord(at, logRecord{
+		level: levelInfo,
+		msg:   "run finished",
+		attrs: []interface{}{
+			"id", 3,
+			"duration", 1500 * time.Millisecond,
+			"error", errors.New(`exit status 1`),
+			"line", `say "hi"`,
+			"empty", "",
+			"odd",
+		},
+	})
+
+	assert.Equal(t, `time=2024-03-01T14:35:00.000Z level=info msg="run finished" id=3 duration=1.5s `+
+		`error="exit status 1" line="say \"hi\"" empty="" odd=!MISSING`+"\n", string(got))
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for _, name := range []string{"debug", "info", "warn", "error"} {
+		level, err := parseLogLevel(strings.ToUpper(name))
+		assert.NoError(t, err)
+		assert.Equal(t, name, level.String())
+	}
+
+	_, err := parseLogLevel("verbose")
+	assert.Equal(t, errLogLevel, err)
+}
+
+func TestDebugLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "viddy", "debug.log")
+
+	l, err := openDebugLog(path, levelInfo)
+	assert.NoError(t, err)
+
+	l.now = func() time.Time { return time.Date(2024, 3, 1, 14, 35, 0, 0, time.UTC) }
+
+	assert.Nil(t, l.log(logRecord{level: levelDebug, msg: "key"}))
+	assert.NotNil(t, l.log(logRecord{level: levelWarn, msg: "tick skipped"}))
+	assert.NoError(t, l.Close())
+
+	// Records which come after the log is closed are left out.
+	l.log(logRecord{level: levelError, msg: "late"})
+
+	got, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "time=2024-03-01T14:35:00.000Z level=warn msg=\"tick skipped\"\n", string(got))
+
+	var nilLog *debugLog
+	assert.Nil(t, nilLog.log(logRecord{level: levelError, msg: "nothing"}))
+	assert.NoError(t, nilLog.Close())
+}
+
+func TestDebugLogDropsWhenFull(t *testing.T) {
+	l := &debugLog{
+		records: make(chan []byte, 1),
+		done:    make(chan struct{}),
+		now:     time.Now,
+	}
+
+	l.log(logRecord{level: levelInfo, msg: "first"})
+	l.log(logRecord{level: levelInfo, msg: "second"})
+
+	assert.Equal(t, int64(1), l.dropped)
+}
+
+func TestNewConfigStartupLog(t *testing.T) {
+	t.Setenv("VIDDY_GENERAL_SHELL", "zsh")
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	assert.NoError(t, v.ReadConfig(bytes.NewBufferString(`
+[general]
+shell = "bash"
+tab_width = 4
+
+[keymap]
+toggle_diff = "Ctrl-T"
+`)))
+
+	conf, err := newConfig(v, []string{"--debug", "--tab-width", "2", "ls"})
+	assert.NoError(t, err)
+
+	var lines []string
+	for _, r := range conf.runtime.startupLog {
+		lines = append(lines, strings.TrimPrefix(string(formatLogRecord(time.Time{}, r)), "time=0001-01-01T00:00:00.000Z "))
+	}
+
+	assert.Contains(t, lines, "level=info msg=\"config file\" path=none\n")
+	assert.Contains(t, lines, "level=debug msg=flags given=\"--debug --tab-width\"\n")
+	assert.Contains(t, lines,
+		"level=debug msg=\"config value\" key=general.shell value=zsh from=\"env VIDDY_GENERAL_SHELL\" overrides=\"config file\"\n")
+	assert.Contains(t, lines,
+		"level=debug msg=\"config value\" key=general.tab_width value=2 from=\"flag --tab-width\" overrides=\"config file\"\n")
+	assert.Contains(t, lines, "level=debug msg=keymap action=keymap.toggle_diff keys=Ctrl-T source=config\n")
+	assert.Contains(t, lines, "level=debug msg=keymap action=keymap.quit keys=Ctrl-C source=default\n")
+
+	conf, err = newConfig(viper.New(), []string{"ls"})
+	assert.NoError(t, err)
+	assert.Nil(t, conf.runtime.startupLog)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// diffOffset is how far back the snapshot which the differences are taken
+// against is, a number of runs or a duration. The previous run by default.
+type diffOffset struct {
+	runs     int
+	duration time.Duration
+}
+
+// parseDiffOffset parses a number of runs such as "60", or a duration such
+// as "5m" like the interval.
+func parseDiffOffset(s string) (diffOffset, error) {
+	s = strings.TrimSpace(s)
+
+	if runs, err := strconv.Atoi(s); err == nil {
+		if runs < 1 {
+			return diffOffset{}, errDifferencesAgainst
+		}
+
+		return diffOffset{runs: runs}, nil
+	}
+
+	d, err := parseInterval("differences_against", s)
+	if err != nil || d <= 0 {
+		return diffOffset{}, errDifferencesAgainst
+	}
+
+	return diffOffset{duration: d}, nil
+}
+
+func (o diffOffset) String() string {
+	if o.duration > 0 {
+		return o.duration.String()
+	}
+
+	if o.runs == 0 {
+		return "1"
+	}
+
+	return strconv.Itoa(o.runs)
+}
+
+// isPrevious tells whether the differences are those with the previous run.
+func (o diffOffset) isPrevious() bool {
+	return o.duration == 0 && o.runs <= 1
+}
+
+// reaches tells whether a snapshot the runs before, which ran age before,
+// is far enough back.
+func (o diffOffset) reaches(runs int, age time.Duration) bool {
+	if o.duration > 0 {
+		return age >= o.duration
+	}
+
+	return runs >= o.runs
+}
+
+// diffBaseline is the snapshot which another one is diffed with, and how far
+// back it is.
+type diffBaseline struct {
+	s    *Snapshot
+	runs int
+	age  time.Duration
+}
+
+// label says how far back the baseline is for the header, which may be less
+// than asked for while the history is short.
+func (b diffBaseline) label(o diffOffset) string {
+	switch {
+	case b.s == nil:
+		return ""
+	case o.duration > 0:
+		return fmt.Sprintf("%s ago", b.age.Round(time.Second))
+	case b.runs == 1:
+		return "the previous run"
+	}
+
+	return fmt.Sprintf("%d runs ago", b.runs)
+}
+
+// findDiffBaseline returns the snapshot which s is diffed with under
+// --differences-against: the newest one far enough back, or the oldest one
+// while there is none. Runs which were skipped or did not finish are passed
+// over.
+func (v *Viddy) findDiffBaseline(s *Snapshot) diffBaseline {
+	v.RLock()
+	defer v.RUnlock()
+
+	var b diffBaseline
+
+	runs := 0
+
+	for i := v.indexOf(s.id) - 1; i >= 0; i-- {
+		before := v.getSnapShot(v.idList[i])
+		if before == nil || !before.completed || before.skipped {
+			continue
+		}
+
+		runs++
+		b = diffBaseline{s: before, runs: runs, age: s.start.Sub(before.start)}
+
+		if v.diffAgainst.reaches(runs, b.age) {
+			break
+		}
+	}
+
+	return b
+}
+
+// offsetComparison returns s diffed with its baseline under
+// --differences-against, or nil if that is the previous run as usual. The
+// header tells what the baseline is.
+func (v *Viddy) offsetComparison(s *Snapshot) *Snapshot {
+	if v.diffAgainst.isPrevious() || v.isPermanentDiff {
+		v.setDiffBaseline("")
+
+		return nil
+	}
+
+	b := v.findDiffBaseline(s)
+	v.setDiffBaseline(b.label(v.diffAgainst))
+
+	if b.s == nil || b.s == s.diffBase {
+		return nil
+	}
+
+	// Redrawing the same snapshot does not diff it again.
+	if c := v.offsetCompared; c != nil && c.id == s.id && c.before == b.s {
+		return c
+	}
+
+	c, err := s.compareWith(b.s)
+	if err != nil {
+		return nil
+	}
+
+	v.offsetCompared = c
+
+	return c
+}
+
+func (v *Viddy) setDiffBaseline(label string) {
+	if label == v.diffBaseline {
+		return
+	}
+
+	v.diffBaseline = label
+	v.UpdateStatusView()
+}
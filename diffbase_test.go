@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiffOffset(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  diffOffset
+	}{
+		{value: "60", want: diffOffset{runs: 60}},
+		{value: "1", want: diffOffset{runs: 1}},
+		{value: "5m", want: diffOffset{duration: 5 * time.Minute}},
+		{value: "1.5", want: diffOffset{duration: 1500 * time.Millisecond}},
+	} {
+		got, err := parseDiffOffset(tt.value)
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+
+		again, err := parseDiffOffset(got.String())
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, got, again, tt.value)
+	}
+
+	for _, value := range []string{"0", "-3", "-5m", "", "often"} {
+		_, err := parseDiffOffset(value)
+		assert.Equal(t, errDifferencesAgainst, err, value)
+	}
+
+	assert.True(t, diffOffset{runs: 1}.isPrevious())
+	assert.True(t, diffOffset{}.isPrevious())
+	assert.False(t, diffOffset{runs: 2}.isPrevious())
+	assert.False(t, diffOffset{duration: time.Second}.isPrevious())
+}
+
+func TestFindDiffBaseline(t *testing.T) {
+	begin := time.Date(2021, 9, 4, 12, 0, 0, 0, time.UTC)
+	v := &Viddy{}
+
+	// A run every 10s, the fourth of which was skipped.
+	for i := 0; i < 10; i++ {
+		s := &Snapshot{id: int64(i * 10000), start: begin.Add(time.Duration(i) * 10 * time.Second), completed: true}
+		s.skipped = i == 3
+
+		v.snapshots.Store(s.id, s)
+		v.idList = append(v.idList, s.id)
+	}
+
+	at := func(i int) *Snapshot { return v.getSnapShot(int64(i * 10000)) }
+
+	v.diffAgainst = diffOffset{runs: 3}
+	b := v.findDiffBaseline(at(9))
+	assert.Same(t, at(6), b.s)
+	assert.Equal(t, "3 runs ago", b.label(v.diffAgainst))
+
+	// The skipped run does not count.
+	assert.Same(t, at(1), v.findDiffBaseline(at(5)).s)
+
+	// Without enough history, the oldest snapshot is the baseline.
+	b = v.findDiffBaseline(at(2))
+	assert.Same(t, at(0), b.s)
+	assert.Equal(t, "2 runs ago", b.label(v.diffAgainst))
+
+	v.diffAgainst = diffOffset{duration: 25 * time.Second}
+	b = v.findDiffBaseline(at(9))
+	assert.Same(t, at(6), b.s)
+	assert.Equal(t, "30s ago", b.label(v.diffAgainst))
+
+	b = v.findDiffBaseline(at(1))
+	assert.Same(t, at(0), b.s)
+	assert.Equal(t, "10s ago", b.label(v.diffAgainst))
+
+	// The first snapshot has nothing to compare with.
+	b = v.findDiffBaseline(at(0))
+	assert.Nil(t, b.s)
+	assert.Equal(t, "", b.label(v.diffAgainst))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/rivo/uniseg"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffMask marks characters of an output by line and column. Lines are
+// shared between masks, so they must not be changed once set.
+type diffMask [][]bool
+
+func (m diffMask) has(line, col int) bool {
+	return line < len(m) && col < len(m[line]) && m[line][col]
+}
+
+// union returns a mask with the characters of both. Lines without changes in
+// other are shared with m.
+func (m diffMask) union(other diffMask) diffMask {
+	size := len(m)
+	if len(other) > size {
+		size = len(other)
+	}
+
+	u := make(diffMask, size)
+	copy(u, m)
+
+	for line, cols := range other {
+		if len(cols) == 0 {
+			continue
+		}
+
+		var old []bool
+		if line < len(m) {
+			old = m[line]
+		}
+
+		width := len(cols)
+		if len(old) > width {
+			width = len(old)
+		}
+
+		merged := make([]bool, width)
+		copy(merged, old)
+
+		for col, changed := range cols {
+			merged[col] = merged[col] || changed
+		}
+
+		u[line] = merged
+	}
+
+	return u
+}
+
+// changedLines returns which lines have any of their characters marked.
+func (m diffMask) changedLines() []bool {
+	changed := make([]bool, len(m))
+
+	for line, cols := range m {
+		for _, c := range cols {
+			if c {
+				changed[line] = true
+
+				break
+			}
+		}
+	}
+
+	return changed
+}
+
+// changedPositions returns the characters of the new text which the diffs
+// insert, and those right after a deletion, like DiffPrettyText highlights them.
+func changedPositions(diffs []diffmatchpatch.Diff) diffMask {
+	var m diffMask
+
+	line, col := 0, 0
+
+	mark := func() {
+		for len(m) <= line {
+			m = append(m, nil)
+		}
+
+		for len(m[line]) <= col {
+			m[line] = append(m[line], false)
+		}
+
+		m[line][col] = true
+	}
+
+	advance := func(c rune) {
+		if c == '\n' {
+			line++
+			col = 0
+		} else {
+			col++
+		}
+	}
+
+	for i, diff := range diffs {
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			for _, c := range diff.Text {
+				if c != '\n' {
+					mark()
+				}
+
+				advance(c)
+			}
+		case diffmatchpatch.DiffEqual:
+			for j, c := range diff.Text {
+				if j == 0 && i > 0 && diffs[i-1].Type == diffmatchpatch.DiffDelete && c != '\n' {
+					mark()
+				}
+
+				advance(c)
+			}
+		}
+	}
+
+	return m
+}
+
+// PermanentPrettyText highlights the characters of the text in the mask with
+// the changed colors of the theme, and colors those in the highlight mask.
+func PermanentPrettyText(text string, mask diffMask, hl highlightMask, t theme) string {
+	var buff bytes.Buffer
+
+	changedFg, changedBg := colorTag(t.diffChangedForeground), colorTag(t.diffChangedBackground)
+
+	var p textPosition
+
+	// The mask has a column for every rune, and a character of several runes
+	// is highlighted as a whole if any of them changed.
+	g := uniseg.NewGraphemes(text)
+	for g.Next() {
+		runes := g.Runes()
+		highlighted := false
+
+		for q, i := p, 0; i < len(runes); i++ {
+			highlighted = highlighted || mask.has(q.line, q.col)
+			q.advanceRune(runes[i])
+		}
+
+		fg, bg := "", ""
+		if highlighted {
+			fg, bg = changedFg, changedBg
+		}
+
+		writeHighlighted(&buff, g.Str(), highlightTags(fg, bg, hl.next(&p, runes)))
+	}
+
+	return buff.String()
+}
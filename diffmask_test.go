@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedPositions(t *testing.T) {
+	diffs := dmp.DiffCleanupSemantic(dmp.DiffMain("a 1\nb\nabc\n", "a 2\nb\nac\nd\n", false))
+
+	assert.Equal(t, diffMask{
+		{false, false, true},
+		nil,
+		{false, true},
+		{true},
+	}, changedPositions(diffs))
+}
+
+func TestDiffMaskUnion(t *testing.T) {
+	m := diffMask{{true}, {false, true}}
+	u := m.union(diffMask{nil, {true}, {false, true}})
+
+	assert.Equal(t, diffMask{{true}, {true, true}, {false, true}}, u)
+	assert.Equal(t, diffMask{{true}, {false, true}}, m)
+	assert.True(t, &m[0][0] == &u[0][0], "unchanged lines are shared")
+}
+
+func TestDiffMaskChangedLines(t *testing.T) {
+	assert.Equal(t, []bool{true, false, false, true}, diffMask{{true}, nil, {false, false}, {false, true}}.changedLines())
+}
+
+func TestPermanentPrettyText(t *testing.T) {
+	th := theme{diffChangedBackground: tcell.ColorGreen, diffChangedForeground: tcell.ColorBlack}
+
+	got := PermanentPrettyText("ab c\nd\n", diffMask{{true, false, true, true}, nil}, nil, th)
+	assert.Equal(t, "[black:green]a[-:-:-]b [black:green]c[-:-:-]\nd\n", got)
+
+	// Only the combining mark changed, but the letter is highlighted with it.
+	got = PermanentPrettyText("e\u0300x\n", diffMask{{false, true, false}}, nil, th)
+	assert.Equal(t, "[black:green]e\u0300[-:-:-]x\n", got)
+}
+
+func TestSnapshotPermanentMask(t *testing.T) {
+	th := theme{diffChangedBackground: tcell.ColorGreen, diffChangedForeground: tcell.ColorBlack}
+
+	a := &Snapshot{id: 1, result: []byte("x 1\ny 1\n"), completed: true}
+	b := &Snapshot{id: 2, result: []byte("x 2\ny 1\n"), completed: true, before: a}
+	c := &Snapshot{id: 3, result: []byte("x 2\ny 2\n"), completed: true, before: b}
+
+	render := func(s *Snapshot) string {
+		var buf bytes.Buffer
+		assert.NoError(t, s.render(&buf, true, true, nil, th))
+
+		return buf.String()
+	}
+
+	// b was never compared on its own, so c compares it first.
+	assert.Equal(t, "x [black:green]2[-:-:-]\ny [black:green]2[-:-:-]\n", render(c))
+	assert.Equal(t, "x [black:green]2[-:-:-]\ny 1\n", render(b))
+	assert.Equal(t, "x 1\ny 1\n", render(a))
+
+	c.setPermanentMask(nil)
+
+	d := &Snapshot{id: 4, result: []byte("x 2\ny 3\n"), completed: true, before: c}
+	assert.Equal(t, "x 2\ny [black:green]3[-:-:-]\n", render(d))
+}
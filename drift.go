@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// drift returns how late the run started after it was due, and false if it
+// was not due at any particular time or never ran.
+func (s *Snapshot) drift() (time.Duration, bool) {
+	if s.due.IsZero() || s.start.IsZero() || s.skipped || s.restored {
+		return 0, false
+	}
+
+	return s.start.Sub(s.due), true
+}
+
+// driftStats sums up how well the runs kept to their schedule.
+type driftStats struct {
+	runs  int
+	min   time.Duration
+	max   time.Duration
+	total time.Duration
+	last  time.Duration
+	// missed counts the ticks which were skipped.
+	missed int64
+}
+
+func (d *driftStats) add(drift time.Duration) {
+	if d.runs == 0 || drift < d.min {
+		d.min = drift
+	}
+
+	if d.runs == 0 || drift > d.max {
+		d.max = drift
+	}
+
+	d.runs++
+	d.total += drift
+	d.last = drift
+}
+
+func (d driftStats) String() string {
+	if d.runs == 0 {
+		return fmt.Sprintf("no runs, %d ticks missed", d.missed)
+	}
+
+	return fmt.Sprintf("%d runs, min %s avg %s max %s, %d ticks missed",
+		d.runs, formatDrift(d.min), formatDrift(d.total/time.Duration(d.runs)), formatDrift(d.max), d.missed)
+}
+
+// formatDrift formats how late a run started, to the millisecond unless it
+// is less than one.
+func formatDrift(d time.Duration) string {
+	if d < time.Millisecond && d > -time.Millisecond {
+		d = d.Round(time.Microsecond)
+	} else {
+		d = d.Round(time.Millisecond)
+	}
+
+	if d >= 0 {
+		return "+" + d.String()
+	}
+
+	return d.String()
+}
+
+// recordDrift adds how late the run started to the stats of the pane.
+func (v *Viddy) recordDrift(s *Snapshot) {
+	d, ok := s.drift()
+	if !ok {
+		return
+	}
+
+	v.Lock()
+	v.drift.add(d)
+	v.Unlock()
+}
+
+// lastDrift returns how late the latest run started, and false if no run
+// was due at any particular time.
+func (v *Viddy) lastDrift() (time.Duration, bool) {
+	v.RLock()
+	defer v.RUnlock()
+
+	return v.drift.last, v.drift.runs > 0
+}
+
+// driftSummary sums up how well the runs of the pane kept to the schedule,
+// or returns "" if none was due at any particular time.
+func (v *Viddy) driftSummary() string {
+	v.RLock()
+	stats := v.drift
+	v.RUnlock()
+
+	stats.missed = atomic.LoadInt64(&v.skippedRuns)
+	if stats.runs == 0 && stats.missed == 0 {
+		return ""
+	}
+
+	return strings.Join(v.activeCommand().line(), " ") + ": " + stats.String()
+}
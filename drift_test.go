@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDrift(t *testing.T) {
+	start := time.Date(2021, 9, 4, 12, 0, 0, 0, time.UTC)
+
+	d, ok := (&Snapshot{start: start, due: start.Add(-3 * time.Millisecond)}).drift()
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Millisecond, d)
+
+	_, ok = (&Snapshot{start: start}).drift()
+	assert.False(t, ok)
+
+	_, ok = (&Snapshot{start: start, due: start, skipped: true}).drift()
+	assert.False(t, ok)
+}
+
+func TestDriftStats(t *testing.T) {
+	var d driftStats
+	assert.Equal(t, "no runs, 0 ticks missed", d.String())
+
+	for _, drift := range []time.Duration{4 * time.Millisecond, 1 * time.Millisecond, 7 * time.Millisecond} {
+		d.add(drift)
+	}
+
+	d.missed = 2
+	assert.Equal(t, "3 runs, min +1ms avg +4ms max +7ms, 2 ticks missed", d.String())
+	assert.Equal(t, 7*time.Millisecond, d.last)
+}
+
+func TestFormatDrift(t *testing.T) {
+	assert.Equal(t, "+12ms", formatDrift(12345*time.Microsecond))
+	assert.Equal(t, "+346µs", formatDrift(345678*time.Nanosecond))
+	assert.Equal(t, "+0s", formatDrift(0))
+	assert.Equal(t, "-2ms", formatDrift(-2*time.Millisecond))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os/exec"
+)
+
+// directCommand returns the command to run without a shell, as --exec does.
+// A command line given as a whole, by --cmd or by editing it, has no
+// arguments of its own and is split into words the way a shell would.
+func directCommand(command string, args []string) (*exec.Cmd, error) {
+	argv := append([]string{command}, args...)
+
+	if args == nil {
+		words, err := splitShellWords(command)
+		if err != nil {
+			return nil, err
+		}
+
+		argv = words
+	}
+
+	if len(argv) == 0 {
+		return nil, errExecCommand
+	}
+
+	return exec.Command(argv[0], argv[1:]...), nil //nolint:gosec
+}
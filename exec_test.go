@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		want    []string
+		wantErr error
+	}{
+		{name: "arguments", command: "printf", args: []string{"%s\n", "a  b"}, want: []string{"printf", "%s\n", "a  b"}},
+		{name: "no arguments", command: "date", args: []string{}, want: []string{"date"}},
+		{name: "whole line", command: `grep -c "a  b" file`, want: []string{"grep", "-c", "a  b", "file"}},
+		{name: "unterminated quote", command: `echo "a`, wantErr: shellWordsError{column: 6, reason: "unterminated double quote"}},
+		{name: "empty line", command: " ", wantErr: errExecCommand},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := directCommand(tt.command, tt.args)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestSnapshotRunExec(t *testing.T) {
+	// Without a shell, the spaces and the $ reach the command as they are.
+	s := NewSnapshot(0, "printf", []string{"%s\n", "a  $HOME"}, runOptions{exec: true}, nil, make(chan struct{}))
+	require.NoError(t, s.run(make(chan int64, 1)))
+
+	assert.Equal(t, "a  $HOME\n", string(s.result))
+	assert.Equal(t, 0, s.exitStatus())
+}
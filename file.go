@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// filePath returns the path of the file of --file, relative ones being in
+// the directory of --chdir.
+func filePath(file, dir string) string {
+	if filepath.IsAbs(file) || dir == "" {
+		return filepath.Clean(file)
+	}
+
+	return filepath.Join(dir, file)
+}
+
+// runFile reads the file of --file as the output of the run, which fails if
+// it cannot be read. It returns false if the run was killed before it started.
+func (s *Snapshot) runFile() bool {
+	if s.isKilled() {
+		return false
+	}
+
+	b := newLimitedBuffer(s.opts.maxLines, s.opts.maxBytes)
+
+	f, err := os.Open(filePath(s.opts.file, s.opts.dir))
+	if err == nil {
+		_, err = io.Copy(b, f)
+		_ = f.Close()
+	}
+
+	if err != nil {
+		s.err = err
+		s.errorResult = []byte(err.Error() + "\n")
+	}
+
+	s.result = b.Bytes()
+	s.totalLines = b.totalLines()
+
+	return true
+}
+
+// watchFile runs right away whenever the file of --file changes, besides the
+// interval. The directory is watched, so that a file replaced by a rename is
+// still followed. Where the file cannot be watched, only the interval reads it.
+func (v *Viddy) watchFile() {
+	path := filePath(v.file, v.dir)
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		v.log(levelWarn, "file not watched", "path", path, "error", err)
+
+		return
+	}
+
+	defer w.Close()
+
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		v.log(levelWarn, "file not watched", "path", path, "error", err)
+
+		return
+	}
+
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(e.Name) == path && e.Op != fsnotify.Chmod {
+				v.log(levelDebug, "file changed", "path", path, "op", e.Op.String())
+				v.runNow()
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+
+			v.log(levelWarn, "file watch failed", "path", path, "error", err)
+		}
+	}
+}
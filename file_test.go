@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePath(t *testing.T) {
+	assert.Equal(t, "/proc/meminfo", filePath("/proc/meminfo", "/tmp"))
+	assert.Equal(t, "/tmp/status.txt", filePath("status.txt", "/tmp"))
+	assert.Equal(t, "status.txt", filePath("./status.txt", ""))
+}
+
+func TestSnapshotRunFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "status.txt"), []byte("a\nb\nc\n"), 0o600))
+
+	tests := []struct {
+		name       string
+		opts       runOptions
+		wantResult string
+		wantStatus int
+	}{
+		{
+			name:       "read",
+			opts:       runOptions{file: "status.txt", dir: dir},
+			wantResult: "a\nb\nc\n",
+		},
+		{
+			name:       "limited",
+			opts:       runOptions{file: "status.txt", dir: dir, maxLines: 2},
+			wantResult: "a\nb\n",
+		},
+		{
+			name:       "missing",
+			opts:       runOptions{file: "missing.txt", dir: dir},
+			wantResult: "",
+			wantStatus: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSnapshot(0, tt.opts.file, nil, tt.opts, nil, make(chan struct{}))
+			require.NoError(t, s.run(make(chan int64, 1)))
+
+			assert.Equal(t, tt.wantResult, string(s.result))
+			assert.Equal(t, tt.wantStatus, s.exitStatus())
+
+			if tt.wantStatus != 0 {
+				assert.Contains(t, string(s.errorResult), "no such file or directory")
+			}
+		})
+	}
+}
@@ -1,21 +1,170 @@
 package main
 
-import "time"
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
 
 type newSnapFunc func(int64, *Snapshot, chan<- struct{}) *Snapshot
 
-func ClockSnapshot(begin int64, newSnap newSnapFunc, interval time.Duration) <-chan *Snapshot {
+// OverlapPolicy decides what happens when a run is due while the previous one is still executing.
+type OverlapPolicy string
+
+const (
+	OverlapPolicySkip OverlapPolicy = "skip"
+	OverlapPolicyWait OverlapPolicy = "wait"
+	OverlapPolicyKill OverlapPolicy = "kill"
+)
+
+func isFinished(finish <-chan struct{}) bool {
+	select {
+	case <-finish:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitOrKill waits for the run to finish, killing it once the timeout passes.
+func waitOrKill(s *Snapshot, finish <-chan struct{}, timeout time.Duration) {
+	select {
+	case <-finish:
+	case <-time.After(timeout):
+		s.kill()
+		<-finish
+	}
+}
+
+// newSnapshotQueue starts the generator of the interval mode of the config.
+// onNext is only called with a schedule, a is only used in adaptive mode, st
+// with a stepped interval and t with a fixed one.
+func newSnapshotQueue(conf *config, begin int64, newSnap newSnapFunc, onSkip func(), onNext func(time.Time),
+	b *backoff, a *adaptive, st *steppedInterval, t *tunedInterval,
+) <-chan *Snapshot {
+	interval, jitter, policy := conf.runtime.interval, conf.runtime.jitter, conf.general.overlapPolicy
+
+	switch conf.runtime.mode {
+	case ViddyIntervalModeClockwork:
+		return ClockSnapshot(begin, newSnap, interval, jitter, policy, onSkip, b, st, t)
+	case ViddyIntervalModePrecise:
+		return PreciseSnapshot(begin, newSnap, interval, jitter, policy, onSkip, b, st, t)
+	case ViddyIntervalModeSchedule:
+		return ScheduleSnapshot(begin, newSnap, conf.runtime.schedule, jitter, policy, onSkip, onNext)
+	case ViddyIntervalModeAdaptive:
+		return SequentialSnapshot(begin, newSnap, interval, jitter, policy, b, a, nil, nil)
+	case ViddyIntervalModeOnce:
+		return OnceSnapshot(begin, newSnap)
+	default:
+		return SequentialSnapshot(begin, newSnap, interval, jitter, policy, b, nil, st, t)
+	}
+}
+
+// jitterRand is seeded on start, so that instances started together spread out.
+var (
+	jitterRand   = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	jitterRandMu sync.Mutex
+)
+
+// randomJitter returns a random duration in [0, jitter).
+func randomJitter(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+
+	jitterRandMu.Lock()
+	defer jitterRandMu.Unlock()
+
+	return time.Duration(jitterRand.Int63n(int64(jitter)))
+}
+
+// ClockSnapshot runs the command on a grid of the interval, shifted by a
+// random phase within the jitter. While backing off, it leaves out ticks
+// until the backed off interval has passed. Ticks which cannot start within
+// half an interval, because the previous run or the receiver held it up,
+// are skipped rather than run late one after the other. With a stepped
+// interval, the grid changes at the bounds of the steps, and with a tuned
+// one, a new grid starts from the last run when it changes.
+//
+//nolint:gocognit,cyclop
+func ClockSnapshot(begin int64, newSnap newSnapFunc, interval, jitter time.Duration, policy OverlapPolicy,
+	onSkip func(), b *backoff, st *steppedInterval, t *tunedInterval,
+) <-chan *Snapshot {
 	c := make(chan *Snapshot)
 
 	go func() {
-		var s *Snapshot
+		var (
+			s        *Snapshot
+			finish   chan struct{}
+			recorded bool
+			last     time.Time
+		)
 
-		t := time.Tick(interval)
+		settle := func() {
+			if s != nil && !recorded && isFinished(finish) {
+				b.record(s)
+				recorded = true
+			}
+		}
 
-		for now := range t {
-			finish := make(chan struct{})
-			id := (now.UnixNano() - begin) / int64(time.Millisecond)
+		time.Sleep(randomJitter(jitter))
+
+		next := time.Now().Add(st.interval(t.interval(interval)))
+
+		for {
+			if !t.sleep(time.Until(next)) {
+				next = retune(last, st.interval(t.interval(interval)))
+
+				continue
+			}
+
+			// The ticks which passed meanwhile are missed, and the latest
+			// one is due.
+			now := time.Now()
+			every := st.interval(t.interval(interval))
+			due := next
+
+			for next = next.Add(every); !next.After(now); next = next.Add(every) {
+				onSkip()
+
+				due = next
+			}
+
+			settle()
+
+			// Ticks drift a little, so any tick closer than half an interval counts.
+			if s != nil && recorded && due.Sub(last)+every/2 < b.interval(every) {
+				continue
+			}
+
+			if s != nil && !isFinished(finish) {
+				switch policy {
+				case OverlapPolicySkip:
+					onSkip()
+
+					continue
+				case OverlapPolicyWait:
+					<-finish
+				case OverlapPolicyKill:
+					s.kill()
+					<-finish
+				}
+
+				settle()
+			}
+
+			if time.Since(due) > every/2 {
+				onSkip()
+
+				continue
+			}
+
+			finish = make(chan struct{})
+			recorded = false
+			last = due
+			id := (due.UnixNano() - begin) / int64(time.Millisecond)
 			s = newSnap(id, s, finish)
+			s.due = due
 			c <- s
 		}
 	}()
@@ -23,31 +172,61 @@ func ClockSnapshot(begin int64, newSnap newSnapFunc, interval time.Duration) <-c
 	return c
 }
 
-func PreciseSnapshot(newSnap newSnapFunc, interval time.Duration) <-chan *Snapshot {
+// PreciseSnapshot runs the command every interval from its start, shifted
+// by a random phase within the jitter. A tuned interval which changes while
+// it waits counts from the start of the last run.
+//
+//nolint:cyclop
+func PreciseSnapshot(begin int64, newSnap newSnapFunc, interval, jitter time.Duration, policy OverlapPolicy,
+	onSkip func(), b *backoff, st *steppedInterval, t *tunedInterval,
+) <-chan *Snapshot {
 	c := make(chan *Snapshot)
 
 	go func() {
 		var s *Snapshot
 
-		begin := time.Now().UnixNano()
+		time.Sleep(randomJitter(jitter))
+
+		due := time.Now()
 
 		for {
 			finish := make(chan struct{})
 			start := time.Now()
 			id := (start.UnixNano() - begin) / int64(time.Millisecond)
 			ns := newSnap(id, s, finish)
+			ns.due = due
 			s = ns
 
 			c <- ns
 
-			<-finish
+			if policy == OverlapPolicyKill {
+				waitOrKill(ns, finish, st.interval(t.interval(interval)))
+			} else {
+				<-finish
+			}
+
+			b.record(ns)
 
 			pTime := time.Since(start)
+			next := b.interval(st.interval(t.interval(interval)))
+			due = start.Add(next)
+
+			if pTime <= next {
+				for !t.sleep(time.Until(due)) {
+					due = retune(start, b.interval(st.interval(t.interval(interval))))
+				}
 
-			if pTime > interval {
 				continue
-			} else {
-				time.Sleep(interval - pTime)
+			}
+
+			if policy == OverlapPolicySkip {
+				missed := int64(pTime / next)
+				for i := int64(0); i < missed; i++ {
+					onSkip()
+				}
+
+				due = start.Add(time.Duration(missed+1) * next)
+				time.Sleep(time.Duration(missed+1)*next - pTime)
 			}
 		}
 	}()
@@ -55,24 +234,118 @@ func PreciseSnapshot(newSnap newSnapFunc, interval time.Duration) <-chan *Snapsh
 	return c
 }
 
-func SequentialSnapshot(newSnap newSnapFunc, interval time.Duration) <-chan *Snapshot {
+// SequentialSnapshot waits the interval and a random part of the jitter
+// between the end of a run and the start of the next. With an adaptive, the
+// interval follows how often the output changes, and with a stepped
+// interval, how long viddy has been running. A tuned interval which changes
+// while it waits counts from the end of the last run.
+func SequentialSnapshot(begin int64, newSnap newSnapFunc, interval, jitter time.Duration, policy OverlapPolicy,
+	b *backoff, a *adaptive, st *steppedInterval, t *tunedInterval,
+) <-chan *Snapshot {
 	c := make(chan *Snapshot)
 
 	go func() {
 		var s *Snapshot
 
-		begin := time.Now().UnixNano()
-
 		for {
 			finish := make(chan struct{})
 			id := (time.Now().UnixNano() - begin) / int64(time.Millisecond)
 			s = newSnap(id, s, finish)
 			c <- s
 
-			<-finish
+			if policy == OverlapPolicyKill {
+				waitOrKill(s, finish, a.interval(st.interval(t.interval(interval))))
+			} else {
+				<-finish
+			}
+
+			b.record(s)
+			a.record(s)
+
+			end, j := time.Now(), randomJitter(jitter)
+			for !t.sleep(time.Until(end.Add(b.interval(a.interval(st.interval(t.interval(interval)))) + j))) {
+				// The interval changed, so the wait is that of the new one.
+			}
+		}
+	}()
+
+	return c
+}
+
+// retune returns when the next run is due after the interval changed: the
+// new interval after the last run, or now if that already passed.
+func retune(last time.Time, every time.Duration) time.Time {
+	next := last.Add(every)
+	if now := time.Now(); next.Before(now) {
+		return now
+	}
+
+	return next
+}
+
+// OnceSnapshot runs the command a single time, right away, and closes the
+// channel after it.
+func OnceSnapshot(begin int64, newSnap newSnapFunc) <-chan *Snapshot {
+	c := make(chan *Snapshot)
+
+	go func() {
+		defer close(c)
+
+		id := (time.Now().UnixNano() - begin) / int64(time.Millisecond)
+		c <- newSnap(id, nil, make(chan struct{}))
+	}()
+
+	return c
+}
+
+// ScheduleSnapshot runs the command at the times of the schedule, each
+// delayed by a random part of the jitter, handling overlaps like
+// ClockSnapshot. onNext is told when the following run is due before each
+// snapshot is sent.
+func ScheduleSnapshot(begin int64, newSnap newSnapFunc, schedule *cronSchedule, jitter time.Duration, policy OverlapPolicy,
+	onSkip func(), onNext func(time.Time),
+) <-chan *Snapshot {
+	c := make(chan *Snapshot)
+
+	go func() {
+		var (
+			s      *Snapshot
+			finish chan struct{}
+		)
+
+		next := schedule.next(time.Now())
+		onNext(next)
+
+		for !next.IsZero() {
+			due := next.Add(randomJitter(jitter))
+			time.Sleep(time.Until(due))
 
-			time.Sleep(interval)
+			now := time.Now()
+			next = schedule.next(now)
+			onNext(next)
+
+			if s != nil && !isFinished(finish) {
+				switch policy {
+				case OverlapPolicySkip:
+					onSkip()
+
+					continue
+				case OverlapPolicyWait:
+					<-finish
+				case OverlapPolicyKill:
+					s.kill()
+					<-finish
+				}
+			}
+
+			finish = make(chan struct{})
+			id := (now.UnixNano() - begin) / int64(time.Millisecond)
+			s = newSnap(id, s, finish)
+			s.due = due
+			c <- s
 		}
+
+		close(c)
 	}()
 
 	return c
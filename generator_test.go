@@ -0,0 +1,138 @@
+package main
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeNewSnap(id int64, before *Snapshot, finish chan<- struct{}) *Snapshot {
+	return NewSnapshot(id, "", nil, runOptions{}, before, finish)
+}
+
+// consumeSlowly pretends every run takes d and reports the maximum number of runs alive at once.
+func consumeSlowly(c <-chan *Snapshot, d time.Duration, until time.Duration) int64 {
+	var alive, maxAlive int64
+
+	timeout := time.After(until)
+
+	for {
+		select {
+		case s := <-c:
+			n := atomic.AddInt64(&alive, 1)
+			if n > atomic.LoadInt64(&maxAlive) {
+				atomic.StoreInt64(&maxAlive, n)
+			}
+
+			go func() {
+				time.Sleep(d)
+				atomic.AddInt64(&alive, -1)
+				close(s.finish)
+			}()
+		case <-timeout:
+			return atomic.LoadInt64(&maxAlive)
+		}
+	}
+}
+
+func TestOverlapPolicySkip(t *testing.T) {
+	interval := 10 * time.Millisecond
+
+	tests := []struct {
+		name      string
+		generator func(onSkip func()) <-chan *Snapshot
+		skips     bool
+	}{
+		{
+			name: "clockwork",
+			generator: func(onSkip func()) <-chan *Snapshot {
+				return ClockSnapshot(time.Now().UnixNano(), fakeNewSnap, interval, 0, OverlapPolicySkip, onSkip, nil, nil, nil)
+			},
+			skips: true,
+		},
+		{
+			name: "precise",
+			generator: func(onSkip func()) <-chan *Snapshot {
+				return PreciseSnapshot(time.Now().UnixNano(), fakeNewSnap, interval, 0, OverlapPolicySkip, onSkip, nil, nil, nil)
+			},
+			skips: true,
+		},
+		{
+			name: "sequential",
+			generator: func(onSkip func()) <-chan *Snapshot {
+				return SequentialSnapshot(time.Now().UnixNano(), fakeNewSnap, interval, 0, OverlapPolicySkip, nil, nil, nil, nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var skipped int64
+
+			c := tt.generator(func() { atomic.AddInt64(&skipped, 1) })
+
+			maxAlive := consumeSlowly(c, 5*interval, 30*interval)
+			assert.Equal(t, int64(1), maxAlive)
+
+			if tt.skips {
+				assert.Greater(t, atomic.LoadInt64(&skipped), int64(0))
+			}
+		})
+	}
+}
+
+func TestClockSnapshotKeepsToTheGrid(t *testing.T) {
+	interval := 50 * time.Millisecond
+
+	var skipped int64
+
+	onSkip := func() { atomic.AddInt64(&skipped, 1) }
+	c := ClockSnapshot(time.Now().UnixNano(), fakeNewSnap, interval, 0, OverlapPolicyWait, onSkip, nil, nil, nil)
+
+	// The first run takes three ticks, so the ticks it held up are skipped
+	// rather than run late.
+	first := <-c
+	time.Sleep(3 * interval)
+	close(first.finish)
+
+	second := <-c
+	close(second.finish)
+
+	assert.Greater(t, atomic.LoadInt64(&skipped), int64(0))
+
+	ticks := second.due.Sub(first.due)
+	assert.Equal(t, time.Duration(0), ticks%interval, "runs are due on the grid")
+	assert.GreaterOrEqual(t, int64(ticks), int64(3*interval))
+}
+
+func TestOverlapPolicyKill(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	finish := make(chan struct{})
+	finishedQueue := make(chan int64, 1)
+	s := NewSnapshot(0, "sleep", []string{"10"}, runOptions{shell: "sh"}, nil, finish)
+
+	go func() { _ = s.run(finishedQueue) }()
+
+	start := time.Now()
+	waitOrKill(s, finish, 50*time.Millisecond)
+
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	assert.True(t, s.completed)
+	assert.Equal(t, timeoutExitStatus, s.exitStatus())
+}
+
+func TestRandomJitter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), randomJitter(0))
+
+	for i := 0; i < 100; i++ {
+		d := randomJitter(time.Second)
+		assert.GreaterOrEqual(t, int64(d), int64(0))
+		assert.Less(t, int64(d), int64(time.Second))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DiffGranularity decides how finely the changes within a line are
+// highlighted.
+type DiffGranularity string
+
+var (
+	// DiffGranularityChar highlights the characters which changed.
+	DiffGranularityChar DiffGranularity = "char"
+	// DiffGranularityWord highlights the words which changed, so that a
+	// number which changed lights up as a whole.
+	DiffGranularityWord DiffGranularity = "word"
+	// DiffGranularityLine highlights the lines which changed.
+	DiffGranularityLine DiffGranularity = "line"
+)
+
+// parseDiffGranularity returns the granularity of the name, or false if
+// there is none.
+func parseDiffGranularity(name string) (DiffGranularity, bool) {
+	switch g := DiffGranularity(strings.ToLower(name)); g {
+	case DiffGranularityChar, DiffGranularityWord, DiffGranularityLine:
+		return g, true
+	default:
+		return "", false
+	}
+}
+
+// refine returns the diff of the changed lines at the granularity, or nil to
+// keep them whole.
+func (g DiffGranularity) refine() func(before, after string) []diffmatchpatch.Diff {
+	switch g {
+	case DiffGranularityWord:
+		return diffWords
+	case DiffGranularityLine:
+		return nil
+	default:
+		return diffGraphemes
+	}
+}
+
+// splitWords splits the text into words, runs of spaces, and every other
+// character on its own, line breaks included.
+func splitWords(text string) []string {
+	kind := func(r rune) int {
+		switch {
+		case r == '\n':
+			return 0
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		default:
+			return 0
+		}
+	}
+
+	var words []string
+
+	start, last := 0, -1
+
+	for i, r := range text {
+		k := kind(r)
+		if i > start && (k == 0 || k != last) {
+			words = append(words, text[start:i])
+			start = i
+		}
+
+		last = k
+	}
+
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+
+	return words
+}
+
+// diffWords diffs two texts word by word, as splitWords splits them.
+func diffWords(before, after string) []diffmatchpatch.Diff {
+	index := map[string]rune{}
+
+	var words []string
+
+	encode := func(text string) []rune {
+		split := splitWords(text)
+		runes := make([]rune, 0, len(split))
+
+		for _, w := range split {
+			r, ok := index[w]
+			if !ok {
+				r = rune(len(words) + 1)
+				if r >= 0xD800 {
+					// Skip surrogates, they do not survive the conversion to string.
+					r += 0x800
+				}
+
+				index[w] = r
+				words = append(words, w)
+			}
+
+			runes = append(runes, r)
+		}
+
+		return runes
+	}
+
+	decode := func(r rune) string {
+		if r >= 0xD800+0x800 {
+			r -= 0x800
+		}
+
+		return words[r-1]
+	}
+
+	b, a := encode(before), encode(after)
+	diffs := dmp.DiffMainRunes(b, a, false)
+
+	for i, diff := range diffs {
+		var text strings.Builder
+		for _, r := range diff.Text {
+			text.WriteString(decode(r))
+		}
+
+		diffs[i].Text = text.String()
+	}
+
+	return diffs
+}
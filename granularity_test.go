@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "empty",
+			text: "",
+			want: nil,
+		},
+		{
+			name: "words and spaces",
+			text: "Mem:  7953 used\n",
+			want: []string{"Mem", ":", "  ", "7953", " ", "used", "\n"},
+		},
+		{
+			name: "punctuation on its own",
+			text: "1.25ms",
+			want: []string{"1", ".", "25ms"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitWords(tt.text))
+		})
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	got := diffWords("Mem: 7953 1234\n", "Mem: 7953 1299\n")
+
+	assert.Equal(t, []diffmatchpatch.Diff{
+		{Type: diffmatchpatch.DiffEqual, Text: "Mem: 7953 "},
+		{Type: diffmatchpatch.DiffDelete, Text: "1234"},
+		{Type: diffmatchpatch.DiffInsert, Text: "1299"},
+		{Type: diffmatchpatch.DiffEqual, Text: "\n"},
+	}, got)
+}
+
+func TestParseDiffGranularity(t *testing.T) {
+	g, ok := parseDiffGranularity("Word")
+	assert.True(t, ok)
+	assert.Equal(t, DiffGranularityWord, g)
+
+	_, ok = parseDiffGranularity("sentence")
+	assert.False(t, ok)
+}
+
+func TestDiffOutputsGranularity(t *testing.T) {
+	before, after := "pod-a Running\npod-b 3\n", "pod-a Running\npod-b 12\n"
+
+	tests := []struct {
+		g    DiffGranularity
+		want []diffmatchpatch.Diff
+	}{
+		{
+			g: DiffGranularityChar,
+			want: []diffmatchpatch.Diff{
+				{Type: diffmatchpatch.DiffEqual, Text: "pod-a Running\npod-b "},
+				{Type: diffmatchpatch.DiffDelete, Text: "3"},
+				{Type: diffmatchpatch.DiffInsert, Text: "12"},
+				{Type: diffmatchpatch.DiffEqual, Text: "\n"},
+			},
+		},
+		{
+			g: DiffGranularityLine,
+			want: []diffmatchpatch.Diff{
+				{Type: diffmatchpatch.DiffEqual, Text: "pod-a Running\n"},
+				{Type: diffmatchpatch.DiffDelete, Text: "pod-b 3\n"},
+				{Type: diffmatchpatch.DiffInsert, Text: "pod-b 12\n"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.g), func(t *testing.T) {
+			got, _ := diffOutputs(before, after, lineHashes, lineHashes, diffNormalize{}, tt.g)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rivo/uniseg"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// firstPrivateRune starts the private use planes, which stand in for
+// characters made of several runes while diffing.
+const firstPrivateRune = 0xF0000
+
+// diffGraphemes diffs two texts character by character, where a character is
+// what takes cells on screen: an accented letter made of a letter and a
+// combining mark, or an emoji sequence, changes as a whole. Otherwise only a
+// part of it would be highlighted, which does not show at all.
+func diffGraphemes(before, after string) []diffmatchpatch.Diff {
+	index := map[string]rune{}
+
+	var clusters []string
+
+	encode := func(text string) ([]rune, bool) {
+		runes := make([]rune, 0, len(text))
+
+		g := uniseg.NewGraphemes(text)
+		for g.Next() {
+			rs := g.Runes()
+			if len(rs) == 1 && rs[0] < firstPrivateRune {
+				runes = append(runes, rs[0])
+
+				continue
+			}
+
+			cluster := g.Str()
+
+			r, ok := index[cluster]
+			if !ok {
+				r = firstPrivateRune + rune(len(clusters))
+				if r > 0x10FFFF {
+					return nil, false
+				}
+
+				index[cluster] = r
+				clusters = append(clusters, cluster)
+			}
+
+			runes = append(runes, r)
+		}
+
+		return runes, true
+	}
+
+	b, okBefore := encode(before)
+	a, okAfter := encode(after)
+
+	if !okBefore || !okAfter {
+		return dmp.DiffCleanupSemantic(dmp.DiffMain(before, after, false))
+	}
+
+	diffs := dmp.DiffCleanupSemantic(dmp.DiffMainRunes(b, a, false))
+	if len(clusters) == 0 {
+		return diffs
+	}
+
+	for i, diff := range diffs {
+		var text strings.Builder
+
+		for _, r := range diff.Text {
+			if r >= firstPrivateRune {
+				text.WriteString(clusters[r-firstPrivateRune])
+			} else {
+				text.WriteRune(r)
+			}
+		}
+
+		diffs[i].Text = text.String()
+	}
+
+	return diffs
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/stretchr/testify/assert"
+)
+
+// highlightedCells draws the diff of the texts and returns the first line
+// with "*" for every highlighted cell and "." for the others.
+func highlightedCells(t *testing.T, before, after string) string {
+	t.Helper()
+
+	th := theme{diffAdded: tcell.ColorBlue, diffRemoved: tcell.ColorRed, diffChangedBackground: tcell.ColorGreen}
+
+	view := tview.NewTextView().SetDynamicColors(true)
+	view.SetText(DiffPrettyText(diffGraphemes(before, after), nil, th))
+	view.SetRect(0, 0, 10, 1)
+
+	screen := tcell.NewSimulationScreen("")
+	assert.NoError(t, screen.Init())
+	screen.SetSize(10, 1)
+	view.Draw(screen)
+
+	var b strings.Builder
+
+	for x := 0; x < 10; x++ {
+		_, _, style, _ := screen.GetContent(x, 0)
+		if _, bg, _ := style.Decompose(); bg == tcell.ColorGreen || bg == tcell.ColorRed {
+			b.WriteString("*")
+		} else {
+			b.WriteString(".")
+		}
+	}
+
+	return strings.TrimRight(b.String(), ".")
+}
+
+func TestDiffGraphemes(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{name: "narrow after wide", before: "日本a x\n", after: "日本b x\n", want: "....*"},
+		{name: "wide after narrow", before: "ab 日 x\n", after: "ab 本 x\n", want: "...**"},
+		{name: "combining mark", before: "é x\n", after: "è x\n", want: "*"},
+		{name: "emoji sequence", before: "👨‍👩 x\n", after: "👨‍👦 x\n", want: "**"},
+		{name: "narrow becomes wide", before: "ab x\n", after: "日b x\n", want: "**"},
+		{name: "wide becomes narrow", before: "日b x\n", after: "ab x\n", want: "*"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, highlightedCells(t, tt.before, tt.after))
+		})
+	}
+}
+
+func TestDiffGraphemesKeepsText(t *testing.T) {
+	before := "é 👨‍👩 \U000F0001\n"
+	after := "è 👨‍👦 \U000F0002\n"
+
+	var gotBefore, gotAfter strings.Builder
+
+	for _, diff := range diffGraphemes(before, after) {
+		if diff.Type != diffmatchpatch.DiffInsert {
+			gotBefore.WriteString(diff.Text)
+		}
+
+		if diff.Type != diffmatchpatch.DiffDelete {
+			gotAfter.WriteString(diff.Text)
+		}
+	}
+
+	assert.Equal(t, before, gotBefore.String())
+	assert.Equal(t, after, gotAfter.String())
+}
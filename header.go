@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Widths of the boxes of the header besides the command, the host and the
+// status.
+const (
+	intervalViewWidth = 10
+	timeViewWidth     = 21
+	changesViewWidth  = 20
+)
+
+// currentHost returns user@hostname of the machine viddy runs on, leaving out
+// what is unknown.
+func currentHost() string {
+	hostname, _ := os.Hostname()
+
+	var name string
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	// Windows prefixes the user with the domain.
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	switch {
+	case name == "":
+		return hostname
+	case hostname == "":
+		return name
+	default:
+		return name + "@" + hostname
+	}
+}
+
+// header shows the interval, the command, the host, the status, the changes
+// of the diff and the time. The command gives up its width first on narrow
+// terminals, since the host is what tells several viddys apart. The host, the
+// status and the changes are left out when nil. The status is as wide as its
+// items, which change while it is shown.
+type header struct {
+	*tview.Flex
+
+	host      *tview.TextView
+	hostWidth int
+
+	status      *tview.TextView
+	statusWidth int
+
+	changesWidth int
+	clockWidth   int
+}
+
+// newHeader lays out the boxes, the clock being clockWidth wide, or
+// timeViewWidth if wider.
+func newHeader(interval *tview.TextView, command tview.Primitive, host, status, changes, clock *tview.TextView,
+	clockWidth int,
+) *header {
+	if clockWidth < timeViewWidth {
+		clockWidth = timeViewWidth
+	}
+
+	h := &header{Flex: tview.NewFlex().SetDirection(tview.FlexColumn), host: host, status: status}
+	h.AddItem(interval, intervalViewWidth, 1, false).
+		AddItem(command, 0, 1, false)
+
+	if host != nil {
+		h.hostWidth = tview.TaggedStringWidth(host.GetText(false)) + 2
+		h.AddItem(host, h.hostWidth, 0, false)
+	}
+
+	if status != nil {
+		h.statusWidth = boxWidth(status)
+		h.AddItem(status, h.statusWidth, 0, false)
+	}
+
+	if changes != nil {
+		h.changesWidth = changesViewWidth
+		h.AddItem(changes, h.changesWidth, 1, false)
+	}
+
+	h.clockWidth = clockWidth
+	h.AddItem(clock, clockWidth, 1, false)
+
+	return h
+}
+
+func (h *header) Draw(screen tcell.Screen) {
+	if h.status != nil {
+		h.statusWidth = boxWidth(h.status)
+		h.ResizeItem(h.status, h.statusWidth, 0)
+	}
+
+	if h.host != nil {
+		_, _, width, _ := h.GetRect()
+
+		hostWidth := h.hostWidth
+		if free := width - intervalViewWidth - h.statusWidth - h.changesWidth - h.clockWidth - hostWidth; free < 0 {
+			hostWidth += free
+		}
+
+		if hostWidth < 0 {
+			hostWidth = 0
+		}
+
+		h.ResizeItem(h.host, hostWidth, 0)
+	}
+
+	h.Flex.Draw(screen)
+}
+
+// boxWidth is the width of the bordered box which fits its text and title.
+func boxWidth(view *tview.TextView) int {
+	width := tview.TaggedStringWidth(view.GetText(false))
+	if title := tview.TaggedStringWidth(view.GetTitle()); title > width {
+		width = title
+	}
+
+	return width + 2
+}
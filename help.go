@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// unboundKeys stands in for the keys of an action which has none.
+const unboundKeys = "(unbound)"
+
+// helpAction is an action on the help page with the keys it is bound to.
+type helpAction struct {
+	desc string
+	keys map[KeySequence]struct{}
+}
+
+// helpSection is a group of actions on the help page.
+type helpSection struct {
+	title   string
+	actions []helpAction
+}
+
+// helpSections lists every action with its keys, so that the help page shows
+// the keys which are bound, not the defaults.
+//
+//nolint:funlen
+func (k *keymapping) helpSections() []helpSection {
+	return []helpSection{
+		{title: "General", actions: []helpAction{
+			{desc: "Toggle time machine mode", keys: k.toggleTimeMachine},
+			{desc: "Toggle suspend execution", keys: k.toggleSuspend},
+			{desc: "Pause or resume the runs", keys: k.togglePause},
+			{desc: "Run less often", keys: k.increaseInterval},
+			{desc: "Run more often", keys: k.decreaseInterval},
+			{desc: "Toggle diff", keys: k.toggleDiff},
+			{desc: "Reset permanent diff", keys: k.resetDiff},
+			{desc: "Clear history", keys: k.clearHistory},
+			{desc: "Toggle only changed lines", keys: k.toggleChangesOnly},
+			{desc: "Toggle side by side", keys: k.toggleSideBySide},
+			{desc: "Toggle wrapping", keys: k.toggleWrap},
+			{desc: "Toggle line ages", keys: k.toggleLineAge},
+			{desc: "Edit the command", keys: k.editCommand},
+			{desc: "Toggle header display", keys: k.toggleHeader},
+			{desc: "Toggle help view", keys: k.toggleHelp},
+			{desc: "Toggle log view", keys: k.toggleLog},
+			{desc: "Toggle snapshot list", keys: k.toggleSnapshotList},
+			{desc: "Toggle change log", keys: k.toggleChangeLog},
+			{desc: "Switch stderr capture", keys: k.toggleStderr},
+			{desc: "Toggle output before pipe", keys: k.toggleRaw},
+			{desc: "Copy the output", keys: k.yank},
+			{desc: "Copy the visible lines", keys: k.yankVisible},
+			{desc: "Suspend to a shell", keys: k.shell},
+			{desc: "Search text", keys: k.search},
+			{desc: "Toggle ignoring case in the search", keys: k.toggleSearchCase},
+			{desc: "Toggle whole words in the search", keys: k.toggleSearchWord},
+			{desc: "Focus the next pane", keys: k.focusNextPane},
+			{desc: "Quit", keys: k.quit},
+		}},
+		{title: "Scrolling", actions: []helpAction{
+			{desc: "Move to next line", keys: k.scrollDown},
+			{desc: "Move to previous line", keys: k.scrollUp},
+			{desc: "Scroll left", keys: k.scrollLeft},
+			{desc: "Scroll right", keys: k.scrollRight},
+			{desc: "Page down", keys: k.pageDown},
+			{desc: "Page up", keys: k.pageUp},
+			{desc: "Go to top of page", keys: k.scrollToTop},
+			{desc: "Go to bottom of page", keys: k.scrollToBottom},
+			{desc: "Go to next change", keys: k.nextChange},
+			{desc: "Go to previous change", keys: k.previousChange},
+		}},
+		{title: "Bookmarks", actions: []helpAction{
+			{desc: "Toggle bookmark", keys: k.toggleBookmark},
+			{desc: "Go to next bookmark", keys: k.nextBookmark},
+			{desc: "Go to previous bookmark", keys: k.previousBookmark},
+			{desc: "Clear bookmarks", keys: k.clearBookmarks},
+		}},
+		{title: "Time machine", actions: []helpAction{
+			{desc: "Go to the past", keys: k.goToPastOnTimeMachine},
+			{desc: "Back to the future", keys: k.goToFutureOnTimeMachine},
+			{desc: "Go to more past", keys: k.goToMorePastOnTimeMachine},
+			{desc: "Back to more future", keys: k.goToMoreFutureOnTimeMachine},
+			{desc: "Go to oldest position", keys: k.goToOldestOnTimeMachine},
+			{desc: "Back to current position", keys: k.goToNowOnTimeMachine},
+			{desc: "Go to time", keys: k.goToTimeOnTimeMachine},
+			{desc: "Go back by the step", keys: k.backDurationOnTimeMachine},
+			{desc: "Go forward by the step", keys: k.forwardDurationOnTimeMachine},
+			{desc: "Increase the step", keys: k.increaseStepOnTimeMachine},
+			{desc: "Decrease the step", keys: k.decreaseStepOnTimeMachine},
+			{desc: "Play / pause the history", keys: k.playOnTimeMachine},
+			{desc: "Mark snapshot to compare", keys: k.markOnTimeMachine},
+			{desc: "Compare with the mark", keys: k.compareOnTimeMachine},
+			{desc: "Toggle running while here", keys: k.togglePollOnTimeMachine},
+		}},
+	}
+}
+
+// formatHelp writes the sections as the text of the help view, with the keys
+// in the syntax of the config file.
+func formatHelp(sections []helpSection) string {
+	width := 0
+	for _, s := range sections {
+		for _, a := range s.actions {
+			if w := runewidth.StringWidth(a.desc); w > width {
+				width = w
+			}
+		}
+	}
+
+	var b strings.Builder
+
+	for i, s := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, " [::u]%s[-:-:-]\n\n", s.title)
+
+		for _, a := range s.actions {
+			keys := unboundKeys
+			if len(a.keys) > 0 {
+				keys = keysToString(a.keys)
+			}
+
+			fmt.Fprintf(&b, "   %s : [yellow]%s[-:-:-]\n", runewidth.FillRight(a.desc, width), tview.Escape(keys))
+		}
+	}
+
+	return b.String()
+}
+
+// helpModal shows the help view in the middle of the screen over the panes,
+// as large as its text if it fits. It scrolls otherwise.
+type helpModal struct {
+	*tview.TextView
+	width, height int
+}
+
+func newHelpModal(text string) *helpModal {
+	tv := tview.NewTextView()
+	tv.SetDynamicColors(true)
+	tv.SetWrap(false)
+	tv.SetBorder(true).SetTitle(" Key bindings ")
+	tv.SetText(text)
+
+	m := &helpModal{TextView: tv}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for _, line := range lines {
+		if w := tview.TaggedStringWidth(line); w > m.width {
+			m.width = w
+		}
+	}
+
+	// Room for the border and a margin on the right.
+	m.width += 3
+	m.height = len(lines) + 2
+
+	return m
+}
+
+func (m *helpModal) Draw(screen tcell.Screen) {
+	w, h := screen.Size()
+
+	width, height := m.width, m.height
+	if width > w-2 {
+		width = w - 2
+	}
+
+	if height > h-2 {
+		height = h - 2
+	}
+
+	m.SetRect((w-width)/2, (h-height)/2, width, height)
+	m.TextView.Draw(screen)
+}
+
+// scroll moves the help by the rows, to the top or bottom if it would go past.
+func (m *helpModal) scroll(rows int) {
+	row, column := m.GetScrollOffset()
+	_, _, _, height := m.GetInnerRect()
+
+	row += rows
+	if last := m.height - 2 - height; row > last {
+		row = last
+	}
+
+	if row < 0 {
+		row = 0
+	}
+
+	m.ScrollTo(row, column)
+}
+
+func (m *helpModal) pageSize() int {
+	_, _, _, height := m.GetInnerRect()
+
+	return height
+}
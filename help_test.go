@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatHelp(t *testing.T) {
+	got := formatHelp([]helpSection{
+		{title: "General", actions: []helpAction{
+			{desc: "Quit", keys: map[KeySequence]struct{}{mustParseKeymap("Ctrl-C"): {}, mustParseKeymap("Shift-Q"): {}}},
+			{desc: "Toggle diff", keys: map[KeySequence]struct{}{}},
+		}},
+		{title: "Bookmarks", actions: []helpAction{
+			{desc: "Go to previous bookmark", keys: map[KeySequence]struct{}{mustParseKeymap("["): {}}},
+		}},
+	})
+
+	assert.Equal(t, " [::u]General[-:-:-]\n\n"+
+		"   Quit                    : [yellow]Ctrl-C, Shift-Q[-:-:-]\n"+
+		"   Toggle diff             : [yellow](unbound)[-:-:-]\n"+
+		"\n [::u]Bookmarks[-:-:-]\n\n"+
+		"   Go to previous bookmark : [yellow][[-:-:-]\n", got)
+}
+
+func TestHelpSectionsParseBack(t *testing.T) {
+	conf, err := newConfig(viper.New(), []string{"ls"})
+	assert.NoError(t, err)
+
+	count := 0
+
+	for _, s := range conf.keymap.helpSections() {
+		for _, a := range s.actions {
+			for seq := range a.keys {
+				formatted := formatKeySequence(seq)
+
+				parsed, err := ParseKeySequence(formatted)
+				assert.NoError(t, err, formatted)
+				assert.Equal(t, seq, parsed, formatted)
+			}
+
+			count++
+		}
+	}
+
+	// Every action is on the help page.
+	assert.Equal(t, len(conf.keymap.generalBindings())+len(conf.keymap.timeMachineBindings()), count)
+	assert.False(t, strings.Contains(formatHelp(conf.keymap.helpSections()), unboundKeys))
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// defaultHighlightColor colors the matches of a highlight without a color.
+const defaultHighlightColor = tcell.ColorRed
+
+type highlightError struct {
+	spec string
+	err  error
+}
+
+func (e highlightError) Error() string {
+	return fmt.Sprintf("invalid highlight %q: %v", e.spec, e.err)
+}
+
+func (e highlightError) Unwrap() error {
+	return e.err
+}
+
+var errEmptyHighlight = errors.New("the regexp is empty")
+
+// highlightRule colors the matches of the pattern in every output, whether
+// they changed or not.
+type highlightRule struct {
+	spec    string
+	pattern *regexp.Regexp
+	color   tcell.Color
+}
+
+// parseHighlight parses "REGEX[:color]". The color is whatever follows the
+// last colon, so a regexp with a colon needs a color after it.
+func parseHighlight(spec string) (highlightRule, error) {
+	pattern, color := spec, defaultHighlightColor
+
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		c, ok := parseColor(spec[i+1:])
+		if !ok {
+			return highlightRule{}, highlightError{
+				spec: spec,
+				err:  fmt.Errorf("unknown color %q, a regexp with \":\" needs a color after it", spec[i+1:]),
+			}
+		}
+
+		pattern, color = spec[:i], c
+	}
+
+	if pattern == "" {
+		return highlightRule{}, highlightError{spec: spec, err: errEmptyHighlight}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return highlightRule{}, highlightError{spec: spec, err: err}
+	}
+
+	return highlightRule{spec: spec, pattern: re, color: color}, nil
+}
+
+// getHighlights parses the highlights of the flags followed by those of the
+// config, so that the flags win where they overlap.
+func getHighlights(v *viper.Viper, key string, args []string) ([]highlightRule, error) {
+	entries := append([]string{}, args...)
+
+	switch value := v.Get(key).(type) {
+	case nil:
+	case string:
+		entries = append(entries, value)
+	default:
+		list, err := cast.ToStringSliceE(value)
+		if err != nil {
+			return nil, highlightError{spec: cast.ToString(value), err: err}
+		}
+
+		entries = append(entries, list...)
+	}
+
+	var rules []highlightRule
+
+	for _, entry := range entries {
+		rule, err := parseHighlight(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// highlightMask is the color of the characters of an output which the
+// highlights match, by line and column like diffMask.
+type highlightMask [][]tcell.Color
+
+func (m highlightMask) at(line, col int) tcell.Color {
+	if line < len(m) && col < len(m[line]) {
+		return m[line][col]
+	}
+
+	return tcell.ColorDefault
+}
+
+// next returns the color of the character of the runes at the position, and
+// moves the position past it. A character of several runes is highlighted
+// if any of them is.
+func (m highlightMask) next(p *textPosition, runes []rune) tcell.Color {
+	c := tcell.ColorDefault
+
+	for _, r := range runes {
+		if c == tcell.ColorDefault {
+			c = m.at(p.line, p.col)
+		}
+
+		p.advanceRune(r)
+	}
+
+	return c
+}
+
+// textPosition is a line and a column of runes in an output.
+type textPosition struct {
+	line, col int
+}
+
+func (p *textPosition) advanceRune(r rune) {
+	if r == '\n' {
+		p.line++
+		p.col = 0
+	} else {
+		p.col++
+	}
+}
+
+func (p *textPosition) advance(text string) {
+	if i := strings.LastIndex(text, "\n"); i >= 0 {
+		p.line += strings.Count(text, "\n")
+		p.col = utf8.RuneCountInString(text[i+1:])
+	} else {
+		p.col += utf8.RuneCountInString(text)
+	}
+}
+
+// highlightPositions finds the matches of the rules in every line of the
+// text. The first rule wins where matches overlap. Escape sequences are left
+// out of the lines the rules see, and are never highlighted.
+func highlightPositions(text string, rules []highlightRule) highlightMask {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	m := make(highlightMask, len(lines))
+
+	for i, line := range lines {
+		plain, cols := withoutEscapes(line)
+
+		var colors []tcell.Color
+
+		for _, rule := range rules {
+			for _, loc := range rule.pattern.FindAllStringIndex(plain, -1) {
+				for b := loc[0]; b < loc[1]; b++ {
+					col := cols[b]
+
+					for len(colors) <= col {
+						colors = append(colors, tcell.ColorDefault)
+					}
+
+					if colors[col] == tcell.ColorDefault {
+						colors[col] = rule.color
+					}
+				}
+			}
+		}
+
+		m[i] = colors
+	}
+
+	return m
+}
+
+// withoutEscapes returns the line without its escape sequences, and for every
+// byte of it the column of its rune in the line.
+func withoutEscapes(line string) (string, []int) {
+	var plain strings.Builder
+
+	cols := make([]int, 0, len(line))
+	escapes := ansiEscape.FindAllStringIndex(line, -1)
+	col := 0
+
+	for pos := 0; pos < len(line); {
+		if len(escapes) > 0 && escapes[0][0] == pos {
+			col += utf8.RuneCountInString(line[pos:escapes[0][1]])
+			pos = escapes[0][1]
+			escapes = escapes[1:]
+
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(line[pos:])
+
+		plain.WriteString(line[pos : pos+size])
+
+		for j := 0; j < size; j++ {
+			cols = append(cols, col)
+		}
+
+		pos += size
+		col++
+	}
+
+	return plain.String(), cols
+}
+
+// highlightTags returns the style tags of a character with the colors of the
+// diff, its foreground replaced by the color of a highlight if there is one.
+func highlightTags(fg, bg string, c tcell.Color) string {
+	if c != tcell.ColorDefault {
+		fg = colorTag(c)
+	}
+
+	return colorTags(fg, bg)
+}
+
+// HighlightPrettyText colors the characters of the text in the mask.
+func HighlightPrettyText(text string, m highlightMask) string {
+	var (
+		buff bytes.Buffer
+		p    textPosition
+	)
+
+	g := uniseg.NewGraphemes(text)
+	for g.Next() {
+		writeHighlighted(&buff, g.Str(), highlightTags("", "", m.next(&p, g.Runes())))
+	}
+
+	return buff.String()
+}
+
+// writeHighlightedText writes the text with the colors of the mask from the
+// position, and moves the position past it.
+func writeHighlightedText(buff *bytes.Buffer, text string, m highlightMask, p *textPosition) {
+	if m == nil {
+		_, _ = buff.WriteString(text)
+		p.advance(text)
+
+		return
+	}
+
+	g := uniseg.NewGraphemes(text)
+	for g.Next() {
+		writeHighlighted(buff, g.Str(), highlightTags("", "", m.next(p, g.Runes())))
+	}
+}
+
+// movedLine renders the raw line without its escape sequences on the
+// background, with the colors of the highlights of the line.
+func movedLine(raw string, line int, bg tcell.Color, m highlightMask) string {
+	plain, cols := withoutEscapes(raw)
+
+	var (
+		b     strings.Builder
+		run   strings.Builder
+		color = tcell.ColorDefault
+	)
+
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+
+		fmt.Fprintf(&b, "%s%s[-:-:-]", highlightTags("", colorTag(bg), color), tview.Escape(run.String()))
+		run.Reset()
+	}
+
+	for pos, r := range plain {
+		if c := m.at(line, cols[pos]); c != color {
+			flush()
+
+			color = c
+		}
+
+		run.WriteRune(r)
+	}
+
+	flush()
+
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHighlight(t *testing.T) {
+	tests := []struct {
+		spec    string
+		pattern string
+		color   tcell.Color
+		wantErr bool
+	}{
+		{spec: "ERROR|FATAL", pattern: "ERROR|FATAL", color: tcell.ColorRed},
+		{spec: "Running:green", pattern: "Running", color: tcell.ColorGreen},
+		{spec: `\d+:\d+:#ff8800`, pattern: `\d+:\d+`, color: tcell.NewHexColor(0xff8800)},
+		{spec: `\d+:\d+`, wantErr: true},
+		{spec: ":red", wantErr: true},
+		{spec: "(unclosed", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.spec, func(t *testing.T) {
+			rule, err := parseHighlight(tt.spec)
+			if tt.wantErr {
+				var herr highlightError
+				assert.ErrorAs(t, err, &herr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.spec, rule.spec)
+			assert.Equal(t, tt.pattern, rule.pattern.String())
+			assert.Equal(t, tt.color, rule.color)
+		})
+	}
+}
+
+func TestGetHighlights(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(bytes.NewBufferString("[general]\nhighlight = [\"Running:green\"]\n")))
+
+	rules, err := getHighlights(v, "general.highlight", []string{"ERROR"})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "ERROR", rules[0].spec)
+	assert.Equal(t, "Running:green", rules[1].spec)
+
+	v.Set("general.highlight", "FATAL:yellow")
+	rules, err = getHighlights(v, "general.highlight", nil)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, tcell.ColorYellow, rules[0].color)
+}
+
+func TestHighlightPositions(t *testing.T) {
+	rules := []highlightRule{
+		{pattern: regexp.MustCompile("bc"), color: tcell.ColorRed},
+		{pattern: regexp.MustCompile("abcd"), color: tcell.ColorGreen},
+	}
+
+	assert.Nil(t, highlightPositions("abcd", nil))
+
+	// The first rule wins where they overlap.
+	m := highlightPositions("abcd\nxabcd", rules)
+	assert.Equal(t, highlightMask{
+		{tcell.ColorGreen, tcell.ColorRed, tcell.ColorRed, tcell.ColorGreen},
+		{tcell.ColorDefault, tcell.ColorGreen, tcell.ColorRed, tcell.ColorRed, tcell.ColorGreen},
+	}, m)
+
+	// Escape sequences are left out of the matches.
+	m = highlightPositions("a\x1b[31mb\x1b[0mcd", rules[1:])
+	assert.Equal(t, tcell.ColorGreen, m.at(0, 0))
+	assert.Equal(t, tcell.ColorDefault, m.at(0, 1))
+	assert.Equal(t, tcell.ColorGreen, m.at(0, 6))
+	assert.Equal(t, tcell.ColorGreen, m.at(0, 12))
+}
+
+func TestHighlightPrettyText(t *testing.T) {
+	rules := []highlightRule{{pattern: regexp.MustCompile("ERR"), color: tcell.ColorRed}}
+	text := "ok ERR\n"
+
+	assert.Equal(t, "ok [red:]E[-:-:-][red:]R[-:-:-][red:]R[-:-:-]\n", HighlightPrettyText(text, highlightPositions(text, rules)))
+}
+
+func TestDiffPrettyTextHighlights(t *testing.T) {
+	th := theme{diffAdded: tcell.ColorGreen, diffChangedBackground: tcell.ColorGreen}
+	diffs := []diffmatchpatch.Diff{
+		{Type: diffmatchpatch.DiffEqual, Text: "ERR "},
+		{Type: diffmatchpatch.DiffDelete, Text: "a"},
+		{Type: diffmatchpatch.DiffInsert, Text: "b"},
+		{Type: diffmatchpatch.DiffEqual, Text: "\n"},
+	}
+
+	rules := []highlightRule{{pattern: regexp.MustCompile("R|b"), color: tcell.ColorRed}}
+	hl := highlightPositions("ERR b\n", rules)
+
+	// The highlights keep the background of the changes.
+	assert.Equal(t, "E[red:]R[-:-:-][red:]R[-:-:-] [red:green]b[-:-:-]\n", DiffPrettyText(diffs, hl, th))
+}
+
+func TestMovedLine(t *testing.T) {
+	rules := []highlightRule{{pattern: regexp.MustCompile("b"), color: tcell.ColorRed}}
+	raw := "a\x1b[1mb[c]"
+
+	assert.Equal(t, "[:blue]a[-:-:-][red:blue]b[-:-:-][:blue][c[][-:-:-]",
+		movedLine(raw, 0, tcell.ColorBlue, highlightPositions(raw, rules)))
+	assert.Equal(t, "[:blue]ab[c[][-:-:-]", movedLine(raw, 0, tcell.ColorBlue, nil))
+}
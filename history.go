@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyRecord is a line of the history file: a run as --batch-format json
+// writes it, with the command it ran if that is not the one of the line
+// before.
+type historyRecord struct {
+	Command []string `json:"command,omitempty"`
+	batchRecord
+}
+
+type historyFileError struct {
+	path string
+	err  error
+}
+
+func (e historyFileError) Error() string {
+	return fmt.Sprintf("cannot use the history file %q: %v", e.path, e.err)
+}
+
+func (e historyFileError) Unwrap() error {
+	return e.err
+}
+
+// historyFile appends every run to a file, one line of JSON each, so that
+// --load can look back at them after viddy quit.
+type historyFile struct {
+	sync.Mutex
+
+	path string
+	file *os.File
+	// command is the command of the line written last, which the lines
+	// after it leave out until it changes.
+	command string
+}
+
+func openHistoryFile(path string) (*historyFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644) //nolint:gosec
+	if err != nil {
+		return nil, historyFileError{path: path, err: err}
+	}
+
+	return &historyFile{path: path, file: f}, nil
+}
+
+// write appends the run. A nil file ignores it.
+func (h *historyFile) write(s *Snapshot, changed bool) error {
+	if h == nil {
+		return nil
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	r := historyRecord{batchRecord: newBatchRecord(s, changed)}
+	if line := s.commandLine(); line != h.command {
+		r.Command = append([]string{s.command}, s.args...)
+		h.command = line
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return historyFileError{path: h.path, err: err}
+	}
+
+	if _, err := h.file.Write(append(b, '\n')); err != nil {
+		return historyFileError{path: h.path, err: err}
+	}
+
+	return nil
+}
+
+func (h *historyFile) Close() error {
+	if h == nil {
+		return nil
+	}
+
+	return h.file.Close()
+}
+
+var errHistoryEmpty = errors.New("no runs in it")
+
+// loadHistory reads a history file, or a session file of --session, as a
+// session to look back at. The last line may have been cut short by viddy
+// stopping while it wrote it, and is left out then.
+func loadHistory(path string) (*session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, historyFileError{path: path, err: err}
+	}
+
+	var saved session
+	if json.Unmarshal(data, &saved) == nil && saved.Version != 0 {
+		if err := saved.validate(); err != nil {
+			return nil, historyFileError{path: path, err: err}
+		}
+
+		return &saved, nil
+	}
+
+	records, err := readHistoryRecords(bytes.NewReader(data))
+	if err != nil {
+		return nil, historyFileError{path: path, err: err}
+	}
+
+	if len(records) == 0 {
+		return nil, historyFileError{path: path, err: errHistoryEmpty}
+	}
+
+	return historySession(records), nil
+}
+
+// readHistoryRecords reads the lines, filling in the command each one ran.
+func readHistoryRecords(r io.Reader) ([]historyRecord, error) {
+	var (
+		records []historyRecord
+		command []string
+	)
+
+	br := bufio.NewReader(r)
+
+	for n := 1; ; n++ {
+		line, readErr := br.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+
+		if strings.TrimSpace(string(line)) != "" {
+			var rec historyRecord
+
+			if err := json.Unmarshal(line, &rec); err != nil {
+				// Only a last line without its line break was cut short.
+				if readErr == io.EOF {
+					break
+				}
+
+				return nil, fmt.Errorf("corrupt line %d: %w", n, err)
+			}
+
+			if len(rec.Command) > 0 {
+				command = rec.Command
+			}
+
+			if len(command) == 0 {
+				return nil, fmt.Errorf("corrupt line %d: no command", n)
+			}
+
+			rec.Command = command
+			records = append(records, rec)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	return records, nil
+}
+
+// historySession returns the records as a session whose snapshots count from
+// the first run, taking the command of the last one.
+func historySession(records []historyRecord) *session {
+	// Runs may finish out of order, and so be written.
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+
+	last := records[len(records)-1].Command
+	s := &session{
+		Version: sessionVersion,
+		Command: last,
+		Begin:   records[0].Time,
+	}
+
+	id := int64(-1)
+
+	for _, r := range records {
+		next := r.Time.Sub(s.Begin).Milliseconds()
+		if next <= id {
+			next = id + 1
+		}
+
+		id = next
+
+		saved := sessionSnapshot{
+			ID:          id,
+			Start:       r.Time,
+			End:         r.Time.Add(time.Duration(r.Duration * float64(time.Second))),
+			Result:      []byte(r.Output),
+			ErrorResult: []byte(r.Stderr),
+			ExitCode:    r.ExitCode,
+			Err:         r.Error,
+		}
+
+		if strings.Join(r.Command, "\x00") != strings.Join(last, "\x00") {
+			saved.Command = r.Command
+		}
+
+		s.Snapshots = append(s.Snapshots, saved)
+	}
+
+	return s
+}
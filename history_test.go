@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pods.history")
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	h, err := openHistoryFile(path)
+	require.NoError(t, err)
+
+	snapshots := []*Snapshot{
+		{command: "kubectl", args: []string{"get", "pods"}, start: start, end: start.Add(time.Second), result: []byte("a\n")},
+		{
+			command: "kubectl", args: []string{"get", "pods"}, start: start.Add(2 * time.Second), end: start.Add(3 * time.Second),
+			result: []byte("b\n"), exitCode: 1,
+		},
+		{command: "kubectl get pods -A", start: start.Add(4 * time.Second), end: start.Add(4 * time.Second), result: []byte("c\n")},
+	}
+
+	for i, s := range snapshots {
+		require.NoError(t, h.write(s, i > 0))
+	}
+
+	require.NoError(t, h.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// The command is only written when it changes.
+	var first, second historyRecord
+	lines := splitLines(string(data))
+	require.Len(t, lines, 3)
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, []string{"kubectl", "get", "pods"}, first.Command)
+	assert.Empty(t, second.Command)
+	assert.Equal(t, 1, second.ExitCode)
+
+	// A line cut short by viddy stopping is left out.
+	require.NoError(t, os.WriteFile(path, append(data, `{"time":"2021-09-01T12:00:0`...), 0o600))
+
+	saved, err := loadHistory(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"kubectl get pods -A"}, saved.Command)
+	assert.Equal(t, start, saved.Begin)
+	require.Len(t, saved.Snapshots, 3)
+
+	assert.Equal(t, int64(0), saved.Snapshots[0].ID)
+	assert.Equal(t, int64(2000), saved.Snapshots[1].ID)
+	assert.Equal(t, []string{"kubectl", "get", "pods"}, saved.Snapshots[1].Command)
+	assert.Equal(t, 1, saved.Snapshots[1].ExitCode)
+	assert.Equal(t, start.Add(3*time.Second), saved.Snapshots[1].End)
+	assert.Empty(t, saved.Snapshots[2].Command)
+
+	restored := saved.restore()
+	assert.Equal(t, "b\n", string(restored[1].result))
+	assert.Equal(t, "kubectl get pods", restored[1].commandLine())
+	assert.Same(t, restored[1], restored[2].before)
+}
+
+func TestLoadHistory(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	sessionData, err := json.Marshal(session{Version: sessionVersion, Command: []string{"df"}, Begin: start,
+		Snapshots: []sessionSnapshot{{ID: 10, Start: start, Result: []byte("x\n")}}})
+	require.NoError(t, err)
+
+	files := map[string]string{
+		"session":    string(sessionData),
+		"empty":      "",
+		"corrupt":    "{\"time\":\n{\"command\":[\"df\"]}\n",
+		"no command": `{"time":"2021-09-01T12:00:00Z","output":"x"}` + "\n",
+		"same time":  `{"command":["df"],"time":"2021-09-01T12:00:00Z"}` + "\n" + `{"time":"2021-09-01T12:00:00Z"}` + "\n",
+		"out of order": `{"command":["df"],"time":"2021-09-01T12:00:05Z","output":"late"}` + "\n" +
+			`{"time":"2021-09-01T12:00:01Z","output":"early"}` + "\n",
+	}
+
+	load := func(name string) (*session, error) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(files[name]), 0o600))
+
+		return loadHistory(path)
+	}
+
+	saved, err := load("session")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), saved.Snapshots[0].ID)
+
+	for _, name := range []string{"empty", "corrupt", "no command"} {
+		_, err := load(name)
+		assert.Error(t, err, name)
+	}
+
+	saved, err = load("same time")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), saved.Snapshots[1].ID)
+
+	saved, err = load("out of order")
+	require.NoError(t, err)
+	assert.Equal(t, "early", string(saved.Snapshots[0].Result))
+	assert.Equal(t, int64(4000), saved.Snapshots[1].ID)
+
+	_, err = loadHistory(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
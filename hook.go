@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errRunKilled = errors.New("the run was killed")
+
+type hookError struct {
+	name     string
+	exitCode int
+	output   string
+	err      error
+}
+
+func (e hookError) Error() string {
+	msg := fmt.Sprintf("%s hook failed: %v", e.name, e.err)
+	if e.exitCode > 0 {
+		msg = fmt.Sprintf("%s hook exited with %d", e.name, e.exitCode)
+	}
+
+	if e.output != "" {
+		msg += ": " + e.output
+	}
+
+	return msg
+}
+
+func (e hookError) Unwrap() error {
+	return e.err
+}
+
+// hookEnv describes the snapshot to a hook.
+func hookEnv(s *Snapshot) []string {
+	return []string{
+		"VIDDY_COMMAND=" + s.commandLine(),
+		"VIDDY_TIMESTAMP=" + s.start.Format(time.RFC3339),
+		"VIDDY_EXIT_CODE=" + strconv.Itoa(s.exitCode),
+	}
+}
+
+// hookCommand prepares the command line to run through the shell of the
+// snapshot with the snapshot in its environment and its output on stdin.
+// The output of the hook is collected in the buffer.
+func hookCommand(cmdline string, s *Snapshot) (*exec.Cmd, *bytes.Buffer) {
+	command := shellCommand(s.opts.shell, s.opts.shellOpts, cmdline)
+	command.Env = append(applyEnv(os.Environ(), s.opts.env), hookEnv(s)...)
+	command.Stdin = bytes.NewReader(s.result)
+
+	// Hooks run locally, the directory of a remote command is on the host.
+	if s.opts.remote == nil {
+		command.Dir = s.opts.dir
+	}
+
+	var out bytes.Buffer
+	command.Stdout = &out
+	command.Stderr = &out
+
+	return command, &out
+}
+
+func newHookError(name string, command *exec.Cmd, out *bytes.Buffer, err error) hookError {
+	return hookError{
+		name:     name,
+		exitCode: command.ProcessState.ExitCode(),
+		output:   strings.TrimSpace(firstLine(out.String())),
+		err:      err,
+	}
+}
+
+// runHook runs the command line of the hook for the snapshot and waits for it.
+func runHook(name, cmdline string, s *Snapshot) error {
+	command, out := hookCommand(cmdline, s)
+
+	if err := command.Run(); err != nil {
+		return newHookError(name, command, out, err)
+	}
+
+	return nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
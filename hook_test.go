@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	s := &Snapshot{
+		command:  "kubectl",
+		args:     []string{"get", "pods"},
+		opts:     runOptions{shell: "sh"},
+		result:   []byte("pod-a Running\n"),
+		start:    time.Date(2024, 3, 1, 14, 35, 0, 0, time.UTC),
+		exitCode: 3,
+	}
+
+	err := runHook("on-change", `{ echo "$VIDDY_COMMAND|$VIDDY_TIMESTAMP|$VIDDY_EXIT_CODE"; cat; } > `+out, s)
+	assert.NoError(t, err)
+
+	got, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "kubectl get pods|2024-03-01T14:35:00Z|3\npod-a Running\n", string(got))
+
+	err = runHook("on-change", "echo broken >&2; exit 2", s)
+	assert.EqualError(t, err, "on-change hook exited with 2: broken")
+}
+
+func TestEachHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	dir := t.TempDir()
+	run := func(opts runOptions, command string) *Snapshot {
+		opts.shell = "sh"
+		opts.dir = dir
+
+		finishedQueue := make(chan int64, 1)
+		s := NewSnapshot(0, command, nil, opts, nil, make(chan struct{}))
+		_ = s.run(finishedQueue)
+		<-finishedQueue
+
+		return s
+	}
+
+	s := run(runOptions{beforeEach: "echo ran > before", afterEach: `echo "$VIDDY_EXIT_CODE" > after`}, "cat before; exit 3")
+	assert.Equal(t, "ran\n", string(s.result))
+	assert.Equal(t, 3, s.exitCode)
+	assert.False(t, s.hookFailed)
+	assert.NoError(t, s.hookErr)
+
+	got, err := os.ReadFile(filepath.Join(dir, "after"))
+	assert.NoError(t, err)
+	assert.Equal(t, "3\n", string(got))
+
+	// The command is skipped when the before-each hook fails.
+	s = run(runOptions{beforeEach: "echo expired >&2; exit 1"}, "touch ran")
+	assert.True(t, s.hookFailed)
+	assert.EqualError(t, s.err, "before-each hook exited with 1: expired")
+	assert.Equal(t, "before-each hook exited with 1: expired\n", string(s.errorResult))
+	assert.NoFileExists(t, filepath.Join(dir, "ran"))
+
+	s = run(runOptions{afterEach: "exit 2"}, "echo ok")
+	assert.Equal(t, "ok\n", string(s.result))
+	assert.EqualError(t, s.hookErr, "after-each hook exited with 2")
+}
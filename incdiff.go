@@ -0,0 +1,303 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// lineHashes returns the FNV-1a hash of every line, so that equal lines are
+// found without comparing them character by character.
+func lineHashes(lines []string) []uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+
+	hashes := make([]uint64, len(lines))
+
+	for i, line := range lines {
+		h := uint64(offset)
+		for j := 0; j < len(line); j++ {
+			h ^= uint64(line[j])
+			h *= prime
+		}
+
+		hashes[i] = h
+	}
+
+	return hashes
+}
+
+// diffHashedLines diffs the lines by the keys they are compared with and
+// the hashes of the keys, which are the lines themselves unless normalized.
+// Equal lines are those after. The lines which the outputs start and end
+// with are left out first, which is most of them when a large output hardly
+// changes.
+//
+//nolint:funlen
+func diffHashedLines(before, after, beforeKeys, afterKeys []string, beforeHashes, afterHashes []uint64) []diffmatchpatch.Diff {
+	same := func(i, j int) bool {
+		return beforeHashes[i] == afterHashes[j] && beforeKeys[i] == afterKeys[j]
+	}
+
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && same(prefix, prefix) {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		same(len(before)-1-suffix, len(after)-1-suffix) {
+		suffix++
+	}
+
+	var diffs []diffmatchpatch.Diff
+
+	if prefix > 0 {
+		diffs = append(diffs, diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: strings.Join(after[:prefix], "")})
+	}
+
+	// Every distinct line in between is encoded as a single rune so that the
+	// character diff works on whole lines.
+	index := map[uint64]rune{}
+
+	encode := func(hashes []uint64) []rune {
+		runes := make([]rune, 0, len(hashes))
+
+		for _, h := range hashes {
+			r, ok := index[h]
+			if !ok {
+				r = rune(len(index) + 1)
+				if r >= 0xD800 {
+					// Skip surrogates, they do not survive the conversion to string.
+					r += 0x800
+				}
+
+				index[h] = r
+			}
+
+			runes = append(runes, r)
+		}
+
+		return runes
+	}
+
+	b := encode(beforeHashes[prefix : len(before)-suffix])
+	a := encode(afterHashes[prefix : len(after)-suffix])
+
+	// The diff tells how many lines of either side every part has.
+	i, j := prefix, prefix
+
+	for _, diff := range dmp.DiffMainRunes(b, a, false) {
+		n := utf8.RuneCountInString(diff.Text)
+
+		var lines []string
+
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			lines = before[i : i+n]
+			i += n
+		case diffmatchpatch.DiffInsert:
+			lines = after[j : j+n]
+			j += n
+		case diffmatchpatch.DiffEqual:
+			lines = after[j : j+n]
+			i, j = i+n, j+n
+		}
+
+		diffs = append(diffs, diffmatchpatch.Diff{Type: diff.Type, Text: strings.Join(lines, "")})
+	}
+
+	if suffix > 0 {
+		diffs = append(diffs, diffmatchpatch.Diff{
+			Type: diffmatchpatch.DiffEqual,
+			Text: strings.Join(after[len(after)-suffix:], ""),
+		})
+	}
+
+	return diffs
+}
+
+// hunkContext is how many unchanged lines around every hunk are diffed
+// along with it. The character diff moves changes to where they read best,
+// which may be in the lines around them.
+const hunkContext = 2
+
+// refineLineDiffs diffs the removed and inserted lines of every hunk of the
+// line diff with refine, character by character as diffGraphemes does by
+// default, with the lines around them. The other lines are equal as they
+// are, so they are not segmented into characters, which is where the time
+// goes on large outputs.
+func refineLineDiffs(diffs []diffmatchpatch.Diff, refine func(before, after string) []diffmatchpatch.Diff) []diffmatchpatch.Diff {
+	var (
+		refined           []diffmatchpatch.Diff
+		removed, inserted strings.Builder
+		changed           bool
+	)
+
+	both := func(text string) {
+		removed.WriteString(text)
+		inserted.WriteString(text)
+	}
+
+	flush := func() {
+		refined = appendDiffs(refined, refine(removed.String(), inserted.String())...)
+		changed = false
+
+		removed.Reset()
+		inserted.Reset()
+	}
+
+	for i, diff := range diffs {
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			removed.WriteString(diff.Text)
+			changed = true
+
+			continue
+		case diffmatchpatch.DiffInsert:
+			inserted.WriteString(diff.Text)
+			changed = true
+
+			continue
+		}
+
+		text := diff.Text
+		last := i == len(diffs)-1
+
+		if changed {
+			// Hunks close to each other are diffed together.
+			if !last && strings.Count(text, "\n") <= 2*hunkContext {
+				both(text)
+
+				continue
+			}
+
+			head := text[:afterLines(text, hunkContext)]
+			both(head)
+			flush()
+
+			text = text[len(head):]
+		}
+
+		if last {
+			refined = appendDiffs(refined, diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: text})
+
+			continue
+		}
+
+		tail := beforeLines(text, hunkContext)
+		refined = appendDiffs(refined, diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: text[:tail]})
+		both(text[tail:])
+	}
+
+	if changed {
+		flush()
+	}
+
+	return refined
+}
+
+// afterLines returns where the text goes on after its first n lines.
+func afterLines(text string, n int) int {
+	i := 0
+
+	for ; n > 0; n-- {
+		j := strings.IndexByte(text[i:], '\n')
+		if j < 0 {
+			return len(text)
+		}
+
+		i += j + 1
+	}
+
+	return i
+}
+
+// beforeLines returns where the last n lines of the text start.
+func beforeLines(text string, n int) int {
+	i := len(text)
+	if strings.HasSuffix(text, "\n") {
+		i--
+	}
+
+	for ; n > 0; n-- {
+		j := strings.LastIndexByte(text[:i], '\n')
+		if j < 0 {
+			return 0
+		}
+
+		i = j
+	}
+
+	return i + 1
+}
+
+// appendDiffs appends the diffs, merging those of the same type which meet.
+func appendDiffs(diffs []diffmatchpatch.Diff, more ...diffmatchpatch.Diff) []diffmatchpatch.Diff {
+	for _, d := range more {
+		if d.Text == "" {
+			continue
+		}
+
+		if last := len(diffs) - 1; last >= 0 && diffs[last].Type == d.Type {
+			diffs[last].Text += d.Text
+
+			continue
+		}
+
+		diffs = append(diffs, d)
+	}
+
+	return diffs
+}
+
+// diffOutputs diffs the outputs of two snapshots line by line, and the
+// changed lines at the granularity. The hashes of the lines are those of the
+// snapshots, so that those of the previous output are not computed again.
+// The lines are compared as n normalizes them.
+func diffOutputs(before, after string, beforeHashes, afterHashes func([]string) []uint64,
+	n diffNormalize, g DiffGranularity,
+) ([]diffmatchpatch.Diff, *lineMap) {
+	refine := func(lines []diffmatchpatch.Diff) []diffmatchpatch.Diff {
+		if r := g.refine(); r != nil {
+			return refineLineDiffs(lines, r)
+		}
+
+		return lines
+	}
+
+	b, a := splitLines(before), splitLines(after)
+	if n.isZero() {
+		lines := diffHashedLines(b, a, b, a, beforeHashes(b), afterHashes(a))
+
+		return refine(lines), lineMapOf(lines, a)
+	}
+
+	// Outputs which only differ in what is ignored did not change at all.
+	if n.text(b) == n.text(a) {
+		lines := appendDiffs(nil, diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: after})
+
+		return lines, lineMapOf(lines, a)
+	}
+
+	nb, na := n.lines(b), n.lines(a)
+	lines := diffHashedLines(b, a, nb, na, beforeHashes(nb), afterHashes(na))
+
+	return refine(lines), lineMapOf(lines, a)
+}
+
+// hashLines returns the hashes of the lines of the output, which are kept
+// for the next snapshot to compare with.
+func (s *Snapshot) hashLines(lines []string) []uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.hashes == nil {
+		s.hashes = lineHashes(lines)
+	}
+
+	return s.hashes
+}
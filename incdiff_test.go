@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/stretchr/testify/assert"
+)
+
+// table returns a kubectl-like output of n pods, with the status of the pods
+// in changed replaced.
+func table(n int, changed map[int]string) string {
+	var b strings.Builder
+
+	b.WriteString("NAME\tREADY\tSTATUS\tRESTARTS\tAGE\n")
+
+	for i := 0; i < n; i++ {
+		status := "Running"
+		if s, ok := changed[i]; ok {
+			status = s
+		}
+
+		fmt.Fprintf(&b, "web-%05d\t1/1\t%s\t%d\t%dm\n", i, status, i%7, i%60)
+	}
+
+	return b.String()
+}
+
+func TestDiffOutputsMatchesDiffGraphemes(t *testing.T) {
+	th := theme{diffAdded: tcell.ColorBlue, diffRemoved: tcell.ColorRed, diffChangedBackground: tcell.ColorGreen}
+
+	for _, tt := range []struct {
+		name          string
+		before, after string
+	}{
+		{name: "empty", before: "", after: ""},
+		{name: "first run", before: "", after: "a\nb\n"},
+		{name: "cleared", before: "a\nb\n", after: ""},
+		{name: "same", before: table(50, nil), after: table(50, nil)},
+		{name: "one cell", before: table(200, nil), after: table(200, map[int]string{120: "Pending"})},
+		{name: "two lines", before: table(200, nil), after: table(200, map[int]string{3: "Pending", 190: "Terminating"})},
+		{name: "neighbours", before: table(200, nil), after: table(200, map[int]string{50: "Pending", 52: "Pending"})},
+		{name: "appended", before: table(20, nil), after: table(22, nil)},
+		{name: "truncated", before: table(22, nil), after: table(20, nil)},
+		{name: "inserted", before: "a\nb\nd\ne\n", after: "a\nb\nc\nd\ne\n"},
+		{name: "removed", before: "a\nb\nc\nd\ne\n", after: "a\nb\nd\ne\n"},
+		{name: "counter", before: "uptime 99\nload 0.5\n", after: "uptime 100\nload 0.5\n"},
+		{name: "no newline", before: "a\nb\n", after: "a\nb"},
+		{name: "replaced", before: "foo\nbar\n", after: "baz\nqux\n"},
+		{name: "graphemes", before: "ok 👍🏽\ncafé\n", after: "ok 👍🏿\ncafè\n"},
+		{name: "wide", before: "名前\t状態\n東京\t晴れ\n", after: "名前\t状態\n東京\t雨\n"},
+	} {
+		naive := DiffPrettyText(diffGraphemes(tt.before, tt.after), nil, th)
+
+		diffs, _ := diffOutputs(tt.before, tt.after, lineHashes, lineHashes, diffNormalize{}, DiffGranularityChar)
+		assert.Equal(t, naive, DiffPrettyText(diffs, nil, th), tt.name)
+		assert.Equal(t, changedPositions(diffGraphemes(tt.before, tt.after)), changedPositions(diffs), tt.name)
+	}
+}
+
+func TestDiffHashedLines(t *testing.T) {
+	before, after := splitLines("a\nb\nc\nd\n"), splitLines("a\nx\nc\nd\ny\n")
+
+	assert.Equal(t, []diffmatchpatch.Diff{
+		{Type: diffmatchpatch.DiffEqual, Text: "a\n"},
+		{Type: diffmatchpatch.DiffDelete, Text: "b\n"},
+		{Type: diffmatchpatch.DiffInsert, Text: "x\n"},
+		{Type: diffmatchpatch.DiffEqual, Text: "c\nd\n"},
+		{Type: diffmatchpatch.DiffInsert, Text: "y\n"},
+	}, diffHashedLines(before, after, before, after, lineHashes(before), lineHashes(after)))
+}
+
+func TestSnapshotHashLines(t *testing.T) {
+	a := &Snapshot{id: 1, result: []byte("a\nb\n"), completed: true}
+	b := &Snapshot{id: 2, result: []byte("a\nc\n"), completed: true, before: a}
+
+	assert.NoError(t, a.compareFromBefore())
+	assert.NoError(t, b.compareFromBefore())
+
+	// The hashes of the previous output are those it kept.
+	assert.Equal(t, lineHashes(splitLines("a\nb\n")), a.hashes)
+	assert.Equal(t, lineHashes(splitLines("a\nc\n")), b.hashes)
+}
+
+func TestLines(t *testing.T) {
+	assert.Equal(t, 4, afterLines("a\nb\nc\n", 2))
+	assert.Equal(t, 6, afterLines("a\nb\nc\n", 5))
+	assert.Equal(t, 2, beforeLines("a\nb\nc\n", 2))
+	assert.Equal(t, 0, beforeLines("a\nb\nc\n", 5))
+	assert.Equal(t, 2, beforeLines("a\nb", 1))
+}
+
+// churned returns an output of n lines and the same with 1% of them changed.
+func churned(n int) (string, string) {
+	r := rand.New(rand.NewSource(1))
+
+	changed := map[int]string{}
+	for i := 0; i < n/100; i++ {
+		changed[r.Intn(n)] = "CrashLoopBackOff"
+	}
+
+	return table(n, nil), table(n, changed)
+}
+
+func BenchmarkDiff(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		before, after := churned(n)
+
+		b.Run(fmt.Sprintf("naive/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				diffGraphemes(before, after)
+				newLineMap(before, after)
+			}
+		})
+
+		// The hashes of the previous output are kept from when it was compared.
+		hashes := lineHashes(splitLines(before))
+		previous := func([]string) []uint64 { return hashes }
+
+		b.Run(fmt.Sprintf("incremental/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				diffOutputs(before, after, previous, lineHashes, diffNormalize{}, DiffGranularityChar)
+			}
+		})
+	}
+}
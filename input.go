@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+var errStdinTerminal = errors.New(
+	"--stdin-capture needs the input piped to viddy, such as: produce-data | viddy --stdin-capture jq .")
+
+// runInput is what the command reads on its stdin on every run. Without a
+// file or captured input, it reads nothing.
+type runInput struct {
+	// file is opened again on every run, so that changes to it are seen.
+	file string
+	// data is the stdin of viddy, captured once.
+	data []byte
+}
+
+// open returns the stdin of a run, nil for none, and a function which
+// closes it once the command exited.
+func (i runInput) open() (io.Reader, func(), error) {
+	switch {
+	case i.file != "":
+		f, err := os.Open(i.file)
+		if err != nil {
+			return nil, func() {}, fmt.Errorf("input: %w", err)
+		}
+
+		return f, func() { _ = f.Close() }, nil
+	case i.data != nil:
+		return bytes.NewReader(i.data), func() {}, nil
+	}
+
+	return nil, func() {}, nil
+}
+
+// captureStdin reads all of the stdin of viddy, which the runs read in turn
+// since the screen takes the terminal.
+func captureStdin(f *os.File) ([]byte, error) {
+	if term.IsTerminal(int(f.Fd())) {
+		return nil, errStdinTerminal
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("stdin: %w", err)
+	}
+
+	// Empty input is still fed, as opposed to no input.
+	if b == nil {
+		b = []byte{}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotInput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	path := filepath.Join(t.TempDir(), "query.sql")
+
+	run := func(input runInput) *Snapshot {
+		s := NewSnapshot(0, "cat", nil, runOptions{shell: "sh", input: input}, nil, make(chan struct{}))
+		assert.NoError(t, s.run(make(chan int64, 1)))
+
+		return s
+	}
+
+	// The file is read again on every run.
+	assert.NoError(t, os.WriteFile(path, []byte("select 1;\n"), 0o600))
+	assert.Equal(t, "select 1;\n", string(run(runInput{file: path}).result))
+
+	assert.NoError(t, os.WriteFile(path, []byte("select 2;\n"), 0o600))
+	assert.Equal(t, "select 2;\n", string(run(runInput{file: path}).result))
+
+	captured := runInput{data: []byte("{\"summary\": 1}\n")}
+	assert.Equal(t, "{\"summary\": 1}\n", string(run(captured).result))
+	assert.Equal(t, "{\"summary\": 1}\n", string(run(captured).result))
+
+	// Without input, the command reads nothing.
+	assert.Equal(t, "", string(run(runInput{}).result))
+
+	// A file which cannot be read fails the run, not viddy.
+	s := run(runInput{file: filepath.Join(t.TempDir(), "missing.sql")})
+	assert.Error(t, s.err)
+	assert.Contains(t, string(s.errorResult), "input: open ")
+	assert.Equal(t, 1, s.exitStatus())
+	assert.True(t, s.completed)
+}
+
+func TestCaptureStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+
+	// It fits in the buffer of the pipe.
+	_, err = w.Write([]byte("produced\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	b, err := captureStdin(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "produced\n", string(b))
+
+	r, w, err = os.Pipe()
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	// Empty input is fed all the same.
+	b, err = captureStdin(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{}, b)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxKeySequenceLength = 4
+
+// KeySequence is one or more keystrokes pressed in order, like "g g".
+// Unused trailing entries are zero, which no key parses to.
+type KeySequence [maxKeySequenceLength]KeyStroke
+
+func newKeySequence(strokes ...KeyStroke) KeySequence {
+	var seq KeySequence
+
+	copy(seq[:], strokes)
+
+	return seq
+}
+
+func (s KeySequence) strokes() []KeyStroke {
+	for i, stroke := range s {
+		if stroke == (KeyStroke{}) {
+			return s[:i]
+		}
+	}
+
+	return s[:]
+}
+
+// hasPrefix reports whether the sequence starts with, and is longer than, the strokes.
+func (s KeySequence) hasPrefix(prefix []KeyStroke) bool {
+	strokes := s.strokes()
+	if len(strokes) <= len(prefix) {
+		return false
+	}
+
+	for i, stroke := range prefix {
+		if strokes[i] != stroke {
+			return false
+		}
+	}
+
+	return true
+}
+
+type keySequenceTooLongError struct {
+	key string
+}
+
+func (e keySequenceTooLongError) Error() string {
+	return fmt.Sprintf("key sequence %q is longer than %d keys", e.key, maxKeySequenceLength)
+}
+
+// ParseKeySequence parses keys separated by spaces, such as "g g".
+// A string made only of spaces is the space key itself.
+func ParseKeySequence(key string) (KeySequence, error) {
+	if key != "" && strings.TrimSpace(key) == "" {
+		stroke, err := ParseKeyStroke(key)
+		if err != nil {
+			return KeySequence{}, err
+		}
+
+		return newKeySequence(stroke), nil
+	}
+
+	return parseKeyStrokes(strings.Fields(key), key)
+}
+
+func parseKeyStrokes(keys []string, original string) (KeySequence, error) {
+	if len(keys) == 0 {
+		return KeySequence{}, parseKeyStrokeError{key: original, reason: "empty key"}
+	}
+
+	if len(keys) > maxKeySequenceLength {
+		return KeySequence{}, keySequenceTooLongError{key: original}
+	}
+
+	strokes := make([]KeyStroke, 0, len(keys))
+
+	for _, k := range keys {
+		stroke, err := ParseKeyStroke(k)
+		if err != nil {
+			return KeySequence{}, err
+		}
+
+		strokes = append(strokes, stroke)
+	}
+
+	return newKeySequence(strokes...), nil
+}
+
+func formatKeySequence(seq KeySequence) string {
+	strokes := seq.strokes()
+	str := make([]string, 0, len(strokes))
+
+	for _, stroke := range strokes {
+		str = append(str, formatKeyStroke(stroke))
+	}
+
+	return strings.Join(str, " ")
+}
+
+// keyDispatcher matches keystrokes against key sequences of actions.
+// Actions of earlier scopes win over the ones of later scopes.
+type keyDispatcher struct {
+	pending []KeyStroke
+	// matched is the action bound to the first matchedLen pending keys.
+	matched    func()
+	matchedLen int
+}
+
+// feed handles a keystroke. It returns the action to run now, if any, and
+// whether it waits for more keys, in which case timeout must be called unless
+// another key comes first.
+func (d *keyDispatcher) feed(scopes [][]keyAction, stroke KeyStroke) (func(), bool) {
+	d.pending = append(d.pending, stroke)
+
+	var (
+		matched func()
+		longer  bool
+	)
+
+	seq := newKeySequence(d.pending...)
+
+	for _, actions := range scopes {
+		for _, a := range actions {
+			if _, ok := a.keys[seq]; ok && matched == nil {
+				matched = a.run
+			}
+
+			for k := range a.keys {
+				if k.hasPrefix(d.pending) {
+					longer = true
+				}
+			}
+		}
+	}
+
+	if longer && len(d.pending) < maxKeySequenceLength {
+		if matched != nil {
+			d.matched, d.matchedLen = matched, len(d.pending)
+		}
+
+		return nil, true
+	}
+
+	if matched == nil && len(d.pending) > 1 {
+		// Not a known sequence. Run the action of the longest bound prefix, if
+		// any, and start over from the keys after it, or else from the last key.
+		prefix, rest := d.matched, d.pending[len(d.pending)-1:]
+		if prefix != nil {
+			rest = d.pending[d.matchedLen:]
+		}
+
+		d.reset()
+
+		return d.refeed(scopes, prefix, rest)
+	}
+
+	d.reset()
+
+	return matched, false
+}
+
+// refeed feeds the strokes again after the action run, returning the actions
+// to run in order.
+func (d *keyDispatcher) refeed(scopes [][]keyAction, run func(), strokes []KeyStroke) (func(), bool) {
+	var (
+		runs    []func()
+		waiting bool
+	)
+
+	if run != nil {
+		runs = append(runs, run)
+	}
+
+	for _, stroke := range strokes {
+		var r func()
+
+		r, waiting = d.feed(scopes, stroke)
+		if r != nil {
+			runs = append(runs, r)
+		}
+	}
+
+	if len(runs) == 0 {
+		return nil, waiting
+	}
+
+	return func() {
+		for _, r := range runs {
+			r()
+		}
+	}, waiting
+}
+
+// timeout gives up waiting, returning the action bound to the keys pressed so far, if any.
+func (d *keyDispatcher) timeout() func() {
+	matched := d.matched
+	d.reset()
+
+	return matched
+}
+
+func (d *keyDispatcher) reset() {
+	d.pending = nil
+	d.matched = nil
+	d.matchedLen = 0
+}
+
+func (d *keyDispatcher) pendingString() string {
+	if len(d.pending) == 0 {
+		return ""
+	}
+
+	return formatKeySequence(newKeySequence(d.pending...))
+}
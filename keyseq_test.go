@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeySequence(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []KeyStroke
+		wantErr error
+	}{
+		{
+			key:  "g g",
+			want: []KeyStroke{{Key: tcell.KeyRune, Rune: 'g'}, {Key: tcell.KeyRune, Rune: 'g'}},
+		},
+		{
+			key:  " ",
+			want: []KeyStroke{{Key: tcell.KeyRune, Rune: ' '}},
+		},
+		{
+			key:  "Ctrl-W  Shift-J",
+			want: []KeyStroke{{Key: tcell.KeyRune, Rune: 'w', ModMask: tcell.ModCtrl}, {Key: tcell.KeyRune, Rune: 'J'}},
+		},
+		{
+			key:     "a b c d e",
+			wantErr: keySequenceTooLongError{key: "a b c d e"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := ParseKeySequence(tt.key)
+			assert.Equal(t, tt.wantErr, err)
+
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.want, got.strokes())
+			}
+		})
+	}
+}
+
+func TestKeyDispatcher(t *testing.T) {
+	var ran []string
+
+	action := func(name string, keys ...string) keyAction {
+		m := map[KeySequence]struct{}{}
+		for _, k := range keys {
+			m[mustParseKeymap(k)] = struct{}{}
+		}
+
+		return keyAction{keys: m, run: func() { ran = append(ran, name) }}
+	}
+
+	general := []keyAction{
+		action("top", "g g"),
+		action("go", "g"),
+		action("down", "j"),
+		action("find", "Ctrl-W f"),
+		action("mark", "m"),
+		action("marked", "m g x"),
+	}
+	timeMachine := []keyAction{
+		action("past", "j"),
+	}
+
+	feed := func(d *keyDispatcher, scopes [][]keyAction, keys string) bool {
+		var waiting bool
+
+		for _, stroke := range mustParseKeymap(keys).strokes() {
+			var run func()
+
+			run, waiting = d.feed(scopes, stroke)
+			if run != nil {
+				run()
+			}
+		}
+
+		return waiting
+	}
+
+	tests := []struct {
+		name    string
+		scopes  [][]keyAction
+		keys    string
+		timeout bool
+		want    []string
+		waiting bool
+	}{
+		{name: "single key", scopes: [][]keyAction{general}, keys: "j", want: []string{"down"}},
+		{name: "sequence", scopes: [][]keyAction{general}, keys: "g g", want: []string{"top"}},
+		{name: "prefix waits", scopes: [][]keyAction{general}, keys: "g", waiting: true},
+		{name: "prefix on timeout", scopes: [][]keyAction{general}, keys: "g", timeout: true, want: []string{"go"}},
+		{name: "prefix without action on timeout", scopes: [][]keyAction{general}, keys: "Ctrl-W", timeout: true},
+		{name: "broken sequence", scopes: [][]keyAction{general}, keys: "Ctrl-W j", want: []string{"down"}},
+		{name: "prefix binding then another key", scopes: [][]keyAction{general}, keys: "g j", want: []string{"go", "down"}},
+		{name: "prefix binding then a sequence", scopes: [][]keyAction{general}, keys: "g Ctrl-W f", want: []string{"go", "find"}},
+		{
+			name:   "longest bound prefix of a broken sequence",
+			scopes: [][]keyAction{general},
+			keys:   "m g j",
+			want:   []string{"mark", "go", "down"},
+		},
+		{
+			name:   "earlier scope wins",
+			scopes: [][]keyAction{timeMachine, general},
+			keys:   "j",
+			want:   []string{"past"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ran = nil
+
+			d := &keyDispatcher{}
+			waiting := feed(d, tt.scopes, tt.keys)
+			assert.Equal(t, tt.waiting || tt.timeout, waiting)
+
+			if tt.timeout {
+				if run := d.timeout(); run != nil {
+					run()
+				}
+			}
+
+			assert.Equal(t, tt.want, ran)
+		})
+	}
+}
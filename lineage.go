@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// lineAgeWidth is how wide the gutter with the ages of the lines is, the
+// widest age and a space.
+const lineAgeWidth = 5
+
+// lineTimesOf returns when every line of the output last changed, out of the
+// times of the previous output and the line map between them. Lines which
+// are the same, or only moved, keep their time, and the others changed at
+// now. The times of the previous output are returned as they are when no
+// line changed, so that a steady output keeps a single copy of them.
+func lineTimesOf(lines *lineMap, before []int64, now int64) []int64 {
+	same := len(lines.toBefore) == len(before)
+	times := make([]int64, len(lines.toBefore))
+
+	for i, j := range lines.toBefore {
+		if j < 0 || j >= len(before) {
+			times[i] = now
+			same = false
+
+			continue
+		}
+
+		times[i] = before[j]
+		same = same && i == j
+	}
+
+	if same {
+		return before
+	}
+
+	return times
+}
+
+func (s *Snapshot) lineChangeTimes() []int64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.lineTimes
+}
+
+func (s *Snapshot) setLineChangeTimes(times []int64) {
+	s.Lock()
+	s.lineTimes = times
+	s.Unlock()
+}
+
+// formatLineAge formats how long ago a line changed for the gutter, in the
+// largest unit which fits.
+func formatLineAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		if d < 0 {
+			d = 0
+		}
+
+		return fmt.Sprintf("%ds", d/time.Second)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d < 1000*24*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+
+	return "999d"
+}
+
+// lineAgeLabels returns how long before s ran every line of its output
+// changed, relative to s so that the time machine shows the ages back then.
+func lineAgeLabels(s *Snapshot) []string {
+	times := s.lineChangeTimes()
+	labels := make([]string, len(times))
+
+	for i, t := range times {
+		labels[i] = formatLineAge(time.Duration(s.start.UnixNano() - t))
+	}
+
+	return labels
+}
+
+// drawLineAges draws the ages of the lines of the body in the gutter left
+// of it, on the first row of every line. Like the clip markers, they are
+// only drawn on screen, so they are never searched, yanked or compared.
+func (v *Viddy) drawLineAges(screen tcell.Screen) {
+	if !v.showsLineAges() {
+		return
+	}
+
+	x, y, width, height := v.lineAgeView.GetRect()
+	if width <= 1 || len(v.lineAges) == 0 {
+		return
+	}
+
+	row, _ := v.bodyView.GetScrollOffset()
+	starts := v.bodyView.rowStarts(v.bodyView.wrapWidth())
+
+	for i := 0; i < height; i++ {
+		line := lineOfRow(starts, row+i)
+		if line >= len(starts)-1 || starts[line] != row+i || v.isHunkSeparator(line) {
+			continue
+		}
+
+		if origin := v.originalLine(line); origin < len(v.lineAges) {
+			tview.Print(screen, v.lineAges[origin], x, y+i, width-1, tview.AlignRight, tview.Styles.TertiaryTextColor)
+		}
+	}
+}
+
+// showsLineAges tells whether the body has the gutter with the ages of the
+// lines. It is left out beside the previous output.
+func (v *Viddy) showsLineAges() bool {
+	return v.isShowLineAge && !v.isSideBySide
+}
+
+// isHunkSeparator tells whether the row of the body's text separates the
+// hunks of collapsed output, which maps to the line after it.
+func (v *Viddy) isHunkSeparator(row int) bool {
+	return v.shownLines != nil && row+1 < len(v.shownLines) && v.shownLines[row+1] == v.shownLines[row]
+}
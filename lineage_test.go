@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineTimesOf(t *testing.T) {
+	before := []int64{10, 20, 30}
+
+	// The first output changed all at once.
+	assert.Equal(t, []int64{5, 5}, lineTimesOf(newLineMap("", "a\nb\n"), nil, 5))
+
+	// A steady output shares the times of the previous one.
+	times := lineTimesOf(newLineMap("a\nb\nc\n", "a\nb\nc\n"), before, 40)
+	assert.Equal(t, before, times)
+	assert.Same(t, &before[0], &times[0])
+
+	// Modified and inserted lines changed now, the others keep their time.
+	assert.Equal(t, []int64{10, 40, 40, 30}, lineTimesOf(newLineMap("a\nb\nc\n", "a\nB\nnew\nc\n"), before, 40))
+	assert.Equal(t, []int64{10, 30}, lineTimesOf(newLineMap("a\nb\nc\n", "a\nc\n"), before, 40))
+
+	// A line which moved is the same line.
+	assert.Equal(t, []int64{20, 10, 30}, lineTimesOf(newLineMap("a\nb\nc\n", "b\na\nc\n"), before, 40))
+}
+
+func TestSnapshotLineTimes(t *testing.T) {
+	begin := time.Date(2021, 9, 4, 12, 0, 0, 0, time.UTC)
+
+	var before *Snapshot
+
+	for i, output := range []string{"ready 1\nready 2\n", "ready 1\nready 2\n", "ready 1\nfailed 2\n", "ready 1\nfailed 2\n"} {
+		s := &Snapshot{
+			id: int64(i), start: begin.Add(time.Duration(i) * time.Minute), result: []byte(output), completed: true, before: before,
+		}
+		assert.NoError(t, s.compareFromBefore())
+
+		before = s
+	}
+
+	assert.Equal(t, []string{"3m", "1m"}, lineAgeLabels(before))
+	assert.Equal(t, []string{"2m", "0s"}, lineAgeLabels(before.before))
+}
+
+func TestFormatLineAge(t *testing.T) {
+	assert.Equal(t, "0s", formatLineAge(-time.Second))
+	assert.Equal(t, "12s", formatLineAge(12*time.Second+900*time.Millisecond))
+	assert.Equal(t, "3m", formatLineAge(3*time.Minute+59*time.Second))
+	assert.Equal(t, "2h", formatLineAge(2*time.Hour+30*time.Minute))
+	assert.Equal(t, "400d", formatLineAge(400*24*time.Hour))
+	assert.Equal(t, "999d", formatLineAge(5000*24*time.Hour))
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// lineMap is the line correspondence between a snapshot and the one before it.
+// Anything that needs to follow a line across refreshes should use it
+// instead of guessing line identity on its own.
+type lineMap struct {
+	// toAfter maps a line of the previous output to the current output, or -1 if it was removed.
+	toAfter []int
+	// toBefore maps a line of the current output to the previous output, or -1 if it is new.
+	toBefore []int
+	// moved is true for lines of the current output which were moved rather than modified.
+	moved []bool
+	// removedAt are the lines of the current output before which lines were removed.
+	removedAt []int
+
+	stats diffStats
+}
+
+// diffStats counts the lines added, removed and modified since the previous
+// output. Where a hunk both removes and adds lines, they pair up as modified
+// lines. Moved lines count as modified.
+type diffStats struct {
+	added    int
+	removed  int
+	modified int
+}
+
+func (d diffStats) isZero() bool {
+	return d == diffStats{}
+}
+
+func (d diffStats) String() string {
+	return fmt.Sprintf("+%d −%d ~%d", d.added, d.removed, d.modified)
+}
+
+// lineHunk is a run of removed and inserted lines between unchanged ones.
+type lineHunk struct {
+	removed  []int
+	inserted []int
+}
+
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines diffs two texts line by line.
+func diffLines(before, after string) []diffmatchpatch.Diff {
+	b, a := splitLines(before), splitLines(after)
+
+	return diffHashedLines(b, a, b, a, lineHashes(b), lineHashes(a))
+}
+
+func newLineMap(before, after string) *lineMap {
+	return lineMapOf(diffLines(before, after), splitLines(after))
+}
+
+// lineMapOf makes the line map from the line diff of the outputs, whose
+// current one has the lines afterLines.
+func lineMapOf(diffs []diffmatchpatch.Diff, afterLines []string) *lineMap {
+	m := &lineMap{}
+	removed := map[string][]int{}
+
+	var (
+		inserted []int
+		hunks    []*lineHunk
+		hunk     *lineHunk
+	)
+
+	for _, diff := range diffs {
+		lines := splitLines(diff.Text)
+
+		if diff.Type == diffmatchpatch.DiffEqual {
+			hunk = nil
+		} else if hunk == nil {
+			hunk = &lineHunk{}
+			hunks = append(hunks, hunk)
+		}
+
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			for range lines {
+				m.toAfter = append(m.toAfter, len(m.toBefore))
+				m.toBefore = append(m.toBefore, len(m.toAfter)-1)
+				m.moved = append(m.moved, false)
+			}
+		case diffmatchpatch.DiffDelete:
+			m.removedAt = append(m.removedAt, len(m.toBefore))
+
+			for _, line := range lines {
+				removed[line] = append(removed[line], len(m.toAfter))
+				hunk.removed = append(hunk.removed, len(m.toAfter))
+				m.toAfter = append(m.toAfter, -1)
+			}
+		case diffmatchpatch.DiffInsert:
+			for range lines {
+				inserted = append(inserted, len(m.toBefore))
+				hunk.inserted = append(hunk.inserted, len(m.toBefore))
+				m.toBefore = append(m.toBefore, -1)
+				m.moved = append(m.moved, false)
+			}
+		}
+	}
+
+	for _, i := range inserted {
+		line := afterLines[i]
+		if isWhiteString(line) || len(removed[line]) == 0 {
+			continue
+		}
+
+		j := removed[line][0]
+		removed[line] = removed[line][1:]
+
+		m.toBefore[i] = j
+		m.toAfter[j] = i
+		m.moved[i] = true
+		m.stats.modified++
+	}
+
+	for _, h := range hunks {
+		m.stats.add(h, m)
+	}
+
+	return m
+}
+
+// add counts the lines of the hunk which did not move elsewhere.
+func (d *diffStats) add(h *lineHunk, m *lineMap) {
+	removed, inserted := 0, 0
+
+	for _, j := range h.removed {
+		if m.toAfter[j] == -1 {
+			removed++
+		}
+	}
+
+	for _, i := range h.inserted {
+		if m.toBefore[i] == -1 {
+			inserted++
+		}
+	}
+
+	modified := removed
+	if inserted < modified {
+		modified = inserted
+	}
+
+	d.modified += modified
+	d.added += inserted - modified
+	d.removed += removed - modified
+}
+
+// follow returns the line of the current output which corresponds to the
+// given line of the previous output. Removed lines follow the nearest
+// surviving line above them.
+func (m *lineMap) follow(line int) int {
+	if line >= len(m.toAfter) {
+		return line + len(m.toBefore) - len(m.toAfter)
+	}
+
+	for i := line; i >= 0; i-- {
+		if m.toAfter[i] >= 0 {
+			return m.toAfter[i] + line - i
+		}
+	}
+
+	return line
+}
+
+// anchor returns the line of the current output to show at the top, so that
+// the first of the visible lines of the previous output which is still there
+// stays on the same row. Lines which moved elsewhere do not count. Without
+// such a line it follows the top line.
+func (m *lineMap) anchor(top, visible int) int {
+	for line := top; line < top+visible && line < len(m.toAfter); line++ {
+		j := m.toAfter[line]
+		if j < 0 || m.isMoved(j) {
+			continue
+		}
+
+		if j < line-top {
+			return 0
+		}
+
+		return j - (line - top)
+	}
+
+	return m.follow(top)
+}
+
+func (m *lineMap) isMoved(line int) bool {
+	return line < len(m.moved) && m.moved[line]
+}
+
+// changedLines returns which lines of the current output are new, modified
+// or moved, or follow removed lines. Lines removed at the end mark the last line.
+func (m *lineMap) changedLines() []bool {
+	changed := make([]bool, len(m.toBefore))
+
+	for i, j := range m.toBefore {
+		changed[i] = j == -1 || m.moved[i]
+	}
+
+	for _, i := range m.removedAt {
+		if i >= len(changed) {
+			i = len(changed) - 1
+		}
+
+		if i >= 0 {
+			changed[i] = true
+		}
+	}
+
+	return changed
+}
+
+// changeHunk is a run of changed lines of the current output, from start up
+// to end.
+type changeHunk struct {
+	start int
+	end   int
+}
+
+// changeHunks groups the changed lines into hunks of consecutive ones, in
+// the order of the output.
+func changeHunks(changed []bool) []changeHunk {
+	var hunks []changeHunk
+
+	for i, c := range changed {
+		if !c {
+			continue
+		}
+
+		if n := len(hunks); n > 0 && hunks[n-1].end == i {
+			hunks[n-1].end++
+
+			continue
+		}
+
+		hunks = append(hunks, changeHunk{start: i, end: i + 1})
+	}
+
+	return hunks
+}
+
+// hunkSeparator is shown between the hunks of collapsed output.
+const hunkSeparator = "…"
+
+// collapseLines keeps the changed lines with context lines around them,
+// separating the hunks with hunkSeparator. origins maps every kept line to
+// its line in lines, and separators to the first line of the following hunk.
+func collapseLines(lines []string, changed []bool, context int) (kept []string, origins []int) {
+	shown := make([]bool, len(lines))
+
+	for i := range lines {
+		if i >= len(changed) || !changed[i] {
+			continue
+		}
+
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				shown[j] = true
+			}
+		}
+	}
+
+	for i, line := range lines {
+		if !shown[i] {
+			continue
+		}
+
+		if len(kept) > 0 && !shown[i-1] {
+			kept = append(kept, hunkSeparator)
+			origins = append(origins, i)
+		}
+
+		kept = append(kept, line)
+		origins = append(origins, i)
+	}
+
+	return kept, origins
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLineMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		toBefore []int
+		toAfter  []int
+		moved    []bool
+	}{
+		{
+			name:     "insert at top",
+			before:   "a\nb\nc\n",
+			after:    "x\na\nb\nc\n",
+			toBefore: []int{-1, 0, 1, 2},
+			toAfter:  []int{1, 2, 3},
+			moved:    []bool{false, false, false, false},
+		},
+		{
+			name:     "delete in middle",
+			before:   "a\nb\nc\n",
+			after:    "a\nc\n",
+			toBefore: []int{0, 2},
+			toAfter:  []int{0, -1, 1},
+			moved:    []bool{false, false},
+		},
+		{
+			name:     "modified line",
+			before:   "a\nb\nc\n",
+			after:    "a\nB\nc\n",
+			toBefore: []int{0, -1, 2},
+			toAfter:  []int{0, -1, 2},
+			moved:    []bool{false, false, false},
+		},
+		{
+			name:     "moved line",
+			before:   "a\nb\nc\n",
+			after:    "b\nc\na\n",
+			toBefore: []int{1, 2, 0},
+			toAfter:  []int{2, 0, 1},
+			moved:    []bool{false, false, true},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			m := newLineMap(tt.before, tt.after)
+			assert.Equal(t, tt.toBefore, m.toBefore)
+			assert.Equal(t, tt.toAfter, m.toAfter)
+			assert.Equal(t, tt.moved, m.moved)
+		})
+	}
+}
+
+func TestLineMap_follow(t *testing.T) {
+	m := newLineMap("a\nb\nc\nd\n", "x\ny\na\nc\nd\n")
+
+	assert.Equal(t, 2, m.follow(0))
+	assert.Equal(t, 3, m.follow(1))
+	assert.Equal(t, 3, m.follow(2))
+	assert.Equal(t, 4, m.follow(3))
+	assert.Equal(t, 5, m.follow(4))
+}
+
+func TestLineMap_anchor(t *testing.T) {
+	m := newLineMap("a\nb\nc\nd\ne\n", "x\ny\nB\nc\nd\ne\n")
+
+	// b was modified, so c keeps its row below the top.
+	assert.Equal(t, 2, m.anchor(1, 3))
+	assert.Equal(t, 3, m.anchor(2, 3))
+
+	// Lines removed above the top shift it up.
+	m = newLineMap("a\nb\nc\nd\ne\n", "c\nd\ne\n")
+	assert.Equal(t, 0, m.anchor(2, 3))
+	assert.Equal(t, 1, m.anchor(3, 3))
+
+	// A line which moved away is no anchor.
+	m = newLineMap("a\nb\nc\n", "b\nc\na\n")
+	assert.Equal(t, 0, m.anchor(0, 3))
+
+	// Nothing visible survived, so the top follows the line above.
+	m = newLineMap("a\nb\nc\n", "a\nx\ny\n")
+	assert.Equal(t, 1, m.anchor(1, 2))
+}
+
+func TestLineMap_changedLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []bool
+	}{
+		{name: "modified", before: "a\nb\nc\n", after: "a\nB\nc\n", want: []bool{false, true, false}},
+		{name: "removed in middle", before: "a\nb\nc\n", after: "a\nc\n", want: []bool{false, true}},
+		{name: "removed at end", before: "a\nb\nc\n", after: "a\nb\n", want: []bool{false, true}},
+		{name: "moved", before: "a\nb\nc\n", after: "b\na\nc\n", want: []bool{true, true, false}},
+		{name: "unchanged", before: "a\nb\n", after: "a\nb\n", want: []bool{false, false}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newLineMap(tt.before, tt.after).changedLines())
+		})
+	}
+}
+
+func TestChangeHunks(t *testing.T) {
+	assert.Equal(t, []changeHunk{{start: 1, end: 3}, {start: 4, end: 5}},
+		changeHunks([]bool{false, true, true, false, true}))
+	assert.Equal(t, []changeHunk{{start: 0, end: 2}}, changeHunks([]bool{true, true}))
+	assert.Nil(t, changeHunks([]bool{false, false}))
+	assert.Nil(t, changeHunks(nil))
+}
+
+func TestLineMap_stats(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   diffStats
+	}{
+		{name: "unchanged", before: "a\nb\n", after: "a\nb\n", want: diffStats{}},
+		{name: "first run", before: "", after: "a\nb\n", want: diffStats{added: 2}},
+		{name: "modified", before: "a\nb\nc\n", after: "a\nB\nc\n", want: diffStats{modified: 1}},
+		{name: "modified and added", before: "a\nb\nc\n", after: "a\nB\nx\nc\n", want: diffStats{added: 1, modified: 1}},
+		{name: "removed", before: "a\nb\nc\nd\n", after: "a\nd\n", want: diffStats{removed: 2}},
+		{name: "moved", before: "a\nb\nc\n", after: "b\nc\na\n", want: diffStats{modified: 1}},
+		{
+			name:   "several hunks",
+			before: "a\nb\nc\nd\ne\n",
+			after:  "x\na\nc\nD\ne\ny\n",
+			want:   diffStats{added: 2, removed: 1, modified: 1},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newLineMap(tt.before, tt.after).stats)
+		})
+	}
+}
+
+func TestDiffStats_String(t *testing.T) {
+	assert.Equal(t, "+12 −3 ~5", diffStats{added: 12, removed: 3, modified: 5}.String())
+	assert.True(t, diffStats{}.isZero())
+	assert.False(t, diffStats{removed: 1}.isZero())
+}
+
+func TestCollapseLines(t *testing.T) {
+	lines := []string{"0", "1", "2", "3", "4", "5", "6", "7"}
+	changed := []bool{false, true, false, false, false, false, true, false}
+
+	kept, origins := collapseLines(lines, changed, 0)
+	assert.Equal(t, []string{"1", hunkSeparator, "6"}, kept)
+	assert.Equal(t, []int{1, 6, 6}, origins)
+
+	kept, origins = collapseLines(lines, changed, 1)
+	assert.Equal(t, []string{"0", "1", "2", hunkSeparator, "5", "6", "7"}, kept)
+	assert.Equal(t, []int{0, 1, 2, 5, 5, 6, 7}, origins)
+
+	kept, _ = collapseLines(lines, changed, 2)
+	assert.Equal(t, lines, kept)
+
+	kept, origins = collapseLines(lines, make([]bool, len(lines)), 1)
+	assert.Empty(t, kept)
+	assert.Empty(t, origins)
+}
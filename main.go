@@ -49,19 +49,130 @@ func main() {
 		printVersion()
 	}
 
+	if conf.runtime.completion != "" {
+		fmt.Print(completionScript(conf.runtime.completion, newFlagSet()))
+		os.Exit(0)
+	}
+
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
+	if conf.runtime.showConfig {
+		fmt.Print(formatConfig(conf))
+		os.Exit(0)
+	}
+
+	if conf.runtime.stdinCapture {
+		conf.runtime.stdin, err = captureStdin(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if conf.runtime.batch {
+		latest, err := runBatch(conf, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if conf.general.exitCode && latest != nil {
+			os.Exit(latest.exitStatus())
+		}
+
+		os.Exit(0)
+	}
+
 	tview.Styles = conf.theme.Theme
 
-	app := NewViddy(conf)
+	var saved *session
+
+	// A session is only kept for a single command.
+	if conf.general.sessionFile != "" {
+		command := conf.runtime.commands[0].line()
+
+		saved, err = loadSession(conf.general.sessionFile, command, conf.runtime.sessionForce)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	// A loaded history shows the command it ran instead.
+	if conf.runtime.load != "" {
+		saved, err = loadHistory(conf.runtime.load)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 
-	if err := app.Run(); err != nil {
+		conf.runtime.commands = []commandSpec{{cmd: saved.Command[0], args: saved.Command[1:]}}
+	}
+
+	if conf.general.showHost {
+		conf.runtime.host = currentHost()
+	}
+
+	if conf.general.debug {
+		conf.runtime.debugLog, err = openDebugLog(conf.general.log, conf.general.logLevel)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		for _, r := range conf.runtime.startupLog {
+			conf.runtime.debugLog.log(r)
+		}
+
+		for _, w := range conf.warnings {
+			conf.runtime.debugLog.log(logRecord{level: levelWarn, msg: "config warning", attrs: []interface{}{"warning", w}})
+		}
+	}
+
+	panes := make([]*Viddy, 0, len(conf.runtime.commands))
+	for _, command := range conf.runtime.commands {
+		panes = append(panes, NewViddy(conf, command, saved))
+	}
+
+	// Only a single command may be controlled.
+	if conf.runtime.listen != "" {
+		panes[0].control, err = listenControl(conf.runtime.listen, panes[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	app := newPaneGroup(panes, conf.general.split)
+
+	err = app.Run()
+
+	if closeErr := panes[0].control.close(); err == nil {
+		err = closeErr
+	}
+
+	if conf.general.debug {
+		_ = conf.runtime.debugLog.Close()
+		fmt.Fprintln(os.Stderr, "debug log:", conf.general.log)
+
+		for _, pane := range panes {
+			if summary := pane.driftSummary(); summary != "" {
+				fmt.Fprintln(os.Stderr, "drift of", summary)
+			}
+		}
+	}
+
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+
+	if conf.general.exitCode {
+		os.Exit(app.exitStatus())
+	}
 }
 
 func help() {
@@ -70,15 +181,76 @@ Viddy well, gopher. Viddy well.
 
 Usage:
  viddy [options] command
+ viddy [options] @profile [args]
+ viddy [options] command --- command
+ viddy [options] --file path
+ viddy [options] --load path
 
 Options:
   -d, --differences          highlight changes between updates
-  -n, --interval <interval>  seconds to wait between updates (default "2s")
+  --differences=permanent    highlight everything that changed since the start
+  --differences=word         highlight whole words which changed, or "char" (the default) or "line"
+  --differences-against <n>  highlight changes since n runs ago, or since a duration ago such as "5m"
+  --diff-normalize <opts>    ignore some differences when looking for changes: trim_trailing_space, collapse_whitespace,
+                             ignore_case and ignore_blank_lines, separated by commas
+  --changes-only             show only the lines which changed since the previous run
+  --side-by-side             show the previous run beside the current one, or above it in narrow terminals
+  -n, --interval <interval>  seconds to wait between updates (default "2s"), or steps like 1s:1m,5s:10m,30s
   -p, --precise              attempt run command in precise intervals
   -c, --clockwork            run command in precise intervals forcibly
+  --adaptive                 wait -n after a run which changed the output, doubling it after every 3 runs which did not
+  --adaptive-max <interval>  longest interval in adaptive mode (default "1m")
+  --jitter <interval>        delay runs by a random duration shorter than this, to spread out instances
+  --schedule <cron>          run command on a cron schedule such as "*/5 * * * *" instead of -n
   -t, --no-title             turn off header
-  --shell                    shell (default "sh")
+  --title <text>             show the text in the header instead of the command, with placeholders such as
+                             {command}, {interval}, {host}, {exit_code} and {time}
+  --no-wrap                  cut long lines off at the right edge instead of wrapping them
+  --tab-width <columns>      columns between tab stops (default 8)
+  --chdir <path>             working directory of the command
+  --file <path>              watch the contents of the file instead of a command, read afresh on every run and
+                             right away when it is written
+  --input <path>             feed the file to the stdin of the command on every run, reading it afresh every time
+  --listen unix:<path>       serve the latest run, the history length and pause, resume and run over HTTP on a unix socket
+  --stdin-capture            read the stdin of viddy once and feed it to the command on every run
+  --config <path>            read the config file at the path instead of the default one
+  --show-config              print the effective configuration as a config file and exit
+  --completion <shell>       print the completion script of bash, zsh or fish and exit
+  --profile <name>           use a profile of the config file, same as @name
+  --env <key=value>          set environment variable of the command (repeatable)
+  -x, --exec                 run command directly instead of through the shell, passing its arguments as they are
+  --shell                    shell (default "sh", "powershell" on Windows)
   --shell-options            additional shell options
+  --pty                      run command in a pseudo-terminal
+  --no-color                 strip ANSI escape sequences such as colors, cursor movements and titles from the output
+  --on-change <command>      run the command through the shell when the output changes
+  --before-each <command>    run the command through the shell before every run, skipping the run when it fails
+  --after-each <command>     run the command through the shell after every run, with VIDDY_EXIT_CODE set
+  --pipe <command>           show the output through the shell filter, keeping the exit code and duration of the command
+  --trigger <regexp>         ring the bell when the regexp starts matching the output (repeatable)
+  --trigger-exit             exit when a trigger fires, printing the matching line
+  --highlight <regexp[:color]> color the matches in every run, red by default, whether or not -d is on (repeatable)
+  --batch                    write every run to stdout instead of showing it, until interrupted
+  --batch-format <format>    "text" writes a header line before every output (default), "json" a line of JSON per run
+  --exit-code                exit with the exit status of the latest run, 124 if it timed out, 128+n if signal n ended it
+  --once                     run the command a single time and keep showing it until quit, or print it and exit with --batch
+  --log-file <path>          append the output of every run to the file
+  --max-lines <n>            keep only the first n lines of every run, dropping the rest as it comes in
+  --max-bytes <size>         keep only the first bytes of every run, such as "1MB", dropping the rest as it comes in
+  --keep-for <duration>      drop the snapshots older than the duration from the history after every run, such as "2h"
+  --flash-on-change          flash the header when the output differs from the previous run
+  --notify <when>            send a desktop notification on "change" of the output, "error" of the command or "both"
+  --ssh <[user@]host[:port]> run command on the host over one SSH connection, using ~/.ssh/config
+  --session <path>           save the history to the file on exit, and restore it from there on start
+  --session-force            restore the session even if it was saved for another command
+  --history-file <path>      append every run to the file as a line of JSON, like --batch-format json writes it
+  --load <path>              look back at the runs of a history file or session in the time machine, running nothing
+  --cmd <command>            watch the command in a pane of its own (repeatable), same as separating commands with ---
+  --split <layout>           "horizontal" stacks the panes (default), "vertical" puts them side by side
+  --backoff                  double the interval after every consecutive failure, until a run succeeds
+  --backoff-max <interval>   maximum interval when backing off (default "5m")
+  --no-mouse                 turn off mouse support
+  --theme <preset>           color theme preset (dark, light, solarized-dark, solarized-light, nord)
 
  -h, --help     display this help and exit
  -v, --version  output version information and exit`)
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// diffNormalizeOptions are the names of the options of diff_normalize.
+var diffNormalizeOptions = []string{"trim_trailing_space", "collapse_whitespace", "ignore_case", "ignore_blank_lines"}
+
+// diffNormalize is how outputs may differ without counting as a change. The
+// comparison sees the output normalized, while it is shown as it is.
+type diffNormalize struct {
+	trimTrailingSpace  bool
+	collapseWhitespace bool
+	ignoreCase         bool
+	ignoreBlankLines   bool
+}
+
+type diffNormalizeError struct {
+	option string
+}
+
+func (e diffNormalizeError) Error() string {
+	names := make([]string, 0, len(diffNormalizeOptions))
+	for _, name := range diffNormalizeOptions {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+
+	return fmt.Sprintf("diff_normalize: unknown option %q, must be among %s", e.option, strings.Join(names, ", "))
+}
+
+// parseDiffNormalize parses a list of options, or a string of them separated
+// by commas as --diff-normalize takes them.
+func parseDiffNormalize(value interface{}) (diffNormalize, error) {
+	var n diffNormalize
+
+	names, err := cast.ToStringSliceE(value)
+	if s, ok := value.(string); ok {
+		names, err = strings.Split(s, ","), nil
+	}
+
+	if err != nil {
+		return n, diffNormalizeError{option: cast.ToString(value)}
+	}
+
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "trim_trailing_space":
+			n.trimTrailingSpace = true
+		case "collapse_whitespace":
+			n.collapseWhitespace = true
+		case "ignore_case":
+			n.ignoreCase = true
+		case "ignore_blank_lines":
+			n.ignoreBlankLines = true
+		default:
+			return diffNormalize{}, diffNormalizeError{option: name}
+		}
+	}
+
+	return n, nil
+}
+
+// names returns the options which are on, the way parseDiffNormalize reads
+// them.
+func (n diffNormalize) names() []string {
+	on := []bool{n.trimTrailingSpace, n.collapseWhitespace, n.ignoreCase, n.ignoreBlankLines}
+	names := []string{}
+
+	for i, name := range diffNormalizeOptions {
+		if on[i] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (n diffNormalize) isZero() bool {
+	return n == diffNormalize{}
+}
+
+// line returns the line as it is compared, keeping its line break.
+func (n diffNormalize) line(line string) string {
+	body := strings.TrimSuffix(line, "\n")
+	newline := line[len(body):]
+
+	if n.ignoreBlankLines && strings.TrimSpace(body) == "" {
+		return newline
+	}
+
+	// Runs of whitespace become a single space, and those around the line go.
+	if n.collapseWhitespace {
+		body = strings.Join(strings.Fields(body), " ")
+	}
+
+	if n.trimTrailingSpace {
+		body = strings.TrimRight(body, " \t\r")
+	}
+
+	if n.ignoreCase {
+		body = strings.ToLower(body)
+	}
+
+	return body + newline
+}
+
+// lines returns every line as it is compared.
+func (n diffNormalize) lines(lines []string) []string {
+	normalized := make([]string, len(lines))
+	for i, line := range lines {
+		normalized[i] = n.line(line)
+	}
+
+	return normalized
+}
+
+// text returns the lines as they are compared, without the blank ones if
+// they are ignored.
+func (n diffNormalize) text(lines []string) string {
+	var b strings.Builder
+
+	for _, line := range n.lines(lines) {
+		if n.ignoreBlankLines && strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiffNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		want   diffNormalize
+		expErr error
+	}{
+		{
+			name:  "nothing",
+			value: "",
+			want:  diffNormalize{},
+		},
+		{
+			name:  "comma separated",
+			value: "trim_trailing_space, Ignore_Case",
+			want:  diffNormalize{trimTrailingSpace: true, ignoreCase: true},
+		},
+		{
+			name:  "list",
+			value: []interface{}{"collapse_whitespace", "ignore_blank_lines"},
+			want:  diffNormalize{collapseWhitespace: true, ignoreBlankLines: true},
+		},
+		{
+			name:   "unknown option",
+			value:  "ignore_case,ignore_dates",
+			want:   diffNormalize{},
+			expErr: diffNormalizeError{option: "ignore_dates"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDiffNormalize(tt.value)
+			assert.Equal(t, tt.expErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDiffNormalizeLine(t *testing.T) {
+	tests := []struct {
+		name string
+		n    diffNormalize
+		line string
+		want string
+	}{
+		{
+			name: "nothing",
+			n:    diffNormalize{},
+			line: "  Pod  A \n",
+			want: "  Pod  A \n",
+		},
+		{
+			name: "trim trailing space",
+			n:    diffNormalize{trimTrailingSpace: true},
+			line: "  Pod  A \t\r\n",
+			want: "  Pod  A\n",
+		},
+		{
+			name: "collapse whitespace",
+			n:    diffNormalize{collapseWhitespace: true},
+			line: "  Pod \t A ",
+			want: "Pod A",
+		},
+		{
+			name: "ignore case",
+			n:    diffNormalize{ignoreCase: true},
+			line: "Pod A\n",
+			want: "pod a\n",
+		},
+		{
+			name: "blank line",
+			n:    diffNormalize{ignoreBlankLines: true},
+			line: " \t\n",
+			want: "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.n.line(tt.line))
+		})
+	}
+}
+
+func TestDiffOutputsNormalized(t *testing.T) {
+	tests := []struct {
+		name        string
+		n           diffNormalize
+		before      string
+		after       string
+		wantChanged bool
+	}{
+		{
+			name:        "whitespace counts by default",
+			n:           diffNormalize{},
+			before:      "pod-a Running\n",
+			after:       "pod-a  Running  \n",
+			wantChanged: true,
+		},
+		{
+			name:   "whitespace ignored",
+			n:      diffNormalize{collapseWhitespace: true},
+			before: "pod-a Running\n",
+			after:  "pod-a  Running  \n",
+		},
+		{
+			name:   "case ignored",
+			n:      diffNormalize{ignoreCase: true},
+			before: "pod-a RUNNING\n",
+			after:  "pod-a Running\n",
+		},
+		{
+			name:   "blank lines ignored",
+			n:      diffNormalize{ignoreBlankLines: true},
+			before: "pod-a\npod-b\n",
+			after:  "pod-a\n\npod-b\n",
+		},
+		{
+			name:        "other changes still count",
+			n:           diffNormalize{ignoreCase: true},
+			before:      "pod-a Pending\n",
+			after:       "pod-a Running\n",
+			wantChanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, _ := diffOutputs(tt.before, tt.after, lineHashes, lineHashes, tt.n, DiffGranularityChar)
+
+			changed := false
+			shown := ""
+
+			for _, d := range diffs {
+				if d.Type != diffmatchpatch.DiffEqual {
+					changed = true
+				}
+
+				if d.Type != diffmatchpatch.DiffDelete {
+					shown += d.Text
+				}
+			}
+
+			assert.Equal(t, tt.wantChanged, changed)
+			assert.Equal(t, tt.after, shown)
+		})
+	}
+}
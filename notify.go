@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var errNoNotifier = errors.New("no notifier found: install notify-send or gdbus, or terminal-notifier on macOS")
+
+// NotifyMode decides which runs send a desktop notification.
+type NotifyMode string
+
+var (
+	// NotifyModeOff sends none.
+	NotifyModeOff NotifyMode = ""
+	// NotifyModeChange sends one when the output changed.
+	NotifyModeChange NotifyMode = "change"
+	// NotifyModeError sends one when the command failed.
+	NotifyModeError NotifyMode = "error"
+	// NotifyModeBoth sends one for either.
+	NotifyModeBoth NotifyMode = "both"
+)
+
+func (m NotifyMode) onChange() bool {
+	return m == NotifyModeChange || m == NotifyModeBoth
+}
+
+func (m NotifyMode) onError() bool {
+	return m == NotifyModeError || m == NotifyModeBoth
+}
+
+type notifierError struct {
+	command string
+	err     error
+}
+
+func (e notifierError) Error() string {
+	return fmt.Sprintf("%s could not notify: %v", e.command, e.err)
+}
+
+func (e notifierError) Unwrap() error {
+	return e.err
+}
+
+// notification is what the desktop is told about a run.
+type notification struct {
+	title string
+	body  string
+}
+
+const (
+	// notificationLines is how many lines of the output a notification
+	// quotes, and notificationWidth how much of each.
+	notificationLines = 3
+	notificationWidth = 80
+)
+
+// runNotification returns the notification about the run under the mode, if
+// it sends one. A failure takes precedence over a change.
+func runNotification(mode NotifyMode, s *Snapshot, changed bool) (notification, bool) {
+	// The run did not happen when its hook failed.
+	if s.hookFailed {
+		return notification{}, false
+	}
+
+	if status := s.exitStatus(); status != 0 && mode.onError() {
+		excerpt := s.errorResult
+		if isWhiteString(string(excerpt)) {
+			excerpt = s.result
+		}
+
+		body := fmt.Sprintf("exit status %d", status)
+		if lines := excerptLines(splitLines(string(stripANSI(excerpt))), nil); lines != "" {
+			body += "\n" + lines
+		}
+
+		return notification{title: s.commandLine() + " failed", body: body}, true
+	}
+
+	if changed && mode.onChange() && s.lines != nil {
+		lines := splitLines(string(stripANSI([]byte(s.text()))))
+
+		return notification{title: s.commandLine() + " changed", body: excerptLines(lines, s.lines.changedLines())}, true
+	}
+
+	return notification{}, false
+}
+
+// excerptLines quotes the first few of the lines which are not blank and,
+// if keep is not nil, which it keeps. Long lines are cut short.
+func excerptLines(lines []string, keep []bool) string {
+	var excerpt []string
+
+	more := 0
+
+	for i, line := range lines {
+		if keep != nil && (i >= len(keep) || !keep[i]) || isWhiteString(line) {
+			continue
+		}
+
+		if len(excerpt) == notificationLines {
+			more++
+
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if utf8.RuneCountInString(line) > notificationWidth {
+			line = string([]rune(line)[:notificationWidth-1]) + "…"
+		}
+
+		excerpt = append(excerpt, line)
+	}
+
+	if more > 0 {
+		excerpt = append(excerpt, fmt.Sprintf("and %d more lines", more))
+	}
+
+	return strings.Join(excerpt, "\n")
+}
+
+// notifierCommand is a program which shows a desktop notification.
+type notifierCommand struct {
+	name string
+	args []string
+}
+
+// notifierCommands returns the programs which may show the notification on
+// the system, in the order they are tried. There are none where viddy does
+// not know how to notify.
+func notifierCommands(goos string, n notification) []notifierCommand {
+	switch goos {
+	case "darwin":
+		return []notifierCommand{
+			{name: "terminal-notifier", args: []string{"-group", "viddy", "-title", n.title, "-message", n.body}},
+			{name: "osascript", args: []string{"-e", fmt.Sprintf("display notification %s with title %s",
+				appleScriptString(n.body), appleScriptString(n.title))}},
+		}
+	case "windows", "android", "ios", "js", "plan9":
+		return nil
+	}
+
+	return []notifierCommand{
+		{name: "notify-send", args: []string{"--app-name=viddy", "--", n.title, n.body}},
+		{name: "gdbus", args: []string{
+			"call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			gvariantString("viddy"), "0", gvariantString(""), gvariantString(n.title), gvariantString(n.body), "[]", "{}", "-1",
+		}},
+	}
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// gvariantString quotes s as a string in the text format of GVariant, which
+// gdbus parses its arguments in.
+func gvariantString(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '"':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r < ' ' || r == 0x7f:
+			// Other control characters are left out.
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// notifierTimeout is how long a program may take to notify.
+const notifierTimeout = 2 * time.Second
+
+// notifier sends desktop notifications, at most one per cooldown so that an
+// output which keeps flapping does not flood the desktop.
+type notifier struct {
+	goos     string
+	lookPath func(string) (string, error)
+	now      func() time.Time
+	cooldown time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newNotifier(cooldown time.Duration) *notifier {
+	return &notifier{
+		goos:     runtime.GOOS,
+		lookPath: exec.LookPath,
+		now:      time.Now,
+		cooldown: cooldown,
+	}
+}
+
+// allow tells whether a notification may go out now. If so, the following
+// ones are held back until the cooldown passed.
+func (n *notifier) allow() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	if !n.last.IsZero() && now.Sub(n.last) < n.cooldown {
+		return false
+	}
+
+	n.last = now
+
+	return true
+}
+
+// notify shows the notification through the first program which is
+// installed, and returns it. Where viddy does not know how to notify, it
+// does nothing.
+func (n *notifier) notify(m notification) (string, error) {
+	commands := notifierCommands(n.goos, m)
+	if len(commands) == 0 {
+		return "", nil
+	}
+
+	for _, command := range commands {
+		if _, err := n.lookPath(command.name); err != nil {
+			continue
+		}
+
+		return command.name, runNotifierCommand(command)
+	}
+
+	return "", errNoNotifier
+}
+
+func runNotifierCommand(command notifierCommand) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifierTimeout)
+	defer cancel()
+
+	if out, err := exec.CommandContext(ctx, command.name, command.args...).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(firstLine(string(out))); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return notifierError{command: command.name, err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunNotification(t *testing.T) {
+	before := &Snapshot{
+		command: "kubectl", args: []string{"get", "pods"},
+		result: []byte("web-1 Running\nweb-2 Running\ndb-1 Running\n"), completed: true,
+	}
+	assert.NoError(t, before.compareFromBefore())
+
+	s := &Snapshot{
+		command: "kubectl", args: []string{"get", "pods"},
+		result: []byte("web-1 Running\n\x1b[31mweb-2 CrashLoopBackOff\x1b[0m\ndb-1 Running\n"), completed: true, before: before,
+	}
+	assert.NoError(t, s.compareFromBefore())
+
+	n, ok := runNotification(NotifyModeChange, s, true)
+	assert.True(t, ok)
+	assert.Equal(t, notification{title: "kubectl get pods changed", body: "web-2 CrashLoopBackOff"}, n)
+
+	_, ok = runNotification(NotifyModeError, s, true)
+	assert.False(t, ok)
+
+	_, ok = runNotification(NotifyModeOff, s, true)
+	assert.False(t, ok)
+
+	_, ok = runNotification(NotifyModeBoth, s, false)
+	assert.False(t, ok)
+
+	// A failure is told about rather than the change, with stderr if any.
+	s.exitCode = 1
+	s.errorResult = []byte("error: You must be logged in to the server\n")
+
+	n, ok = runNotification(NotifyModeBoth, s, true)
+	assert.True(t, ok)
+	assert.Equal(t, notification{
+		title: "kubectl get pods failed", body: "exit status 1\nerror: You must be logged in to the server",
+	}, n)
+
+	_, ok = runNotification(NotifyModeChange, s, true)
+	assert.True(t, ok)
+
+	s.hookFailed = true
+	_, ok = runNotification(NotifyModeBoth, s, true)
+	assert.False(t, ok)
+}
+
+func TestExcerptLines(t *testing.T) {
+	lines := []string{"one\n", "  \n", "two\n", "three\n", "four\n", "five\n"}
+
+	assert.Equal(t, "one\ntwo\nthree\nand 2 more lines", excerptLines(lines, nil))
+	assert.Equal(t, "two\nfive", excerptLines(lines, []bool{false, true, true, false, false, true}))
+	assert.Equal(t, "", excerptLines(nil, nil))
+
+	long := excerptLines([]string{strings.Repeat("é", 100)}, nil)
+	assert.Equal(t, strings.Repeat("é", notificationWidth-1)+"…", long)
+}
+
+func TestNotifierAllow(t *testing.T) {
+	now := time.Date(2021, 9, 4, 12, 0, 0, 0, time.UTC)
+	n := &notifier{now: func() time.Time { return now }, cooldown: 30 * time.Second}
+
+	assert.True(t, n.allow())
+
+	now = now.Add(10 * time.Second)
+	assert.False(t, n.allow())
+
+	// Held back notifications do not extend the cooldown.
+	now = now.Add(20 * time.Second)
+	assert.True(t, n.allow())
+
+	n.cooldown = 0
+	assert.True(t, n.allow())
+}
+
+func TestNotifierNotify(t *testing.T) {
+	notifierFor := func(goos string, installed ...string) *notifier {
+		return &notifier{
+			goos: goos,
+			lookPath: func(name string) (string, error) {
+				for _, i := range installed {
+					if i == name {
+						return "/bin/true", nil
+					}
+				}
+
+				return "", exec.ErrNotFound
+			},
+			now: time.Now,
+		}
+	}
+
+	// Where viddy does not know how to notify, nothing happens.
+	via, err := notifierFor("windows").notify(notification{title: "df changed"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", via)
+
+	_, err = notifierFor("linux").notify(notification{title: "df changed"})
+	assert.True(t, errors.Is(err, errNoNotifier))
+
+	n := notification{title: `say "hi"`, body: "-v\nback\\slash"}
+	assert.Equal(t, []string{"notify-send", "gdbus"}, notifierCommandNames(notifierCommands("linux", n)))
+	assert.Equal(t, []string{"notify-send", "gdbus"}, notifierCommandNames(notifierCommands("freebsd", n)))
+	assert.Equal(t, []string{"terminal-notifier", "osascript"}, notifierCommandNames(notifierCommands("darwin", n)))
+
+	assert.Equal(t, []string{"--app-name=viddy", "--", `say "hi"`, "-v\nback\\slash"}, notifierCommands("linux", n)[0].args)
+	assert.Equal(t, []string{"-e", `display notification "-v` + "\n" + `back\\slash" with title "say \"hi\""`},
+		notifierCommands("darwin", n)[1].args)
+}
+
+func notifierCommandNames(commands []notifierCommand) []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+
+	return names
+}
+
+func TestGvariantString(t *testing.T) {
+	assert.Equal(t, `"say \"hi\"\nback\\slash"`, gvariantString("say \"hi\"\nback\\slash\x07"))
+	assert.Equal(t, `""`, gvariantString(""))
+}
+
+func TestRunNotifierCommand(t *testing.T) {
+	if _, err := exec.LookPath("false"); err != nil {
+		t.Skip("requires false")
+	}
+
+	err := runNotifierCommand(notifierCommand{name: "false"})
+	assert.EqualError(t, err, "false could not notify: exit status 1")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+)
+
+// limitedBuffer keeps the output written to it up to maxLines lines and
+// maxBytes bytes, zero being no limit. The rest is counted and dropped as it
+// comes in, so the command goes on to the end without its output piling up.
+// The buffer is not embedded, since io.Copy would read into it directly.
+// The output so far may be read while the command writes to it.
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+
+	maxLines int
+	maxBytes int64
+
+	keptLines int
+	truncated bool
+
+	// lines and last count the lines of the whole output.
+	lines int
+	last  byte
+}
+
+func newLimitedBuffer(maxLines int, maxBytes int64) *limitedBuffer {
+	return &limitedBuffer{maxLines: maxLines, maxBytes: maxBytes}
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lines += bytes.Count(p, []byte("\n"))
+	b.last = p[len(p)-1]
+
+	// The lines were complete, and this goes past them.
+	if b.maxLines > 0 && b.keptLines >= b.maxLines {
+		b.truncated = true
+	}
+
+	if b.truncated {
+		return len(p), nil
+	}
+
+	keep := p
+
+	if b.maxBytes > 0 && int64(b.buf.Len()+len(keep)) > b.maxBytes {
+		keep = keep[:b.maxBytes-int64(b.buf.Len())]
+		b.truncated = true
+	}
+
+	if b.maxLines > 0 {
+		for i, c := range keep {
+			if c != '\n' {
+				continue
+			}
+
+			b.keptLines++
+
+			if b.keptLines == b.maxLines {
+				if i+1 < len(keep) {
+					keep = keep[:i+1]
+					b.truncated = true
+				}
+
+				break
+			}
+		}
+	}
+
+	_, _ = b.buf.Write(keep)
+
+	return len(p), nil
+}
+
+// Bytes returns the output which was kept.
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// partial returns a copy of the output kept so far, while the command may
+// still write to the buffer.
+func (b *limitedBuffer) partial() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// totalLines returns how many lines the whole output has if it was cut
+// short, 0 otherwise.
+func (b *limitedBuffer) totalLines() int {
+	if !b.truncated {
+		return 0
+	}
+
+	if b.last != '\n' {
+		return b.lines + 1
+	}
+
+	return b.lines
+}
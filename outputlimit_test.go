@@ -0,0 +1,57 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxLines  int
+		maxBytes  int64
+		writes    []string
+		want      string
+		wantTotal int
+	}{
+		{name: "no limit", writes: []string{"a\nb\n", "c"}, want: "a\nb\nc"},
+		{name: "within the limits", maxLines: 3, maxBytes: 6, writes: []string{"a\nb\n", "c\n"}, want: "a\nb\nc\n"},
+		{name: "lines", maxLines: 2, writes: []string{"a\nb\nc\n", "d"}, want: "a\nb\n", wantTotal: 4},
+		{name: "lines ending a write", maxLines: 2, writes: []string{"a\nb\n", "c\n"}, want: "a\nb\n", wantTotal: 3},
+		{name: "bytes", maxBytes: 3, writes: []string{"ab", "cd\ne\n"}, want: "abc", wantTotal: 2},
+		{name: "bytes before lines", maxLines: 2, maxBytes: 3, writes: []string{"a\nb\nc\n"}, want: "a\nb", wantTotal: 3},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b := newLimitedBuffer(tt.maxLines, tt.maxBytes)
+
+			for _, w := range tt.writes {
+				n, err := b.Write([]byte(w))
+				require.NoError(t, err)
+				assert.Equal(t, len(w), n)
+			}
+
+			assert.Equal(t, tt.want, string(b.Bytes()))
+			assert.Equal(t, tt.wantTotal, b.totalLines())
+		})
+	}
+}
+
+func TestSnapshotMaxLines(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	s := NewSnapshot(0, "seq 100000", nil, runOptions{shell: "sh", maxLines: 3}, nil, make(chan struct{}))
+	require.NoError(t, s.run(make(chan int64, 1)))
+
+	assert.Equal(t, "1\n2\n3\n", string(s.result))
+	assert.Equal(t, 100000, s.totalLines)
+	assert.Equal(t, 0, s.exitStatus())
+	assert.Equal(t, "output truncated: showing 3 of 100000 lines", truncationNotice(s))
+}
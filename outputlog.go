@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// outputLog appends the output of every run to a file, moving it aside to
+// path.1 once it would grow beyond maxSize.
+type outputLog struct {
+	sync.Mutex
+
+	path    string
+	maxSize int64
+
+	file *os.File
+	size int64
+}
+
+type outputLogError struct {
+	path string
+	err  error
+}
+
+func (e outputLogError) Error() string {
+	return fmt.Sprintf("cannot write the log file %q: %v", e.path, e.err)
+}
+
+func (e outputLogError) Unwrap() error {
+	return e.err
+}
+
+func openOutputLog(path string, maxSize int64) (*outputLog, error) {
+	l := &outputLog{path: path, maxSize: maxSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *outputLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644) //nolint:gosec
+	if err != nil {
+		return outputLogError{path: l.path, err: err}
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+
+		return outputLogError{path: l.path, err: err}
+	}
+
+	l.file = f
+	l.size = info.Size()
+
+	return nil
+}
+
+// formatLogEntry is a header line describing the run, with the fields
+// added, followed by its raw output.
+func formatLogEntry(s *Snapshot, fields ...string) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "==> %s exit=%d duration=%s", s.start.Format("2006-01-02T15:04:05.000Z07:00"),
+		s.exitCode, s.end.Sub(s.start).Round(time.Millisecond))
+
+	for _, field := range fields {
+		b.WriteString(" " + field)
+	}
+
+	if s.err != nil && s.exitCode <= 0 {
+		fmt.Fprintf(&b, " error=%q", s.err.Error())
+	}
+
+	b.WriteString(" <==\n")
+	b.Write(s.result)
+
+	if len(s.result) > 0 && s.result[len(s.result)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes()
+}
+
+// write appends the run with a single write, so that every entry reaches the
+// file as a whole.
+func (l *outputLog) write(s *Snapshot) error {
+	entry := formatLogEntry(s)
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(entry)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(entry)
+	l.size += int64(n)
+
+	if err != nil {
+		return outputLogError{path: l.path, err: err}
+	}
+
+	return nil
+}
+
+func (l *outputLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return outputLogError{path: l.path, err: err}
+	}
+
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return outputLogError{path: l.path, err: err}
+	}
+
+	return l.open()
+}
+
+func (l *outputLog) Close() error {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.file.Close()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatLogEntry(t *testing.T) {
+	start := time.Date(2024, 3, 1, 14, 35, 0, 0, time.UTC)
+
+	s := &Snapshot{result: []byte("a\nb"), start: start, end: start.Add(1234 * time.Microsecond), exitCode: 1}
+	assert.Equal(t, "==> 2024-03-01T14:35:00.000Z exit=1 duration=1ms <==\na\nb\n", string(formatLogEntry(s)))
+
+	s = &Snapshot{start: start, end: start, exitCode: -1, err: errors.New("not found")}
+	assert.Equal(t, "==> 2024-03-01T14:35:00.000Z exit=-1 duration=0s error=\"not found\" <==\n", string(formatLogEntry(s)))
+}
+
+func TestOutputLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "viddy.log")
+	assert.NoError(t, os.WriteFile(path, []byte("earlier\n"), 0o600))
+
+	l, err := openOutputLog(path, 100)
+	assert.NoError(t, err)
+
+	start := time.Date(2024, 3, 1, 14, 35, 0, 0, time.UTC)
+	entry := func(out string) *Snapshot {
+		return &Snapshot{result: []byte(out), start: start, end: start}
+	}
+
+	assert.NoError(t, l.write(entry("one\n")))
+	assert.NoError(t, l.write(entry("two\n")))
+	assert.NoError(t, l.Close())
+
+	rotated, err := os.ReadFile(path + ".1")
+	assert.NoError(t, err)
+	assert.Equal(t, "earlier\n==> 2024-03-01T14:35:00.000Z exit=0 duration=0s <==\none\n", string(rotated))
+
+	current, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "==> 2024-03-01T14:35:00.000Z exit=0 duration=0s <==\ntwo\n", string(current))
+}
+
+func TestOpenOutputLogFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "viddy.log")
+
+	_, err := openOutputLog(path, 0)
+	assert.Equal(t, path, err.(outputLogError).path)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// SplitLayout decides how the panes of several commands share the screen.
+type SplitLayout string
+
+var (
+	// SplitHorizontal stacks the panes one above the other.
+	SplitHorizontal SplitLayout = "horizontal"
+	// SplitVertical puts the panes side by side.
+	SplitVertical SplitLayout = "vertical"
+)
+
+// paneGroup shows a viddy for each command in one app. Keys go to the
+// focused pane.
+type paneGroup struct {
+	app     *tview.Application
+	screen  *suspendableScreen
+	panes   []*Viddy
+	focused int
+	split   SplitLayout
+
+	// hurry is made once quitting starts, and closed to kill the commands
+	// which did not stop yet.
+	hurry     chan struct{}
+	hurryOnce sync.Once
+}
+
+func newPaneGroup(panes []*Viddy, split SplitLayout) *paneGroup {
+	g := &paneGroup{panes: panes, split: split}
+
+	for _, v := range panes {
+		v.group = g
+	}
+
+	return g
+}
+
+func (g *paneGroup) focusedPane() *Viddy {
+	return g.panes[g.focused]
+}
+
+// focus moves the keys to the pane.
+func (g *paneGroup) focus(i int) {
+	if i == g.focused {
+		return
+	}
+
+	g.focused = i
+	g.arrange()
+	g.focusedPane().UpdateStatusView()
+}
+
+func (g *paneGroup) focusNext() {
+	g.focus((g.focused + 1) % len(g.panes))
+}
+
+func (g *paneGroup) arrange() {
+	focused := g.focusedPane()
+	root := g.layout()
+
+	// The help is drawn over the panes.
+	if focused.showHelpView {
+		root = tview.NewPages().
+			AddPage("panes", root, true, true).
+			AddPage("help", focused.helpView, false, true)
+	}
+
+	g.app.SetRoot(root, true)
+}
+
+func (g *paneGroup) layout() tview.Primitive {
+	if len(g.panes) == 1 {
+		return g.focusedPane().layout()
+	}
+
+	direction := tview.FlexRow
+	if g.split == SplitVertical {
+		direction = tview.FlexColumn
+	}
+
+	flex := tview.NewFlex().SetDirection(direction)
+
+	for i, v := range g.panes {
+		v.highlightHeader(i == g.focused)
+		flex.AddItem(v.layout(), 0, 1, i == g.focused)
+	}
+
+	return flex
+}
+
+// handleMouse focuses the pane which is clicked. Otherwise the mouse acts on
+// the focused pane like the keys.
+func (g *paneGroup) handleMouse(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+	focused := g.focusedPane()
+
+	if action == tview.MouseLeftDown && !focused.showHelpView {
+		x, y := event.Position()
+
+		for i, v := range g.panes {
+			if i != g.focused && v.frame != nil && v.frame.InRect(x, y) {
+				g.focus(i)
+
+				return nil, action
+			}
+		}
+	}
+
+	return focused.handleMouse(event, action)
+}
+
+// exitStatus returns the first exit status of the panes which is not 0.
+func (g *paneGroup) exitStatus() int {
+	for _, v := range g.panes {
+		if status := v.exitStatus(); status != 0 {
+			return status
+		}
+	}
+
+	return 0
+}
+
+// Run shows the panes until the app is stopped.
+func (g *paneGroup) Run() error {
+	app := tview.NewApplication()
+	g.app = app
+
+	first := g.panes[0]
+
+	for _, v := range g.panes {
+		if err := v.setup(app); err != nil {
+			return err
+		}
+	}
+
+	// The screen is made here rather than by the app, so that drawing can
+	// stop while suspended to a shell.
+	screen, err := newScreen(first.forceTruecolor)
+	if err != nil {
+		return err
+	}
+
+	g.screen = &suspendableScreen{Screen: screen}
+	app.SetScreen(g.screen)
+
+	// The panes share the connection to the remote host.
+	if first.remote != nil {
+		first.remote.onChange = func() {
+			app.QueueUpdateDraw(func() {
+				for _, v := range g.panes {
+					v.updateRemoteStatus()
+				}
+			})
+		}
+	}
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		event = g.focusedPane().handleKey(event)
+
+		// The app would stop right away, leaving the commands running.
+		if event != nil && event.Key() == tcell.KeyCtrlC {
+			g.quit()
+
+			return nil
+		}
+
+		return event
+	})
+
+	app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		for _, v := range g.panes {
+			v.storeViewportSize()
+		}
+
+		if !g.focusedPane().showHelpView {
+			for _, v := range g.panes {
+				v.drawClipMarkers(screen)
+				v.drawLineAges(screen)
+			}
+		}
+	})
+
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		beep := false
+
+		for _, v := range g.panes {
+			beep = beep || v.isBeeping
+			v.isBeeping = false
+		}
+
+		if beep {
+			_ = screen.Beep()
+		}
+
+		return false
+	})
+
+	app.SetMouseCapture(g.handleMouse)
+	app.EnableMouse(first.isMouse)
+
+	for _, v := range g.panes {
+		v.start()
+	}
+
+	g.arrange()
+
+	stopSignals := g.handleSignals()
+	err = app.Run()
+	stopSignals()
+
+	for _, v := range g.panes {
+		if stopErr := v.stop(); err == nil {
+			err = stopErr
+		}
+	}
+
+	if err == nil {
+		for _, v := range g.panes {
+			if v.exitLine != nil {
+				fmt.Println(*v.exitLine)
+			}
+		}
+	}
+
+	return err
+}
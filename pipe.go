@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type pipeError struct {
+	exitCode int
+	output   string
+	err      error
+}
+
+func (e pipeError) Error() string {
+	msg := fmt.Sprintf("pipe filter failed: %v", e.err)
+	if e.exitCode > 0 {
+		msg = fmt.Sprintf("pipe filter exited with %d", e.exitCode)
+	}
+
+	if e.output != "" {
+		msg += ": " + e.output
+	}
+
+	return msg
+}
+
+func (e pipeError) Unwrap() error {
+	return e.err
+}
+
+// runPipe feeds the output through the --pipe filter, which shows and
+// compares what it writes instead. The filter runs once the command is done,
+// so it counts towards neither the duration nor the exit status of the run.
+// If it fails, the output stays the way the command wrote it.
+func (s *Snapshot) runPipe() {
+	command, _ := hookCommand(s.opts.pipe, s)
+	setProcessGroup(command)
+
+	var out, errOut bytes.Buffer
+	command.Stdout = &out
+	command.Stderr = &errOut
+
+	s.Lock()
+	if s.killed {
+		s.Unlock()
+
+		return
+	}
+
+	err := command.Start()
+	s.process = command
+	s.Unlock()
+
+	if err == nil {
+		err = command.Wait()
+	}
+
+	if err != nil {
+		if !s.isKilled() {
+			s.pipeErr = pipeError{
+				exitCode: command.ProcessState.ExitCode(),
+				output:   strings.TrimSpace(firstLine(errOut.String())),
+				err:      err,
+			}
+		}
+
+		return
+	}
+
+	s.raw = s.result
+	s.result = out.Bytes()
+
+	if s.opts.stripANSI {
+		s.result = stripANSI(s.result)
+	}
+
+	// The lines of the filtered output are not those stderr wrote.
+	s.stderrLines = nil
+}
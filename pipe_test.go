@@ -0,0 +1,42 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	run := func(pipe, command string) *Snapshot {
+		finishedQueue := make(chan int64, 1)
+		s := NewSnapshot(0, command, nil, runOptions{shell: "sh", pipe: pipe}, nil, make(chan struct{}))
+		_ = s.run(finishedQueue)
+		<-finishedQueue
+
+		return s
+	}
+
+	s := run(`sort; echo "exit $VIDDY_EXIT_CODE"`, "printf 'b\\na\\n'; exit 3")
+	assert.Equal(t, "a\nb\nexit 3\n", string(s.result))
+	assert.Equal(t, "b\na\n", string(s.raw))
+	assert.Equal(t, 3, s.exitCode)
+	assert.NoError(t, s.pipeErr)
+
+	// The filter does not count towards the duration of the run.
+	s = run("sleep 0.3; cat", "echo ok")
+	assert.Equal(t, "ok\n", string(s.result))
+	assert.Less(t, s.end.Sub(s.start), 300*time.Millisecond)
+
+	// The output stays as it was when the filter fails.
+	s = run("echo 'parse error' >&2; exit 5", "echo ok")
+	assert.Equal(t, "ok\n", string(s.result))
+	assert.Nil(t, s.raw)
+	assert.Equal(t, 0, s.exitCode)
+	assert.EqualError(t, s.pipeErr, "pipe filter exited with 5: parse error")
+}
@@ -0,0 +1,114 @@
+package main
+
+import "time"
+
+// missedTick tells whether the tick due at the time can no longer start on
+// time, going by the same half an interval as ClockSnapshot.
+func missedTick(due, now time.Time, every time.Duration) bool {
+	return !due.IsZero() && now.Sub(due) > every/2
+}
+
+// drop ends the run without running it or showing it in the history, so that
+// the generator which made it goes on to the next tick.
+func (s *Snapshot) drop() {
+	s.skipped = true
+	s.complete()
+	close(s.finish)
+}
+
+// syncPausedRuns pauses the runs while they are paused with the key, or the
+// time machine is open without poll_in_timemachine, and resumes them else.
+func (v *Viddy) syncPausedRuns() {
+	if v.isPaused || v.isTimeMachine && !v.pollInTimeMachine {
+		v.pauseRuns()
+	} else {
+		v.resumeRuns()
+	}
+}
+
+// pauseRuns keeps the runs to come from starting until resumeRuns.
+func (v *Viddy) pauseRuns() {
+	v.Lock()
+	defer v.Unlock()
+
+	if v.paused == nil {
+		v.paused = make(chan struct{})
+	}
+}
+
+func (v *Viddy) resumeRuns() {
+	v.Lock()
+	defer v.Unlock()
+
+	if v.paused != nil {
+		close(v.paused)
+		v.paused = nil
+	}
+}
+
+// waitResumed waits while the runs are paused. A clockwork tick which fell
+// due meanwhile is dropped, so that the runs take up their grid again rather
+// than start late; it returns false then.
+func (v *Viddy) waitResumed(s *Snapshot) bool {
+	v.RLock()
+	paused := v.paused
+	v.RUnlock()
+
+	if paused == nil {
+		return true
+	}
+
+	<-paused
+
+	if v.mode == ViddyIntervalModeClockwork && missedTick(s.due, time.Now(), v.interval()) {
+		v.log(levelDebug, "run dropped", "id", s.id, "reason", "it fell due while paused")
+		s.drop()
+
+		return false
+	}
+
+	return true
+}
+
+// togglePollInTimeMachine switches between running the command and pausing
+// it while the time machine is open.
+func (v *Viddy) togglePollInTimeMachine() {
+	v.pollInTimeMachine = !v.pollInTimeMachine
+	v.syncPausedRuns()
+
+	if v.pollInTimeMachine {
+		v.setMessage("Running the command in the time machine")
+	} else {
+		v.setMessage("Paused the command until the time machine is left")
+	}
+
+	v.UpdateStatusView()
+}
+
+// togglePause stops starting runs, keeping the history and the time machine
+// as they are, or starts them again.
+func (v *Viddy) togglePause() {
+	v.pauseCommand(!v.isPaused)
+}
+
+// pauseCommand pauses or resumes the runs.
+func (v *Viddy) pauseCommand(paused bool) {
+	if v.load != "" {
+		v.setMessage("Nothing runs while looking back at a file")
+
+		return
+	}
+
+	v.isPaused = paused
+	v.syncPausedRuns()
+	v.log(levelInfo, "runs paused", "paused", v.isPaused)
+
+	if v.isPaused {
+		v.setMessage("Paused the command")
+	} else {
+		v.setMessage("Resumed the command")
+	}
+
+	v.updateCommandViewTitle()
+	v.UpdateStatusView()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissedTick(t *testing.T) {
+	due := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		due  time.Time
+		now  time.Time
+		want bool
+	}{
+		{name: "on time", due: due, now: due, want: false},
+		{name: "within half an interval", due: due, now: due.Add(time.Second), want: false},
+		{name: "past half an interval", due: due, now: due.Add(1500 * time.Millisecond), want: true},
+		{name: "not due at a time", now: due, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, missedTick(tt.due, tt.now, 2*time.Second))
+		})
+	}
+}
+
+func TestSnapshotDrop(t *testing.T) {
+	finish := make(chan struct{})
+	s := &Snapshot{finish: finish}
+	s.drop()
+
+	assert.True(t, s.skipped)
+	assert.True(t, s.isCompleted())
+	assert.True(t, isFinished(finish))
+}
+
+func TestSyncPausedRuns(t *testing.T) {
+	tests := []struct {
+		name              string
+		isPaused          bool
+		isTimeMachine     bool
+		pollInTimeMachine bool
+		want              bool
+	}{
+		{name: "running", pollInTimeMachine: true, want: false},
+		{name: "paused with the key", isPaused: true, pollInTimeMachine: true, want: true},
+		{name: "polling in the time machine", isTimeMachine: true, pollInTimeMachine: true, want: false},
+		{name: "not polling in the time machine", isTimeMachine: true, want: true},
+		{name: "not polling out of the time machine", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Viddy{isPaused: tt.isPaused, isTimeMachine: tt.isTimeMachine, pollInTimeMachine: tt.pollInTimeMachine}
+			v.pauseRuns()
+
+			paused := v.paused
+			v.syncPausedRuns()
+
+			assert.Equal(t, tt.want, v.paused != nil)
+			assert.Equal(t, !tt.want, isFinished(paused), "the runs which waited go on")
+		})
+	}
+}
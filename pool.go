@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type poolJob struct {
+	deadline time.Time
+	run      func()
+	skip     func()
+}
+
+// runPool limits how many commands may execute at once across panes.
+// Jobs are queued per pane and dispatched round-robin so that a busy pane
+// cannot starve the others. A job which could not start before its deadline
+// (usually the next tick) is skipped instead of being run late.
+type runPool struct {
+	sync.Mutex
+
+	limit   int
+	running int
+
+	panes  []string
+	queues map[string][]poolJob
+	next   int
+
+	now func() time.Time
+}
+
+// newRunPool returns a pool running at most limit jobs at once.
+// A limit of zero or less means no limit.
+func newRunPool(limit int) *runPool {
+	return &runPool{
+		limit:  limit,
+		queues: map[string][]poolJob{},
+		now:    time.Now,
+	}
+}
+
+// submit queues a job for the pane and returns immediately.
+// Either run or skip is eventually called in its own goroutine.
+func (p *runPool) submit(pane string, deadline time.Time, run func(), skip func()) {
+	p.Lock()
+
+	if _, ok := p.queues[pane]; !ok {
+		p.panes = append(p.panes, pane)
+	}
+
+	p.queues[pane] = append(p.queues[pane], poolJob{deadline: deadline, run: run, skip: skip})
+	p.Unlock()
+
+	p.dispatch()
+}
+
+func (p *runPool) dispatch() {
+	p.Lock()
+	defer p.Unlock()
+
+	for p.limit <= 0 || p.running < p.limit {
+		job, ok := p.pop()
+		if !ok {
+			return
+		}
+
+		if !job.deadline.IsZero() && p.now().After(job.deadline) {
+			go job.skip()
+
+			continue
+		}
+
+		p.running++
+
+		go func() {
+			job.run()
+
+			p.Lock()
+			p.running--
+			p.Unlock()
+
+			p.dispatch()
+		}()
+	}
+}
+
+func (p *runPool) pop() (poolJob, bool) {
+	for i := 0; i < len(p.panes); i++ {
+		index := (p.next + i) % len(p.panes)
+		pane := p.panes[index]
+
+		q := p.queues[pane]
+		if len(q) == 0 {
+			continue
+		}
+
+		p.queues[pane] = q[1:]
+		p.next = index + 1
+
+		return q[0], true
+	}
+
+	return poolJob{}, false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeExecutor struct {
+	sync.Mutex
+
+	started []string
+	skipped []string
+	release chan struct{}
+}
+
+func newFakeExecutor() *fakeExecutor {
+	return &fakeExecutor{release: make(chan struct{})}
+}
+
+func (e *fakeExecutor) job(name string) (func(), func()) {
+	run := func() {
+		e.Lock()
+		e.started = append(e.started, name)
+		e.Unlock()
+
+		<-e.release
+	}
+
+	skip := func() {
+		e.Lock()
+		e.skipped = append(e.skipped, name)
+		e.Unlock()
+	}
+
+	return run, skip
+}
+
+func (e *fakeExecutor) startedJobs() []string {
+	e.Lock()
+	defer e.Unlock()
+
+	return append([]string{}, e.started...)
+}
+
+func (e *fakeExecutor) skippedJobs() []string {
+	e.Lock()
+	defer e.Unlock()
+
+	return append([]string{}, e.skipped...)
+}
+
+func (e *fakeExecutor) waitStarted(t *testing.T, n int) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		return len(e.startedJobs()) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestRunPool_orderAndFairness(t *testing.T) {
+	e := newFakeExecutor()
+	p := newRunPool(1)
+
+	for _, name := range []string{"a1", "a2", "a3", "b1", "b2"} {
+		run, skip := e.job(name)
+		p.submit(name[:1], time.Time{}, run, skip)
+	}
+
+	for i := 1; i <= 5; i++ {
+		e.waitStarted(t, i)
+		e.release <- struct{}{}
+	}
+
+	assert.Equal(t, []string{"a1", "b1", "a2", "b2", "a3"}, e.startedJobs())
+	assert.Empty(t, e.skippedJobs())
+}
+
+func TestRunPool_limit(t *testing.T) {
+	e := newFakeExecutor()
+	p := newRunPool(2)
+
+	for _, name := range []string{"a1", "b1", "c1"} {
+		run, skip := e.job(name)
+		p.submit(name[:1], time.Time{}, run, skip)
+	}
+
+	e.waitStarted(t, 2)
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, e.startedJobs(), 2)
+
+	e.release <- struct{}{}
+	e.waitStarted(t, 3)
+
+	e.release <- struct{}{}
+	e.release <- struct{}{}
+}
+
+func TestRunPool_submitNeverBlocks(t *testing.T) {
+	e := newFakeExecutor()
+	p := newRunPool(1)
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			run, skip := e.job("a")
+			p.submit("a", time.Time{}, run, skip)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("submit blocked on a full pool")
+	}
+
+	close(e.release)
+}
+
+func TestRunPool_skipAfterDeadline(t *testing.T) {
+	e := newFakeExecutor()
+	p := newRunPool(1)
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	run, skip := e.job("a1")
+	p.submit("a", now.Add(time.Second), run, skip)
+	e.waitStarted(t, 1)
+
+	run, skip = e.job("a2")
+	p.submit("a", now.Add(time.Second), run, skip)
+
+	p.Lock()
+	now = now.Add(2 * time.Second)
+	p.Unlock()
+
+	e.release <- struct{}{}
+
+	assert.Eventually(t, func() bool {
+		return len(e.skippedJobs()) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a1"}, e.startedJobs())
+	assert.Equal(t, []string{"a2"}, e.skippedJobs())
+}
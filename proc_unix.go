@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+const defaultShell = "sh"
+
+// shellCommand builds the command running cmdline in the shell.
+func shellCommand(shell string, shellOpts []string, cmdline string) *exec.Cmd {
+	var args []string
+	args = append(args, shellOpts...)
+	args = append(args, "-c", cmdline)
+
+	return exec.Command(shell, args...) //nolint:gosec
+}
+
+// setProcessGroup puts the command in its own process group, so that
+// killProcess also reaches the children spawned by the shell.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// terminateProcess asks the process group of the command to stop.
+func terminateProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// startPty starts the command attached to a new pseudo-terminal of the given size.
+func startPty(cmd *exec.Cmd, rows, cols uint16) (*os.File, error) {
+	return pty.StartWithSize(cmd, &pty.Winsize{Rows: rows, Cols: cols})
+}
@@ -0,0 +1,78 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const defaultShell = "powershell"
+
+// shellCommand builds the command running cmdline in the shell.
+//
+// cmd.exe does not follow the usual quoting rules of Windows programs, so its
+// command line is passed verbatim. PowerShell takes the command with -Command,
+// and anything else is assumed to be a POSIX shell such as Git Bash.
+func shellCommand(shell string, shellOpts []string, cmdline string) *exec.Cmd {
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(shell)), ".exe")
+
+	switch name {
+	case "cmd":
+		command := exec.Command(shell)
+		command.SysProcAttr = &syscall.SysProcAttr{
+			CmdLine: fmt.Sprintf(`%s %s /s /c "%s"`, syscall.EscapeArg(shell), strings.Join(shellOpts, " "), cmdline),
+		}
+
+		return command
+	case "powershell", "pwsh":
+		var args []string
+		args = append(args, "-NoProfile")
+		args = append(args, shellOpts...)
+		args = append(args, "-Command", cmdline)
+
+		return exec.Command(shell, args...) //nolint:gosec
+	default:
+		var args []string
+		args = append(args, shellOpts...)
+		args = append(args, "-c", cmdline)
+
+		return exec.Command(shell, args...) //nolint:gosec
+	}
+}
+
+// setProcessGroup starts the command in a new process group, so that it does
+// not receive the console signals meant for viddy.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// killProcess terminates the whole process tree of the command.
+func killProcess(cmd *exec.Cmd) error {
+	pid := strconv.Itoa(cmd.Process.Pid)
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", pid).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+
+	return nil
+}
+
+// terminateProcess kills the process tree of the command, Windows has no
+// signal which asks it to stop.
+func terminateProcess(cmd *exec.Cmd) error {
+	return killProcess(cmd)
+}
+
+func startPty(cmd *exec.Cmd, rows, cols uint16) (*os.File, error) {
+	return nil, errPtyNotSupported
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// reflowView is a text view whose text is wrapped when it is drawn, at the
+// width it is drawn at. Its scroll offset counts rows on screen, so it
+// converts between them and the lines of the text, and keeps the line at
+// the top in place when the width changes.
+type reflowView struct {
+	*tview.TextView
+
+	wrap bool
+
+	// mu guards lineWidths, which are written with the text while the view
+	// is drawn.
+	mu sync.Mutex
+	// lineWidths are the widths of the lines of the text, nil until they are
+	// asked for after it changed.
+	lineWidths []int
+
+	// width is the width the text was last wrapped at, 0 before it is drawn.
+	width int
+}
+
+func newReflowView() *reflowView {
+	return &reflowView{TextView: tview.NewTextView(), wrap: true}
+}
+
+func (r *reflowView) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lineWidths = nil
+
+	return r.TextView.Write(p)
+}
+
+func (r *reflowView) Clear() *tview.TextView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lineWidths = nil
+
+	return r.TextView.Clear()
+}
+
+func (r *reflowView) SetText(text string) *tview.TextView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lineWidths = nil
+
+	return r.TextView.SetText(text)
+}
+
+func (r *reflowView) SetWrap(wrap bool) *tview.TextView {
+	r.wrap = wrap
+
+	return r.TextView.SetWrap(wrap)
+}
+
+// widths returns how wide every line of the text is on screen.
+func (r *reflowView) widths() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lineWidths == nil {
+		widths := lineWidths(r.GetText(false))
+		// The last line is what the view holds back, such as an unclosed tag.
+		r.lineWidths = widths[:len(widths)-1]
+	}
+
+	return r.lineWidths
+}
+
+// rowStarts returns the row each line starts on, followed by the number of
+// rows, when the text wraps at width or, if width is 0, does not wrap.
+func (r *reflowView) rowStarts(width int) []int {
+	widths := r.widths()
+	starts := make([]int, 0, len(widths)+1)
+
+	row := 0
+	for _, w := range widths {
+		starts = append(starts, row)
+		row += wrappedRows(w, width)
+	}
+
+	return append(starts, row)
+}
+
+// wrapWidth is the width the text is wrapped at now, or 0 if it is not.
+func (r *reflowView) wrapWidth() int {
+	if !r.wrap {
+		return 0
+	}
+
+	return r.width
+}
+
+// rowOf returns the row the line of the text starts on.
+func (r *reflowView) rowOf(line int) int {
+	return rowOfLine(r.rowStarts(r.wrapWidth()), line)
+}
+
+// lineOf returns the line of the text shown on the row.
+func (r *reflowView) lineOf(row int) int {
+	return lineOfRow(r.rowStarts(r.wrapWidth()), row)
+}
+
+// rowCount returns how many rows the text takes.
+func (r *reflowView) rowCount() int {
+	starts := r.rowStarts(r.wrapWidth())
+
+	return starts[len(starts)-1]
+}
+
+// scrollLine returns the line of the text at the top, and the column.
+func (r *reflowView) scrollLine() (int, int) {
+	row, column := r.GetScrollOffset()
+
+	return r.lineOf(row), column
+}
+
+// scrollToLine shows the line of the text at the top.
+func (r *reflowView) scrollToLine(line, column int) {
+	r.ScrollTo(r.rowOf(line), column)
+}
+
+func (r *reflowView) Draw(screen tcell.Screen) {
+	// The inner rect is fresh once the view was laid out.
+	if _, _, width, _ := r.GetInnerRect(); width != r.width {
+		if r.wrap && r.width > 0 && width > 0 {
+			row, column := r.GetScrollOffset()
+			line := lineOfRow(r.rowStarts(r.width), row)
+			r.ScrollTo(rowOfLine(r.rowStarts(width), line), column)
+		}
+
+		r.width = width
+	}
+
+	r.TextView.Draw(screen)
+}
+
+// wrappedRows returns how many rows a line of the width takes when it wraps
+// at wrap, 0 being no wrapping. An empty line takes a row as well.
+func wrappedRows(width, wrap int) int {
+	if wrap <= 0 || width <= wrap {
+		return 1
+	}
+
+	return (width + wrap - 1) / wrap
+}
+
+// rowOfLine returns the row the line starts on, out of the starts of
+// rowStarts.
+func rowOfLine(starts []int, line int) int {
+	if line < 0 {
+		return 0
+	}
+
+	if line >= len(starts) {
+		return starts[len(starts)-1]
+	}
+
+	return starts[line]
+}
+
+// lineOfRow returns the line shown on the row, out of the starts of
+// rowStarts.
+func lineOfRow(starts []int, row int) int {
+	lines := len(starts) - 1
+	if lines <= 0 || row <= 0 {
+		return 0
+	}
+
+	line := sort.Search(lines, func(i int) bool { return starts[i] > row }) - 1
+	if line < 0 {
+		return 0
+	}
+
+	return line
+}
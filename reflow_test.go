@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrappedRows(t *testing.T) {
+	assert.Equal(t, 1, wrappedRows(0, 10))
+	assert.Equal(t, 1, wrappedRows(10, 10))
+	assert.Equal(t, 2, wrappedRows(11, 10))
+	assert.Equal(t, 3, wrappedRows(25, 10))
+	assert.Equal(t, 1, wrappedRows(25, 0))
+}
+
+func TestRowOfLineAndLineOfRow(t *testing.T) {
+	// Lines of 1, 3 and 2 rows.
+	starts := []int{0, 1, 4, 6}
+
+	assert.Equal(t, 0, rowOfLine(starts, 0))
+	assert.Equal(t, 4, rowOfLine(starts, 2))
+	assert.Equal(t, 6, rowOfLine(starts, 5))
+	assert.Equal(t, 0, rowOfLine(starts, -1))
+
+	for row, line := range []int{0, 1, 1, 1, 2, 2, 2, 2} {
+		assert.Equal(t, line, lineOfRow(starts, row), "row %d", row)
+	}
+
+	assert.Equal(t, 0, lineOfRow([]int{0}, 3))
+}
+
+func newReflowScreen(t *testing.T) tcell.Screen {
+	t.Helper()
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	require.NoError(t, screen.Init())
+	screen.SetSize(40, 10)
+
+	return screen
+}
+
+func TestReflowViewKeepsTheLineAtTheTop(t *testing.T) {
+	screen := newReflowScreen(t)
+
+	r := newReflowView()
+	r.SetDynamicColors(true)
+	_, err := io.WriteString(r, "0\n1 [red]long line which wraps[-]\n2\n3 another long line\n4\n5\n6\n7\n8\n9")
+	require.NoError(t, err)
+
+	r.SetRect(0, 0, 20, 3)
+	r.Draw(screen)
+
+	r.scrollToLine(3, 0)
+	assert.Equal(t, 4, r.rowOf(3))
+	line, _ := r.scrollLine()
+	assert.Equal(t, 3, line)
+
+	// Narrower, the lines above wrap to more rows.
+	r.SetRect(0, 0, 6, 3)
+	r.Draw(screen)
+
+	line, _ = r.scrollLine()
+	assert.Equal(t, 3, line)
+	assert.Equal(t, "3 anot", screenRow(screen, 0, 6))
+
+	// Without wrapping, rows are lines.
+	r.SetWrap(false)
+	assert.Equal(t, 3, r.rowOf(3))
+	assert.Equal(t, 10, r.rowCount())
+}
+
+func TestReflowViewWidths(t *testing.T) {
+	r := newReflowView()
+	_, err := io.WriteString(r, "ab\n[red]abc[-]")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, r.widths())
+
+	r.Clear()
+	_, err = io.WriteString(r, "abcd")
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, r.widths())
+
+	r.SetText("a\nbc")
+	assert.Equal(t, []int{1, 2}, r.widths())
+}
+
+// screenRow returns the first width characters of the row of the screen.
+func screenRow(screen tcell.Screen, y, width int) string {
+	row := make([]rune, 0, width)
+
+	for x := 0; x < width; x++ {
+		r, _, _, _ := screen.GetContent(x, y)
+		row = append(row, r)
+	}
+
+	return string(row)
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// remoteSignals are the numbers of the signals the host reports by name. They
+// are the same on the usual systems.
+var remoteSignals = map[ssh.Signal]int{
+	ssh.SIGHUP:  1,
+	ssh.SIGINT:  2,
+	ssh.SIGQUIT: 3,
+	ssh.SIGILL:  4,
+	ssh.SIGABRT: 6,
+	ssh.SIGFPE:  8,
+	ssh.SIGKILL: 9,
+	ssh.SIGSEGV: 11,
+	ssh.SIGPIPE: 13,
+	ssh.SIGALRM: 14,
+	ssh.SIGTERM: 15,
+}
+
+const (
+	// remoteDialTimeout limits how long connecting to the host may take.
+	remoteDialTimeout = 10 * time.Second
+
+	// remoteKeepAlive is how often the connection is checked while idle.
+	remoteKeepAlive = 15 * time.Second
+
+	// remoteRetryMin and remoteRetryMax bound the delay between attempts to
+	// connect again, which doubles with every failed attempt.
+	remoteRetryMin = time.Second
+	remoteRetryMax = time.Minute
+)
+
+var errConnectionLost = errors.New("connection lost")
+
+type remoteError struct {
+	target string
+	err    error
+}
+
+func (e remoteError) Error() string {
+	return fmt.Sprintf("ssh %s: %v", e.target, e.err)
+}
+
+func (e remoteError) Unwrap() error {
+	return e.err
+}
+
+// remoteHost runs commands on a host over one SSH connection, which is made
+// again with backoff once it drops.
+type remoteHost struct {
+	sync.Mutex
+
+	target string
+	addr   string
+	config *ssh.ClientConfig
+
+	client   *ssh.Client
+	failures int
+	retryAt  time.Time
+	lastErr  error
+
+	// onChange is called after the connection was made or lost.
+	onChange func()
+}
+
+// parseRemoteTarget splits [user@]host[:port].
+func parseRemoteTarget(target string) (string, string, string, error) {
+	var userName string
+	if i := strings.LastIndex(target, "@"); i >= 0 {
+		userName, target = target[:i], target[i+1:]
+	}
+
+	host, port := target, ""
+
+	// A bare IPv6 address has colons of its own.
+	if strings.HasPrefix(target, "[") || strings.Count(target, ":") == 1 {
+		var err error
+
+		host, port, err = net.SplitHostPort(target)
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+
+	if host == "" {
+		return "", "", "", errors.New("missing host")
+	}
+
+	return userName, host, port, nil
+}
+
+// newRemoteHost prepares the connection to the target, taking the host
+// name, port, user, identity files and known hosts from ~/.ssh/config. It
+// connects on the first run.
+func newRemoteHost(target string) (*remoteHost, error) {
+	userName, alias, port, err := parseRemoteTarget(target)
+	if err != nil {
+		return nil, remoteError{target: target, err: err}
+	}
+
+	host := alias
+	if name := ssh_config.Get(alias, "HostName"); name != "" {
+		host = strings.ReplaceAll(name, "%h", alias)
+	}
+
+	if port == "" {
+		port = ssh_config.Get(alias, "Port")
+	}
+
+	if userName == "" {
+		userName = ssh_config.Get(alias, "User")
+	}
+
+	if userName == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, remoteError{target: target, err: err}
+		}
+
+		userName = u.Username
+	}
+
+	hostKeyCallback, err := knownHostsCallback(alias)
+	if err != nil {
+		return nil, remoteError{target: target, err: err}
+	}
+
+	return &remoteHost{
+		target: target,
+		addr:   net.JoinHostPort(host, port),
+		config: &ssh.ClientConfig{
+			User:            userName,
+			Auth:            remoteAuthMethods(alias),
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         remoteDialTimeout,
+		},
+	}, nil
+}
+
+func expandHome(path string) string {
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+
+	return path
+}
+
+// knownHostsCallback checks host keys against the known hosts files. Hosts
+// must be trusted with ssh beforehand.
+func knownHostsCallback(alias string) (ssh.HostKeyCallback, error) {
+	var files []string
+
+	for _, file := range strings.Fields(ssh_config.Get(alias, "UserKnownHostsFile")) {
+		if file = expandHome(file); fileExists(file) {
+			files = append(files, file)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no known_hosts file, connect with ssh once to trust the host key")
+	}
+
+	return knownhosts.New(files...)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
+
+// remoteAuthMethods offers the keys of the SSH agent and the identity files
+// which are not protected by a passphrase.
+func remoteAuthMethods(alias string) []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	files := ssh_config.GetAll(alias, "IdentityFile")
+	files = append(files, "~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa")
+
+	var signers []ssh.Signer
+
+	seen := map[string]struct{}{}
+
+	for _, file := range files {
+		file = expandHome(file)
+		if _, ok := seen[file]; ok {
+			continue
+		}
+
+		seen[file] = struct{}{}
+
+		key, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		if signer, err := ssh.ParsePrivateKey(key); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	return methods
+}
+
+// status tells whether the host is connected, and otherwise the last error.
+func (r *remoteHost) status() (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.client != nil, r.lastErr
+}
+
+// newSession returns a session on the connection, connecting first if
+// needed. While the delay after a failed attempt lasts, it fails right away.
+func (r *remoteHost) newSession() (*ssh.Session, error) {
+	r.Lock()
+
+	if r.client == nil {
+		if wait := time.Until(r.retryAt); wait > 0 {
+			err := r.lastErr
+			r.Unlock()
+
+			return nil, remoteError{target: r.target, err: fmt.Errorf("%w, retrying in %s", err, wait.Round(time.Second))}
+		}
+
+		client, err := ssh.Dial("tcp", r.addr, r.config)
+		if err != nil {
+			r.fail(err)
+			r.Unlock()
+			r.changed()
+
+			return nil, remoteError{target: r.target, err: err}
+		}
+
+		r.client = client
+		r.failures = 0
+		r.lastErr = nil
+
+		go r.watch(client)
+
+		defer r.changed()
+	}
+
+	client := r.client
+	r.Unlock()
+
+	session, err := client.NewSession()
+	if err != nil {
+		r.lost(client, err)
+
+		return nil, remoteError{target: r.target, err: err}
+	}
+
+	return session, nil
+}
+
+// fail schedules the next attempt to connect. r must be locked.
+func (r *remoteHost) fail(err error) {
+	r.failures++
+	r.lastErr = err
+
+	delay := remoteRetryMin
+	for i := 1; i < r.failures && delay < remoteRetryMax; i++ {
+		delay *= 2
+	}
+
+	if delay > remoteRetryMax {
+		delay = remoteRetryMax
+	}
+
+	r.retryAt = time.Now().Add(delay)
+}
+
+// watch sends keepalives until the connection drops.
+func (r *remoteHost) watch(client *ssh.Client) {
+	done := make(chan struct{})
+
+	go func() {
+		_ = client.Wait()
+		close(done)
+	}()
+
+	t := time.NewTicker(remoteKeepAlive)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			r.lost(client, errConnectionLost)
+
+			return
+		case <-t.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				r.lost(client, errConnectionLost)
+
+				return
+			}
+		}
+	}
+}
+
+// lost forgets the connection, so that the next run connects again.
+func (r *remoteHost) lost(client *ssh.Client, err error) {
+	r.Lock()
+	current := r.client == client
+
+	if current {
+		r.client = nil
+		r.fail(err)
+	}
+	r.Unlock()
+
+	if current {
+		_ = client.Close()
+		r.changed()
+	}
+}
+
+func (r *remoteHost) changed() {
+	if r.onChange != nil {
+		r.onChange()
+	}
+}
+
+// remoteCommandLine changes to the directory and sets the environment
+// before the command, since servers do not accept them otherwise.
+func remoteCommandLine(cmdline, dir string, env []envVar) string {
+	var b strings.Builder
+
+	for _, ev := range env {
+		if ev.unset {
+			fmt.Fprintf(&b, "unset %s; ", ev.key)
+		} else {
+			fmt.Fprintf(&b, "export %s=%s; ", ev.key, shellQuote(ev.value))
+		}
+	}
+
+	if dir != "" {
+		fmt.Fprintf(&b, "cd %s && ", shellQuote(dir))
+	}
+
+	b.WriteString(cmdline)
+
+	return b.String()
+}
+
+// shellQuote quotes the string for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runRemote executes the command on the remote host and blocks until it
+// finishes. It returns false if the run was killed before it started.
+func (s *Snapshot) runRemote() bool {
+	var eb bytes.Buffer
+
+	b := newLimitedBuffer(s.opts.maxLines, s.opts.maxBytes)
+	out := newCapture(s.opts, b, &eb)
+
+	commands := []string{s.command}
+	commands = append(commands, s.args...)
+
+	stdin, closeStdin, err := s.opts.input.open()
+	if err != nil {
+		s.err = err
+		s.errorResult = []byte(err.Error() + "\n")
+
+		return true
+	}
+
+	defer closeStdin()
+
+	session, err := s.opts.remote.newSession()
+	if err == nil {
+		session.Stdin = stdin
+		session.Stdout, session.Stderr = out.stdout, out.stderr
+
+		if s.opts.pty {
+			err = session.RequestPty("xterm-256color", int(s.opts.ptyRows), int(s.opts.ptyCols), ssh.TerminalModes{})
+		}
+	}
+
+	s.Lock()
+	if s.killed {
+		s.Unlock()
+
+		if session != nil {
+			_ = session.Close()
+		}
+
+		return false
+	}
+
+	if err == nil {
+		err = session.Start(remoteCommandLine(strings.Join(commands, " "), s.opts.dir, s.opts.env))
+	}
+
+	s.session = session
+	s.live = b
+	s.Unlock()
+
+	started := err == nil
+	if started {
+		err = session.Wait()
+		_ = session.Close()
+	}
+
+	out.finish(s)
+
+	s.result = s.terminalOutput(b.Bytes())
+
+	s.totalLines = b.totalLines()
+	s.errorResult = eb.Bytes()
+
+	var exitErr *ssh.ExitError
+
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		s.err = err
+		s.exitCode = exitErr.ExitStatus()
+		s.signal = remoteSignals[ssh.Signal(exitErr.Signal())]
+	case !started:
+		// Without the connection there is no output to show but the error.
+		s.failStart(err)
+	default:
+		// The connection dropped while the command ran.
+		s.err = err
+		s.errorResult = append(s.errorResult, err.Error()+"\n"...)
+	}
+
+	return true
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer answers exec requests by echoing the command line on stdout,
+// writing "oops" on stderr when it contains "fail", and exiting with 3 then.
+type testSSHServer struct {
+	sync.Mutex
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.PublicKey
+	conns    []*ssh.ServerConn
+	commands []string
+}
+
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &testSSHServer{listener: listener, config: config, hostKey: signer.PublicKey()}
+	t.Cleanup(func() {
+		_ = listener.Close()
+		srv.dropAll()
+	})
+
+	go srv.serve()
+
+	return srv
+}
+
+func (srv *testSSHServer) serve() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go srv.handle(conn)
+	}
+}
+
+func (srv *testSSHServer) handle(conn net.Conn) {
+	sc, chans, reqs, err := ssh.NewServerConn(conn, srv.config)
+	if err != nil {
+		return
+	}
+
+	srv.Lock()
+	srv.conns = append(srv.conns, sc)
+	srv.Unlock()
+
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+
+		go srv.session(ch, reqs)
+	}
+}
+
+func (srv *testSSHServer) session(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	for req := range reqs {
+		if req.Type != "exec" {
+			_ = req.Reply(req.Type == "pty-req", nil)
+
+			continue
+		}
+
+		var exec struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &exec); err != nil {
+			_ = req.Reply(false, nil)
+
+			return
+		}
+
+		_ = req.Reply(true, nil)
+
+		srv.Lock()
+		srv.commands = append(srv.commands, exec.Command)
+		srv.Unlock()
+
+		var status struct{ Status uint32 }
+
+		_, _ = ch.Write([]byte(exec.Command + "\n"))
+		if strings.Contains(exec.Command, "fail") {
+			_, _ = ch.Stderr().Write([]byte("oops\n"))
+			status.Status = 3
+		}
+
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(&status))
+
+		return
+	}
+}
+
+// dropAll closes the connections as if the network went away.
+func (srv *testSSHServer) dropAll() {
+	srv.Lock()
+	defer srv.Unlock()
+
+	for _, c := range srv.conns {
+		_ = c.Close()
+	}
+
+	srv.conns = nil
+}
+
+func (srv *testSSHServer) remote() *remoteHost {
+	return &remoteHost{
+		target: "test",
+		addr:   srv.listener.Addr().String(),
+		config: &ssh.ClientConfig{
+			User:            "viddy",
+			HostKeyCallback: ssh.FixedHostKey(srv.hostKey),
+			Timeout:         remoteDialTimeout,
+		},
+	}
+}
+
+func runRemoteSnapshot(r *remoteHost, command string) *Snapshot {
+	finish := make(chan struct{})
+	finishedQueue := make(chan int64, 1)
+
+	s := NewSnapshot(0, command, nil, runOptions{remote: r, dir: "/tmp"}, nil, finish)
+	_ = s.run(finishedQueue)
+	<-finishedQueue
+
+	return s
+}
+
+func TestRunRemote(t *testing.T) {
+	srv := newTestSSHServer(t)
+	r := srv.remote()
+
+	changes := make(chan struct{}, 10)
+	r.onChange = func() { changes <- struct{}{} }
+
+	s := runRemoteSnapshot(r, "echo hello")
+	assert.Equal(t, "cd '/tmp' && echo hello\n", string(s.result))
+	assert.Empty(t, s.errorResult)
+	assert.NoError(t, s.err)
+	assert.Equal(t, 0, s.exitCode)
+	assert.Len(t, changes, 1)
+
+	connected, err := r.status()
+	assert.True(t, connected)
+	assert.NoError(t, err)
+
+	s = runRemoteSnapshot(r, "fail")
+	assert.Equal(t, "oops\n", string(s.errorResult))
+	assert.Error(t, s.err)
+	assert.Equal(t, 3, s.exitCode)
+
+	// Both runs share the connection.
+	srv.Lock()
+	assert.Len(t, srv.conns, 1)
+	srv.Unlock()
+}
+
+func TestRemoteReconnects(t *testing.T) {
+	srv := newTestSSHServer(t)
+	r := srv.remote()
+
+	changes := make(chan struct{}, 10)
+	r.onChange = func() { changes <- struct{}{} }
+
+	_, err := r.newSession()
+	require.NoError(t, err)
+	<-changes
+
+	srv.dropAll()
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost connection was not noticed")
+	}
+
+	connected, err := r.status()
+	assert.False(t, connected)
+	assert.ErrorIs(t, err, errConnectionLost)
+
+	// Until the delay is over, runs fail without trying to connect.
+	s := runRemoteSnapshot(r, "echo hello")
+	assert.Error(t, s.err)
+	assert.Contains(t, string(s.errorResult), "ssh test: connection lost, retrying in 1s")
+
+	r.Lock()
+	r.retryAt = time.Now()
+	r.Unlock()
+
+	s = runRemoteSnapshot(r, "echo hello")
+	assert.NoError(t, s.err)
+	assert.Equal(t, "cd '/tmp' && echo hello\n", string(s.result))
+
+	connected, err = r.status()
+	assert.True(t, connected)
+	assert.NoError(t, err)
+}
+
+func TestRemoteBackoff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	r := &remoteHost{target: "test", addr: addr, config: &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         time.Second,
+	}}
+
+	var delays []time.Duration
+
+	for i := 0; i < 8; i++ {
+		r.Lock()
+		r.retryAt = time.Time{}
+		r.Unlock()
+
+		_, err := r.newSession()
+
+		var remoteErr remoteError
+
+		assert.True(t, errors.As(err, &remoteErr))
+
+		r.Lock()
+		delays = append(delays, time.Until(r.retryAt).Round(time.Second))
+		r.Unlock()
+	}
+
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 32 * time.Second, time.Minute, time.Minute,
+	}, delays)
+}
+
+func TestParseRemoteTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		user   string
+		host   string
+		port   string
+		err    bool
+	}{
+		{target: "web1", host: "web1"},
+		{target: "admin@web1", user: "admin", host: "web1"},
+		{target: "admin@web1:2222", user: "admin", host: "web1", port: "2222"},
+		{target: "me@corp@web1", user: "me@corp", host: "web1"},
+		{target: "[::1]:2222", host: "::1", port: "2222"},
+		{target: "::1", host: "::1"},
+		{target: "admin@", err: true},
+		{target: ":22", err: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.target, func(t *testing.T) {
+			user, host, port, err := parseRemoteTarget(tt.target)
+			if tt.err {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.user, user)
+			assert.Equal(t, tt.host, host)
+			assert.Equal(t, tt.port, port)
+		})
+	}
+}
+
+func TestRemoteCommandLine(t *testing.T) {
+	env := []envVar{{key: "A", value: "it's"}, {key: "PAGER", unset: true}}
+
+	assert.Equal(t, "ls -l", remoteCommandLine("ls -l", "", nil))
+	assert.Equal(t, `export A='it'\''s'; unset PAGER; cd '/var/log' && ls -l`, remoteCommandLine("ls -l", "/var/log", env))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+)
+
+// expiredSnapshots returns how many of the oldest of the sorted ids are
+// taken before cutoff and can be dropped from the history. It stops at keep,
+// the snapshot which is shown, and at the first snapshot which is not done
+// with, since a newer one may still look back at it.
+func expiredSnapshots(ids []int64, cutoff, keep int64, done func(id int64) bool) int {
+	n := 0
+
+	for _, id := range ids {
+		if id >= cutoff || id >= keep || !done(id) {
+			break
+		}
+
+		n++
+	}
+
+	return n
+}
+
+// isSettled reports whether nothing reads the snapshot anymore but to show
+// it: it finished and was compared with the run before.
+func (s *Snapshot) isSettled() bool {
+	return s.completed && (s.diffPrepared || s.skipped || s.hookFailed)
+}
+
+// dropIDsBefore returns the sorted ids from the first one at or after id on.
+func dropIDsBefore(ids []int64, id int64) []int64 {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+
+	return ids[i:]
+}
+
+// prune forgets the marks of the snapshots before the id.
+func (m *timelineMarks) prune(id int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.changed = dropIDsBefore(m.changed, id)
+	m.failed = dropIDsBefore(m.failed, id)
+}
+
+// prune leaves the snapshots before the id out of the session.
+func (w *sessionWriter) prune(id int64) {
+	if w == nil {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	kept := w.snapshots[:0]
+
+	for _, s := range w.snapshots {
+		if s.id >= id {
+			kept = append(kept, s)
+		}
+	}
+
+	if len(kept) < len(w.snapshots) {
+		// Let the dropped snapshots go.
+		for i := len(kept); i < len(w.snapshots); i++ {
+			w.snapshots[i] = nil
+		}
+
+		w.snapshots = kept
+		w.dirty = true
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiredSnapshots(t *testing.T) {
+	ids := []int64{0, 1000, 2000, 3000, 4000}
+	done := func(id int64) bool { return true }
+
+	tests := []struct {
+		name   string
+		cutoff int64
+		keep   int64
+		done   func(id int64) bool
+		want   int
+	}{
+		{name: "none old enough", cutoff: 0, keep: 4000, done: done, want: 0},
+		{name: "older than the cutoff", cutoff: 2500, keep: 4000, done: done, want: 3},
+		{name: "the shown snapshot stays", cutoff: 5000, keep: 1000, done: done, want: 1},
+		{name: "nothing shown yet", cutoff: 5000, keep: -1, done: done, want: 0},
+		{
+			name:   "stops at a run which is not done",
+			cutoff: 5000,
+			keep:   4000,
+			done:   func(id int64) bool { return id != 2000 },
+			want:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expiredSnapshots(ids, tt.cutoff, tt.keep, tt.done))
+		})
+	}
+}
+
+func TestSnapshotIsSettled(t *testing.T) {
+	assert.False(t, (&Snapshot{}).isSettled())
+	assert.False(t, (&Snapshot{completed: true}).isSettled())
+	assert.True(t, (&Snapshot{completed: true, diffPrepared: true}).isSettled())
+	assert.True(t, (&Snapshot{completed: true, skipped: true}).isSettled())
+	assert.True(t, (&Snapshot{completed: true, hookFailed: true}).isSettled())
+}
+
+func TestTimelineMarksPrune(t *testing.T) {
+	m := &timelineMarks{changed: []int64{1, 5, 9}, failed: []int64{2, 3}}
+	m.prune(5)
+
+	assert.Equal(t, []int64{5, 9}, m.changed)
+	assert.Empty(t, m.failed)
+}
+
+func TestSessionWriterPrune(t *testing.T) {
+	w := &sessionWriter{snapshots: []*Snapshot{{id: 2000}, {id: 0}, {id: 1000}}}
+	w.prune(1000)
+
+	assert.Equal(t, []*Snapshot{{id: 2000}, {id: 1000}}, w.snapshots)
+	assert.True(t, w.isDirty())
+
+	w.dirty = false
+	w.prune(1000)
+	assert.False(t, w.isDirty())
+
+	var none *sessionWriter
+	none.prune(1000)
+}
+
+func TestSnapshotRestart(t *testing.T) {
+	a := &Snapshot{id: 1, result: []byte("x 1\n"), completed: true}
+	b := &Snapshot{id: 2, result: []byte("x 2\n"), completed: true, before: a}
+
+	assert.NoError(t, b.compareFromBefore())
+	assert.NotNil(t, b.permanentMask())
+
+	b.restart()
+
+	assert.Nil(t, b.before)
+	assert.Nil(t, b.diffBase)
+	assert.Nil(t, b.permanentMask())
+	assert.True(t, b.diffPrepared)
+
+	// The next run compares with it alone.
+	c := &Snapshot{id: 3, result: []byte("x 2\n"), completed: true, before: b}
+	assert.NoError(t, c.compareFromBefore())
+	assert.Zero(t, c.diffAdditionCount+c.diffDeletionCount)
+	assert.Empty(t, c.permanentMask())
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+var (
+	errRules     = errors.New("rules must be an array of tables, written as [[rules]]")
+	errRuleMatch = errors.New(`"match" is required`)
+)
+
+type ruleError struct {
+	index int
+	err   error
+}
+
+func (e ruleError) Error() string {
+	return fmt.Sprintf("rule %d: %v", e.index+1, e.err)
+}
+
+func (e ruleError) Unwrap() error {
+	return e.err
+}
+
+// rule is a [[rules]] section of the config file. Its values are those of a
+// profile but the command, and apply to the commands which match matches.
+type rule struct {
+	index  int
+	match  *regexp.Regexp
+	values profile
+}
+
+func (r *rule) String() string {
+	return fmt.Sprintf("rule %d (match = %q)", r.index+1, r.match.String())
+}
+
+// ruleKeys are the keys of a rule which are not values to apply.
+var ruleKeys = map[string]struct{}{
+	"match": {},
+}
+
+// ruleEntries returns the keys and values of every rule of the config file.
+func ruleEntries(v *viper.Viper) ([]map[string]interface{}, error) {
+	value := v.Get("rules")
+	if value == nil {
+		return nil, nil
+	}
+
+	list, err := cast.ToSliceE(value)
+	if err != nil {
+		return nil, errRules
+	}
+
+	entries := make([]map[string]interface{}, 0, len(list))
+
+	for i, item := range list {
+		entry, err := cast.ToStringMapE(item)
+		if err != nil {
+			return nil, ruleError{index: i, err: errRules}
+		}
+
+		// viper leaves the keys of arrays of tables as they are written.
+		lower := make(map[string]interface{}, len(entry))
+		for key, value := range entry {
+			lower[strings.ToLower(key)] = value
+		}
+
+		entries = append(entries, lower)
+	}
+
+	return entries, nil
+}
+
+// getRules reads the rules of the config file in order.
+func getRules(v *viper.Viper) ([]*rule, error) {
+	entries, err := ruleEntries(v)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*rule, 0, len(entries))
+
+	for i, entry := range entries {
+		expr := cast.ToString(entry["match"])
+		if expr == "" {
+			return nil, ruleError{index: i, err: errRuleMatch}
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, ruleError{index: i, err: err}
+		}
+
+		values := profile{}
+
+		for key, value := range entry {
+			if _, ok := ruleKeys[key]; !ok && key != "command" {
+				values[key] = value
+			}
+		}
+
+		rules = append(rules, &rule{index: i, match: re, values: values})
+	}
+
+	return rules, nil
+}
+
+// ruleCommandLine returns the command line the rules match: the words of the
+// command, followed by the commands of --cmd as if they were separated by ---.
+func ruleCommandLine(words, cmdLines []string) string {
+	var lines []string
+
+	if len(words) > 0 {
+		lines = append(lines, strings.Join(words, " "))
+	}
+
+	lines = append(lines, cmdLines...)
+
+	return strings.Join(lines, " "+commandSeparator+" ")
+}
+
+// matchRule returns the first of the rules which matches the command line,
+// or nil.
+func matchRule(rules []*rule, commandLine string) *rule {
+	for _, r := range rules {
+		if r.match.MatchString(commandLine) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// withRule returns the profile with the values of the rule it does not set
+// itself, since a profile is asked for by name.
+func (p profile) withRule(r *rule) profile {
+	if r == nil {
+		return p
+	}
+
+	merged := profile{}
+
+	for key, value := range r.values {
+		merged[key] = value
+	}
+
+	for key, value := range p {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// describeRule tells which of the rules matched the command, or nothing if
+// there are no rules.
+func describeRule(rules int, matched *rule) string {
+	switch {
+	case matched != nil:
+		return fmt.Sprintf("%s matched", matched)
+	case rules > 0:
+		return "no rule matched"
+	default:
+		return ""
+	}
+}
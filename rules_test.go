@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRulesViper(t *testing.T, configFile string) *viper.Viper {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(bytes.NewBufferString(configFile)))
+
+	return v
+}
+
+func TestGetRules(t *testing.T) {
+	rules, err := getRules(newRulesViper(t, `
+[[rules]]
+match = "^kubectl"
+Differences = true
+command = "ignored"
+
+[[rules]]
+match = "aws "
+interval = "10s"
+`))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	assert.Equal(t, "^kubectl", rules[0].match.String())
+	assert.Equal(t, profile{"differences": true}, rules[0].values)
+	assert.Equal(t, profile{"interval": "10s"}, rules[1].values)
+	assert.Equal(t, `rule 2 (match = "aws ")`, rules[1].String())
+
+	rules, err = getRules(viper.New())
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestGetRulesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		configFile string
+		expErr     string
+	}{
+		{
+			name:       "invalid regexp",
+			configFile: "[[rules]]\nmatch = \"^ls\"\n\n[[rules]]\nmatch = \"kubectl (\"\n",
+			expErr:     "rule 2: error parsing regexp: missing closing ): `kubectl (`",
+		},
+		{
+			name:       "missing match",
+			configFile: "[[rules]]\ninterval = \"5s\"\n",
+			expErr:     `rule 1: "match" is required`,
+		},
+		{
+			name:       "not an array of tables",
+			configFile: "rules = \"kubectl\"\n",
+			expErr:     errRules.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := getRules(newRulesViper(t, tt.configFile))
+			assert.EqualError(t, err, tt.expErr)
+		})
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	rules := []*rule{
+		{index: 0, match: regexp.MustCompile(`^kubectl get`)},
+		{index: 1, match: regexp.MustCompile(`kubectl`)},
+	}
+
+	assert.Equal(t, rules[0], matchRule(rules, "kubectl get pods"))
+	assert.Equal(t, rules[1], matchRule(rules, "watch kubectl top"))
+	assert.Nil(t, matchRule(rules, "ls -l"))
+	assert.Nil(t, matchRule(nil, "ls -l"))
+}
+
+func TestRuleCommandLine(t *testing.T) {
+	assert.Equal(t, "kubectl get pods", ruleCommandLine([]string{"kubectl", "get", "pods"}, nil))
+	assert.Equal(t, "ls --- df -h", ruleCommandLine([]string{"ls"}, []string{"df -h"}))
+	assert.Equal(t, "uptime --- df -h", ruleCommandLine(nil, []string{"uptime", "df -h"}))
+}
+
+func TestProfileWithRule(t *testing.T) {
+	r := &rule{values: profile{"interval": "10s", "pty": true}}
+
+	assert.Equal(t, profile{"interval": "10s", "pty": true}, profile(nil).withRule(r))
+	assert.Equal(t, profile{"interval": "1s", "pty": true, "command": "ls"},
+		profile{"interval": "1s", "command": "ls"}.withRule(r))
+	assert.Equal(t, profile{"interval": "1s"}, profile{"interval": "1s"}.withRule(nil))
+}
+
+func TestDescribeRule(t *testing.T) {
+	r := &rule{index: 0, match: regexp.MustCompile(`^kubectl`)}
+
+	assert.Equal(t, `rule 1 (match = "^kubectl") matched`, describeRule(1, r))
+	assert.Equal(t, "no rule matched", describeRule(2, nil))
+	assert.Equal(t, "", describeRule(0, nil))
+}
+
+func TestNewConfigWithRules(t *testing.T) {
+	configFile := `
+[general]
+no_title = false
+
+[[rules]]
+match = "^kubectl"
+differences = true
+interval = "10s"
+
+[[rules]]
+match = "kubectl|docker"
+no_title = true
+
+[profiles.pods]
+command = "kubectl get pods"
+interval = "5s"
+`
+
+	conf, err := newConfig(newRulesViper(t, configFile), []string{"kubectl", "get", "pods"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, conf.runtime.rule.index)
+	assert.Equal(t, 2, conf.runtime.rules)
+	assert.Contains(t, formatConfig(conf), "#\n# Rules: rule 1 (match = \"^kubectl\") matched.\n")
+	assert.True(t, conf.general.differences)
+	assert.Equal(t, "10s", conf.runtime.interval.String())
+	// Only the first rule which matches applies.
+	assert.False(t, conf.general.noTitle)
+
+	// Flags win over the rule.
+	conf, err = newConfig(newRulesViper(t, configFile), []string{"-n", "3", "kubectl", "get", "pods"})
+	require.NoError(t, err)
+	assert.Equal(t, "3s", conf.runtime.interval.String())
+	assert.True(t, conf.general.differences)
+
+	// So does the profile, and the rule matches its command.
+	conf, err = newConfig(newRulesViper(t, configFile), []string{"@pods"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, conf.runtime.rule.index)
+	assert.Equal(t, "5s", conf.runtime.interval.String())
+	assert.True(t, conf.general.differences)
+
+	conf, err = newConfig(newRulesViper(t, configFile), []string{"docker", "ps"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, conf.runtime.rule.index)
+	assert.True(t, conf.general.noTitle)
+	assert.False(t, conf.general.differences)
+
+	conf, err = newConfig(newRulesViper(t, configFile), []string{"ls"})
+	require.NoError(t, err)
+	assert.Nil(t, conf.runtime.rule)
+	assert.Contains(t, formatConfig(conf), "# Rules: no rule matched.\n")
+	assert.Equal(t, "2s", conf.runtime.interval.String())
+
+	_, err = newConfig(newRulesViper(t, "[[rules]]\nmatch = \"(\"\n"), []string{"ls"})
+	assert.EqualError(t, err, "rule 1: error parsing regexp: missing closing ): `(`")
+}
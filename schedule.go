@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type scheduleError struct {
+	expr   string
+	reason string
+}
+
+func (e scheduleError) Error() string {
+	return fmt.Sprintf("cannot parse schedule %q: %s", e.expr, e.reason)
+}
+
+// cronSchedule is a cron expression with an optional leading seconds field.
+// Each field is a bit set of the values it matches.
+type cronSchedule struct {
+	expr string
+
+	seconds, minutes, hours, days, months, weekdays uint64
+
+	// Like cron, a day matches either field if both are restricted.
+	anyDay, anyWeekday bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	secondField  = cronField{name: "second", min: 0, max: 59}
+	minuteField  = cronField{name: "minute", min: 0, max: 59}
+	hourField    = cronField{name: "hour", min: 0, max: 23}
+	dayField     = cronField{name: "day of month", min: 1, max: 31}
+	monthField   = cronField{name: "month", min: 1, max: 12, names: monthNames}
+	weekdayField = cronField{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+)
+
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseSchedule parses "minute hour day month weekday", or the same preceded
+// by a seconds field, or a descriptor such as "@hourly".
+func parseSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		if d, ok := scheduleDescriptors[strings.ToLower(fields[0])]; ok {
+			fields = strings.Fields(d)
+		}
+	}
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, scheduleError{expr: expr, reason: fmt.Sprintf("expected 5 or 6 fields, got %d", len(fields))}
+	}
+
+	s := &cronSchedule{expr: expr}
+	targets := []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&s.seconds, secondField},
+		{&s.minutes, minuteField},
+		{&s.hours, hourField},
+		{&s.days, dayField},
+		{&s.months, monthField},
+		{&s.weekdays, weekdayField},
+	}
+
+	for i, t := range targets {
+		bits, err := t.field.parse(fields[i])
+		if err != nil {
+			return nil, scheduleError{expr: expr, reason: err.Error()}
+		}
+
+		*t.bits = bits
+	}
+
+	// Sunday is both 0 and 7.
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1
+	}
+
+	s.anyDay = isWildcard(fields[3])
+	s.anyWeekday = isWildcard(fields[5])
+
+	return s, nil
+}
+
+func isWildcard(field string) bool {
+	return field == "*" || field == "?"
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, item)
+			}
+
+			rangePart, step = item[:i], n
+		}
+
+		low, high, err := f.parseRange(rangePart)
+		if err != nil {
+			return 0, err
+		}
+
+		// "5/15" means from 5 to the end in steps of 15.
+		if step > 1 && !strings.Contains(rangePart, "-") && !isWildcard(rangePart) {
+			high = f.max
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) parseRange(s string) (int, int, error) {
+	if isWildcard(s) {
+		return f.min, f.max, nil
+	}
+
+	lowStr, highStr := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		lowStr, highStr = s[:i], s[i+1:]
+	}
+
+	low, err := f.parseValue(lowStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	high, err := f.parseValue(highStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if low > high {
+		return 0, 0, fmt.Errorf("%s range %q is backwards", f.name, s)
+	}
+
+	return low, high, nil
+}
+
+func (f cronField) parseValue(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %q is not between %d and %d", f.name, s, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+func (s *cronSchedule) String() string {
+	return s.expr
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// maxScheduleYears bounds the search for expressions which never match, like February 30.
+const maxScheduleYears = 5
+
+// next returns the first time after t whose wall clock in the location of t
+// matches, or the zero time if there is none.
+//
+// Like cron, the search runs on the wall clock. A time skipped by the clock
+// going forward runs at the same distance after the change, and one repeated
+// by it going back runs once, unless the schedule matches every hour.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	w := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC).Add(time.Second)
+
+	// After the clock goes back, the repeated wall clock is behind t.
+	if s.hours == everyHour {
+		w = w.Add(-time.Hour)
+	}
+
+	limit := w.AddDate(maxScheduleYears, 0, 0)
+
+	for w.Before(limit) {
+		switch {
+		case s.months&(1<<uint(w.Month())) == 0:
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(w):
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hours&(1<<uint(w.Hour())) == 0:
+			w = w.Truncate(time.Hour).Add(time.Hour)
+		case s.minutes&(1<<uint(w.Minute())) == 0:
+			w = w.Truncate(time.Minute).Add(time.Minute)
+		case s.seconds&(1<<uint(w.Second())) == 0:
+			w = w.Add(time.Second)
+		default:
+			if at, ok := s.occurrence(w, loc, t); ok {
+				return at
+			}
+
+			w = w.Add(time.Second)
+		}
+	}
+
+	return time.Time{}
+}
+
+// everyHour is the mask of a schedule matching every hour.
+const everyHour = 1<<24 - 1
+
+// occurrence returns when the wall clock w happens in loc after t.
+func (s *cronSchedule) occurrence(w time.Time, loc *time.Location, t time.Time) (time.Time, bool) {
+	at := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, loc)
+
+	// time.Date picks one of the instants showing a repeated wall clock, so
+	// look for the others around it.
+	var occurrences []time.Time
+
+	for _, d := range []time.Duration{-time.Hour, 0, time.Hour} {
+		c := at.Add(d)
+		if c.Hour() == w.Hour() && c.Minute() == w.Minute() && c.Day() == w.Day() {
+			occurrences = append(occurrences, c)
+		}
+	}
+
+	// The wall clock was skipped, so it runs as far after the change as it
+	// is after the start of the gap, like 2:30 at 3:30.
+	if len(occurrences) == 0 {
+		_, offset := at.Add(-2 * time.Hour).Zone()
+		at = w.Add(-time.Duration(offset) * time.Second).In(loc)
+
+		return at, at.After(t)
+	}
+
+	if s.hours != everyHour {
+		occurrences = occurrences[:1]
+	}
+
+	for _, c := range occurrences {
+		if c.After(t) {
+			return c, true
+		}
+	}
+
+	return time.Time{}, false
+}
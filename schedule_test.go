@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "*/5 * * * *"},
+		{expr: "30 */10 * * * *"},
+		{expr: "0 9-17 * * MON-FRI"},
+		{expr: "0 0 1,15 jan,jul ?"},
+		{expr: "@hourly"},
+		{expr: "* * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "* * * * foo", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseSchedule(tt.expr)
+			if tt.wantErr {
+				assert.IsType(t, scheduleError{}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// Friday.
+	from := time.Date(2024, 3, 1, 14, 42, 10, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "*/5 * * * *", want: time.Date(2024, 3, 1, 14, 45, 0, 0, time.UTC)},
+		{expr: "*/15 * * * * *", want: time.Date(2024, 3, 1, 14, 42, 15, 0, time.UTC)},
+		{expr: "10/20 * * * *", want: time.Date(2024, 3, 1, 14, 50, 0, 0, time.UTC)},
+		{expr: "0 9 * * MON", want: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{expr: "0 9 * * 7", want: time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 10 * 6", want: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{expr: "@monthly", want: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 30 2 *", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := parseSchedule(tt.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, s.next(from))
+		})
+	}
+}
+
+func TestCronScheduleNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+
+	runs := func(expr string, from, to time.Time) []string {
+		s, err := parseSchedule(expr)
+		assert.NoError(t, err)
+
+		var got []string
+		for at := s.next(from); at.Before(to); at = s.next(at) {
+			got = append(got, at.Format("15:04 MST"))
+		}
+
+		return got
+	}
+
+	// The clocks go from 2:00 to 3:00 on March 10, 2024.
+	spring := time.Date(2024, 3, 10, 0, 0, 0, 0, loc)
+	assert.Equal(t, []string{"03:30 EDT"}, runs("30 2 * * *", spring, spring.Add(24*time.Hour)))
+	assert.Equal(t, []string{"01:30 EST", "03:30 EDT"}, runs("30 * * * *", spring.Add(time.Hour), spring.Add(3*time.Hour)))
+
+	// The clocks go from 2:00 back to 1:00 on November 3, 2024.
+	fall := time.Date(2024, 11, 3, 0, 0, 0, 0, loc)
+	assert.Equal(t, []string{"01:30 EDT"}, runs("30 1 * * *", fall, fall.Add(24*time.Hour)))
+	assert.Equal(t, []string{"00:30 EDT", "01:30 EDT", "01:30 EST", "02:30 EST"},
+		runs("30 * * * *", fall, fall.Add(4*time.Hour)))
+}
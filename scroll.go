@@ -0,0 +1,73 @@
+package main
+
+import "regexp"
+
+// scrollWithMargin returns the top row of a view of height rows which shows
+// the row with margin rows around it, like vim's scrolloff. A row outside of
+// the view is centered, one inside is kept with as little scrolling as
+// possible. Everything fits if there are no more than height rows in total.
+func scrollWithMargin(top, row, height, total, margin int) int {
+	if total <= height || height <= 0 {
+		return 0
+	}
+
+	if limit := (height - 1) / 2; margin > limit {
+		margin = limit
+	}
+
+	switch {
+	case row < top || row >= top+height:
+		top = row - height/2
+	case row-margin < top:
+		top = row - margin
+	case row+margin >= top+height:
+		top = row + margin - height + 1
+	}
+
+	if top > total-height {
+		top = total - height
+	}
+
+	if top < 0 {
+		top = 0
+	}
+
+	return top
+}
+
+// centerRows returns the top row of a view of height rows which shows the
+// rows from start up to end in its middle, or from start on if they do not
+// fit.
+func centerRows(start, end, height, total int) int {
+	if total <= height || height <= 0 {
+		return 0
+	}
+
+	top := start
+	if end-start < height {
+		top = start - (height-(end-start))/2
+	}
+
+	if top > total-height {
+		top = total - height
+	}
+
+	if top < 0 {
+		top = 0
+	}
+
+	return top
+}
+
+// findMatch returns the first of the lines from the start on which the query
+// matches, going around to the first line, or -1 if none does.
+func findMatch(lines []string, query *regexp.Regexp, start int) int {
+	for i := range lines {
+		line := (start + i) % len(lines)
+		if query.MatchString(lines[line]) {
+			return line
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrollWithMargin(t *testing.T) {
+	tests := []struct {
+		name                            string
+		top, row, height, total, margin int
+		want                            int
+	}{
+		{name: "visible with room", top: 10, row: 15, height: 10, total: 100, margin: 2, want: 10},
+		{name: "near the top edge", top: 10, row: 11, height: 10, total: 100, margin: 2, want: 9},
+		{name: "near the bottom edge", top: 10, row: 18, height: 10, total: 100, margin: 2, want: 11},
+		{name: "below the view", top: 0, row: 50, height: 10, total: 100, margin: 2, want: 45},
+		{name: "above the view", top: 50, row: 3, height: 10, total: 100, margin: 2, want: 0},
+		{name: "past the end", top: 0, row: 98, height: 10, total: 100, margin: 2, want: 90},
+		{name: "margin larger than half", top: 10, row: 10, height: 10, total: 100, margin: 20, want: 6},
+		{name: "output fits", top: 0, row: 8, height: 10, total: 9, margin: 5, want: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scrollWithMargin(tt.top, tt.row, tt.height, tt.total, tt.margin))
+		})
+	}
+}
+
+func TestCenterRows(t *testing.T) {
+	tests := []struct {
+		name                      string
+		start, end, height, total int
+		want                      int
+	}{
+		{name: "middle", start: 50, end: 52, height: 10, total: 100, want: 46},
+		{name: "near the top", start: 2, end: 3, height: 10, total: 100, want: 0},
+		{name: "near the end", start: 97, end: 98, height: 10, total: 100, want: 90},
+		{name: "taller than the view", start: 20, end: 40, height: 10, total: 100, want: 20},
+		{name: "output fits", start: 5, end: 6, height: 10, total: 9, want: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, centerRows(tt.start, tt.end, tt.height, tt.total))
+		})
+	}
+}
+
+func TestFindMatch(t *testing.T) {
+	lines := []string{"pod-a Running", "pod-b Pending", "pod-c Running"}
+
+	assert.Equal(t, 0, findMatch(lines, regexp.MustCompile("Running"), 0))
+	assert.Equal(t, 2, findMatch(lines, regexp.MustCompile("Running"), 1))
+	assert.Equal(t, 0, findMatch(lines, regexp.MustCompile("pod-a"), 1))
+	assert.Equal(t, -1, findMatch(lines, regexp.MustCompile("Failed"), 0))
+	assert.Equal(t, 0, findMatch(lines, searchQuery{text: "running", ignoreCase: true}.pattern(), 0))
+}
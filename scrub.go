@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// defaultScrubAcceleration are the steps, in snapshots, which holding a key
+// of the time machine goes through.
+var defaultScrubAcceleration = []int{1, 2, 5, 10, 25}
+
+const (
+	// scrubRepeatWindow is how soon the same key must come again to count
+	// as held down.
+	scrubRepeatWindow = 150 * time.Millisecond
+	// scrubRepeatsPerStep is how many repeats go by before the next step.
+	scrubRepeatsPerStep = 4
+)
+
+type scrubAccelerationError struct {
+	value string
+}
+
+func (e scrubAccelerationError) Error() string {
+	return fmt.Sprintf("scrub_acceleration: %q must be positive numbers of snapshots, such as [1, 2, 5, 10, 25]", e.value)
+}
+
+// parseScrubAcceleration parses a list of steps, or a string of them
+// separated by commas. An empty list turns acceleration off.
+func parseScrubAcceleration(value interface{}) ([]int, error) {
+	items, err := cast.ToStringSliceE(value)
+	if s, ok := value.(string); ok {
+		items, err = strings.Split(s, ","), nil
+	}
+
+	if err != nil {
+		return nil, scrubAccelerationError{value: cast.ToString(value)}
+	}
+
+	steps := make([]int, 0, len(items))
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 1 {
+			return nil, scrubAccelerationError{value: item}
+		}
+
+		steps = append(steps, n)
+	}
+
+	return steps, nil
+}
+
+// scrubDirection is which way a key of the time machine moves.
+type scrubDirection int
+
+const (
+	scrubPast scrubDirection = iota + 1
+	scrubFuture
+)
+
+// scrubber speeds up a key of the time machine which is held down.
+type scrubber struct {
+	steps     []int
+	direction scrubDirection
+	last      time.Time
+	repeats   int
+}
+
+// step returns how many snapshots the key pressed at the time moves. It
+// starts over from the first step once the key pauses or the other one
+// is pressed.
+func (s *scrubber) step(d scrubDirection, now time.Time) int {
+	if d == s.direction && now.Sub(s.last) <= scrubRepeatWindow {
+		s.repeats++
+	} else {
+		s.repeats = 0
+	}
+
+	s.direction, s.last = d, now
+
+	return s.current()
+}
+
+func (s *scrubber) current() int {
+	if len(s.steps) == 0 {
+		return 1
+	}
+
+	i := s.repeats / scrubRepeatsPerStep
+	if i >= len(s.steps) {
+		i = len(s.steps) - 1
+	}
+
+	return s.steps[i]
+}
+
+// multiplier returns the step while the key is held down at the time, or 0
+// once it paused.
+func (s *scrubber) multiplier(now time.Time) int {
+	if now.Sub(s.last) > scrubRepeatWindow {
+		return 0
+	}
+
+	return s.current()
+}
+
+// scrubOnTimeMachine moves through the history by the accelerated step,
+// and shows it in the status until the key pauses.
+func (v *Viddy) scrubOnTimeMachine(d scrubDirection) {
+	rows := v.scrub.step(d, time.Now())
+	if d == scrubFuture {
+		rows = -rows
+	}
+
+	v.moveOnTimeMachine(rows)
+
+	time.AfterFunc(2*scrubRepeatWindow, func() {
+		v.app.QueueUpdateDraw(v.UpdateStatusView)
+	})
+}
+
+// moveOnTimeMachine goes the number of snapshots into the past, or into the
+// future if negative, stopping at either end.
+func (v *Viddy) moveOnTimeMachine(rows int) {
+	count := v.historyView.GetRowCount()
+	if count == 0 {
+		return
+	}
+
+	selection, _ := v.historyView.GetSelection()
+
+	row := selection + rows
+	if row < 0 {
+		row = 0
+	}
+
+	if row > count-1 {
+		row = count - 1
+	}
+
+	if row == selection {
+		return
+	}
+
+	cell := v.historyView.GetCell(row, 0)
+	if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
+		v.setSelection(id)
+	}
+}
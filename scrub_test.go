@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScrubAcceleration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    []int
+		wantErr error
+	}{
+		{name: "list", value: []interface{}{int64(1), int64(4), int64(16)}, want: []int{1, 4, 16}},
+		{name: "string", value: "1, 2,5", want: []int{1, 2, 5}},
+		{name: "empty", value: []interface{}{}, want: []int{}},
+		{name: "zero", value: []interface{}{int64(1), int64(0)}, wantErr: scrubAccelerationError{value: "0"}},
+		{name: "not a number", value: "1,fast", wantErr: scrubAccelerationError{value: "fast"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScrubAcceleration(tt.value)
+			assert.Equal(t, tt.wantErr, err)
+
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScrubber(t *testing.T) {
+	s := scrubber{steps: defaultScrubAcceleration}
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	var steps []int
+
+	for i := 0; i < 20; i++ {
+		now = now.Add(30 * time.Millisecond)
+		steps = append(steps, s.step(scrubPast, now))
+	}
+
+	assert.Equal(t, []int{1, 1, 1, 1, 2, 2, 2, 2, 5, 5, 5, 5, 10, 10, 10, 10, 25, 25, 25, 25}, steps)
+	assert.Equal(t, 25, s.multiplier(now))
+
+	// Letting go of the key starts over.
+	now = now.Add(time.Second)
+	assert.Equal(t, 0, s.multiplier(now))
+	assert.Equal(t, 1, s.step(scrubPast, now))
+
+	// So does the other direction.
+	for i := 0; i < 8; i++ {
+		now = now.Add(30 * time.Millisecond)
+		s.step(scrubPast, now)
+	}
+
+	assert.Equal(t, 1, s.step(scrubFuture, now.Add(30*time.Millisecond)))
+
+	// Without steps, keys always move by one.
+	off := scrubber{}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 1, off.step(scrubPast, now))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/rivo/tview"
+)
+
+// leadingFlags matches inline flags at the start of a search, such as "(?i)".
+var leadingFlags = regexp.MustCompile(`^\(\?[imsU]+\)`)
+
+// searchQuery is the text searched for in the output and how it matches.
+type searchQuery struct {
+	text       string
+	ignoreCase bool
+	wholeWord  bool
+}
+
+// pattern returns the regexp which finds the text, or nil without any. The
+// text is literal, except for leading inline flags such as "(?i)", which
+// apply as they do in the patterns of --highlight and --trigger.
+func (q searchQuery) pattern() *regexp.Regexp {
+	flags := leadingFlags.FindString(q.text)
+	if len(flags) == len(q.text) {
+		return nil
+	}
+
+	expr := regexp.QuoteMeta(q.text[len(flags):])
+
+	if q.wholeWord {
+		expr = `\b` + expr + `\b`
+	}
+
+	if q.ignoreCase {
+		flags = "(?i)" + flags
+	}
+
+	return regexp.MustCompile(flags + expr)
+}
+
+// label returns the prompt of the search, with a badge for every option on,
+// [I] ignoring case and [W] matching whole words.
+func (q searchQuery) label() string {
+	var badges string
+
+	if q.ignoreCase {
+		badges += "[I]"
+	}
+
+	if q.wholeWord {
+		badges += "[W]"
+	}
+
+	if badges == "" {
+		return "/"
+	}
+
+	return tview.Escape(badges) + " /"
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQueryPattern(t *testing.T) {
+	tests := []struct {
+		name  string
+		query searchQuery
+		text  string
+		want  []string
+	}{
+		{name: "empty", query: searchQuery{}, text: "pod-a Running", want: nil},
+		{name: "literal", query: searchQuery{text: "a.b"}, text: "a.b axb", want: []string{"a.b"}},
+		{name: "case", query: searchQuery{text: "run"}, text: "Running run", want: []string{"run"}},
+		{name: "ignore case", query: searchQuery{text: "run", ignoreCase: true}, text: "Running run", want: []string{"Run", "run"}},
+		{name: "inline flags", query: searchQuery{text: "(?i)run"}, text: "Running run", want: []string{"Run", "run"}},
+		{name: "only flags", query: searchQuery{text: "(?i)"}, text: "Running", want: nil},
+		{name: "whole word", query: searchQuery{text: "run", wholeWord: true}, text: "Running run rerun", want: []string{"run"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			re := tt.query.pattern()
+			if tt.want == nil {
+				assert.Nil(t, re)
+
+				return
+			}
+
+			assert.Equal(t, tt.want, re.FindAllString(tt.text, -1))
+		})
+	}
+}
+
+func TestSearchQueryLabel(t *testing.T) {
+	assert.Equal(t, "/", searchQuery{}.label())
+	assert.Equal(t, "[I[] /", searchQuery{ignoreCase: true}.label())
+	assert.Equal(t, "[I[][W[] /", searchQuery{ignoreCase: true, wholeWord: true}.label())
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sessionVersion is the version of the session file format.
+	sessionVersion = 1
+
+	// sessionSaveInterval is how often the session is saved while new runs come in.
+	sessionSaveInterval = 30 * time.Second
+)
+
+// session is the history and settings of viddy, saved with --session so
+// that they survive a restart.
+type session struct {
+	Version   int               `json:"version"`
+	Command   []string          `json:"command"`
+	Interval  string            `json:"interval"`
+	Begin     time.Time         `json:"begin"`
+	Settings  sessionSettings   `json:"settings"`
+	Snapshots []sessionSnapshot `json:"snapshots"`
+}
+
+type sessionSettings struct {
+	Differences   bool    `json:"differences"`
+	PermanentDiff bool    `json:"permanent_diff"`
+	ChangesOnly   bool    `json:"changes_only"`
+	SideBySide    bool    `json:"side_by_side,omitempty"`
+	NoWrap        bool    `json:"no_wrap,omitempty"`
+	NoTitle       bool    `json:"no_title"`
+	Bookmarks     []int64 `json:"bookmarks,omitempty"`
+}
+
+type sessionSnapshot struct {
+	ID          int64     `json:"id"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Result      []byte    `json:"result,omitempty"`
+	ErrorResult []byte    `json:"error_result,omitempty"`
+	StderrLines []int     `json:"stderr_lines,omitempty"`
+	ExitCode    int       `json:"exit_code,omitempty"`
+	Err         string    `json:"error,omitempty"`
+	Skipped     bool      `json:"skipped,omitempty"`
+	// Command is the command the snapshot ran, if it was edited from the
+	// one of the session.
+	Command []string `json:"command,omitempty"`
+}
+
+type sessionError struct {
+	path string
+	err  error
+}
+
+func (e sessionError) Error() string {
+	return fmt.Sprintf("cannot use the session file %q: %v", e.path, e.err)
+}
+
+func (e sessionError) Unwrap() error {
+	return e.err
+}
+
+type sessionMismatchError struct {
+	path    string
+	saved   []string
+	current []string
+}
+
+func (e sessionMismatchError) Error() string {
+	return fmt.Sprintf("the session file %q was saved for %q, not %q, pass --session-force to load it anyway",
+		e.path, strings.Join(e.saved, " "), strings.Join(e.current, " "))
+}
+
+// loadSession reads the session file, which may not exist yet. The session
+// must have been saved for the same command unless force.
+func loadSession(path string, command []string, force bool) (*session, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, sessionError{path: path, err: err}
+	}
+
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, sessionError{path: path, err: fmt.Errorf("corrupt file: %w", err)}
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, sessionError{path: path, err: err}
+	}
+
+	if !force && strings.Join(s.Command, "\x00") != strings.Join(command, "\x00") {
+		return nil, sessionMismatchError{path: path, saved: s.Command, current: command}
+	}
+
+	return &s, nil
+}
+
+// validate detects files which are not sessions or were cut short.
+func (s *session) validate() error {
+	if s.Version != sessionVersion {
+		return fmt.Errorf("unsupported version %d", s.Version)
+	}
+
+	if len(s.Command) == 0 || s.Begin.IsZero() {
+		return errors.New("corrupt file: missing command or begin")
+	}
+
+	last := int64(-1)
+
+	for _, snap := range s.Snapshots {
+		if snap.ID <= last {
+			return fmt.Errorf("corrupt file: snapshot %d is out of order", snap.ID)
+		}
+
+		last = snap.ID
+	}
+
+	return nil
+}
+
+// restore returns the snapshots of the session, each linked to the one before.
+func (s *session) restore() []*Snapshot {
+	snapshots := make([]*Snapshot, 0, len(s.Snapshots))
+
+	var before *Snapshot
+
+	for _, saved := range s.Snapshots {
+		snap := &Snapshot{
+			id:          saved.ID,
+			command:     s.Command[0],
+			args:        s.Command[1:],
+			result:      saved.Result,
+			errorResult: saved.ErrorResult,
+			stderrLines: saved.StderrLines,
+			start:       saved.Start,
+			end:         saved.End,
+			exitCode:    saved.ExitCode,
+			completed:   true,
+			skipped:     saved.Skipped,
+			restored:    true,
+			before:      before,
+		}
+
+		if saved.Err != "" {
+			snap.err = errors.New(saved.Err)
+		}
+
+		if len(saved.Command) > 0 {
+			snap.command, snap.args = saved.Command[0], saved.Command[1:]
+		}
+
+		snapshots = append(snapshots, snap)
+		before = snap
+	}
+
+	return snapshots
+}
+
+// sessionWriter collects the finished snapshots and writes them to the
+// session file.
+type sessionWriter struct {
+	sync.Mutex
+
+	path     string
+	command  []string
+	interval time.Duration
+	begin    time.Time
+
+	snapshots []*Snapshot
+	dirty     bool
+}
+
+// add records a finished snapshot. It must only be called once the
+// snapshot is done with.
+func (w *sessionWriter) add(s *Snapshot) {
+	if w == nil {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	w.snapshots = append(w.snapshots, s)
+	w.dirty = true
+}
+
+func (w *sessionWriter) isDirty() bool {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.dirty
+}
+
+// save replaces the session file, so that it is either the old or the new
+// session if viddy is stopped meanwhile.
+func (w *sessionWriter) save(settings sessionSettings) error {
+	w.Lock()
+	defer w.Unlock()
+
+	snapshots := make([]*Snapshot, len(w.snapshots))
+	copy(snapshots, w.snapshots)
+
+	// Runs may finish out of order.
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].id < snapshots[j].id })
+
+	s := session{
+		Version:   sessionVersion,
+		Command:   w.command,
+		Interval:  w.interval.String(),
+		Begin:     w.begin,
+		Settings:  settings,
+		Snapshots: make([]sessionSnapshot, 0, len(snapshots)),
+	}
+
+	for _, snap := range snapshots {
+		o := snap.output()
+		saved := sessionSnapshot{
+			ID:          snap.id,
+			Start:       snap.start,
+			End:         snap.end,
+			Result:      o.result,
+			ErrorResult: o.errorResult,
+			StderrLines: snap.stderrLines,
+			ExitCode:    snap.exitCode,
+			Skipped:     snap.skipped,
+		}
+
+		if snap.err != nil {
+			saved.Err = snap.err.Error()
+		}
+
+		if command := append([]string{snap.command}, snap.args...); snap.commandLine() != strings.Join(w.command, " ") {
+			saved.Command = command
+		}
+
+		s.Snapshots = append(s.Snapshots, saved)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return sessionError{path: w.path, err: err}
+	}
+
+	if err := writeFileAtomic(w.path, data); err != nil {
+		return sessionError{path: w.path, err: err}
+	}
+
+	w.dirty = false
+
+	return nil
+}
+
+// writeFileAtomic writes the file next to the path first and then moves it
+// into place.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	begin := time.Date(2024, 3, 1, 14, 35, 0, 0, time.UTC)
+
+	w := &sessionWriter{path: path, command: []string{"ls", "-l"}, interval: 2 * time.Second, begin: begin}
+
+	first := &Snapshot{
+		id: 0, command: "ls", args: []string{"-l"}, result: []byte("a\n"), start: begin, end: begin.Add(time.Second), completed: true,
+	}
+	second := &Snapshot{
+		id: 2000, command: "ls", args: []string{"-l"}, start: begin.Add(2 * time.Second), completed: true, skipped: true,
+	}
+	third := &Snapshot{
+		id:          4000,
+		command:     "ls",
+		args:        []string{"-l"},
+		result:      []byte("b\n"),
+		errorResult: []byte("oops\n"),
+		exitCode:    2,
+		err:         errors.New("exit status 2"),
+		start:       begin.Add(4 * time.Second),
+		completed:   true,
+	}
+
+	// Runs may finish out of order.
+	w.add(first)
+	w.add(third)
+	w.add(second)
+	assert.True(t, w.isDirty())
+
+	settings := sessionSettings{Differences: true, Bookmarks: []int64{2000}}
+	assert.NoError(t, w.save(settings))
+	assert.False(t, w.isDirty())
+
+	s, err := loadSession(path, []string{"ls", "-l"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, settings, s.Settings)
+	assert.Equal(t, "2s", s.Interval)
+	assert.True(t, begin.Equal(s.Begin))
+
+	restored := s.restore()
+	assert.Len(t, restored, 3)
+	assert.Equal(t, []int64{0, 2000, 4000}, []int64{restored[0].id, restored[1].id, restored[2].id})
+	assert.Nil(t, restored[0].before)
+	assert.Equal(t, restored[1], restored[2].before)
+	assert.Equal(t, "a\n", string(restored[0].result))
+	assert.True(t, restored[1].skipped)
+	assert.Equal(t, 2, restored[2].exitCode)
+	assert.Equal(t, "oops\n", string(restored[2].errorResult))
+	assert.EqualError(t, restored[2].err, "exit status 2")
+	assert.Equal(t, "ls", restored[2].command)
+	assert.Equal(t, []string{"-l"}, restored[2].args)
+	assert.Equal(t, "ls -l", restored[2].commandLine())
+
+	for _, snap := range restored {
+		assert.True(t, snap.completed)
+		assert.True(t, snap.restored)
+	}
+
+	assert.NoError(t, restored[2].compareFromBefore())
+	assert.Equal(t, restored[0], restored[2].diffBase)
+}
+
+func TestSessionSaveEditedCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	begin := time.Date(2024, 3, 1, 14, 35, 0, 0, time.UTC)
+
+	w := &sessionWriter{path: path, command: []string{"ls", "-l"}, begin: begin}
+	w.add(&Snapshot{id: 0, command: "ls", args: []string{"-l"}, start: begin, completed: true})
+	w.add(&Snapshot{id: 2000, command: "ls -la", start: begin.Add(2 * time.Second), completed: true})
+	assert.NoError(t, w.save(sessionSettings{}))
+
+	s, err := loadSession(path, []string{"ls", "-l"}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, s.Snapshots[0].Command)
+	assert.Equal(t, []string{"ls -la"}, s.Snapshots[1].Command)
+
+	restored := s.restore()
+	assert.Equal(t, "ls -l", restored[0].commandLine())
+	assert.Equal(t, "ls -la", restored[1].commandLine())
+}
+
+func TestLoadSession(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := loadSession(filepath.Join(dir, "missing.json"), []string{"ls"}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	w := &sessionWriter{path: filepath.Join(dir, "session.json"), command: []string{"ls"}, begin: time.Now()}
+	assert.NoError(t, w.save(sessionSettings{}))
+
+	_, err = loadSession(w.path, []string{"df", "-h"}, false)
+	assert.Equal(t, sessionMismatchError{path: w.path, saved: []string{"ls"}, current: []string{"df", "-h"}}, err)
+
+	s, err = loadSession(w.path, []string{"df", "-h"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ls"}, s.Command)
+
+	data, err := os.ReadFile(w.path)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "partial", data: string(data[:len(data)/2])},
+		{name: "empty", data: ""},
+		{name: "not a session", data: `{"foo": 1}`},
+		{name: "newer version", data: `{"version": 2, "command": ["ls"], "begin": "2024-03-01T14:35:00Z"}`},
+		{
+			name: "out of order",
+			data: `{"version": 1, "command": ["ls"], "begin": "2024-03-01T14:35:00Z", "snapshots": [{"id": 2}, {"id": 1}]}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "corrupt.json")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.data), 0o600))
+
+			s, err := loadSession(path, []string{"ls"}, false)
+			assert.Nil(t, s)
+			assert.IsType(t, sessionError{}, err)
+		})
+	}
+}
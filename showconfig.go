@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// formatConfig renders the effective configuration as a config file. Keys
+// which fell back to their defaults are annotated with the reason.
+func formatConfig(conf *config) string {
+	var b strings.Builder
+
+	b.WriteString("# Effective configuration of viddy")
+
+	if conf.runtime.configFile != "" {
+		fmt.Fprintf(&b, ", read from %s", conf.runtime.configFile)
+	}
+
+	b.WriteString(".\n#\n# Flags and profiles set these rather than the config file:\n")
+	if conf.runtime.steps != nil {
+		fmt.Fprintf(&b, "#   interval = %s\n", tomlValue(formatIntervalSteps(conf.runtime.steps)))
+	} else {
+		fmt.Fprintf(&b, "#   interval = %s\n", tomlValue(conf.runtime.interval))
+	}
+	fmt.Fprintf(&b, "#   jitter = %s\n", tomlValue(conf.runtime.jitter))
+	fmt.Fprintf(&b, "#   mode = %s\n", tomlValue(string(conf.runtime.mode)))
+
+	if conf.runtime.schedule != nil {
+		fmt.Fprintf(&b, "#   schedule = %s\n", tomlValue(conf.runtime.schedule.expr))
+	}
+
+	if rule := describeRule(conf.runtime.rules, conf.runtime.rule); rule != "" {
+		fmt.Fprintf(&b, "#\n# Rules: %s.\n", rule)
+	}
+
+	if len(conf.warnings) > 0 {
+		b.WriteString("#\n# Warnings:\n")
+
+		for _, w := range conf.warnings {
+			fmt.Fprintf(&b, "#   %s\n", oneLine(w))
+		}
+	}
+
+	b.WriteString("\n[general]\n")
+
+	general := generalValues(conf.general)
+	for _, key := range generalKeys {
+		writeConfigKey(&b, conf, "general", key, general[key])
+	}
+
+	b.WriteString("\n[color]\n")
+
+	colors := colorValues(conf.theme)
+	for _, key := range colorKeys {
+		value, ok := colors[key]
+		if !ok {
+			continue
+		}
+
+		writeConfigKey(&b, conf, "color", key, value)
+	}
+
+	b.WriteString("\n[keymap]\n")
+
+	for _, binding := range append(conf.keymap.generalBindings(), conf.keymap.timeMachineBindings()...) {
+		writeConfigKey(&b, conf, "keymap", strings.TrimPrefix(binding.name, "keymap."), keymapValue(binding.keys))
+	}
+
+	return b.String()
+}
+
+func writeConfigKey(b *strings.Builder, conf *config, section, key string, value interface{}) {
+	fmt.Fprintf(b, "%s = %s", key, tomlValue(value))
+
+	if reason, ok := conf.fallbacks[section+"."+key]; ok {
+		fmt.Fprintf(b, " # the default, the config file has an error: %s", oneLine(reason))
+	}
+
+	b.WriteString("\n")
+}
+
+// generalValues returns the value of every key of the general section, as
+// the config file would set it.
+func generalValues(g general) map[string]interface{} {
+	var differences interface{} = g.differences
+	if g.permanentDiff {
+		differences = "permanent"
+	}
+
+	env := make([]string, 0, len(g.env))
+
+	for _, ev := range g.env {
+		if ev.unset {
+			env = append(env, ev.key)
+		} else {
+			env = append(env, ev.key+"="+ev.value)
+		}
+	}
+
+	statusItems := make([]string, 0, len(g.statusItems))
+	for _, item := range g.statusItems {
+		statusItems = append(statusItems, string(item))
+	}
+
+	highlights := make([]string, 0, len(g.highlights))
+	for _, h := range g.highlights {
+		highlights = append(highlights, h.spec)
+	}
+
+	return map[string]interface{}{
+		"adaptive_max":         g.adaptiveMax,
+		"adaptive_steady_runs": g.adaptiveSteadyRuns,
+		"after_each":           g.afterEach,
+		"backoff":              g.backoff,
+		"backoff_max":          g.backoffMax,
+		"before_each":          g.beforeEach,
+		"changes_context":      g.changesContext,
+		"changes_only":         g.changesOnly,
+		"compress_after":       g.compressAfter,
+		"confirm_destructive":  g.confirmDestructive,
+		"debug":                g.debug,
+		"diff_granularity":     string(g.diffGranularity),
+		"diff_normalize":       g.diffNormalize.names(),
+		"differences":          differences,
+		"differences_against":  g.differencesAgainst.String(),
+		"env":                  env,
+		"flash_duration":       g.flashDuration,
+		"flash_on_change":      g.flashOnChange,
+		"force_truecolor":      g.forceTruecolor,
+		"highlight":            highlights,
+		"history_file":         g.historyFile,
+		"keep_for":             g.keepFor,
+		"kill_timeout":         g.killTimeout,
+		"log":                  g.log,
+		"log_file":             g.logFile,
+		"log_level":            g.logLevel.String(),
+		"log_max_size":         g.logMaxSize,
+		"max_bytes":            g.maxBytes,
+		"max_concurrent_runs":  g.maxConcurrentRuns,
+		"max_lines":            g.maxLines,
+		"mouse":                g.mouse,
+		"no_title":             g.noTitle,
+		"no_wrap":              g.noWrap,
+		"notify":               string(g.notify),
+		"notify_cooldown":      g.notifyCooldown,
+		"on_change":            g.onChange,
+		"overlap_policy":       string(g.overlapPolicy),
+		"pipe":                 g.pipe,
+		"playback_speed":       g.playbackSpeed.String(),
+		"poll_in_timemachine":  g.pollInTimeMachine,
+		"poll_while_suspended": g.pollWhileSuspended,
+		"pty":                  g.pty,
+		"scroll_off":           g.scrollOff,
+		"scrub_acceleration":   g.scrubAcceleration,
+		"search_ignore_case":   g.searchIgnoreCase,
+		"session_file":         g.sessionFile,
+		"shell":                g.shell,
+		"shell_options":        joinShellWords(g.shellOptions),
+		"show_host":            g.showHost,
+		"show_line_age":        g.showLineAge,
+		"side_by_side":         g.sideBySide,
+		"show_change_log":      g.showChangeLog,
+		"show_snapshot_list":   g.showSnapshotList,
+		"show_timeline":        g.showTimeline,
+		"split":                string(g.split),
+		"status_items":         statusItems,
+		"stderr":               string(g.stderr),
+		"sticky_scroll":        g.stickyScroll,
+		"strip_ansi":           g.stripANSI,
+		"ssh":                  g.ssh,
+		"strict_config":        g.strictConfig,
+		"stream_grace":         g.streamGrace,
+		"stream_policy":        string(g.streamPolicy),
+		"tab_width":            g.tabWidth,
+		"time_format":          g.timeFormat,
+		"time_zone":            g.timeZone,
+		"timemachine_step":     g.timeMachineStep,
+		"title_template":       g.titleTemplateText,
+	}
+}
+
+// colorValues returns the name of every color of the color section. The
+// preset is left out, since its colors are part of the others.
+func colorValues(t theme) map[string]interface{} {
+	colors := map[string]tcell.Color{
+		"background":               t.PrimitiveBackgroundColor,
+		"border":                   t.BorderColor,
+		"clip_marker":              t.clipMarker,
+		"contrast_background":      t.ContrastBackgroundColor,
+		"contrast_secondary_text":  t.ContrastSecondaryTextColor,
+		"diff_added":               t.diffAdded,
+		"diff_changed_background":  t.diffChangedBackground,
+		"diff_changed_foreground":  t.diffChangedForeground,
+		"diff_moved":               t.diffMoved,
+		"diff_removed":             t.diffRemoved,
+		"flash":                    t.flash,
+		"graphics":                 t.GraphicsColor,
+		"inverse_text":             t.InverseTextColor,
+		"more_contrast_background": t.MoreContrastBackgroundColor,
+		"secondary_text":           t.SecondaryTextColor,
+		"stderr_text":              t.stderrText,
+		"error_text":               t.errorText,
+		"tertiary_text":            t.TertiaryTextColor,
+		"text":                     t.PrimaryTextColor,
+		"title":                    t.TitleColor,
+	}
+
+	values := make(map[string]interface{}, len(colors))
+	for key, c := range colors {
+		values[key] = colorName(c)
+	}
+
+	return values
+}
+
+// colorName returns a name parseColor reads back as the color.
+func colorName(c tcell.Color) string {
+	if c == tcell.ColorDefault {
+		return "default"
+	}
+
+	names := make([]string, 0, 1)
+
+	for name, named := range tcell.ColorNames {
+		if named == c {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) > 0 {
+		sort.Strings(names)
+
+		return names[0]
+	}
+
+	return fmt.Sprintf("#%06x", c.Hex())
+}
+
+// keymapValue returns the keys in the syntax of the config file, "none" if
+// the action is unbound.
+func keymapValue(keys map[KeySequence]struct{}) interface{} {
+	names := make([]string, 0, len(keys))
+	for seq := range keys {
+		names = append(names, formatKeySequence(seq))
+	}
+
+	sort.Strings(names)
+
+	switch len(names) {
+	case 0:
+		return "none"
+	case 1:
+		return names[0]
+	default:
+		return names
+	}
+}
+
+// joinShellWords is the reverse of splitShellWords.
+func joinShellWords(words []string) string {
+	quoted := make([]string, 0, len(words))
+
+	for _, w := range words {
+		if w == "" || strings.ContainsAny(w, " \t\n'\"\\$`") {
+			w = shellQuote(w)
+		}
+
+		quoted = append(quoted, w)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// tomlValue renders the value as TOML.
+func tomlValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return tomlString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Duration:
+		return tomlString(v.String())
+	case []string:
+		items := make([]string, 0, len(v))
+		for _, s := range v {
+			items = append(items, tomlString(s))
+		}
+
+		return "[" + strings.Join(items, ", ") + "]"
+	case []int:
+		items := make([]string, 0, len(v))
+		for _, n := range v {
+			items = append(items, strconv.Itoa(n))
+		}
+
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return tomlString(fmt.Sprint(v))
+	}
+}
+
+// tomlString quotes the string as a TOML basic string, which unlike Go has
+// no \x escapes.
+func tomlString(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// oneLine keeps a message on one line, for a comment.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatConfig(t *testing.T) {
+	configFile := `
+[general]
+shell = "bash"
+shell_options = "-O 'ext glob' -c"
+differences = "permanent"
+env = { kubeconfig = "/tmp/kc" }
+timemachine_step = "30s"
+
+[color]
+text = "#fafafa"
+diff_added = "gren"
+
+[keymap]
+quit = "q"
+scroll_up = ["Ctrl-P", "k"]
+toggle_help = "none"
+timemachine_go_to_past = "Shfit-J"
+`
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(bytes.NewBufferString(configFile)))
+
+	conf, err := newConfig(v, []string{"--show-config", "-n", "5"})
+	require.NoError(t, err)
+
+	out := formatConfig(conf)
+	assert.Contains(t, out, "#   interval = \"5s\"\n")
+	assert.Contains(t, out, "shell_options = \"-O 'ext glob' -c\"\n")
+	assert.Contains(t, out, "env = [\"KUBECONFIG=/tmp/kc\"]\n")
+	assert.Contains(t, out, "text = \"#fafafa\"\n")
+	assert.Contains(t, out, "diff_added = \"green\" # the default, the config file has an error: unknown color \"gren\"\n")
+	assert.Contains(t, out, "scroll_up = [\"Ctrl-P\", \"k\"]\n")
+	assert.Contains(t, out, "toggle_help = \"none\"\n")
+	assert.Contains(t, out,
+		"timemachine_go_to_past = \"Shift-J\" # the default, the config file has an error: cannot parse key \"Shfit-J\"")
+
+	for _, key := range generalKeys {
+		assert.Contains(t, out, "\n"+key+" = ")
+	}
+
+	// Read back, the output gives the same configuration.
+	v = viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(bytes.NewBufferString(out)))
+	assert.Empty(t, findUnknownConfigKeys(v))
+
+	again, err := newConfig(v, []string{"ls"})
+	require.NoError(t, err)
+	assert.Equal(t, conf.general, again.general)
+	assert.Equal(t, conf.theme, again.theme)
+	assert.Equal(t, conf.keymap, again.keymap)
+}
+
+func TestTomlValue(t *testing.T) {
+	assert.Equal(t, `"a \"b\" \\ \n\u001B"`, tomlValue("a \"b\" \\ \n\x1b"))
+	assert.Equal(t, `["a", "b"]`, tomlValue([]string{"a", "b"}))
+	assert.Equal(t, "[1, 2, 5]", tomlValue([]int{1, 2, 5}))
+	assert.Equal(t, "true", tomlValue(true))
+	assert.Equal(t, "42", tomlValue(int64(42)))
+}
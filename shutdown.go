@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// reapTimeout is how long killed commands get to be waited for.
+	reapTimeout = time.Second
+	// runPollInterval is how often stopping runs are checked on.
+	runPollInterval = 20 * time.Millisecond
+)
+
+// unfinishedRuns returns the snapshots whose runs did not finish, including
+// those waiting for their turn.
+func (v *Viddy) unfinishedRuns() []*Snapshot {
+	var runs []*Snapshot
+
+	v.snapshots.Range(func(_, value interface{}) bool {
+		if s := value.(*Snapshot); !s.isCompleted() {
+			runs = append(runs, s)
+		}
+
+		return true
+	})
+
+	return runs
+}
+
+// waitRuns waits until the runs finish, at most for timeout. It returns
+// whether they all did.
+func waitRuns(runs []*Snapshot, timeout time.Duration, hurry <-chan struct{}) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(runPollInterval)
+	defer ticker.Stop()
+
+	for {
+		done := true
+		for _, s := range runs {
+			done = done && s.isCompleted()
+		}
+
+		if done {
+			return true
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return false
+		case <-hurry:
+			return false
+		}
+	}
+}
+
+// stopRuns sends SIGTERM to the process groups of the runs, and SIGKILL to
+// those still running after timeout or once hurry is closed. It returns once
+// they finished, or gave up on that.
+func stopRuns(runs []*Snapshot, timeout time.Duration, hurry <-chan struct{}) {
+	for _, s := range runs {
+		s.terminate()
+	}
+
+	if waitRuns(runs, timeout, hurry) {
+		return
+	}
+
+	for _, s := range runs {
+		if !s.isCompleted() {
+			s.kill()
+		}
+	}
+
+	waitRuns(runs, reapTimeout, nil)
+}
+
+// quit stops the app once the commands which are still running stopped, so
+// that none of them outlives viddy. Quitting again kills them at once.
+func (g *paneGroup) quit() {
+	if g.hurry != nil {
+		g.hurryOnce.Do(func() { close(g.hurry) })
+
+		return
+	}
+
+	g.hurry = make(chan struct{})
+
+	var runs []*Snapshot
+
+	for _, v := range g.panes {
+		atomic.StoreInt32(&v.quitting, 1)
+		v.holdRuns()
+		runs = append(runs, v.unfinishedRuns()...)
+	}
+
+	if len(runs) == 0 {
+		g.app.Stop()
+
+		return
+	}
+
+	focused := g.focusedPane()
+	timeout := focused.killTimeout
+
+	focused.log(levelInfo, "stopping runs", "count", len(runs), "timeout", timeout)
+	focused.setMessage(fmt.Sprintf("Waiting up to %s for the commands to stop, quit again to kill them", timeout))
+
+	go func() {
+		stopRuns(runs, timeout, g.hurry)
+
+		g.app.QueueUpdate(g.app.Stop)
+	}()
+}
+
+// handleSignals quits on SIGINT, SIGTERM and SIGHUP the same way as the quit
+// key, so that the terminal is restored. SIGINT goes to the shell while
+// suspended to it. It returns a function which stops handling them.
+func (g *paneGroup) handleSignals() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				g.app.QueueUpdateDraw(func() {
+					if sig == os.Interrupt && g.screen.suspended {
+						return
+					}
+
+					g.focusedPane().log(levelInfo, "signal received", "signal", sig)
+					g.quit()
+				})
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
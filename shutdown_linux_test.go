@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// processGroup returns the processes of the group which are still alive,
+// leaving out zombies.
+func processGroup(t *testing.T, pgid int) []int {
+	t.Helper()
+
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	assert.NoError(t, err)
+
+	var pids []int
+
+	for _, stat := range stats {
+		b, err := os.ReadFile(stat)
+		if err != nil {
+			// The process is gone.
+			continue
+		}
+
+		// The name of the command is in parentheses and may contain spaces.
+		fields := strings.Fields(string(b[strings.LastIndexByte(string(b), ')')+1:]))
+		if len(fields) < 3 || fields[0] == "Z" || fields[2] != strconv.Itoa(pgid) {
+			continue
+		}
+
+		pid, _ := strconv.Atoi(filepath.Base(filepath.Dir(stat)))
+		pids = append(pids, pid)
+	}
+
+	return pids
+}
+
+// startRun runs the command in the background and returns once it started.
+func startRun(t *testing.T, command string) (*Snapshot, int) {
+	t.Helper()
+
+	s := NewSnapshot(0, command, nil, runOptions{shell: "sh"}, nil, make(chan struct{}))
+
+	go func() { _ = s.run(make(chan int64, 1)) }()
+
+	for i := 0; i < 100; i++ {
+		s.Lock()
+		started := s.process != nil && s.process.Process != nil
+		s.Unlock()
+
+		if started {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Lock()
+	pid := s.process.Process.Pid
+	s.Unlock()
+
+	// Gives the shell the time to spawn its children.
+	time.Sleep(100 * time.Millisecond)
+
+	return s, pid
+}
+
+func TestStopRunsLeavesNoProcesses(t *testing.T) {
+	s, pgid := startRun(t, "sleep 30 & sleep 30 & wait")
+	assert.Len(t, processGroup(t, pgid), 3)
+
+	begin := time.Now()
+	stopRuns([]*Snapshot{s}, 5*time.Second, nil)
+
+	assert.True(t, s.completed)
+	assert.Less(t, time.Since(begin), 5*time.Second)
+	assert.Empty(t, processGroup(t, pgid))
+}
+
+func TestStopRunsKillsAfterTimeout(t *testing.T) {
+	s, pgid := startRun(t, `trap "" TERM; sleep 30 & sleep 30 & wait`)
+
+	begin := time.Now()
+	stopRuns([]*Snapshot{s}, 300*time.Millisecond, nil)
+
+	assert.True(t, s.completed)
+	assert.GreaterOrEqual(t, time.Since(begin), 300*time.Millisecond)
+	assert.Empty(t, processGroup(t, pgid))
+}
+
+func TestStopRunsHurried(t *testing.T) {
+	s, pgid := startRun(t, `trap "" TERM; sleep 30 & wait`)
+
+	hurry := make(chan struct{})
+	close(hurry)
+
+	begin := time.Now()
+	stopRuns([]*Snapshot{s}, 5*time.Second, hurry)
+
+	assert.True(t, s.completed)
+	assert.Less(t, time.Since(begin), 5*time.Second)
+	assert.Empty(t, processGroup(t, pgid))
+}
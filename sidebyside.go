@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// sideBySideMinWidth is the width below which the previous snapshot is shown
+// above the current one instead of beside it.
+const sideBySideMinWidth = 100
+
+// sideBySideRow pairs a line of the previous output with a line of the
+// current output. Either is -1 where the other side has no counterpart.
+type sideBySideRow struct {
+	before  int
+	after   int
+	changed bool
+}
+
+// alignLines pairs the lines of the outputs, so that unchanged lines are on
+// the same row. Removed lines are paired with the lines added in their place.
+func alignLines(before, after string) []sideBySideRow {
+	var (
+		rows           []sideBySideRow
+		b, a           int
+		removed, added int
+	)
+
+	flush := func() {
+		for i := 0; i < removed || i < added; i++ {
+			row := sideBySideRow{before: -1, after: -1, changed: true}
+
+			if i < removed {
+				row.before = b
+				b++
+			}
+
+			if i < added {
+				row.after = a
+				a++
+			}
+
+			rows = append(rows, row)
+		}
+
+		removed, added = 0, 0
+	}
+
+	for _, diff := range diffLines(before, after) {
+		n := len(splitLines(diff.Text))
+
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			flush()
+
+			for i := 0; i < n; i++ {
+				rows = append(rows, sideBySideRow{before: b, after: a})
+				b++
+				a++
+			}
+		case diffmatchpatch.DiffDelete:
+			removed += n
+		case diffmatchpatch.DiffInsert:
+			added += n
+		}
+	}
+
+	flush()
+
+	return rows
+}
+
+// renderSideBySide renders the previous and the current snapshot in aligned
+// rows with the changed lines highlighted. origins is the line of the current
+// output on every row, or the next line where it has none. Without a
+// previous snapshot nothing is highlighted.
+func renderSideBySide(previous, current *Snapshot, query *regexp.Regexp, t theme) (left, right string, origins []int, err error) {
+	var rows []sideBySideRow
+
+	var previousLines, previousRaw []string
+
+	if previous != nil {
+		var b bytes.Buffer
+		if err := previous.render(&b, false, false, query, t); err != nil {
+			return "", "", nil, err
+		}
+
+		previousLines = strings.Split(b.String(), "\n")
+		previousRaw = strings.Split(previous.text(), "\n")
+		rows = alignLines(previous.text(), current.text())
+	} else {
+		for i := range splitLines(current.text()) {
+			rows = append(rows, sideBySideRow{before: -1, after: i})
+		}
+	}
+
+	var b bytes.Buffer
+	if err := current.render(&b, false, false, query, t); err != nil {
+		return "", "", nil, err
+	}
+
+	currentLines := strings.Split(b.String(), "\n")
+	currentRaw := strings.Split(current.text(), "\n")
+
+	removed := t.diffRemoved
+	if removed == tcell.ColorDefault {
+		removed = t.diffChangedBackground
+	}
+
+	leftRows := make([]string, 0, len(rows))
+	rightRows := make([]string, 0, len(rows))
+	next := 0
+
+	for _, row := range rows {
+		switch {
+		case row.before < 0:
+			leftRows = append(leftRows, "")
+		case row.changed:
+			leftRows = append(leftRows, highlightLine(lineAt(previousRaw, row.before), t.diffChangedForeground, removed))
+		default:
+			leftRows = append(leftRows, lineAt(previousLines, row.before))
+		}
+
+		switch {
+		case row.after < 0:
+			rightRows = append(rightRows, "")
+		case row.changed && row.before < 0:
+			rightRows = append(rightRows, highlightLine(lineAt(currentRaw, row.after), tcell.ColorDefault, t.diffAdded))
+		case row.changed:
+			rightRows = append(rightRows, highlightLine(lineAt(currentRaw, row.after), t.diffChangedForeground, t.diffChangedBackground))
+		default:
+			rightRows = append(rightRows, lineAt(currentLines, row.after))
+		}
+
+		if row.after >= 0 {
+			next = row.after + 1
+			origins = append(origins, row.after)
+		} else {
+			origins = append(origins, next)
+		}
+	}
+
+	return strings.Join(leftRows, "\n"), strings.Join(rightRows, "\n"), origins, nil
+}
+
+// lineAt returns the line, or "" where the rendered output is shorter, such
+// as when the error output is shown instead.
+func lineAt(lines []string, i int) string {
+	if i >= len(lines) {
+		return ""
+	}
+
+	return lines[i]
+}
+
+// highlightLine shows the line as plain text in the colors.
+func highlightLine(raw string, fg, bg tcell.Color) string {
+	plain := tview.Escape(ansiEscape.ReplaceAllString(strings.TrimSuffix(raw, "\r"), ""))
+
+	tags := colorTags(colorTag(fg), colorTag(bg))
+	if tags == "" {
+		return plain
+	}
+
+	return tags + plain + "[-:-:-]"
+}
+
+// splitView shows the previous snapshot beside the current one, or above it
+// when narrow, scrolled along with the current one.
+type splitView struct {
+	*tview.Flex
+
+	previous *reflowView
+	current  *reflowView
+}
+
+func newSplitView(previous, current *reflowView) *splitView {
+	separator := tview.NewBox()
+	separator.SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+		style := tcell.StyleDefault.
+			Foreground(tview.Styles.BorderColor).
+			Background(tview.Styles.PrimitiveBackgroundColor)
+
+		line := tview.Borders.Vertical
+		if height == 1 {
+			line = tview.Borders.Horizontal
+		}
+
+		for i := 0; i < width; i++ {
+			for j := 0; j < height; j++ {
+				screen.SetContent(x+i, y+j, line, nil, style)
+			}
+		}
+
+		return x, y, width, height
+	})
+
+	s := &splitView{Flex: tview.NewFlex(), previous: previous, current: current}
+	s.AddItem(previous, 0, 1, false).
+		AddItem(separator, 1, 0, false).
+		AddItem(current, 0, 1, false)
+
+	return s
+}
+
+func (s *splitView) Draw(screen tcell.Screen) {
+	if _, _, width, _ := s.GetRect(); width < sideBySideMinWidth {
+		s.SetDirection(tview.FlexRow)
+	} else {
+		s.SetDirection(tview.FlexColumn)
+	}
+
+	s.Flex.Draw(screen)
+
+	// The current side may have moved while it was drawn, e.g. to its end.
+	// The sides wrap apart, so they are kept at the same line.
+	line, column := s.current.scrollLine()
+	if l, c := s.previous.scrollLine(); l != line || c != column {
+		s.previous.scrollToLine(line, column)
+		s.previous.Draw(screen)
+	}
+}
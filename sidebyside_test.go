@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []sideBySideRow
+	}{
+		{
+			name:   "unchanged",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   []sideBySideRow{{before: 0, after: 0}, {before: 1, after: 1}},
+		},
+		{
+			name:   "modified line",
+			before: "a\nb\nc\n",
+			after:  "a\nB\nc\n",
+			want: []sideBySideRow{
+				{before: 0, after: 0},
+				{before: 1, after: 1, changed: true},
+				{before: 2, after: 2},
+			},
+		},
+		{
+			name:   "added and removed lines",
+			before: "a\nb\nc\n",
+			after:  "a\nc\nd\ne\n",
+			want: []sideBySideRow{
+				{before: 0, after: 0},
+				{before: 1, after: -1, changed: true},
+				{before: 2, after: 1},
+				{before: -1, after: 2, changed: true},
+				{before: -1, after: 3, changed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, alignLines(tt.before, tt.after))
+		})
+	}
+}
+
+func TestRenderSideBySide(t *testing.T) {
+	th := theme{diffAdded: tcell.ColorGreen, diffChangedBackground: tcell.ColorYellow}
+
+	previous := &Snapshot{result: []byte("NAME READY\nweb 1/1\ndb 1/1\n"), completed: true}
+	current := &Snapshot{result: []byte("NAME READY\nweb 0/1\ndb 1/1\ncache 1/1\n"), completed: true}
+
+	left, right, origins, err := renderSideBySide(previous, current, nil, th)
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME READY\n[:yellow]web 1/1[-:-:-]\ndb 1/1\n", left)
+	assert.Equal(t, "NAME READY\n[:yellow]web 0/1[-:-:-]\ndb 1/1\n[:green]cache 1/1[-:-:-]", right)
+	assert.Equal(t, []int{0, 1, 2, 3}, origins)
+
+	// Rows without a line of the current output point at the next one.
+	current = &Snapshot{result: []byte("NAME READY\ndb 1/1\n"), completed: true}
+
+	left, right, origins, err = renderSideBySide(previous, current, nil, th)
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME READY\n[:yellow]web 1/1[-:-:-]\ndb 1/1", left)
+	assert.Equal(t, "NAME READY\n\ndb 1/1", right)
+	assert.Equal(t, []int{0, 1, 1}, origins)
+
+	// The first snapshot has nothing to compare with.
+	left, right, origins, err = renderSideBySide(nil, current, nil, th)
+	assert.NoError(t, err)
+	assert.Equal(t, "\n", left)
+	assert.Equal(t, "NAME READY\ndb 1/1", right)
+	assert.Equal(t, []int{0, 1}, origins)
+}
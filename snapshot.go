@@ -6,56 +6,212 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"runtime"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 	"unicode"
 
-	"github.com/fatih/color"
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
 	"github.com/sergi/go-diff/diffmatchpatch"
+	"golang.org/x/crypto/ssh"
 )
 
-var dmp = diffmatchpatch.New()
+var (
+	dmp = diffmatchpatch.New()
+
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+)
 
 type Snapshot struct {
 	id int64
 
 	command string
 	args    []string
-
-	shell     string
-	shellOpts string
+	opts    runOptions
 
 	result []byte
 	start  time.Time
 	end    time.Time
+	// due is when the run was meant to start, zero if it does not keep to
+	// a schedule.
+	due time.Time
 
 	exitCode    int
+	signal      int
 	errorResult []byte
 
+	// stderrLines are the lines of the result which the command wrote to
+	// stderr, if it was interleaved.
+	stderrLines []int
+
+	// totalLines is how many lines the command wrote when a size limit cut
+	// its output short, 0 otherwise.
+	totalLines int
+
 	completed bool
+	skipped   bool
+	killed    bool
 	err       error
 
+	// startErr is why the command could not start at all, such as a shell
+	// or a connection which is not there.
+	startErr error
+
+	// hookFailed is true if the before-each hook failed, so the command did
+	// not run. hookErr is the failure of the after-each hook.
+	hookFailed bool
+	hookErr    error
+
+	// raw is the output as the command wrote it when the --pipe filter
+	// replaced it, nil otherwise. pipeErr is the failure of the filter.
+	raw     []byte
+	pipeErr error
+
+	// restored snapshots come from a saved session rather than a run.
+	restored bool
+
+	process *exec.Cmd
+	session *ssh.Session
+	sync.Mutex
+
+	// live is the output while the command runs, nil before it started.
+	// streaming is set once the run kept going with output past the grace
+	// of stream_grace, and streamCut once it was cut short for it.
+	live      *limitedBuffer
+	streaming bool
+	streamCut bool
+
 	diffPrepared bool
 	diff         []diffmatchpatch.Diff
+	diffBase     *Snapshot
+	lines        *lineMap
+
+	// hashes are those of the lines of the output, for the next snapshot.
+	hashes []uint64
 
 	diffAdditionCount int
 	diffDeletionCount int
 
+	// mask accumulates the changes since the first run, or since it was reset.
+	mask diffMask
+
+	// lineTimes are when every line of the output last changed, in Unix
+	// nanoseconds. Unlike the diff, they are kept once the snapshot is packed.
+	lineTimes []int64
+
+	// packed is the output compressed once the snapshot got old, against the
+	// output of dict if not nil. result, errorResult, raw, diff and lines
+	// are nil meanwhile.
+	packed []byte
+	dict   *Snapshot
+
+	// isKeyframe and incompressible are only used by compactHistory. A
+	// keyframe is compressed on its own every time, and the output of an
+	// incompressible snapshot does not get smaller, so it is not tried again.
+	isKeyframe     bool
+	incompressible bool
+
 	before *Snapshot
 	finish chan<- struct{}
 }
 
-//nolint:lll
-func NewSnapshot(id int64, command string, args []string, shell string, shellOpts string, before *Snapshot, finish chan<- struct{}) *Snapshot {
+// runOptions describes how the command of a snapshot is executed.
+type runOptions struct {
+	shell     string
+	shellOpts []string
+	dir       string
+	env       []envVar
+
+	pty     bool
+	ptyRows uint16
+	ptyCols uint16
+
+	// remote runs the command over SSH instead, if not nil.
+	remote *remoteHost
+
+	// beforeEach and afterEach are run through the shell around the command.
+	beforeEach string
+	afterEach  string
+
+	// pipe is run through the shell with the output on stdin, and what it
+	// writes is shown instead.
+	pipe string
+
+	// tabWidth is how far apart the tab stops of the output are.
+	tabWidth int
+
+	// highlights color the matches of their patterns in the output.
+	highlights []highlightRule
+
+	// maxLines and maxBytes limit how much of the output is kept, zero
+	// being no limit.
+	maxLines int
+	maxBytes int64
+
+	// stderr is how the stderr of the command is captured.
+	stderr StderrMode
+
+	// stripANSI drops the escape sequences of the output.
+	stripANSI bool
+
+	// input is fed to the command on stdin.
+	input runInput
+
+	// file is read instead of running the command, if not empty.
+	file string
+
+	// exec runs the command without a shell.
+	exec bool
+
+	// diffNormalize is how the output may differ from the previous one
+	// without counting as a change.
+	diffNormalize diffNormalize
+	// diffGranularity is how finely the changes within lines are marked.
+	diffGranularity DiffGranularity
+}
+
+// newRunOptions returns the options of the runs with the config.
+func newRunOptions(conf *config) runOptions {
+	return runOptions{
+		shell:     conf.general.shell,
+		shellOpts: conf.general.shellOptions,
+		dir:       conf.runtime.chdir,
+		env:       conf.general.env,
+		pty:       conf.general.pty,
+		remote:    conf.runtime.remote,
+
+		beforeEach: conf.general.beforeEach,
+		afterEach:  conf.general.afterEach,
+		pipe:       conf.general.pipe,
+
+		tabWidth:   conf.general.tabWidth,
+		highlights: conf.general.highlights,
+
+		maxLines: conf.general.maxLines,
+		maxBytes: conf.general.maxBytes,
+
+		stderr:    conf.general.stderr,
+		stripANSI: conf.general.stripANSI,
+
+		input: runInput{file: conf.runtime.input, data: conf.runtime.stdin},
+		file:  conf.runtime.file,
+		exec:  conf.runtime.exec,
+
+		diffNormalize:   conf.general.diffNormalize,
+		diffGranularity: conf.general.diffGranularity,
+	}
+}
+
+func NewSnapshot(id int64, command string, args []string, opts runOptions, before *Snapshot, finish chan<- struct{}) *Snapshot {
 	return &Snapshot{
 		id:      id,
 		command: command,
 		args:    args,
-
-		shell:     shell,
-		shellOpts: shellOpts,
+		opts:    opts,
 
 		before: before,
 		finish: finish,
@@ -63,18 +219,34 @@ func NewSnapshot(id int64, command string, args []string, shell string, shellOpt
 }
 
 func (s *Snapshot) compareFromBefore() error {
-	if s.before != nil && !s.before.completed {
+	before := s.before
+	for before != nil && (before.skipped || before.hookFailed) {
+		before = before.before
+	}
+
+	if before != nil && !before.completed {
 		return errNotCompletedYet
 	}
 
-	var beforeResult string
-	if s.before == nil {
-		beforeResult = ""
-	} else {
-		beforeResult = string(s.before.result)
+	beforeResult, beforeHashes := "", lineHashes
+	if before != nil {
+		beforeResult, beforeHashes = before.text(), before.hashLines
 	}
 
-	s.diff = dmp.DiffCleanupSemantic(dmp.DiffMain(beforeResult, string(s.result), false))
+	s.diff, s.lines = diffOutputs(beforeResult, s.text(), beforeHashes, s.hashLines, s.opts.diffNormalize, s.opts.diffGranularity)
+	s.diffBase = before
+
+	if before != nil {
+		// Runs may finish out of order, so the previous mask may not be there yet.
+		if !before.diffPrepared {
+			_ = before.compareFromBefore()
+		}
+
+		s.setPermanentMask(before.permanentMask().union(changedPositions(s.diff)))
+		s.setLineChangeTimes(lineTimesOf(s.lines, before.lineChangeTimes(), s.start.UnixNano()))
+	} else {
+		s.setLineChangeTimes(lineTimesOf(s.lines, nil, s.start.UnixNano()))
+	}
 	addition := 0
 	deletion := 0
 
@@ -88,60 +260,415 @@ func (s *Snapshot) compareFromBefore() error {
 		}
 	}
 
+	s.Lock()
 	s.diffAdditionCount = addition
 	s.diffDeletionCount = deletion
 	s.diffPrepared = true
+	s.Unlock()
 
 	return nil
 }
 
+// restart compares the snapshot again as if it were the first run, which
+// starts the accumulated changes and the ages of its lines over.
+func (s *Snapshot) restart() {
+	s.before = nil
+	s.setPermanentMask(nil)
+	_ = s.compareFromBefore()
+}
+
+func (s *Snapshot) permanentMask() diffMask {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.mask
+}
+
+// setPermanentMask replaces the accumulated changes, so that the following
+// snapshots accumulate from there. nil resets them.
+func (s *Snapshot) setPermanentMask(m diffMask) {
+	s.Lock()
+	s.mask = m
+	s.Unlock()
+}
+
+// compareWith returns a copy of the snapshot whose diff is taken against base
+// instead of the previous run.
+func (s *Snapshot) compareWith(base *Snapshot) (*Snapshot, error) {
+	if !s.completed || !base.completed {
+		return nil, errNotCompletedYet
+	}
+
+	o := s.output()
+	c := &Snapshot{
+		id:          s.id,
+		opts:        s.opts,
+		result:      o.result,
+		errorResult: o.errorResult,
+		stderrLines: s.stderrLines,
+		start:       s.start,
+		end:         s.end,
+		completed:   true,
+		before:      base,
+	}
+
+	if err := c.compareFromBefore(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// run executes the command between the before-each and after-each hooks,
+// and the --pipe filter after them, and blocks until it finishes.
+//
 //nolint:unparam
 func (s *Snapshot) run(finishedQueue chan<- int64) error {
 	s.start = time.Now()
-	defer func() {
+
+	if s.opts.beforeEach != "" {
+		if err := s.runEachHook("before-each", s.opts.beforeEach); err != nil {
+			if s.isKilled() {
+				s.skip(finishedQueue)
+
+				return nil
+			}
+
+			// The command is skipped, its output would be stale anyway.
+			s.err = err
+			s.hookFailed = true
+			s.errorResult = []byte(err.Error() + "\n")
+			s.finished(finishedQueue)
+
+			return nil
+		}
+	}
+
+	var started bool
+
+	switch {
+	case s.opts.file != "":
+		started = s.runFile()
+	case s.opts.remote != nil:
+		started = s.runRemote()
+	default:
+		started = s.runLocal()
+	}
+
+	if !started {
+		s.skip(finishedQueue)
+
+		return nil
+	}
+
+	if s.opts.afterEach != "" {
+		if err := s.runEachHook("after-each", s.opts.afterEach); err != nil && !s.isKilled() {
+			s.hookErr = err
+		}
+	}
+
+	if s.opts.pipe != "" {
 		s.end = time.Now()
-	}()
+		s.runPipe()
+	}
+
+	s.finished(finishedQueue)
+
+	return nil
+}
+
+func (s *Snapshot) finished(finishedQueue chan<- int64) {
+	// The filter is not part of the run, which ended before it.
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+
+	s.complete()
+	finishedQueue <- s.id
+	close(s.finish)
+}
+
+// commandLine returns the command of the snapshot with its arguments.
+func (s *Snapshot) commandLine() string {
+	return strings.Join(append([]string{s.command}, s.args...), " ")
+}
+
+// timeoutExitStatus is the exit status of a run killed for taking too long,
+// the same as timeout(1) gives.
+const timeoutExitStatus = 124
+
+// exitStatus returns the exit status of the run the way a shell reports it:
+// 128+n if signal n ended the command, or 1 if it failed without one.
+func (s *Snapshot) exitStatus() int {
+	switch {
+	case s.killed:
+		return timeoutExitStatus
+	case s.startErr != nil:
+		return startExitStatus(s.startErr)
+	case s.signal > 0:
+		return 128 + s.signal
+	case s.exitCode > 0:
+		return s.exitCode
+	case s.err != nil:
+		return 1
+	}
+
+	return 0
+}
+
+func (s *Snapshot) complete() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.completed = true
+}
+
+// isCompleted tells whether the run finished, from another goroutine than
+// the one which ran it.
+func (s *Snapshot) isCompleted() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.completed
+}
+
+// listStatus returns the exit status of the run as the snapshot list shows
+// it, and whether the output changed from the previous run.
+func (s *Snapshot) listStatus() (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	changed := s.diffPrepared && s.diffAdditionCount+s.diffDeletionCount > 0
+
+	// The run sets the rest before it completes.
+	switch {
+	case !s.completed:
+		return "...", changed
+	case s.skipped:
+		return "skip", changed
+	case s.exitCode > 0:
+		return fmt.Sprintf("E(%d)", s.exitCode), changed
+	}
+
+	return "ok", changed
+}
+
+func (s *Snapshot) isKilled() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.killed
+}
+
+// runEachHook runs a hook as part of the run, so that it counts towards its
+// duration and is killed along with it.
+func (s *Snapshot) runEachHook(name, cmdline string) error {
+	command, out := hookCommand(cmdline, s)
+	setProcessGroup(command)
+
+	s.Lock()
+	if s.killed {
+		s.Unlock()
+
+		return hookError{name: name, err: errRunKilled}
+	}
+
+	err := command.Start()
+	s.process = command
+	s.Unlock()
+
+	if err == nil {
+		err = command.Wait()
+	}
+
+	if err != nil {
+		return newHookError(name, command, out, err)
+	}
+
+	return nil
+}
 
-	var b, eb bytes.Buffer
+// localCommand returns the command of the run, through the shell unless
+// --exec is given.
+func (s *Snapshot) localCommand() (*exec.Cmd, error) {
+	if s.opts.exec {
+		return directCommand(s.command, s.args)
+	}
 
 	commands := []string{s.command}
 	commands = append(commands, s.args...)
 
-	var command *exec.Cmd
+	return shellCommand(s.opts.shell, s.opts.shellOpts, strings.Join(commands, " ")), nil
+}
+
+// runLocal executes the command and blocks until it finishes. It returns
+// false if the run was killed before it started.
+func (s *Snapshot) runLocal() bool {
+	var eb bytes.Buffer
+
+	b := newLimitedBuffer(s.opts.maxLines, s.opts.maxBytes)
+	out := newCapture(s.opts, b, &eb)
+
+	command, err := s.localCommand()
+	if err != nil {
+		s.failStart(err)
+
+		return true
+	}
+
+	command.Dir = s.opts.dir
+	if len(s.opts.env) > 0 {
+		command.Env = applyEnv(os.Environ(), s.opts.env)
+	}
+
+	stdin, closeStdin, err := s.opts.input.open()
+	if err != nil {
+		// The run fails like a command which could not start.
+		s.err = err
+		s.errorResult = []byte(err.Error() + "\n")
+
+		return true
+	}
+
+	defer closeStdin()
+
+	command.Stdin = stdin
+
+	s.Lock()
+	if s.killed {
+		s.Unlock()
 
-	if runtime.GOOS == "windows" {
-		cmdStr := strings.Join(commands, " ")
-		compSec := os.Getenv("COMSPEC")
-		command = exec.Command(compSec, "/c", cmdStr)
+		return false
+	}
+
+	var tty *os.File
+
+	if s.opts.pty {
+		tty, err = startPty(command, s.opts.ptyRows, s.opts.ptyCols)
 	} else {
-		var args []string
-		args = append(args, strings.Fields(s.shellOpts)...)
-		args = append(args, "-c")
-		args = append(args, strings.Join(commands, " "))
-		command = exec.Command(s.shell, args...) //nolint:gosec
+		command.Stdout, command.Stderr = out.stdout, out.stderr
+		setProcessGroup(command)
+
+		err = command.Start()
 	}
 
-	command.Stdout = &b
-	command.Stderr = &eb
+	s.process = command
+	s.live = b
+	s.Unlock()
 
-	if err := command.Start(); err != nil {
-		return nil //nolint:nilerr
+	if err != nil {
+		s.failStart(err)
+
+		return true
 	}
 
-	go func() {
-		if err := command.Wait(); err != nil {
-			s.err = err
+	if tty != nil {
+		// Reading fails once the command closes its side of the terminal.
+		_, _ = io.Copy(b, tty)
+		_ = tty.Close()
+	}
+
+	if err := command.Wait(); err != nil {
+		s.err = err
+	}
+
+	out.finish(s)
+
+	if ws, ok := command.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		s.signal = int(ws.Signal())
+	}
+
+	s.result = s.terminalOutput(b.Bytes())
+
+	s.totalLines = b.totalLines()
+	s.errorResult = eb.Bytes()
+	s.exitCode = command.ProcessState.ExitCode()
+
+	return true
+}
+
+// terminalOutput returns the output of a command which ran in a terminal the
+// way the terminal shows it, and other output as it is.
+func (s *Snapshot) terminalOutput(b []byte) []byte {
+	if !s.opts.pty {
+		return b
+	}
+
+	b = normalizeTerminalOutput(b)
+	if s.opts.stripANSI {
+		b = stripANSI(b)
+	}
+
+	return b
+}
+
+// applyEnv returns the environment with the variables set or removed.
+func applyEnv(environ []string, vars []envVar) []string {
+	env := append([]string{}, environ...)
+
+	for _, ev := range vars {
+		kept := env[:0]
+
+		for _, e := range env {
+			if !strings.HasPrefix(e, ev.key+"=") {
+				kept = append(kept, e)
+			}
 		}
 
-		s.result = b.Bytes()
-		s.errorResult = eb.Bytes()
-		s.exitCode = command.ProcessState.ExitCode()
-		s.completed = true
-		finishedQueue <- s.id
-		close(s.finish)
-	}()
+		env = kept
 
-	return nil
+		if !ev.unset {
+			env = append(env, ev.key+"="+ev.value)
+		}
+	}
+
+	return env
+}
+
+// kill terminates the running command. A command which has not started yet
+// will never start.
+func (s *Snapshot) kill() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.killed = true
+
+	if s.process != nil && s.process.Process != nil {
+		_ = killProcess(s.process)
+	}
+
+	if s.session != nil {
+		// Servers may ignore the signal, closing the session abandons the command anyway.
+		_ = s.session.Signal(ssh.SIGKILL)
+		_ = s.session.Close()
+	}
+}
+
+// terminate asks the running command to stop, and lets it clean up unlike
+// kill. A command which has not started yet will never start.
+func (s *Snapshot) terminate() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.killed = true
+
+	if s.process != nil && s.process.Process != nil {
+		_ = terminateProcess(s.process)
+	}
+
+	if s.session != nil {
+		_ = s.session.Signal(ssh.SIGTERM)
+	}
+}
+
+// skip marks the snapshot as a missed tick which never ran.
+func (s *Snapshot) skip(finishedQueue chan<- int64) {
+	s.start = time.Now()
+	s.end = s.start
+	s.skipped = true
+	s.complete()
+	finishedQueue <- s.id
+	close(s.finish)
 }
 
 func isWhiteString(str string) bool {
@@ -154,32 +681,66 @@ func isWhiteString(str string) bool {
 	return true
 }
 
-func (s *Snapshot) render(w io.Writer, isShowDiff bool, query string) error {
-	src := string(s.result)
+// text returns the output as it is shown and compared, with its tabs expanded.
+func (s *Snapshot) text() string {
+	return expandTabs(string(s.output().result), s.opts.tabWidth)
+}
+
+// render writes the output, highlighting the changes from the previous run,
+// or all changes accumulated so far if permanent. The highlights of the
+// options color their matches either way, and the matches of the query
+// are marked on top.
+func (s *Snapshot) render(w io.Writer, isShowDiff, permanent bool, query *regexp.Regexp, t theme) error {
+	o := s.output()
+	src := expandTabs(string(o.result), s.opts.tabWidth)
+
+	if s.startErr != nil && isWhiteString(src) {
+		_, err := io.WriteString(w, s.startFailure(t))
+
+		return err
+	}
 
 	if isWhiteString(src) {
-		src = string(s.errorResult)
-		_, err := io.WriteString(w, fmt.Sprintf(`[red]%s[-:-:-]`, src))
+		_, err := io.WriteString(w, fmt.Sprintf(`%s%s[-:-:-]`, colorTags(colorTag(t.stderrText), ""), o.errorResult))
 
 		return err
 	}
 
+	hl := withStderrLines(highlightPositions(src, s.opts.highlights), src, s.stderrLines, t.stderrText)
+	prepared := false
+
 	if isShowDiff {
-		if s.diffPrepared {
-			src = DiffPrettyText(s.diff)
-		} else if err := s.compareFromBefore(); err == nil {
-			src = DiffPrettyText(s.diff)
+		prepared = s.diffPrepared
+		if !prepared {
+			prepared = s.compareFromBefore() == nil
+		}
+
+		switch {
+		case prepared && permanent:
+			src = PermanentPrettyText(src, s.permanentMask(), hl, t)
+		case prepared:
+			src = DiffPrettyText(s.diff, hl, t)
 		}
 	}
 
+	if !prepared && hl != nil {
+		src = HighlightPrettyText(src, hl)
+	}
+
 	var b bytes.Buffer
 	if _, err := io.Copy(tview.ANSIWriter(&b), strings.NewReader(src)); err != nil {
 		return err
 	}
 
+	if isShowDiff && !permanent && s.lines != nil && t.diffMoved != tcell.ColorDefault {
+		b = *bytes.NewBufferString(markMovedLines(b.String(), s.text(), s.lines, t.diffMoved, hl))
+	}
+
 	var r io.Reader
-	if query != "" {
-		r = strings.NewReader(strings.ReplaceAll(b.String(), query, fmt.Sprintf(`[black:yellow]%s[-:-:-]`, query)))
+	if query != nil {
+		r = strings.NewReader(query.ReplaceAllStringFunc(b.String(), func(match string) string {
+			return fmt.Sprintf(`[black:yellow]%s[-:-:-]`, match)
+		}))
 	} else {
 		r = &b
 	}
@@ -189,25 +750,133 @@ func (s *Snapshot) render(w io.Writer, isShowDiff bool, query string) error {
 	return err
 }
 
-func DiffPrettyText(diffs []diffmatchpatch.Diff) string {
-	var buff bytes.Buffer
+// markMovedLines replaces the lines which only moved with the plain line on
+// the given background, keeping the colors of the highlights.
+func markMovedLines(rendered, raw string, lines *lineMap, c tcell.Color, hl highlightMask) string {
+	renderedLines := strings.Split(rendered, "\n")
+	rawLines := strings.Split(raw, "\n")
 
-	for _, diff := range diffs {
+	for i := range renderedLines {
+		if i >= len(rawLines) || !lines.isMoved(i) {
+			continue
+		}
+
+		renderedLines[i] = movedLine(rawLines[i], i, c, hl)
+	}
+
+	return strings.Join(renderedLines, "\n")
+}
+
+// DiffPrettyText highlights the inserted text of the diffs with the theme.
+// Lines which are inserted as a whole use the added color and others the
+// changed colors. Text right after a deletion is marked with the removed color.
+// The highlights of the mask color the foreground on top.
+func DiffPrettyText(diffs []diffmatchpatch.Diff, hl highlightMask, t theme) string {
+	var (
+		buff bytes.Buffer
+		p    textPosition
+	)
+
+	added := colorTag(t.diffAdded)
+	changedFg, changedBg := colorTag(t.diffChangedForeground), colorTag(t.diffChangedBackground)
+	removed := colorTag(t.diffRemoved)
+
+	kept := keptLines(diffs)
+
+	for i, diff := range diffs {
 		text := diff.Text
 
 		switch diff.Type {
 		case diffmatchpatch.DiffInsert:
-			for _, c := range text {
-				if unicode.IsSpace(c) {
-					_, _ = buff.WriteRune(c)
-				} else {
-					_, _ = buff.WriteString(color.New(color.BgGreen).Sprintf(string(c)))
+			g := uniseg.NewGraphemes(text)
+			for g.Next() {
+				fg, bg := changedFg, changedBg
+				if !kept[p.line] {
+					fg, bg = "", added
 				}
+
+				writeHighlighted(&buff, g.Str(), highlightTags(fg, bg, hl.next(&p, g.Runes())))
 			}
 		case diffmatchpatch.DiffEqual:
-			_, _ = buff.WriteString(text)
+			if i > 0 && diffs[i-1].Type == diffmatchpatch.DiffDelete {
+				g := uniseg.NewGraphemes(text)
+				if g.Next() {
+					writeHighlighted(&buff, g.Str(), highlightTags("", removed, hl.next(&p, g.Runes())))
+					text = text[len(g.Str()):]
+				}
+			}
+
+			writeHighlightedText(&buff, text, hl, &p)
 		}
 	}
 
 	return buff.String()
 }
+
+// keptLines returns the lines of the new text which keep some of the old text.
+func keptLines(diffs []diffmatchpatch.Diff) map[int]bool {
+	kept := map[int]bool{}
+	line := 0
+
+	for _, diff := range diffs {
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			for _, part := range strings.SplitAfter(diff.Text, "\n") {
+				if part != "" && part != "\n" {
+					kept[line] = true
+				}
+
+				if strings.HasSuffix(part, "\n") {
+					line++
+				}
+			}
+		case diffmatchpatch.DiffInsert:
+			line += strings.Count(diff.Text, "\n")
+		}
+	}
+
+	return kept
+}
+
+func writeHighlighted(buff *bytes.Buffer, c, style string) {
+	if style == "" || isWhiteString(c) {
+		_, _ = buff.WriteString(c)
+
+		return
+	}
+
+	_, _ = buff.WriteString(style)
+	_, _ = buff.WriteString(c)
+	_, _ = buff.WriteString("[-:-:-]")
+}
+
+// colorTags returns the style tag for the colors, or nothing if both are the default.
+func colorTags(fg, bg string) string {
+	if fg == "" && bg == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("[%s:%s]", fg, bg)
+}
+
+// colorTag names the color for style tags. Names are preferred, since a hex
+// value would turn a color of the terminal palette into a true color.
+func colorTag(c tcell.Color) string {
+	if c == tcell.ColorDefault {
+		return ""
+	}
+
+	var tag string
+
+	for name, color := range tcell.ColorNames {
+		if color == c && (tag == "" || name < tag) {
+			tag = name
+		}
+	}
+
+	if tag == "" {
+		tag = fmt.Sprintf("#%06x", c.Hex())
+	}
+
+	return tag
+}
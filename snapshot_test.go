@@ -0,0 +1,145 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPrettyText(t *testing.T) {
+	th := theme{
+		diffAdded:             tcell.ColorBlue,
+		diffRemoved:           tcell.ColorRed,
+		diffChangedBackground: tcell.ColorGreen,
+		diffChangedForeground: tcell.ColorBlack,
+	}
+
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			name:   "changed",
+			before: "a 1\n",
+			after:  "a 2\n",
+			want:   "a [black:green]2[-:-:-]\n",
+		},
+		{
+			name:   "added line",
+			before: "a\n",
+			after:  "a\nb\n",
+			want:   "a\n[:blue]b[-:-:-]\n",
+		},
+		{
+			name:   "removed",
+			before: "abc\n",
+			after:  "ac\n",
+			want:   "a[:red]c[-:-:-]\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := dmp.DiffCleanupSemantic(dmp.DiffMain(tt.before, tt.after, false))
+			got := DiffPrettyText(diffs, nil, th)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestColorTag(t *testing.T) {
+	assert.Equal(t, "", colorTag(tcell.ColorDefault))
+	assert.Equal(t, "green", colorTag(tcell.ColorGreen))
+	assert.Equal(t, "#123456", colorTag(tcell.NewHexColor(0x123456)))
+}
+
+func TestSnapshotCompareWith(t *testing.T) {
+	a := &Snapshot{id: 1, result: []byte("x 1\n"), completed: true}
+	b := &Snapshot{id: 2, result: []byte("x 2\n"), completed: true, before: a}
+	c := &Snapshot{id: 3, result: []byte("x 3\n"), completed: true, before: b}
+
+	got, err := c.compareWith(a)
+	assert.NoError(t, err)
+	assert.Equal(t, a, got.diffBase)
+	assert.Equal(t, 1, got.diffAdditionCount)
+	assert.Equal(t, 1, got.diffDeletionCount)
+	assert.False(t, c.diffPrepared)
+
+	_, err = c.compareWith(&Snapshot{id: 4})
+	assert.Equal(t, errNotCompletedYet, err)
+}
+
+func TestSnapshotListStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		s           *Snapshot
+		wantStatus  string
+		wantChanged bool
+	}{
+		{name: "running", s: &Snapshot{}, wantStatus: "..."},
+		{name: "skipped", s: &Snapshot{completed: true, skipped: true}, wantStatus: "skip"},
+		{name: "failed", s: &Snapshot{completed: true, exitCode: 2}, wantStatus: "E(2)"},
+		{name: "ok", s: &Snapshot{completed: true}, wantStatus: "ok"},
+		{
+			name:        "changed",
+			s:           &Snapshot{completed: true, diffPrepared: true, diffAdditionCount: 1},
+			wantStatus:  "ok",
+			wantChanged: true,
+		},
+		{name: "not compared yet", s: &Snapshot{completed: true, diffAdditionCount: 1}, wantStatus: "ok"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			status, changed := tt.s.listStatus()
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantChanged, changed)
+		})
+	}
+}
+
+func TestSnapshotExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	tests := []struct {
+		command string
+		want    int
+	}{
+		{command: "true", want: 0},
+		{command: "exit 3", want: 3},
+		{command: "kill -TERM $$", want: 128 + 15},
+		{command: "no-such-command-for-viddy", want: 127},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.command, func(t *testing.T) {
+			s := NewSnapshot(0, tt.command, nil, runOptions{shell: "sh"}, nil, make(chan struct{}))
+			assert.NoError(t, s.run(make(chan int64, 1)))
+			assert.Equal(t, tt.want, s.exitStatus())
+		})
+	}
+
+	// Runs which fail without an exit code still fail.
+	assert.Equal(t, 1, (&Snapshot{err: errConnectionLost}).exitStatus())
+}
+
+func TestSnapshotRenderExpandsTabs(t *testing.T) {
+	th := theme{diffChangedBackground: tcell.ColorGreen}
+	opts := runOptions{tabWidth: 4}
+
+	a := &Snapshot{id: 1, result: []byte("sda\t5G\n"), completed: true, opts: opts}
+	b := &Snapshot{id: 2, result: []byte("sda\t6G\n"), completed: true, opts: opts, before: a}
+
+	var got strings.Builder
+	assert.NoError(t, b.render(&got, true, false, nil, th))
+	assert.Equal(t, "sda [:green]6[-:-:-]G\n", got.String())
+}
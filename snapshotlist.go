@@ -0,0 +1,139 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// snapshotList is a sidebar listing the snapshots, newest first. Only the
+// visible rows are formatted on each draw, so it stays fast with long histories.
+type snapshotList struct {
+	*tview.Box
+
+	ids    func() []int64
+	format func(id int64) string
+
+	selected      int64
+	offset        int
+	selectedColor tcell.Color
+}
+
+func newSnapshotList(ids func() []int64, format func(id int64) string) *snapshotList {
+	l := &snapshotList{
+		Box:           tview.NewBox(),
+		ids:           ids,
+		format:        format,
+		selected:      -1,
+		selectedColor: tcell.ColorGray,
+	}
+	l.SetBorder(true).SetTitle("Snapshots")
+
+	return l
+}
+
+// row returns the row of the id in the ids, which are sorted ascending and
+// shown in reverse.
+func (l *snapshotList) row(ids []int64, id int64) int {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	if i == len(ids) || ids[i] != id {
+		return 0
+	}
+
+	return len(ids) - 1 - i
+}
+
+// move moves the selection by rows, towards older snapshots if positive.
+func (l *snapshotList) move(rows int) {
+	ids := l.ids()
+	if len(ids) == 0 {
+		return
+	}
+
+	row := l.row(ids, l.selected) + rows
+	if row < 0 {
+		row = 0
+	}
+
+	if row >= len(ids) {
+		row = len(ids) - 1
+	}
+
+	l.selected = ids[len(ids)-1-row]
+}
+
+// handleKey moves the selection with the arrow and paging keys, and calls
+// enter with the selected id on Enter. It reports whether the key was used.
+func (l *snapshotList) handleKey(event *tcell.EventKey, enter func(id int64)) bool {
+	if event.Modifiers() != tcell.ModNone {
+		return false
+	}
+
+	_, _, _, height := l.GetInnerRect()
+
+	//nolint:exhaustive
+	switch event.Key() {
+	case tcell.KeyUp:
+		l.move(-1)
+	case tcell.KeyDown:
+		l.move(1)
+	case tcell.KeyPgUp:
+		l.move(-height)
+	case tcell.KeyPgDn:
+		l.move(height)
+	case tcell.KeyHome:
+		l.move(-len(l.ids()))
+	case tcell.KeyEnd:
+		l.move(len(l.ids()))
+	case tcell.KeyEnter:
+		if l.selected != -1 {
+			enter(l.selected)
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (l *snapshotList) Draw(screen tcell.Screen) {
+	l.Box.DrawForSubclass(screen, l)
+
+	x, y, width, height := l.GetInnerRect()
+	if height <= 0 {
+		return
+	}
+
+	ids := l.ids()
+	selected := l.row(ids, l.selected)
+
+	if selected < l.offset {
+		l.offset = selected
+	}
+
+	if selected >= l.offset+height {
+		l.offset = selected - height + 1
+	}
+
+	if l.offset > len(ids)-height {
+		l.offset = len(ids) - height
+	}
+
+	if l.offset < 0 {
+		l.offset = 0
+	}
+
+	for i := 0; i < height && l.offset+i < len(ids); i++ {
+		id := ids[len(ids)-1-l.offset-i]
+
+		if id == l.selected {
+			style := tcell.StyleDefault.Background(l.selectedColor)
+			for dx := 0; dx < width; dx++ {
+				screen.SetContent(x+dx, y+i, ' ', nil, style)
+			}
+		}
+
+		tview.Print(screen, l.format(id), x, y+i, width, tview.AlignLeft, tview.Styles.PrimaryTextColor)
+	}
+}
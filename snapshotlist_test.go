@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotListHandleKey(t *testing.T) {
+	ids := []int64{100, 200, 300, 400}
+	l := newSnapshotList(func() []int64 { return ids }, func(id int64) string { return "" })
+	l.selected = 400
+
+	var entered int64
+
+	enter := func(id int64) { entered = id }
+	key := func(k tcell.Key) bool { return l.handleKey(tcell.NewEventKey(k, 0, tcell.ModNone), enter) }
+
+	assert.True(t, key(tcell.KeyDown))
+	assert.Equal(t, int64(300), l.selected)
+
+	assert.True(t, key(tcell.KeyEnd))
+	assert.Equal(t, int64(100), l.selected)
+
+	assert.True(t, key(tcell.KeyDown))
+	assert.Equal(t, int64(100), l.selected)
+
+	assert.True(t, key(tcell.KeyUp))
+	assert.True(t, key(tcell.KeyEnter))
+	assert.Equal(t, int64(200), entered)
+
+	assert.True(t, key(tcell.KeyHome))
+	assert.Equal(t, int64(400), l.selected)
+
+	assert.False(t, key(tcell.KeyTab))
+	assert.False(t, l.handleKey(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModShift), enter))
+}
+
+func TestSnapshotListDrawsVisibleRowsOnly(t *testing.T) {
+	ids := make([]int64, 10000)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+
+	var formatted []int64
+
+	l := newSnapshotList(func() []int64 { return ids }, func(id int64) string {
+		formatted = append(formatted, id)
+
+		return ""
+	})
+	l.selected = 5000
+
+	screen := tcell.NewSimulationScreen("")
+	assert.NoError(t, screen.Init())
+	screen.SetSize(30, 12)
+	l.SetRect(0, 0, 30, 12)
+
+	l.Draw(screen)
+
+	assert.Len(t, formatted, 10)
+	assert.Contains(t, formatted, int64(5000))
+}
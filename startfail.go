@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+
+	"github.com/rivo/tview"
+)
+
+// firstRunPlaceholder is shown in the body until the first run finishes.
+const firstRunPlaceholder = "[::d]Waiting for the first result…[-:-:-]"
+
+// Exit statuses of a command which could not start, the same as shells give.
+const (
+	notExecutableExitStatus = 126
+	notFoundExitStatus      = 127
+)
+
+// failStart records that the command could not be started, which the run
+// shows instead of its output.
+func (s *Snapshot) failStart(err error) {
+	s.err = err
+	s.startErr = err
+	s.errorResult = []byte(err.Error() + "\n")
+}
+
+// startExitStatus returns the exit status of a command which could not start
+// because of the error.
+func startExitStatus(err error) int {
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return notFoundExitStatus
+	case errors.Is(err, fs.ErrPermission):
+		return notExecutableExitStatus
+	}
+
+	return 1
+}
+
+// startFailure describes why the command could not start, in the color of
+// the theme for it.
+func (s *Snapshot) startFailure(t theme) string {
+	return fmt.Sprintf("[%s::b]%s\nexit status %d[-:-:-]", colorTag(t.errorText), tview.Escape(s.startErr.Error()), s.exitStatus())
+}
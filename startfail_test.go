@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "not in the path",
+			err:  &exec.Error{Name: "zsh", Err: exec.ErrNotFound},
+			want: 127,
+		},
+		{
+			name: "no such file",
+			err:  &fs.PathError{Op: "fork/exec", Path: "/bin/zsh", Err: fs.ErrNotExist},
+			want: 127,
+		},
+		{
+			name: "not executable",
+			err:  &fs.PathError{Op: "fork/exec", Path: "/etc/passwd", Err: fs.ErrPermission},
+			want: 126,
+		},
+		{
+			name: "other",
+			err:  errors.New("too many open files"),
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, startExitStatus(tt.err))
+		})
+	}
+}
+
+func TestSnapshotStartFailure(t *testing.T) {
+	s := NewSnapshot(0, "date", nil, runOptions{shell: "viddy-no-such-shell"}, nil, make(chan struct{}))
+	require.NoError(t, s.run(make(chan int64, 1)))
+
+	require.Error(t, s.startErr)
+	assert.Equal(t, 127, s.exitStatus())
+
+	var b bytes.Buffer
+	require.NoError(t, s.render(&b, false, false, nil, theme{errorText: tcell.ColorRed}))
+	assert.Equal(t, fmt.Sprintf("[red::b]%s\nexit status 127[-:-:-]", s.startErr), b.String())
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/spf13/cast"
+)
+
+// StatusItem is a part of the status box of the header.
+type StatusItem string
+
+var (
+	// StatusItemMode shows how the runs are scheduled, such as "precise".
+	StatusItemMode StatusItem = "mode"
+	// StatusItemInterval shows the interval, as the keys last changed it.
+	StatusItemInterval StatusItem = "interval"
+	// StatusItemDrift shows how late the latest run started after it was
+	// due, if runs keep to a schedule.
+	StatusItemDrift StatusItem = "drift"
+	// StatusItemRunning spins while the command is running.
+	StatusItemRunning StatusItem = "running"
+	// StatusItemTimeMachine shows which snapshot the time machine is on.
+	StatusItemTimeMachine StatusItem = "timemachine"
+	// StatusItemSuspend shows whether new runs are left out.
+	StatusItemSuspend StatusItem = "suspend"
+	// StatusItemDiff shows whether the differences are highlighted.
+	StatusItemDiff StatusItem = "diff"
+)
+
+// defaultStatusItems are all the items, in the order they are shown.
+var defaultStatusItems = []StatusItem{
+	StatusItemMode, StatusItemInterval, StatusItemDrift, StatusItemRunning,
+	StatusItemTimeMachine, StatusItemSuspend, StatusItemDiff,
+}
+
+type statusItemError struct {
+	item string
+}
+
+func (e statusItemError) Error() string {
+	names := make([]string, 0, len(defaultStatusItems))
+	for _, item := range defaultStatusItems {
+		names = append(names, fmt.Sprintf("%q", item))
+	}
+
+	return fmt.Sprintf("status_items: unknown item %q, must be among %s", e.item, strings.Join(names, ", "))
+}
+
+// parseStatusItems reads the list of status items. An empty list leaves out
+// the status box.
+func parseStatusItems(value interface{}) ([]StatusItem, error) {
+	names, err := cast.ToStringSliceE(value)
+	if err != nil {
+		return nil, statusItemError{item: cast.ToString(value)}
+	}
+
+	items := make([]StatusItem, 0, len(names))
+
+	for _, name := range names {
+		item := StatusItem(strings.ToLower(strings.TrimSpace(name)))
+
+		known := false
+		for _, i := range defaultStatusItems {
+			known = known || i == item
+		}
+
+		if !known {
+			return nil, statusItemError{item: name}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// spinnerFrames are shown one after the other while a run executes.
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+const spinnerInterval = 100 * time.Millisecond
+
+// status is what the status box shows.
+type status struct {
+	mode     ViddyIntervalMode
+	interval time.Duration
+	// drift is how late the latest run started, if it was due at a
+	// particular time.
+	drift string
+
+	running bool
+	frame   int
+
+	timeMachine     bool
+	position, count int
+	// ranCommand is the command which the snapshot shown in the time machine
+	// ran, if it is not the one the runs execute now.
+	ranCommand string
+	// newSnapshots is how many runs finished since the time machine was
+	// opened, and paused whether the runs are paused instead.
+	newSnapshots int
+	paused       bool
+	// scrubStep is how many snapshots a held key moves, while it is more
+	// than one.
+	scrubStep int
+
+	suspend bool
+	diff    bool
+	// diffAgainst says how far back the differences are taken, if not from
+	// the previous run.
+	diffAgainst string
+	// change is the hunk of changes gone to, out of changes, or 0.
+	change, changes int
+}
+
+// formatStatus writes the items of the status box.
+func formatStatus(items []StatusItem, s status) string {
+	parts := make([]string, 0, len(items))
+
+	for _, item := range items {
+		switch item {
+		case StatusItemMode:
+			parts = append(parts, string(s.mode))
+		case StatusItemInterval:
+			// A schedule has no interval of its own.
+			if s.interval > 0 {
+				parts = append(parts, s.interval.String())
+			}
+		case StatusItemDrift:
+			if s.drift != "" {
+				parts = append(parts, "drift "+s.drift)
+			}
+		case StatusItemRunning:
+			// It keeps its width, so that the rest does not move.
+			if s.running {
+				parts = append(parts, "[yellow]"+string(spinnerFrames[s.frame%len(spinnerFrames)])+"[reset]")
+			} else {
+				parts = append(parts, " ")
+			}
+		case StatusItemTimeMachine:
+			value := convertToOnOrOff(s.timeMachine)
+			if s.timeMachine && s.position > 0 {
+				value = fmt.Sprintf("[green]%d/%d[reset]", s.position, s.count)
+			}
+
+			if s.timeMachine && s.ranCommand != "" {
+				value += " [yellow]ran " + tview.Escape(s.ranCommand) + "[reset]"
+			}
+
+			if s.timeMachine && s.scrubStep > 1 {
+				value += fmt.Sprintf(" [yellow]×%d[reset]", s.scrubStep)
+			}
+
+			switch {
+			case s.timeMachine && s.paused:
+				value += " [yellow]paused[reset]"
+			case s.timeMachine && s.newSnapshots > 0:
+				value += fmt.Sprintf(" [yellow]+%d new[reset]", s.newSnapshots)
+			}
+
+			parts = append(parts, "Time Machine: "+value)
+		case StatusItemSuspend:
+			parts = append(parts, "Suspend: "+convertToOnOrOff(s.suspend))
+		case StatusItemDiff:
+			var details []string
+			if s.diffAgainst != "" {
+				details = append(details, "vs "+s.diffAgainst)
+			}
+
+			if s.change > 0 {
+				details = append(details, fmt.Sprintf("change %d/%d", s.change, s.changes))
+			}
+
+			value := convertToOnOrOff(s.diff)
+			if s.diff && len(details) > 0 {
+				value = "[green]" + strings.Join(details, ", ") + "[reset]"
+			}
+
+			parts = append(parts, "Diff: "+value)
+		}
+	}
+
+	return strings.Join(parts, "  ")
+}
+
+// showsStatus tells whether the status box has the item.
+func (v *Viddy) showsStatus(item StatusItem) bool {
+	for _, i := range v.statusItems {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runStarted counts the run as running and spins the status while any is.
+func (v *Viddy) runStarted() {
+	atomic.AddInt64(&v.running, 1)
+
+	if v.showsStatus(StatusItemRunning) && atomic.CompareAndSwapInt32(&v.spinning, 0, 1) {
+		go v.spin()
+	}
+}
+
+func (v *Viddy) runFinished() {
+	atomic.AddInt64(&v.running, -1)
+}
+
+func (v *Viddy) spin() {
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		v.app.QueueUpdateDraw(func() {
+			v.spinnerFrame++
+			v.UpdateStatusView()
+		})
+
+		if atomic.LoadInt64(&v.running) > 0 {
+			continue
+		}
+
+		atomic.StoreInt32(&v.spinning, 0)
+
+		// A run which started meanwhile left the spinning to this one.
+		if atomic.LoadInt64(&v.running) == 0 || !atomic.CompareAndSwapInt32(&v.spinning, 0, 1) {
+			return
+		}
+	}
+}
+
+// timeMachinePosition returns how many snapshots are up to the one shown,
+// and how many there are.
+func (v *Viddy) timeMachinePosition() (int, int) {
+	v.RLock()
+	defer v.RUnlock()
+
+	for i := len(v.idList) - 1; i >= 0; i-- {
+		if v.idList[i] == v.currentID {
+			return i + 1, len(v.idList)
+		}
+	}
+
+	return 0, len(v.idList)
+}
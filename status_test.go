@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatStatus(t *testing.T) {
+	s := status{mode: ViddyIntervalModePrecise, interval: 2 * time.Second, diff: true}
+
+	assert.Equal(t, "precise  2s     Time Machine: [red]OFF[reset]  Suspend: [red]OFF[reset]  Diff: [green]ON [reset]",
+		formatStatus(defaultStatusItems, s))
+
+	s.running = true
+	s.frame = 1
+	s.timeMachine = true
+	s.position, s.count = 3, 10
+	assert.Equal(t, "[yellow]⠙[reset]  Time Machine: [green]3/10[reset]",
+		formatStatus([]StatusItem{StatusItemRunning, StatusItemTimeMachine}, s))
+
+	// A snapshot of the command before it was edited tells what it ran.
+	s.ranCommand = "df [-h]"
+	assert.Equal(t, "Time Machine: [green]3/10[reset] [yellow]ran df [-h[][reset]",
+		formatStatus([]StatusItem{StatusItemTimeMachine}, s))
+	s.ranCommand = ""
+
+	// The runs which came in meanwhile are counted, unless they are paused.
+	s.newSnapshots = 14
+	assert.Equal(t, "Time Machine: [green]3/10[reset] [yellow]+14 new[reset]", formatStatus([]StatusItem{StatusItemTimeMachine}, s))
+	s.paused = true
+	assert.Equal(t, "Time Machine: [green]3/10[reset] [yellow]paused[reset]", formatStatus([]StatusItem{StatusItemTimeMachine}, s))
+	s.newSnapshots, s.paused = 0, false
+
+	// A held key tells how far it moves.
+	s.scrubStep = 10
+	assert.Equal(t, "Time Machine: [green]3/10[reset] [yellow]×10[reset]", formatStatus([]StatusItem{StatusItemTimeMachine}, s))
+	s.scrubStep = 0
+
+	// A schedule has no interval to show.
+	assert.Equal(t, "schedule", formatStatus([]StatusItem{StatusItemMode, StatusItemInterval},
+		status{mode: ViddyIntervalModeSchedule}))
+
+	assert.Equal(t, "", formatStatus(nil, s))
+
+	// Runs which keep to a schedule tell how late the latest one started.
+	assert.Equal(t, "clockwork  2s  drift +12ms", formatStatus([]StatusItem{StatusItemMode, StatusItemInterval, StatusItemDrift},
+		status{mode: ViddyIntervalModeClockwork, interval: 2 * time.Second, drift: "+12ms"}))
+	assert.Equal(t, "sequential",
+		formatStatus([]StatusItem{StatusItemMode, StatusItemDrift}, status{mode: ViddyIntervalModeSequential}))
+
+	// The baseline of --differences-against replaces ON.
+	s.diffAgainst = "60 runs ago"
+	assert.Equal(t, "Diff: [green]vs 60 runs ago[reset]", formatStatus([]StatusItem{StatusItemDiff}, s))
+
+	// Going through the changes tells which one is on screen.
+	s.change, s.changes = 3, 7
+	assert.Equal(t, "Diff: [green]vs 60 runs ago, change 3/7[reset]", formatStatus([]StatusItem{StatusItemDiff}, s))
+
+	s.diffAgainst = ""
+	assert.Equal(t, "Diff: [green]change 3/7[reset]", formatStatus([]StatusItem{StatusItemDiff}, s))
+}
+
+func TestParseStatusItems(t *testing.T) {
+	items, err := parseStatusItems("mode  Diff")
+	assert.NoError(t, err)
+	assert.Equal(t, []StatusItem{StatusItemMode, StatusItemDiff}, items)
+
+	_, err = parseStatusItems([]interface{}{"mode", "clock"})
+	assert.EqualError(t, err,
+		`status_items: unknown item "clock", must be among "mode", "interval", "drift", "running", "timemachine", "suspend", "diff"`)
+}
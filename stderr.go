@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// StderrMode decides how the stderr of the command is captured.
+type StderrMode string
+
+var (
+	// StderrModeSeparate keeps stderr apart, shown when stdout is empty.
+	StderrModeSeparate StderrMode = "separate"
+	// StderrModeInterleave writes stderr into the output in the order it comes.
+	StderrModeInterleave StderrMode = "interleave"
+	// StderrModeHide discards stderr.
+	StderrModeHide StderrMode = "hide"
+)
+
+// stderrModes is the order the mode goes through at runtime.
+var stderrModes = []StderrMode{StderrModeSeparate, StderrModeInterleave, StderrModeHide}
+
+// next returns the mode which follows in stderrModes.
+func (m StderrMode) next() StderrMode {
+	for i, mode := range stderrModes {
+		if mode == m {
+			return stderrModes[(i+1)%len(stderrModes)]
+		}
+	}
+
+	return stderrModes[0]
+}
+
+// capture is where a run writes stdout and stderr with the mode.
+type capture struct {
+	stdout io.Writer
+	stderr io.Writer
+
+	interleaved *interleavedOutput
+	streams     []*interleavedStream
+}
+
+// newCapture writes stdout to out, and stderr to errOut, out or nowhere as
+// the options say. Escape sequences are stripped from both if the options
+// say so, unless the command runs in a terminal whose output is only
+// stripped once it is interpreted.
+func newCapture(opts runOptions, out *limitedBuffer, errOut io.Writer) *capture {
+	var c *capture
+
+	switch opts.stderr {
+	case StderrModeInterleave:
+		o := newInterleavedOutput(out)
+		streams := []*interleavedStream{o.stream(false), o.stream(true)}
+
+		c = &capture{stdout: streams[0], stderr: streams[1], interleaved: o, streams: streams}
+	case StderrModeHide:
+		c = &capture{stdout: out}
+	default:
+		c = &capture{stdout: out, stderr: errOut}
+	}
+
+	if opts.stripANSI && !opts.pty {
+		c.stdout = newANSIStripper(c.stdout)
+
+		if c.stderr != nil {
+			c.stderr = newANSIStripper(c.stderr)
+		}
+	}
+
+	return c
+}
+
+// finish writes what is left of the streams once the command is done, and
+// tells the snapshot which lines came from stderr.
+func (c *capture) finish(s *Snapshot) {
+	if c.interleaved == nil {
+		return
+	}
+
+	for _, w := range c.streams {
+		w.flush()
+	}
+
+	s.stderrLines = c.interleaved.stderrLines
+}
+
+// maxPartialLine is how much of a line a stream holds back waiting for its
+// end, before it is written anyway.
+const maxPartialLine = 64 * 1024
+
+// interleavedOutput merges stdout and stderr line by line into the output,
+// and remembers which lines came from stderr. Lines are kept whole, so the
+// order is that of their ends rather than exact.
+type interleavedOutput struct {
+	sync.Mutex
+
+	out *limitedBuffer
+
+	line        int
+	midLine     bool
+	stderrLines []int
+}
+
+func newInterleavedOutput(out *limitedBuffer) *interleavedOutput {
+	return &interleavedOutput{out: out}
+}
+
+// stream returns the writer of stdout, or of stderr if stderr is true.
+func (o *interleavedOutput) stream(stderr bool) *interleavedStream {
+	return &interleavedStream{o: o, stderr: stderr}
+}
+
+func (o *interleavedOutput) write(p []byte, stderr bool) {
+	o.Lock()
+	defer o.Unlock()
+
+	for len(p) > 0 {
+		// A line belongs to the stream which started it.
+		if !o.midLine && stderr {
+			o.stderrLines = append(o.stderrLines, o.line)
+		}
+
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			_, _ = o.out.Write(p)
+			o.midLine = true
+
+			return
+		}
+
+		_, _ = o.out.Write(p[:i+1])
+		o.line++
+		o.midLine = false
+		p = p[i+1:]
+	}
+}
+
+type interleavedStream struct {
+	o       *interleavedOutput
+	stderr  bool
+	partial []byte
+}
+
+func (w *interleavedStream) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+
+	if i := bytes.LastIndexByte(w.partial, '\n'); i >= 0 {
+		w.o.write(w.partial[:i+1], w.stderr)
+		w.partial = append(w.partial[:0], w.partial[i+1:]...)
+	}
+
+	if len(w.partial) > maxPartialLine {
+		w.flush()
+	}
+
+	return len(p), nil
+}
+
+// flush writes the rest of a line which did not end.
+func (w *interleavedStream) flush() {
+	if len(w.partial) > 0 {
+		w.o.write(w.partial, w.stderr)
+		w.partial = nil
+	}
+}
+
+// withStderrLines colors the characters of the lines which came from stderr,
+// where the highlights leave them uncolored. Escape sequences are left out.
+func withStderrLines(m highlightMask, text string, lines []int, c tcell.Color) highlightMask {
+	if len(lines) == 0 || c == tcell.ColorDefault {
+		return m
+	}
+
+	textLines := strings.Split(text, "\n")
+
+	if m == nil {
+		m = make(highlightMask, len(textLines))
+	}
+
+	for _, line := range lines {
+		if line >= len(textLines) || line >= len(m) {
+			continue
+		}
+
+		_, cols := withoutEscapes(textLines[line])
+
+		for _, col := range cols {
+			for len(m[line]) <= col {
+				m[line] = append(m[line], tcell.ColorDefault)
+			}
+
+			if m[line][col] == tcell.ColorDefault {
+				m[line][col] = c
+			}
+		}
+	}
+
+	return m
+}
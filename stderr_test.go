@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStderrModeNext(t *testing.T) {
+	assert.Equal(t, StderrModeInterleave, StderrModeSeparate.next())
+	assert.Equal(t, StderrModeHide, StderrModeInterleave.next())
+	assert.Equal(t, StderrModeSeparate, StderrModeHide.next())
+	assert.Equal(t, StderrModeSeparate, StderrMode("").next())
+}
+
+func TestInterleavedOutput(t *testing.T) {
+	o := newInterleavedOutput(newLimitedBuffer(0, 0))
+	stdout, stderr := o.stream(false), o.stream(true)
+
+	_, _ = stdout.Write([]byte("out 1\nout "))
+	_, _ = stderr.Write([]byte("err 1\n"))
+	_, _ = stdout.Write([]byte("2\n"))
+	_, _ = stderr.Write([]byte("err 2"))
+	stdout.flush()
+	stderr.flush()
+
+	// The line of stdout which was cut in two stays whole.
+	assert.Equal(t, "out 1\nerr 1\nout 2\nerr 2", string(o.out.Bytes()))
+	assert.Equal(t, []int{1, 3}, o.stderrLines)
+}
+
+func TestWithStderrLines(t *testing.T) {
+	assert.Nil(t, withStderrLines(nil, "a\nb", nil, tcell.ColorRed))
+	assert.Nil(t, withStderrLines(nil, "a\nb", []int{1}, tcell.ColorDefault))
+
+	m := withStderrLines(nil, "ok\nerr\x1b[0m", []int{1}, tcell.ColorRed)
+	assert.Equal(t, tcell.ColorDefault, m.at(0, 0))
+	assert.Equal(t, tcell.ColorRed, m.at(1, 0))
+	assert.Equal(t, tcell.ColorRed, m.at(1, 2))
+	assert.Equal(t, tcell.ColorDefault, m.at(1, 3))
+
+	// Highlights keep their color.
+	hl := highlightMask{nil, {tcell.ColorDefault, tcell.ColorGreen}}
+	m = withStderrLines(hl, "ok\nerr", []int{1}, tcell.ColorRed)
+	assert.Equal(t, []tcell.Color{tcell.ColorRed, tcell.ColorGreen, tcell.ColorRed}, m[1])
+}
+
+func TestSnapshotStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	command := "echo out; sleep 0.1; echo err >&2; sleep 0.1; echo done"
+
+	tests := []struct {
+		mode        StderrMode
+		result      string
+		errorResult string
+		stderrLines []int
+	}{
+		{mode: StderrModeSeparate, result: "out\ndone\n", errorResult: "err\n"},
+		{mode: StderrModeInterleave, result: "out\nerr\ndone\n", stderrLines: []int{1}},
+		{mode: StderrModeHide, result: "out\ndone\n"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.mode), func(t *testing.T) {
+			s := NewSnapshot(0, command, nil, runOptions{shell: "sh", stderr: tt.mode}, nil, make(chan struct{}))
+			require.NoError(t, s.run(make(chan int64, 1)))
+
+			assert.Equal(t, tt.result, string(s.result))
+			assert.Equal(t, tt.errorResult, string(s.errorResult))
+			assert.Equal(t, tt.stderrLines, s.stderrLines)
+		})
+	}
+}
+
+func TestRenderStderrLines(t *testing.T) {
+	s := &Snapshot{result: []byte("out\nerr\n"), stderrLines: []int{1}, completed: true}
+
+	var b bytes.Buffer
+	require.NoError(t, s.render(&b, false, false, nil, theme{stderrText: tcell.ColorRed}))
+	assert.Equal(t, "out\n[red:]e[-:-:-][red:]r[-:-:-][red:]r[-:-:-]\n", b.String())
+}
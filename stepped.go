@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// intervalStep is an interval which is in effect until some time after the
+// start, or from then on if until is 0.
+type intervalStep struct {
+	interval time.Duration
+	until    time.Duration
+}
+
+// parseIntervalSteps parses a stepped interval like "1s:1m,5s:10m,30s", every
+// step of which is an interval and how long after the start it ends. The
+// bounds must increase, and the last step has none. A single interval gives
+// no steps.
+func parseIntervalSteps(key, value string) ([]intervalStep, error) {
+	if !strings.ContainsAny(value, ",:") {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	steps := make([]intervalStep, 0, len(parts))
+
+	for i, part := range parts {
+		fields := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		bounded := len(fields) == 2
+
+		interval, err := parseInterval(key, fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		last := i == len(parts)-1
+
+		switch {
+		case last && bounded:
+			return nil, durationError{key: key, value: value, reason: "must end with an interval without a bound, such as 30s"}
+		case !last && !bounded:
+			return nil, durationError{key: key, value: value, reason: "needs a bound for every interval but the last, such as 1s:1m"}
+		}
+
+		step := intervalStep{interval: interval}
+
+		if bounded {
+			step.until, err = parseInterval(key, fields[1])
+			if err != nil {
+				return nil, err
+			}
+
+			if step.until <= 0 || i > 0 && step.until <= steps[i-1].until {
+				return nil, durationError{key: key, value: value, reason: "needs bounds which increase"}
+			}
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// formatIntervalSteps writes the steps the way parseIntervalSteps reads them.
+func formatIntervalSteps(steps []intervalStep) string {
+	parts := make([]string, 0, len(steps))
+
+	for _, step := range steps {
+		part := step.interval.String()
+		if step.until > 0 {
+			part += ":" + step.until.String()
+		}
+
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// shortestInterval returns the shortest interval of the steps, or the
+// interval if there are none.
+func shortestInterval(steps []intervalStep, interval time.Duration) time.Duration {
+	for _, step := range steps {
+		if step.interval < interval {
+			interval = step.interval
+		}
+	}
+
+	return interval
+}
+
+// steppedInterval switches to the interval of the next step as the time
+// since the start passes the bound of the current one. A nil stepped
+// interval keeps the interval.
+type steppedInterval struct {
+	sync.Mutex
+
+	steps []intervalStep
+	start time.Time
+	now   func() time.Time
+
+	current int
+
+	// onChange is called after the interval moved on to the next step.
+	onChange func()
+}
+
+func newSteppedInterval(steps []intervalStep, start time.Time) *steppedInterval {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	return &steppedInterval{steps: steps, start: start, now: time.Now}
+}
+
+// currentInterval returns the interval of the step in which the latest
+// interval was taken, without moving on.
+func (s *steppedInterval) currentInterval(interval time.Duration) time.Duration {
+	if s == nil {
+		return interval
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.steps[s.current].interval
+}
+
+// interval returns the interval of the step in effect now.
+func (s *steppedInterval) interval(interval time.Duration) time.Duration {
+	if s == nil {
+		return interval
+	}
+
+	s.Lock()
+	elapsed := s.now().Sub(s.start)
+	before := s.current
+
+	for s.current < len(s.steps)-1 && elapsed >= s.steps[s.current].until {
+		s.current++
+	}
+
+	step := s.steps[s.current]
+	s.Unlock()
+
+	if step != s.steps[before] && s.onChange != nil {
+		s.onChange()
+	}
+
+	return step.interval
+}
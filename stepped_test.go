@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIntervalSteps(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []intervalStep
+		wantErr error
+	}{
+		{s: "2s", want: nil},
+		{
+			s: "1s:1m,5:10m,1.5m",
+			want: []intervalStep{
+				{interval: time.Second, until: time.Minute},
+				{interval: 5 * time.Second, until: 10 * time.Minute},
+				{interval: 90 * time.Second},
+			},
+		},
+		{
+			s: "1s:1m,5s:10m",
+			wantErr: durationError{
+				key: "interval", value: "1s:1m,5s:10m", reason: "must end with an interval without a bound, such as 30s",
+			},
+		},
+		{
+			s: "1s,30s",
+			wantErr: durationError{
+				key: "interval", value: "1s,30s", reason: "needs a bound for every interval but the last, such as 1s:1m",
+			},
+		},
+		{
+			s:       "1s:10m,5s:1m,30s",
+			wantErr: durationError{key: "interval", value: "1s:10m,5s:1m,30s", reason: "needs bounds which increase"},
+		},
+		{s: "1s:1x,30s", wantErr: durationError{key: "interval", value: "1x"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseIntervalSteps("interval", tt.s)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, "1s:1m0s,5s:10m0s,1m30s", formatIntervalSteps([]intervalStep{
+		{interval: time.Second, until: time.Minute},
+		{interval: 5 * time.Second, until: 10 * time.Minute},
+		{interval: 90 * time.Second},
+	}))
+}
+
+func TestSteppedInterval(t *testing.T) {
+	start := time.Now()
+	now := start
+
+	s := newSteppedInterval([]intervalStep{
+		{interval: time.Second, until: time.Minute},
+		{interval: 5 * time.Second, until: 10 * time.Minute},
+		{interval: 30 * time.Second},
+	}, start)
+	s.now = func() time.Time { return now }
+
+	var changes int
+	s.onChange = func() { changes++ }
+
+	assert.Equal(t, time.Second, s.interval(2*time.Second))
+
+	now = start.Add(time.Minute)
+	assert.Equal(t, 5*time.Second, s.interval(2*time.Second))
+	assert.Equal(t, 5*time.Second, s.currentInterval(2*time.Second))
+
+	now = start.Add(time.Hour)
+	assert.Equal(t, 30*time.Second, s.interval(2*time.Second))
+	assert.Equal(t, 2, changes)
+
+	var none *steppedInterval
+	assert.Equal(t, 2*time.Second, none.interval(2*time.Second))
+	assert.Nil(t, newSteppedInterval(nil, start))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// StreamPolicy decides what happens to a run which keeps writing output
+// without exiting, like tail -f does.
+type StreamPolicy string
+
+var (
+	// StreamPolicyLive shows the output of the run as it comes in.
+	StreamPolicyLive StreamPolicy = "live"
+	// StreamPolicyCut kills the run and keeps the output it wrote.
+	StreamPolicyCut StreamPolicy = "cut"
+)
+
+// streamCheckInterval is how often a run past its grace is checked for
+// output, and how often the output of a live one is shown again.
+const streamCheckInterval = 500 * time.Millisecond
+
+// watchStream waits for the run to go on past the grace with some output,
+// and then either cuts it short or calls live every streamCheckInterval
+// until it finishes, depending on the policy.
+func watchStream(s *Snapshot, grace time.Duration, policy StreamPolicy, live func()) {
+	time.Sleep(grace)
+
+	ticker := time.NewTicker(streamCheckInterval)
+	defer ticker.Stop()
+
+	for ; !s.isCompleted(); <-ticker.C {
+		if len(s.partialOutput()) == 0 {
+			continue
+		}
+
+		if policy == StreamPolicyCut {
+			s.cutStream()
+
+			return
+		}
+
+		s.Lock()
+		s.streaming = true
+		s.Unlock()
+
+		live()
+	}
+}
+
+// partialOutput returns the output the command wrote so far, while it runs.
+func (s *Snapshot) partialOutput() []byte {
+	s.Lock()
+	live := s.live
+	s.Unlock()
+
+	if live == nil {
+		return nil
+	}
+
+	return s.terminalOutput(live.partial())
+}
+
+// completeLines returns the lines of the output which are written whole,
+// leaving out the last one while the command is still writing it.
+func completeLines(output []byte) []byte {
+	return output[:bytes.LastIndexByte(output, '\n')+1]
+}
+
+// isStreaming tells whether the run is going on with its output shown live.
+func (s *Snapshot) isStreaming() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.streaming && !s.completed
+}
+
+// cutStream kills the run, which keeps the output written so far.
+func (s *Snapshot) cutStream() {
+	s.Lock()
+	s.streamCut = true
+	s.Unlock()
+
+	s.kill()
+}
+
+// streamNotice describes the run started at the time whose output is shown
+// while it goes on.
+func streamNotice(started time.Time) string {
+	return fmt.Sprintf("still running for %s, showing the output so far", time.Since(started).Truncate(time.Second))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchStreamCut(t *testing.T) {
+	s := &Snapshot{live: newLimitedBuffer(0, 0)}
+	_, _ = s.live.Write([]byte("64 bytes from 127.0.0.1\n"))
+
+	watchStream(s, time.Millisecond, StreamPolicyCut, func() { t.Error("a cut run is not shown live") })
+
+	assert.True(t, s.streamCut)
+	assert.True(t, s.isKilled())
+	assert.False(t, s.isStreaming())
+}
+
+func TestWatchStreamLive(t *testing.T) {
+	s := &Snapshot{live: newLimitedBuffer(0, 0)}
+
+	var shown int64
+
+	done := make(chan struct{})
+	go func() {
+		watchStream(s, time.Millisecond, StreamPolicyLive, func() { atomic.AddInt64(&shown, 1) })
+		close(done)
+	}()
+
+	// Without output the run is only slow.
+	time.Sleep(streamCheckInterval + 100*time.Millisecond)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&shown))
+	assert.False(t, s.isStreaming())
+
+	_, _ = s.live.Write([]byte("Jan 01 00:00:00 host kernel: started\n"))
+	time.Sleep(streamCheckInterval + 100*time.Millisecond)
+	assert.True(t, s.isStreaming())
+	assert.Equal(t, "Jan 01 00:00:00 host kernel: started\n", string(s.partialOutput()))
+
+	s.complete()
+	<-done
+
+	assert.NotZero(t, atomic.LoadInt64(&shown))
+	assert.False(t, s.isStreaming())
+	assert.False(t, s.isKilled())
+}
+
+func TestCompleteLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "nothing", output: "", want: ""},
+		{name: "whole lines", output: "a\nb\n", want: "a\nb\n"},
+		{name: "line being written", output: "a\nb\nsca", want: "a\nb\n"},
+		{name: "no whole line yet", output: "scanning", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(completeLines([]byte(tt.output))))
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/term"
+)
+
+// suspendableScreen leaves the terminal alone while it is suspended, so that
+// the panes go on updating without writing over the shell which has it. It is
+// only used in the event loop.
+type suspendableScreen struct {
+	tcell.Screen
+	suspended bool
+}
+
+func (s *suspendableScreen) Suspend() error {
+	if err := s.Screen.Suspend(); err != nil {
+		return err
+	}
+
+	s.suspended = true
+
+	return nil
+}
+
+func (s *suspendableScreen) Resume() error {
+	s.suspended = false
+
+	return s.Screen.Resume()
+}
+
+func (s *suspendableScreen) Show() {
+	if !s.suspended {
+		s.Screen.Show()
+	}
+}
+
+func (s *suspendableScreen) Sync() {
+	if !s.suspended {
+		s.Screen.Sync()
+	}
+}
+
+func (s *suspendableScreen) Beep() error {
+	if s.suspended {
+		return nil
+	}
+
+	return s.Screen.Beep()
+}
+
+// newScreen makes and initializes the screen of the app.
+func newScreen(forceTruecolor bool) (tcell.Screen, error) {
+	if forceTruecolor {
+		return newTruecolorScreen()
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+
+	return screen, nil
+}
+
+// interactiveShell is the shell to suspend to: $SHELL, or general.shell if
+// it is not set.
+func interactiveShell(fallback string) string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+
+	return fallback
+}
+
+// runShell runs the shell on the terminal until it exits. The terminal is
+// put back the way it was, even if the shell left it in raw mode.
+func runShell(shell string) error {
+	fd := int(os.Stdin.Fd())
+	if state, err := term.GetState(fd); err == nil {
+		defer func() { _ = term.Restore(fd, state) }()
+	}
+
+	// Ctrl-C and Ctrl-\ in the shell are meant for what runs there.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGQUIT)
+
+	defer signal.Stop(interrupt)
+
+	cmd := exec.Command(shell)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// suspendToShell gives the terminal to an interactive shell and takes it back
+// when the shell exits, with the panes as they were. Runs go on meanwhile,
+// unless pause is set.
+func (g *paneGroup) suspendToShell(shell string, pause bool) {
+	if g.screen.suspended {
+		return
+	}
+
+	if err := g.screen.Suspend(); err != nil {
+		g.focusedPane().setMessage(err.Error())
+
+		return
+	}
+
+	if pause {
+		for _, v := range g.panes {
+			v.holdRuns()
+		}
+	}
+
+	go func() {
+		err := runShell(shell)
+
+		g.app.QueueUpdateDraw(func() {
+			// Quitting keeps the runs held.
+			if g.hurry == nil {
+				for _, v := range g.panes {
+					v.releaseRuns()
+				}
+			}
+
+			// Not much can be done if the terminal cannot be taken back.
+			_ = g.screen.Resume()
+			g.screen.Sync()
+
+			focused := g.focusedPane()
+			if err != nil {
+				focused.log(levelWarn, "shell failed", "shell", shell, "error", err)
+				focused.setMessage(fmt.Sprintf("%s: %v", shell, err))
+
+				return
+			}
+
+			focused.log(levelInfo, "shell exited", "shell", shell)
+		})
+	}()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuspendableScreen(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	assert.NoError(t, sim.Init())
+	sim.SetSize(10, 1)
+
+	screen := &suspendableScreen{Screen: sim}
+	shown := func() string {
+		cells, _, _ := sim.GetContents()
+
+		return string(cells[0].Runes)
+	}
+
+	assert.NoError(t, screen.Suspend())
+	screen.SetContent(0, 0, 'a', nil, tcell.StyleDefault)
+	screen.Show()
+	assert.NotEqual(t, "a", shown())
+
+	assert.NoError(t, screen.Resume())
+	screen.Show()
+	assert.Equal(t, "a", shown())
+}
+
+func TestInteractiveShell(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+	assert.Equal(t, "/bin/zsh", interactiveShell("sh"))
+
+	t.Setenv("SHELL", "")
+	assert.Equal(t, "sh", interactiveShell("sh"))
+}
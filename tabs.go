@@ -0,0 +1,59 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// defaultTabWidth is how far apart the tab stops are, like in terminals.
+const defaultTabWidth = 8
+
+var leadingEscape = regexp.MustCompile("^" + ansiEscape.String())
+
+// expandTabs replaces every tab with the spaces up to the next tab stop, so
+// that columns line up like in a terminal. Escape sequences take no columns.
+func expandTabs(text string, width int) string {
+	if width <= 0 || !strings.Contains(text, "\t") {
+		return text
+	}
+
+	var b strings.Builder
+
+	b.Grow(len(text))
+
+	column := 0
+
+	for i := 0; i < len(text); {
+		switch c := text[i]; c {
+		case '\t':
+			n := width - column%width
+			b.WriteString(strings.Repeat(" ", n))
+			column += n
+			i++
+		case '\n', '\r':
+			b.WriteByte(c)
+			column = 0
+			i++
+		case '\x1b':
+			if loc := leadingEscape.FindStringIndex(text[i:]); loc != nil {
+				b.WriteString(text[i : i+loc[1]])
+				i += loc[1]
+
+				continue
+			}
+
+			b.WriteByte(c)
+			i++
+		default:
+			r, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteString(text[i : i+size])
+			column += runewidth.RuneWidth(r)
+			i += size
+		}
+	}
+
+	return b.String()
+}
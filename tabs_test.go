@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{name: "no tabs", text: "a b\n", width: 8, want: "a b\n"},
+		{name: "to the next stop", text: "a\tb\nabcdefgh\tc\n", width: 8, want: "a       b\nabcdefgh        c\n"},
+		{name: "narrow stops", text: "NAME\tSIZE\nsda1\t5G\n", width: 4, want: "NAME    SIZE\nsda1    5G\n"},
+		{name: "escape sequences", text: "\x1b[31mab\x1b[0m\tc", width: 4, want: "\x1b[31mab\x1b[0m  c"},
+		{name: "wide characters", text: "日本\tx", width: 8, want: "日本    x"},
+		{name: "carriage return", text: "ab\r\n\tc", width: 4, want: "ab\r\n    c"},
+		{name: "unset width", text: "a\tb", width: 0, want: "a\tb"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expandTabs(tt.text, tt.width))
+		})
+	}
+}
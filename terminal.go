@@ -0,0 +1,89 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var csiSequence = regexp.MustCompile(`^\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// normalizeTerminalOutput interprets the carriage returns and cursor movements
+// a program writes to a terminal, keeping only the final state of each line.
+// Color sequences are kept, other escape sequences are dropped.
+func normalizeTerminalOutput(b []byte) []byte {
+	var (
+		out     strings.Builder
+		pending strings.Builder
+		line    []string // a cell is a rune with the color sequences before it
+		col     int
+	)
+
+	flush := func() {
+		out.WriteString(strings.Join(line, ""))
+		out.WriteString(pending.String())
+		pending.Reset()
+
+		line = line[:0]
+		col = 0
+	}
+
+	s := string(b)
+
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			seq := csiSequence.FindString(s[i:])
+			if seq == "" {
+				// Not a CSI sequence, drop ESC and the following byte.
+				i += 2
+
+				continue
+			}
+
+			i += len(seq)
+
+			switch seq[len(seq)-1] {
+			case 'm':
+				pending.WriteString(seq)
+			case 'K':
+				if col < len(line) {
+					line = line[:col]
+				}
+			}
+
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch r {
+		case '\r':
+			col = 0
+		case '\n':
+			flush()
+			out.WriteByte('\n')
+		case '\b':
+			if col > 0 {
+				col--
+			}
+		default:
+			cell := pending.String() + string(r)
+			pending.Reset()
+
+			if col < len(line) {
+				line[col] = cell
+			} else {
+				line = append(line, cell)
+			}
+
+			col++
+		}
+	}
+
+	if len(line) > 0 || pending.Len() > 0 {
+		flush()
+	}
+
+	return []byte(out.String())
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTerminalOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "crlf",
+			in:   "a\r\nb\r\n",
+			want: "a\nb\n",
+		},
+		{
+			name: "carriage return overwrites the line",
+			in:   "10%\r50%\r100%\r\n",
+			want: "100%\n",
+		},
+		{
+			name: "shorter overwrite keeps the rest",
+			in:   "abcdef\rxy\n",
+			want: "xycdef\n",
+		},
+		{
+			name: "erase line",
+			in:   "abcdef\r\x1b[Kxy\n",
+			want: "xy\n",
+		},
+		{
+			name: "colors are kept",
+			in:   "\x1b[31mred\x1b[0m\n",
+			want: "\x1b[31mred\x1b[0m\n",
+		},
+		{
+			name: "cursor movement is dropped",
+			in:   "a\x1b[2Ab\n",
+			want: "ab\n",
+		},
+		{
+			name: "backspace",
+			in:   "ab\bc\n",
+			want: "ac\n",
+		},
+		{
+			name: "no trailing newline",
+			in:   "a\r\nb",
+			want: "a\nb",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(normalizeTerminalOutput([]byte(tt.in))))
+		})
+	}
+}
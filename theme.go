@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// themePresets are palettes of the color keys, selected by color.preset or --theme.
+var themePresets = map[string]map[string]string{
+	"dark": {
+		"background":               "black",
+		"contrast_background":      "navy",
+		"more_contrast_background": "green",
+		"border":                   "gray",
+		"title":                    "white",
+		"graphics":                 "white",
+		"text":                     "white",
+		"secondary_text":           "yellow",
+		"tertiary_text":            "green",
+		"inverse_text":             "navy",
+		"contrast_secondary_text":  "darkcyan",
+		"diff_added":               "green",
+		"diff_removed":             "maroon",
+		"diff_changed_background":  "green",
+		"diff_changed_foreground":  "black",
+		"diff_moved":               "navy",
+		"stderr_text":              "red",
+		"error_text":               "orangered",
+		"flash":                    "yellow",
+	},
+	"light": {
+		"background":               "white",
+		"contrast_background":      "silver",
+		"more_contrast_background": "lightgray",
+		"border":                   "gray",
+		"title":                    "black",
+		"graphics":                 "black",
+		"text":                     "black",
+		"secondary_text":           "navy",
+		"tertiary_text":            "darkgreen",
+		"inverse_text":             "white",
+		"contrast_secondary_text":  "darkblue",
+		"diff_added":               "#c6efce",
+		"diff_removed":             "#ffc7ce",
+		"diff_changed_background":  "#ffeb9c",
+		"diff_changed_foreground":  "black",
+		"diff_moved":               "#bdd7ee",
+		"stderr_text":              "maroon",
+		"error_text":               "red",
+		"flash":                    "#ffeb9c",
+	},
+	"solarized-dark": {
+		"background":               "#002b36",
+		"contrast_background":      "#073642",
+		"more_contrast_background": "#586e75",
+		"border":                   "#586e75",
+		"title":                    "#93a1a1",
+		"graphics":                 "#586e75",
+		"text":                     "#839496",
+		"secondary_text":           "#b58900",
+		"tertiary_text":            "#2aa198",
+		"inverse_text":             "#002b36",
+		"contrast_secondary_text":  "#268bd2",
+		"diff_added":               "#859900",
+		"diff_removed":             "#dc322f",
+		"diff_changed_background":  "#b58900",
+		"diff_changed_foreground":  "#002b36",
+		"diff_moved":               "#268bd2",
+		"stderr_text":              "#dc322f",
+		"error_text":               "#cb4b16",
+		"flash":                    "#b58900",
+	},
+	"solarized-light": {
+		"background":               "#fdf6e3",
+		"contrast_background":      "#eee8d5",
+		"more_contrast_background": "#93a1a1",
+		"border":                   "#93a1a1",
+		"title":                    "#586e75",
+		"graphics":                 "#93a1a1",
+		"text":                     "#657b83",
+		"secondary_text":           "#b58900",
+		"tertiary_text":            "#2aa198",
+		"inverse_text":             "#fdf6e3",
+		"contrast_secondary_text":  "#268bd2",
+		"diff_added":               "#859900",
+		"diff_removed":             "#dc322f",
+		"diff_changed_background":  "#b58900",
+		"diff_changed_foreground":  "#fdf6e3",
+		"diff_moved":               "#268bd2",
+		"stderr_text":              "#dc322f",
+		"error_text":               "#cb4b16",
+		"flash":                    "#b58900",
+	},
+	"nord": {
+		"background":               "#2e3440",
+		"contrast_background":      "#3b4252",
+		"more_contrast_background": "#434c5e",
+		"border":                   "#4c566a",
+		"title":                    "#88c0d0",
+		"graphics":                 "#4c566a",
+		"text":                     "#d8dee9",
+		"secondary_text":           "#ebcb8b",
+		"tertiary_text":            "#a3be8c",
+		"inverse_text":             "#2e3440",
+		"contrast_secondary_text":  "#81a1c1",
+		"diff_added":               "#a3be8c",
+		"diff_removed":             "#bf616a",
+		"diff_changed_background":  "#ebcb8b",
+		"diff_changed_foreground":  "#2e3440",
+		"diff_moved":               "#5e81ac",
+		"stderr_text":              "#bf616a",
+		"error_text":               "#d08770",
+		"flash":                    "#ebcb8b",
+	},
+}
+
+type themePresetError struct {
+	name string
+}
+
+func (e themePresetError) Error() string {
+	names := make([]string, 0, len(themePresets))
+	for name := range themePresets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return fmt.Sprintf("unknown theme preset %q, available presets are %s", e.name, strings.Join(names, ", "))
+}
+
+// applyThemePreset makes the colors of the preset the defaults, so that
+// the color keys of the config file still override them.
+func applyThemePreset(v *viper.Viper, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	preset, ok := themePresets[name]
+	if !ok {
+		return themePresetError{name: name}
+	}
+
+	for key, color := range preset {
+		v.SetDefault("color."+key, color)
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThemePresets(t *testing.T) {
+	for name, preset := range themePresets {
+		for key, color := range preset {
+			assert.NotEqual(t, tcell.ColorDefault, tcell.GetColor(color), "%s: color.%s", name, key)
+		}
+	}
+}
+
+func TestThemePresetError(t *testing.T) {
+	assert.EqualError(t, themePresetError{name: "solarized"},
+		`unknown theme preset "solarized", available presets are dark, light, nord, solarized-dark, solarized-light`)
+}
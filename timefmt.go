@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Layouts of the places which show times, unless time_format replaces them.
+const (
+	clockLayout        = "2006-01-02 15:04:05"
+	shortTimeLayout    = "15:04:05"
+	snapshotListLayout = "15:04:05.000"
+)
+
+// timeLayoutAliases are the names time_format takes besides a layout of
+// the reference time.
+var timeLayoutAliases = map[string]string{
+	"iso8601": "2006-01-02T15:04:05Z07:00",
+	"rfc3339": time.RFC3339,
+	"kitchen": time.Kitchen,
+}
+
+// timeFormat is how the times of the clock and of the snapshots are shown.
+type timeFormat struct {
+	// layout replaces the layout of every place if not empty.
+	layout string
+	loc    *time.Location
+}
+
+type timeFormatError struct {
+	key    string
+	value  string
+	reason string
+}
+
+func (e timeFormatError) Error() string {
+	return fmt.Sprintf("%s: %q %s", e.key, e.value, e.reason)
+}
+
+// parseTimeFormat parses the layout, or one of its aliases, and the time
+// zone, which is "Local", "UTC" or a name of the IANA database.
+func parseTimeFormat(layout, zone string) (timeFormat, error) {
+	if alias, ok := timeLayoutAliases[strings.ToLower(layout)]; ok {
+		layout = alias
+	}
+
+	// A layout without any element of the reference time shows no time.
+	if layout != "" && time.Unix(0, 0).Format(layout) == layout {
+		return timeFormat{}, timeFormatError{
+			key:    "general.time_format",
+			value:  layout,
+			reason: `is not a layout of the reference time "Mon Jan 2 15:04:05 MST 2006"`,
+		}
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return timeFormat{}, timeFormatError{key: "general.time_zone", value: zone, reason: "is not a known time zone"}
+	}
+
+	return timeFormat{layout: layout, loc: loc}, nil
+}
+
+// in returns the time in the time zone.
+func (f timeFormat) in(t time.Time) time.Time {
+	if f.loc == nil {
+		return t
+	}
+
+	return t.In(f.loc)
+}
+
+// format shows the time in the layout, or in the given one of the place if
+// there is none.
+func (f timeFormat) format(t time.Time, layout string) string {
+	if f.layout != "" {
+		layout = f.layout
+	}
+
+	return f.in(t).Format(layout)
+}
+
+// width returns how wide the times of the place are at most.
+func (f timeFormat) width(layout string) int {
+	widest := time.Date(2006, time.September, 27, 23, 59, 59, 999999999, time.UTC)
+
+	return len([]rune(f.format(widest, layout)))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeFormat(t *testing.T) {
+	f, err := parseTimeFormat("iso8601", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, timeFormat{layout: "2006-01-02T15:04:05Z07:00", loc: time.UTC}, f)
+
+	f, err = parseTimeFormat("03:04:05 PM", "Local")
+	require.NoError(t, err)
+	assert.Equal(t, timeFormat{layout: "03:04:05 PM", loc: time.Local}, f)
+
+	f, err = parseTimeFormat("", "Asia/Tokyo")
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", f.loc.String())
+
+	_, err = parseTimeFormat("today", "Local")
+	assert.EqualError(t, err, `general.time_format: "today" is not a layout of the reference time "Mon Jan 2 15:04:05 MST 2006"`)
+
+	_, err = parseTimeFormat("", "Europe/Atlantis")
+	assert.EqualError(t, err, `general.time_zone: "Europe/Atlantis" is not a known time zone`)
+}
+
+func TestTimeFormatFormat(t *testing.T) {
+	at := time.Date(2021, time.March, 4, 17, 5, 6, 0, time.UTC)
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	assert.Equal(t, "17:05:06", timeFormat{loc: time.UTC}.format(at, shortTimeLayout))
+	assert.Equal(t, "2021-03-05 02:05:06", timeFormat{loc: tokyo}.format(at, clockLayout))
+	assert.Equal(t, "5:05PM", timeFormat{layout: time.Kitchen, loc: time.UTC}.format(at, clockLayout))
+	assert.Equal(t, at, timeFormat{}.in(at))
+}
+
+func TestTimeFormatWidth(t *testing.T) {
+	assert.Equal(t, 19, timeFormat{loc: time.UTC}.width(clockLayout))
+	assert.Equal(t, 12, timeFormat{loc: time.UTC}.width(snapshotListLayout))
+	assert.Equal(t, 7, timeFormat{layout: time.Kitchen, loc: time.UTC}.width(clockLayout))
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// timelineTickSteps are the round wall-clock durations the ticks of the
+// timeline can be apart.
+var timelineTickSteps = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// timelineTickGap is the fewest columns between two ticks.
+const timelineTickGap = 8
+
+type timelineCell int
+
+// The cells of the timeline, a cell showing the first that applies to its
+// snapshots in the reverse order of these.
+const (
+	timelineEmpty timelineCell = iota
+	timelineLine
+	timelineTick
+	timelineChanged
+	timelineFailed
+	timelineSwitched
+	timelineCurrent
+)
+
+// timelineMarks are the sorted ids of the snapshots whose output changed, of
+// those which failed and of the first ones of an edited command. They are kept apart from the snapshots, so that the
+// timeline finds them by a binary search for each column.
+type timelineMarks struct {
+	sync.Mutex
+
+	changed  []int64
+	failed   []int64
+	switched []int64
+}
+
+func (m *timelineMarks) addChanged(id int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.changed = insertID(m.changed, id)
+}
+
+func (m *timelineMarks) addFailed(id int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.failed = insertID(m.failed, id)
+}
+
+func (m *timelineMarks) addSwitched(id int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.switched = insertID(m.switched, id)
+}
+
+// insertID inserts the id into the sorted ids. Runs mostly finish in order,
+// so it is mostly appended.
+func insertID(ids []int64, id int64) []int64 {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	if i < len(ids) && ids[i] == id {
+		return ids
+	}
+
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+
+	return ids
+}
+
+// hasIDIn reports whether any of the sorted ids is in [from, to).
+func hasIDIn(ids []int64, from, to int64) bool {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= from })
+
+	return i < len(ids) && ids[i] < to
+}
+
+// timeline is a bar of one row for the whole history, marking the snapshot
+// shown, round wall-clock times, the snapshots which changed or failed and
+// where the command was edited.
+// Snapshots are bucketed into the columns, so a long history draws as fast
+// as a short one.
+type timeline struct {
+	*tview.Box
+
+	ids     func() []int64
+	current func() int64
+	marks   *timelineMarks
+
+	// begin is the time the ids count the milliseconds from.
+	begin time.Time
+
+	changedColor  tcell.Color
+	failedColor   tcell.Color
+	switchedColor tcell.Color
+}
+
+func newTimeline(ids func() []int64, current func() int64, marks *timelineMarks, begin time.Time) *timeline {
+	return &timeline{
+		Box:           tview.NewBox(),
+		ids:           ids,
+		current:       current,
+		marks:         marks,
+		begin:         begin,
+		changedColor:  tcell.ColorGreen,
+		failedColor:   tcell.ColorYellow,
+		switchedColor: tcell.ColorAqua,
+	}
+}
+
+// timelineCells buckets the sorted ids into width columns from the first to
+// the last one, and returns what each column shows.
+func timelineCells(ids []int64, current int64, changed, failed, switched []int64, begin time.Time, width int) []timelineCell {
+	cells := make([]timelineCell, width)
+	if width <= 0 || len(ids) == 0 {
+		return cells
+	}
+
+	first, last := ids[0], ids[len(ids)-1]
+	span := last - first
+
+	column := func(id int64) int {
+		if span == 0 {
+			return 0
+		}
+
+		c := int((id - first) * int64(width) / span)
+		if c < 0 {
+			return 0
+		}
+
+		if c >= width {
+			return width - 1
+		}
+
+		return c
+	}
+
+	// start returns the first id in the column, which is the smallest one
+	// column gives it.
+	start := func(c int) int64 {
+		if c >= width {
+			return last + 1
+		}
+
+		if span == 0 {
+			if c == 0 {
+				return first
+			}
+
+			return last + 1
+		}
+
+		return first + (int64(c)*span+int64(width)-1)/int64(width)
+	}
+
+	for c := range cells {
+		from, to := start(c), start(c+1)
+
+		if hasIDIn(ids, from, to) {
+			cells[c] = timelineLine
+		}
+	}
+
+	for _, id := range timelineTicks(begin.Add(time.Duration(first)*time.Millisecond), time.Duration(span)*time.Millisecond, width) {
+		if c := column(id.Sub(begin).Milliseconds()); cells[c] < timelineTick {
+			cells[c] = timelineTick
+		}
+	}
+
+	for c := range cells {
+		from, to := start(c), start(c+1)
+
+		switch {
+		case hasIDIn(switched, from, to):
+			cells[c] = timelineSwitched
+		case hasIDIn(failed, from, to):
+			cells[c] = timelineFailed
+		case hasIDIn(changed, from, to):
+			cells[c] = timelineChanged
+		}
+	}
+
+	if current >= first && current <= last {
+		cells[column(current)] = timelineCurrent
+	}
+
+	return cells
+}
+
+// timelineTicks returns the round wall-clock times within the span from the
+// start, at the shortest step which keeps the ticks timelineTickGap columns apart.
+func timelineTicks(start time.Time, span time.Duration, width int) []time.Time {
+	if span <= 0 || width <= 0 {
+		return nil
+	}
+
+	var step time.Duration
+
+	for _, s := range timelineTickSteps {
+		if s*time.Duration(width) >= span*timelineTickGap {
+			step = s
+
+			break
+		}
+	}
+
+	if step == 0 {
+		return nil
+	}
+
+	// Round in local time, so that the ticks of hours and days fall on
+	// those of the clock.
+	_, offset := start.Zone()
+	shift := time.Duration(offset) * time.Second
+
+	t := start.Add(shift).Truncate(step).Add(-shift)
+	if t.Before(start) {
+		t = t.Add(step)
+	}
+
+	var ticks []time.Time
+
+	for end := start.Add(span); !t.After(end); t = t.Add(step) {
+		ticks = append(ticks, t)
+	}
+
+	return ticks
+}
+
+// timelineAge formats how far the shown snapshot is behind the latest one.
+func timelineAge(d time.Duration) string {
+	d = d.Round(time.Second)
+
+	switch {
+	case d <= 0:
+		return "latest"
+	case d < time.Minute:
+		return fmt.Sprintf("-%ds", d/time.Second)
+	case d < time.Hour:
+		return fmt.Sprintf("-%dm%02ds", d/time.Minute, d%time.Minute/time.Second)
+	default:
+		return fmt.Sprintf("-%dh%02dm", d/time.Hour, d%time.Hour/time.Minute)
+	}
+}
+
+func (t *timeline) Draw(screen tcell.Screen) {
+	t.Box.DrawForSubclass(screen, t)
+
+	x, y, width, height := t.GetInnerRect()
+	if height <= 0 || width <= 0 {
+		return
+	}
+
+	ids := t.ids()
+	if len(ids) == 0 {
+		return
+	}
+
+	current := t.current()
+
+	label := " " + timelineAge(time.Duration(ids[len(ids)-1]-current)*time.Millisecond)
+	if len(label) < width {
+		width -= len(label)
+		tview.Print(screen, label, x+width, y, len(label), tview.AlignLeft, tview.Styles.SecondaryTextColor)
+	}
+
+	t.marks.Lock()
+	cells := timelineCells(ids, current, t.marks.changed, t.marks.failed, t.marks.switched, t.begin, width)
+	t.marks.Unlock()
+
+	for i, cell := range cells {
+		r, color := ' ', tview.Styles.BorderColor
+
+		switch cell {
+		case timelineEmpty:
+		case timelineLine:
+			r = '─'
+		case timelineTick:
+			r, color = '┼', tview.Styles.TertiaryTextColor
+		case timelineChanged:
+			r, color = '•', t.changedColor
+		case timelineFailed:
+			r, color = '•', t.failedColor
+		case timelineSwitched:
+			r, color = '◆', t.switchedColor
+		case timelineCurrent:
+			r, color = '█', tview.Styles.PrimaryTextColor
+		}
+
+		screen.SetContent(x+i, y, r, nil, tcell.StyleDefault.Foreground(color).Background(tview.Styles.PrimitiveBackgroundColor))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimelineCells(t *testing.T) {
+	// Between round times, so that there are no ticks.
+	begin := time.Date(2021, 9, 4, 12, 0, 0, int(500*time.Millisecond), time.UTC)
+	ids := []int64{0, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000}
+
+	cells := timelineCells(ids, 7000, []int64{2000, 3000}, []int64{3000}, nil, begin, 10)
+	assert.Equal(t, []timelineCell{
+		timelineLine, timelineLine, timelineChanged, timelineFailed, timelineLine,
+		timelineLine, timelineLine, timelineCurrent, timelineLine, timelineLine,
+	}, cells)
+
+	// Where the command was edited shows over the rest.
+	cells = timelineCells(ids, 9000, []int64{3000}, []int64{3000}, []int64{3000}, begin, 10)
+	assert.Equal(t, timelineSwitched, cells[3])
+
+	// Columns without snapshots are left empty.
+	cells = timelineCells([]int64{0, 9000}, 9000, nil, nil, nil, begin, 10)
+	assert.Equal(t, timelineLine, cells[0])
+	assert.Equal(t, timelineEmpty, cells[5])
+	assert.Equal(t, timelineCurrent, cells[9])
+
+	cells = timelineCells([]int64{42}, 42, nil, nil, nil, begin, 10)
+	assert.Equal(t, timelineCurrent, cells[0])
+	assert.Equal(t, timelineEmpty, cells[1])
+
+	assert.Len(t, timelineCells(nil, -1, nil, nil, nil, begin, 10), 10)
+}
+
+func TestTimelineCellsLongHistory(t *testing.T) {
+	ids := make([]int64, 100000)
+	for i := range ids {
+		ids[i] = int64(i) * 2000
+	}
+
+	cells := timelineCells(ids, ids[50000], []int64{ids[99999]}, nil, nil, time.Now(), 80)
+	require.Len(t, cells, 80)
+	assert.Equal(t, timelineCurrent, cells[40])
+	assert.Equal(t, timelineChanged, cells[79])
+
+	for _, cell := range cells {
+		assert.NotEqual(t, timelineEmpty, cell)
+	}
+}
+
+func TestTimelineTicks(t *testing.T) {
+	start := time.Date(2021, 9, 4, 12, 3, 20, 0, time.UTC)
+
+	ticks := timelineTicks(start, time.Hour, 80)
+	require.Len(t, ticks, 6)
+	assert.Equal(t, time.Date(2021, 9, 4, 12, 10, 0, 0, time.UTC), ticks[0])
+	assert.Equal(t, 10*time.Minute, ticks[1].Sub(ticks[0]))
+
+	// Hours are those of the local clock.
+	zone := time.FixedZone("", 5*3600+1800)
+	ticks = timelineTicks(time.Date(2021, 9, 4, 12, 3, 20, 0, zone), 3*time.Hour, 24)
+	require.Len(t, ticks, 3)
+	assert.Equal(t, time.Date(2021, 9, 4, 13, 0, 0, 0, zone), ticks[0])
+
+	assert.Empty(t, timelineTicks(start, 0, 80))
+}
+
+func TestTimelineAge(t *testing.T) {
+	assert.Equal(t, "latest", timelineAge(0))
+	assert.Equal(t, "-42s", timelineAge(42*time.Second))
+	assert.Equal(t, "-2m05s", timelineAge(2*time.Minute+5*time.Second))
+	assert.Equal(t, "-2h03m", timelineAge(2*time.Hour+3*time.Minute))
+}
+
+func TestTimelineDraw(t *testing.T) {
+	ids := []int64{0, 60000, 120000}
+	marks := &timelineMarks{}
+	marks.addFailed(120000)
+
+	tl := newTimeline(func() []int64 { return ids }, func() int64 { return 0 }, marks, time.Now())
+
+	screen := tcell.NewSimulationScreen("")
+	require.NoError(t, screen.Init())
+	screen.SetSize(30, 1)
+	tl.SetRect(0, 0, 30, 1)
+
+	tl.Draw(screen)
+
+	r, _, _, _ := screen.GetContent(0, 0)
+	assert.Equal(t, '█', r)
+
+	r, _, style, _ := screen.GetContent(22, 0)
+	fg, _, _ := style.Decompose()
+	assert.Equal(t, '•', r)
+	assert.Equal(t, tcell.ColorYellow, fg)
+
+	var label []rune
+
+	for x := 23; x < 30; x++ {
+		r, _, _, _ := screen.GetContent(x, 0)
+		label = append(label, r)
+	}
+
+	assert.Equal(t, " -2m00s", string(label))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type timeTargetError struct {
+	input string
+}
+
+func (e timeTargetError) Error() string {
+	return fmt.Sprintf(`cannot parse %q, try "14:35", "14:35:10", "2024-03-01 14:35" or "-15m"`, e.input)
+}
+
+var (
+	clockLayouts = []string{"15:04", "15:04:05"}
+	dateLayouts  = []string{"2006-01-02 15:04", "2006-01-02 15:04:05"}
+)
+
+// parseTimeTarget parses a time of day, a date with time or a duration ago.
+// A time of day later than now means that time yesterday.
+func parseTimeTarget(input string, now time.Time) (time.Time, error) {
+	input = strings.TrimSpace(input)
+
+	if strings.HasPrefix(input, "-") {
+		d, err := time.ParseDuration(input[1:])
+		if err != nil {
+			return time.Time{}, timeTargetError{input: input}
+		}
+
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, input, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	for _, layout := range clockLayouts {
+		c, err := time.Parse(layout, input)
+		if err != nil {
+			continue
+		}
+
+		t := time.Date(now.Year(), now.Month(), now.Day(), c.Hour(), c.Minute(), c.Second(), 0, now.Location())
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+
+		return t, nil
+	}
+
+	return time.Time{}, timeTargetError{input: input}
+}
+
+// snapshotAtOrBefore returns the index of the last of the sorted ids at or
+// before the id. It is clamped to the first or last index, in which case
+// clamped is negative or positive.
+func snapshotAtOrBefore(ids []int64, id int64) (index int, clamped int) {
+	if len(ids) == 0 {
+		return -1, 0
+	}
+
+	if id < ids[0] {
+		return 0, -1
+	}
+
+	if id > ids[len(ids)-1] {
+		return len(ids) - 1, 1
+	}
+
+	return sort.Search(len(ids), func(i int) bool { return ids[i] > id }) - 1, 0
+}
+
+// nearestSnapshot returns the index of the sorted id closest to the id, with
+// clamped like snapshotAtOrBefore.
+func nearestSnapshot(ids []int64, id int64) (index int, clamped int) {
+	index, clamped = snapshotAtOrBefore(ids, id)
+	if clamped != 0 || index < 0 {
+		return index, clamped
+	}
+
+	if index+1 < len(ids) && ids[index+1]-id < id-ids[index] {
+		index++
+	}
+
+	return index, 0
+}
+
+// timeMachineSteps are the durations the step of the time machine goes through.
+var timeMachineSteps = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// adjustStep returns the next longer or shorter of timeMachineSteps, or the
+// step itself when there is none.
+func adjustStep(step time.Duration, longer bool) time.Duration {
+	if longer {
+		for _, s := range timeMachineSteps {
+			if s > step {
+				return s
+			}
+		}
+
+		return step
+	}
+
+	for i := len(timeMachineSteps) - 1; i >= 0; i-- {
+		if timeMachineSteps[i] < step {
+			return timeMachineSteps[i]
+		}
+	}
+
+	return step
+}
+
+// playbackSpeed is how fast the time machine replays the history, either in
+// snapshots per second or, when compressed, as a speed-up of the wall clock.
+type playbackSpeed struct {
+	rate       float64
+	compressed bool
+}
+
+// parsePlaybackSpeed parses snapshots per second such as "4" or "4/s", or a
+// speed-up such as "10x".
+func parsePlaybackSpeed(s string) (playbackSpeed, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	var speed playbackSpeed
+	if strings.HasSuffix(s, "x") {
+		speed.compressed = true
+		s = strings.TrimSuffix(s, "x")
+	} else {
+		s = strings.TrimSuffix(s, "/s")
+	}
+
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+		return playbackSpeed{}, errPlaybackSpeed
+	}
+
+	speed.rate = rate
+
+	return speed, nil
+}
+
+// delay returns how long the snapshot with the id from stays on screen
+// before playback moves on to the one with the id to.
+func (s playbackSpeed) delay(from, to int64) time.Duration {
+	if s.compressed {
+		return time.Duration(float64(to-from) * float64(time.Millisecond) / s.rate)
+	}
+
+	return time.Duration(float64(time.Second) / s.rate)
+}
+
+func (s playbackSpeed) scale(f float64) playbackSpeed {
+	s.rate *= f
+
+	return s
+}
+
+func (s playbackSpeed) String() string {
+	rate := strconv.FormatFloat(s.rate, 'g', 4, 64)
+	if s.compressed {
+		return rate + "x"
+	}
+
+	return rate + "/s"
+}
+
+// adjacentBookmark returns the closest bookmarked id after the id, or before it
+// unless forward, among the sorted ids.
+func adjacentBookmark(ids []int64, bookmarks map[int64]struct{}, id int64, forward bool) (int64, bool) {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+
+	if forward {
+		if i < len(ids) && ids[i] == id {
+			i++
+		}
+
+		for ; i < len(ids); i++ {
+			if _, ok := bookmarks[ids[i]]; ok {
+				return ids[i], true
+			}
+		}
+
+		return 0, false
+	}
+
+	for i--; i >= 0; i-- {
+		if _, ok := bookmarks[ids[i]]; ok {
+			return ids[i], true
+		}
+	}
+
+	return 0, false
+}
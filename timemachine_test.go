@@ -0,0 +1,175 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeTarget(t *testing.T) {
+	now := time.Date(2024, 3, 1, 14, 40, 0, 0, time.Local)
+
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr error
+	}{
+		{input: "14:35", want: time.Date(2024, 3, 1, 14, 35, 0, 0, time.Local)},
+		{input: " 14:35:10 ", want: time.Date(2024, 3, 1, 14, 35, 10, 0, time.Local)},
+		{input: "23:00", want: time.Date(2024, 2, 29, 23, 0, 0, 0, time.Local)},
+		{input: "2024-02-28 09:15", want: time.Date(2024, 2, 28, 9, 15, 0, 0, time.Local)},
+		{input: "2024-02-28 09:15:30", want: time.Date(2024, 2, 28, 9, 15, 30, 0, time.Local)},
+		{input: "-15m", want: time.Date(2024, 3, 1, 14, 25, 0, 0, time.Local)},
+		{input: "-1h30s", want: time.Date(2024, 3, 1, 13, 39, 30, 0, time.Local)},
+		{input: "-15", wantErr: timeTargetError{input: "-15"}},
+		{input: "25:00", wantErr: timeTargetError{input: "25:00"}},
+		{input: "yesterday", wantErr: timeTargetError{input: "yesterday"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTimeTarget(tt.input, now)
+			assert.Equal(t, tt.wantErr, err)
+
+			if tt.wantErr == nil {
+				assert.True(t, tt.want.Equal(got), "got %v", got)
+			}
+		})
+	}
+}
+
+func TestSnapshotAtOrBefore(t *testing.T) {
+	ids := []int64{100, 200, 300}
+
+	tests := []struct {
+		name        string
+		ids         []int64
+		id          int64
+		wantIndex   int
+		wantClamped int
+	}{
+		{name: "exact", ids: ids, id: 200, wantIndex: 1},
+		{name: "between", ids: ids, id: 299, wantIndex: 1},
+		{name: "before oldest", ids: ids, id: 50, wantIndex: 0, wantClamped: -1},
+		{name: "after newest", ids: ids, id: 301, wantIndex: 2, wantClamped: 1},
+		{name: "newest", ids: ids, id: 300, wantIndex: 2},
+		{name: "empty", id: 10, wantIndex: -1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			index, clamped := snapshotAtOrBefore(tt.ids, tt.id)
+			assert.Equal(t, tt.wantIndex, index)
+			assert.Equal(t, tt.wantClamped, clamped)
+		})
+	}
+}
+
+func TestNearestSnapshot(t *testing.T) {
+	ids := []int64{100, 200, 300}
+
+	tests := []struct {
+		name        string
+		ids         []int64
+		id          int64
+		wantIndex   int
+		wantClamped int
+	}{
+		{name: "exact", ids: ids, id: 200, wantIndex: 1},
+		{name: "closer to earlier", ids: ids, id: 240, wantIndex: 1},
+		{name: "closer to later", ids: ids, id: 260, wantIndex: 2},
+		{name: "before oldest", ids: ids, id: 50, wantIndex: 0, wantClamped: -1},
+		{name: "after newest", ids: ids, id: 350, wantIndex: 2, wantClamped: 1},
+		{name: "empty", id: 10, wantIndex: -1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			index, clamped := nearestSnapshot(tt.ids, tt.id)
+			assert.Equal(t, tt.wantIndex, index)
+			assert.Equal(t, tt.wantClamped, clamped)
+		})
+	}
+}
+
+func TestAdjustStep(t *testing.T) {
+	tests := []struct {
+		step   time.Duration
+		longer bool
+		want   time.Duration
+	}{
+		{step: time.Minute, longer: true, want: 5 * time.Minute},
+		{step: time.Minute, want: 30 * time.Second},
+		{step: 90 * time.Second, longer: true, want: 5 * time.Minute},
+		{step: 90 * time.Second, want: time.Minute},
+		{step: 500 * time.Millisecond, want: 500 * time.Millisecond},
+		{step: 48 * time.Hour, longer: true, want: 48 * time.Hour},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.step.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, adjustStep(tt.step, tt.longer))
+		})
+	}
+}
+
+func TestParsePlaybackSpeed(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    playbackSpeed
+		wantErr error
+	}{
+		{input: "4", want: playbackSpeed{rate: 4}},
+		{input: "0.5/s", want: playbackSpeed{rate: 0.5}},
+		{input: "10x", want: playbackSpeed{rate: 10, compressed: true}},
+		{input: " 2.5X ", want: playbackSpeed{rate: 2.5, compressed: true}},
+		{input: "0", wantErr: errPlaybackSpeed},
+		{input: "-2x", wantErr: errPlaybackSpeed},
+		{input: "fast", wantErr: errPlaybackSpeed},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parsePlaybackSpeed(tt.input)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPlaybackSpeedDelay(t *testing.T) {
+	assert.Equal(t, 250*time.Millisecond, playbackSpeed{rate: 4}.delay(0, 60000))
+	assert.Equal(t, 200*time.Millisecond, playbackSpeed{rate: 10, compressed: true}.delay(1000, 3000))
+	assert.Equal(t, "8/s", playbackSpeed{rate: 4}.scale(2).String())
+	assert.Equal(t, "2.5x", playbackSpeed{rate: 5, compressed: true}.scale(0.5).String())
+}
+
+func TestAdjacentBookmark(t *testing.T) {
+	ids := []int64{100, 200, 300, 400, 500}
+	bookmarks := map[int64]struct{}{200: {}, 400: {}, 999: {}}
+
+	tests := []struct {
+		name    string
+		id      int64
+		forward bool
+		want    int64
+		wantOK  bool
+	}{
+		{name: "next", id: 100, forward: true, want: 200, wantOK: true},
+		{name: "next from bookmark", id: 200, forward: true, want: 400, wantOK: true},
+		{name: "no next", id: 400, forward: true},
+		{name: "previous", id: 500, want: 400, wantOK: true},
+		{name: "previous from bookmark", id: 400, want: 200, wantOK: true},
+		{name: "no previous", id: 200},
+		{name: "previous from dropped snapshot", id: 350, want: 200, wantOK: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := adjacentBookmark(ids, bookmarks, tt.id, tt.forward)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
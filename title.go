@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// titlePlaceholders are what title_template may show, as {command} or as
+// {{.command}} like text/template names them.
+var titlePlaceholders = []string{"command", "interval", "host", "exit_code", "time"}
+
+// titlePlaceholder matches the actions of the template, which are left
+// alone, and the placeholders in single braces.
+var titlePlaceholder = regexp.MustCompile(`\{\{.*?\}\}|\{(\w+)\}`)
+
+type titleTemplateError struct {
+	err error
+}
+
+func (e titleTemplateError) Error() string {
+	return "title_template: " + e.err.Error()
+}
+
+// parseTitleTemplate parses the template of the header, nil if empty. Its
+// mistakes are found here rather than every time it is shown.
+func parseTitleTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	var unknown string
+
+	src := titlePlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		if strings.HasPrefix(m, "{{") {
+			return m
+		}
+
+		name := m[1 : len(m)-1]
+		for _, p := range titlePlaceholders {
+			if name == p {
+				return "{{." + name + "}}"
+			}
+		}
+
+		if unknown == "" {
+			unknown = m
+		}
+
+		return m
+	})
+
+	if unknown != "" {
+		return nil, titleTemplateError{err: fmt.Errorf("unknown placeholder %s, must be among {%s}",
+			unknown, strings.Join(titlePlaceholders, "}, {"))}
+	}
+
+	t, err := template.New("title").Option("missingkey=error").Parse(src)
+	if err != nil {
+		return nil, titleTemplateError{err: err}
+	}
+
+	// Fields which do not exist only fail once the template runs.
+	if err := t.Execute(io.Discard, titleFields{}.values()); err != nil {
+		return nil, titleTemplateError{err: err}
+	}
+
+	return t, nil
+}
+
+// titleFields are the values of the placeholders of title_template.
+type titleFields struct {
+	command  string
+	interval string
+	host     string
+	exitCode string
+	time     string
+}
+
+func (f titleFields) values() map[string]string {
+	return map[string]string{
+		"command":   f.command,
+		"interval":  f.interval,
+		"host":      f.host,
+		"exit_code": f.exitCode,
+		"time":      f.time,
+	}
+}
+
+// renderTitle returns what the header shows in place of the command.
+func renderTitle(t *template.Template, f titleFields) string {
+	if t == nil {
+		return f.command
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, f.values()); err != nil {
+		return err.Error()
+	}
+
+	return b.String()
+}
+
+// truncateMiddle shortens the text to the width, replacing its middle with
+// an ellipsis, since both ends of a long command tell the most.
+func truncateMiddle(text string, width int) string {
+	if runewidth.StringWidth(text) <= width {
+		return text
+	}
+
+	if width <= 1 {
+		return runewidth.Truncate(text, width, "")
+	}
+
+	runes := []rune(text)
+	head := width / 2
+	tail := width - 1 - head
+
+	var b strings.Builder
+
+	w := 0
+	for _, r := range runes {
+		if w+runewidth.RuneWidth(r) > head {
+			break
+		}
+
+		w += runewidth.RuneWidth(r)
+		b.WriteRune(r)
+	}
+
+	b.WriteString("…")
+
+	start := len(runes)
+	w = 0
+
+	for start > 0 && w+runewidth.RuneWidth(runes[start-1]) <= tail {
+		start--
+		w += runewidth.RuneWidth(runes[start])
+	}
+
+	b.WriteString(string(runes[start:]))
+
+	return b.String()
+}
+
+// titleView shows the title, cut short in its middle to fit the width of
+// the box every time it is drawn.
+type titleView struct {
+	*tview.TextView
+
+	title func() string
+}
+
+func newTitleView(title func() string) *titleView {
+	return &titleView{TextView: tview.NewTextView(), title: title}
+}
+
+func (t *titleView) Draw(screen tcell.Screen) {
+	_, _, width, _ := t.GetInnerRect()
+	t.SetText(truncateMiddle(t.title(), width))
+	t.TextView.Draw(screen)
+}
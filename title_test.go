@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTitleTemplate(t *testing.T) {
+	fields := titleFields{
+		command:  "kubectl get pods",
+		interval: "2s",
+		host:     "me@box",
+		exitCode: "1",
+		time:     "12:00:00",
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "label",
+			text: "pods",
+			want: "pods",
+		},
+		{
+			name: "placeholders",
+			text: "{command} on {host} every {interval}: {exit_code} at {time}",
+			want: "kubectl get pods on me@box every 2s: 1 at 12:00:00",
+		},
+		{
+			name: "template actions",
+			text: `{{if ne .exit_code "0"}}FAILING {{end}}{command}`,
+			want: "FAILING kubectl get pods",
+		},
+		{
+			name:    "unknown placeholder",
+			text:    "{cmd}",
+			wantErr: "title_template: unknown placeholder {cmd}, must be among {command}, {interval}, {host}, {exit_code}, {time}",
+		},
+		{
+			name:    "unknown field",
+			text:    "{{.cmd}}",
+			wantErr: `title_template: template: title:1:2: executing "title" at <.cmd>: map has no entry for key "cmd"`,
+		},
+		{
+			name:    "broken action",
+			text:    "{{if}}",
+			wantErr: "title_template: template: title:1: missing value for if",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseTitleTemplate(tt.text)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, renderTitle(tmpl, fields))
+		})
+	}
+}
+
+func TestRenderTitleWithoutTemplate(t *testing.T) {
+	tmpl, err := parseTitleTemplate("")
+	require.NoError(t, err)
+	assert.Nil(t, tmpl)
+
+	assert.Equal(t, "df -h", renderTitle(tmpl, titleFields{command: "df -h"}))
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{
+			name:  "fits",
+			text:  "kubectl get pods",
+			width: 16,
+			want:  "kubectl get pods",
+		},
+		{
+			name:  "even width",
+			text:  "kubectl get pods -n kube-system",
+			width: 10,
+			want:  "kubec…stem",
+		},
+		{
+			name:  "odd width",
+			text:  "kubectl get pods -n kube-system",
+			width: 11,
+			want:  "kubec…ystem",
+		},
+		{
+			name:  "wide characters",
+			text:  "日本語のコマンド",
+			width: 7,
+			want:  "日…ド",
+		},
+		{
+			name:  "no room",
+			text:  "kubectl",
+			width: 0,
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, truncateMiddle(tt.text, tt.width))
+		})
+	}
+}
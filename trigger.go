@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type triggerError struct {
+	pattern string
+	err     error
+}
+
+func (e triggerError) Error() string {
+	return fmt.Sprintf("invalid --trigger %q: %v", e.pattern, e.err)
+}
+
+func (e triggerError) Unwrap() error {
+	return e.err
+}
+
+// compileTriggers compiles the patterns with ^ and $ matching at line breaks.
+func compileTriggers(patterns []string) ([]*regexp.Regexp, error) {
+	var triggers []*regexp.Regexp
+
+	for _, p := range patterns {
+		// Compiled as given first, so that errors quote the pattern unchanged.
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, triggerError{pattern: p, err: err}
+		}
+
+		re, err := regexp.Compile("(?m)" + p)
+		if err != nil {
+			return nil, triggerError{pattern: p, err: err}
+		}
+
+		triggers = append(triggers, re)
+	}
+
+	return triggers, nil
+}
+
+// triggerMatch is where a trigger started matching the output.
+type triggerMatch struct {
+	line int
+	text string
+}
+
+// findTriggerMatch returns the first line matched by a trigger which does
+// not match the previous output. There is no previous output for the first
+// snapshot, so any match fires.
+func findTriggerMatch(triggers []*regexp.Regexp, output, previous []byte, hasPrevious bool) (triggerMatch, bool) {
+	for _, re := range triggers {
+		loc := re.FindIndex(output)
+		if loc == nil || hasPrevious && re.Match(previous) {
+			continue
+		}
+
+		start := bytes.LastIndexByte(output[:loc[0]], '\n') + 1
+
+		end := bytes.IndexByte(output[loc[0]:], '\n')
+		if end < 0 {
+			end = len(output)
+		} else {
+			end += loc[0]
+		}
+
+		return triggerMatch{
+			line: bytes.Count(output[:loc[0]], []byte("\n")),
+			text: string(output[start:end]),
+		}, true
+	}
+
+	return triggerMatch{}, false
+}
+
+// markRegion wraps the line of the rendered text in a tview region.
+func markRegion(rendered string, line int, region string) string {
+	lines := strings.Split(rendered, "\n")
+	if line < 0 || line >= len(lines) {
+		return rendered
+	}
+
+	lines[line] = fmt.Sprintf(`["%s"]%s[""]`, region, lines[line])
+
+	return strings.Join(lines, "\n")
+}
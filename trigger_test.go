@@ -0,0 +1,79 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileTriggers(t *testing.T) {
+	triggers, err := compileTriggers([]string{"Error", "ready$"})
+	assert.NoError(t, err)
+	assert.Len(t, triggers, 2)
+
+	assert.True(t, triggers[1].MatchString("still ready\nnext"))
+
+	_, err = compileTriggers([]string{"ok", "(unclosed"})
+	assert.Equal(t, "(unclosed", err.(triggerError).pattern)
+}
+
+func TestFindTriggerMatch(t *testing.T) {
+	triggers := []*regexp.Regexp{regexp.MustCompile("Crash"), regexp.MustCompile("Error")}
+
+	tests := []struct {
+		name        string
+		output      string
+		previous    string
+		hasPrevious bool
+		want        triggerMatch
+		wantOK      bool
+	}{
+		{
+			name:        "starts matching",
+			output:      "pod-a Running\npod-b Error\n",
+			previous:    "pod-a Running\npod-b Running\n",
+			hasPrevious: true,
+			want:        triggerMatch{line: 1, text: "pod-b Error"},
+			wantOK:      true,
+		},
+		{
+			name:        "still matching",
+			output:      "pod-a Running\npod-b Error\n",
+			previous:    "pod-a Error\npod-b Running\n",
+			hasPrevious: true,
+		},
+		{
+			name:        "another trigger starts matching",
+			output:      "pod-a Crash\npod-b Error\n",
+			previous:    "pod-b Error\n",
+			hasPrevious: true,
+			want:        triggerMatch{line: 0, text: "pod-a Crash"},
+			wantOK:      true,
+		},
+		{
+			name:   "first snapshot",
+			output: "Error",
+			want:   triggerMatch{line: 0, text: "Error"},
+			wantOK: true,
+		},
+		{
+			name:        "no match",
+			output:      "pod-a Running\n",
+			hasPrevious: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := findTriggerMatch(triggers, []byte(tt.output), []byte(tt.previous), tt.hasPrevious)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMarkRegion(t *testing.T) {
+	assert.Equal(t, "a\n[\"trigger\"]b[\"\"]\nc", markRegion("a\nb\nc", 1, "trigger"))
+	assert.Equal(t, "a\nb", markRegion("a\nb", 5, "trigger"))
+}
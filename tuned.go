@@ -0,0 +1,152 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// intervalLadder are the intervals which the keys of increase_interval and
+// decrease_interval go through.
+var intervalLadder = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	15 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+}
+
+// nextInterval returns the interval of the ladder which comes after the
+// interval, or before it if not longer, or false past either end.
+func nextInterval(interval time.Duration, longer bool) (time.Duration, bool) {
+	if longer {
+		for _, d := range intervalLadder {
+			if d > interval {
+				return d, true
+			}
+		}
+
+		return 0, false
+	}
+
+	for i := len(intervalLadder) - 1; i >= 0; i-- {
+		if d := intervalLadder[i]; d < interval {
+			return d, true
+		}
+	}
+
+	return 0, false
+}
+
+// tunedInterval is the interval as the keys change it while viddy runs. A
+// nil tuned interval keeps the interval.
+type tunedInterval struct {
+	sync.Mutex
+
+	current time.Duration
+	// changed is closed when the interval changes, to wake the generator.
+	changed chan struct{}
+}
+
+func newTunedInterval(interval time.Duration) *tunedInterval {
+	return &tunedInterval{current: interval, changed: make(chan struct{})}
+}
+
+// interval returns the interval in effect.
+func (t *tunedInterval) interval(interval time.Duration) time.Duration {
+	if t == nil {
+		return interval
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	return t.current
+}
+
+func (t *tunedInterval) set(interval time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.current = interval
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// sleep waits for the duration, and returns false if the interval changed
+// before it passed.
+func (t *tunedInterval) sleep(d time.Duration) bool {
+	if t == nil {
+		time.Sleep(d)
+
+		return true
+	}
+
+	t.Lock()
+	changed := t.changed
+	t.Unlock()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-changed:
+		return false
+	}
+}
+
+// changeInterval steps the interval to the next one of the ladder, longer
+// or shorter, and runs the command on it from now on.
+func (v *Viddy) changeInterval(longer bool) {
+	if v.tuned == nil {
+		switch {
+		case v.load != "":
+			v.setMessage("Nothing runs while looking back at a file")
+		case v.mode == ViddyIntervalModeOnce:
+			v.setMessage("The command runs only once")
+		case v.schedule != nil:
+			v.setMessage("The command runs on a schedule, which cannot change")
+		case v.adaptive != nil:
+			v.setMessage("The adaptive interval cannot change")
+		default:
+			v.setMessage("The stepped interval cannot change")
+		}
+
+		return
+	}
+
+	interval, ok := nextInterval(v.interval(), longer)
+	if !ok {
+		if longer {
+			v.setMessage("The interval is already the longest")
+		} else {
+			v.setMessage("The interval is already the shortest")
+		}
+
+		return
+	}
+
+	v.tuned.set(interval)
+	v.log(levelInfo, "interval changed", "interval", interval)
+	v.setMessage("Running every " + interval.String())
+	v.updateIntervalView()
+	v.UpdateStatusView()
+}
+
+// interval returns the interval of the runs, as stepped or changed with the
+// keys.
+func (v *Viddy) interval() time.Duration {
+	return v.steps.currentInterval(v.tuned.interval(v.duration))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		longer   bool
+		want     time.Duration
+		wantOK   bool
+	}{
+		{
+			name:     "longer",
+			interval: 2 * time.Second,
+			longer:   true,
+			want:     5 * time.Second,
+			wantOK:   true,
+		},
+		{
+			name:     "shorter",
+			interval: 2 * time.Second,
+			want:     time.Second,
+			wantOK:   true,
+		},
+		{
+			name:     "between the steps",
+			interval: 3 * time.Second,
+			want:     2 * time.Second,
+			wantOK:   true,
+		},
+		{
+			name:     "longest",
+			interval: time.Hour,
+			longer:   true,
+		},
+		{
+			name:     "shortest",
+			interval: 50 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := nextInterval(tt.interval, tt.longer)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTunedIntervalWakesTheGenerator(t *testing.T) {
+	tuned := newTunedInterval(time.Hour)
+	c := SequentialSnapshot(time.Now().UnixNano(), fakeNewSnap, time.Hour, 0, OverlapPolicySkip, nil, nil, nil, tuned)
+
+	first := <-c
+	close(first.finish)
+
+	// Let the generator start waiting the hour.
+	time.Sleep(20 * time.Millisecond)
+	tuned.set(10 * time.Millisecond)
+
+	select {
+	case second := <-c:
+		close(second.finish)
+	case <-time.After(time.Second):
+		t.Fatal("the run after the interval changed did not come")
+	}
+
+	var nilTuned *tunedInterval
+	assert.Equal(t, time.Minute, nilTuned.interval(time.Minute))
+	assert.True(t, nilTuned.sleep(0))
+}
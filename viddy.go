@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"html/template"
 	"io"
+	"math"
+	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -22,53 +27,226 @@ type HistoryRow struct {
 	addition *tview.TableCell
 	deletion *tview.TableCell
 	exitCode *tview.TableCell
+	bookmark *tview.TableCell
 }
 
 type Viddy struct {
 	begin int64
 
 	keymap keymapping
-
-	cmd  string
-	args []string
-
+	theme  theme
+
+	keys               keyDispatcher
+	keysWaitID         int64
+	generalActions     []keyAction
+	timeMachineActions []keyAction
+	helpActions        []keyAction
+
+	cmd        string
+	args       []string
+	dir        string
+	command    commandSpec // what the runs execute, cmd and args until edited
+	onChange   string
+	pipe       string
+	logFile    string
+	logMaxSize int64
+	outputLog  *outputLog
+	session    *sessionWriter
+	restored   []*Snapshot
+	keepFor    time.Duration // how long snapshots stay in the history, 0 for ever
+	times      timeFormat
+
+	// historyPath is where every run is appended for --load, and load the
+	// file looked back at instead of running the command.
+	historyPath string
+	history     *historyFile
+	load        string
+
+	// compressAfter is how many of the newest snapshots stay uncompressed, 0
+	// for all.
+	compressAfter int
+	compactor     compactor
+
+	triggers    []*regexp.Regexp
+	triggerExit bool
+	triggered   *triggeredSnapshot
+	exitLine    *string
+	isBeeping   bool
+	flashID     int64
+
+	flashOnChange bool
+	flashDuration time.Duration
+
+	// notifyMode is which runs send a desktop notification through the
+	// notifier, which are queued to notifications.
+	notifyMode    NotifyMode
+	notifier      *notifier
+	notifications chan notification
+
+	// killTimeout is how long the running commands get to stop when viddy
+	// quits, before they are killed.
+	killTimeout time.Duration
+
+	// Runs which go on writing output past streamGrace, twice the interval
+	// unless set, are handled by streamPolicy.
+	streamGrace  time.Duration
+	streamPolicy StreamPolicy
+
+	remote    *remoteHost
+	file      string // read instead of running the command, if not empty
+	host      string // user@hostname shown in the header, if any
 	duration  time.Duration
+	mode      ViddyIntervalMode
+	jitter    time.Duration
+	schedule  *cronSchedule
+	backoff   *backoff
+	adaptive  *adaptive
+	steps     *steppedInterval
+	tuned     *tunedInterval
+	nextRun   int64 // unix nanoseconds, or -1 once the schedule ends
 	snapshots sync.Map
 
+	// titleTemplate is shown in place of the command if not nil, with
+	// titleHost as its {host}.
+	titleTemplate *template.Template
+	titleHost     string
+
 	intervalView *tview.TextView
-	commandView  *tview.TextView
+	commandView  *titleView
+	hostView     *tview.TextView
 	timeView     *tview.TextView
 	historyView  *tview.Table
 	historyRows  map[int64]*HistoryRow
+	bookmarks    map[int64]struct{}
 	sync.RWMutex
 
 	idList []int64
 
-	bodyView    *tview.TextView
-	app         *tview.Application
-	logView     *tview.TextView
-	helpView    *tview.TextView
-	statusView  *tview.TextView
-	queryEditor *tview.InputField
+	bodyView       *reflowView
+	lineAgeView    *tview.Box
+	previousView   *reflowView
+	sideBySide     *splitView
+	truncationView *tview.TextView
+	truncation     string
+	app            *tview.Application
+	group          *paneGroup
+	frame          *tview.Flex // the pane as last arranged
+	logView        *tview.TextView
+	helpView       *helpModal
+	statusView     *tview.TextView
+	changesView    *tview.TextView
+	messageView    *tview.TextView
+	queryEditor    *tview.InputField
+	timeEditor     *tview.InputField
+	commandEditor  *tview.InputField
 
 	snapshotQueue <-chan *Snapshot
+	pool          *runPool
+
+	// newSnap makes the snapshots of the runs, lastSnap being the latest.
+	// runNowQueue asks for a run besides those of the interval.
+	newSnap     newSnapFunc
+	lastSnap    *Snapshot
+	runNowQueue chan struct{}
+
+	// control serves the latest run on the socket of --listen, if any.
+	control *controlServer
+
 	queue         chan int64
 	finishedQueue chan int64
 	diffQueue     chan int64
+	changes       chan *Snapshot
 
 	currentID        int64
+	renderedID       int64
 	latestFinishedID int64
+	skippedRuns      int64
+	drift            driftStats // how late the runs started, guarded by the lock
+	viewport         int64
 	isTimeMachine    bool
 	isSuspend        bool
 	isNoTitle        bool
 	isShowDiff       bool
+	isPermanentDiff  bool
+	isChangesOnly    bool
+	isSideBySide     bool
+	isShowRaw        bool // show the output before the --pipe filter
+	isNoWrap         bool
+	isStickyScroll   bool
+	scrollOff        int
+	changesContext   int
+	shownLines       []int // the line of the output on each row of the body, nil if all are shown
+	isShowLineAge    bool
+	lineAges         []string     // how long ago every line of the output shown changed, nil without the gutter
+	hunks            []changeHunk // the changed lines of the output shown, nil without the diff
+	hunk             int          // the hunk of changes gone to, -1 if none
 	isEditQuery      bool
-
-	query string
-
-	isDebug      bool
-	showLogView  bool
-	showHelpView bool
+	isEditTime       bool
+	isEditCommand    bool
+	isMouse          bool
+	isScrubbing      bool
+
+	query   searchQuery
+	message string
+
+	// confirm runs once the question of the message is answered with y.
+	confirm            func()
+	confirmDestructive bool
+
+	clipboard *clipboard
+
+	statusItems  []StatusItem
+	running      int64 // runs executing
+	spinning     int32 // 1 while the status spins
+	spinnerFrame int
+
+	shell              string
+	pollWhileSuspended bool
+	held               chan struct{} // closed when runs held for the shell may start
+	quitting           int32         // 1 once quitting stops the runs
+
+	pollInTimeMachine bool
+	isPaused          bool          // the runs are paused with keymap.toggle_pause
+	paused            chan struct{} // closed when paused runs may start
+	newSnapshots      int           // the runs which finished since the time machine was opened
+
+	scrub           scrubber
+	timeMachineStep time.Duration
+	playbackSpeed   playbackSpeed
+	playID          int64
+	isPlaying       bool
+
+	markedID    int64
+	compareBase *Snapshot
+
+	// diffAgainst is how far back the differences are taken, diffBaseline
+	// what the header says about it and offsetCompared the snapshot shown
+	// last, diffed that far back.
+	diffAgainst    diffOffset
+	diffBaseline   string
+	offsetCompared *Snapshot
+
+	isDebug        bool
+	debugLog       *debugLog
+	startupLog     []logRecord
+	forceTruecolor bool
+	showLogView    bool
+	showHelpView   bool
+
+	snapshotList     *snapshotList
+	showSnapshotList bool
+
+	// changeLog lists the snapshots which changed, shown in changeLogView.
+	changeLog     changeLog
+	changeLogView *snapshotList
+	showChangeLog bool
+
+	timeline      *timeline
+	timelineMarks timelineMarks
+	showTimeline  bool
+
+	// stderrMode is the StderrMode of the runs to come.
+	stderrMode atomic.Value
 }
 
 type ViddyIntervalMode string
@@ -77,51 +255,252 @@ var (
 	ViddyIntervalModeClockwork  ViddyIntervalMode = "clockwork"
 	ViddyIntervalModePrecise    ViddyIntervalMode = "precise"
 	ViddyIntervalModeSequential ViddyIntervalMode = "sequential"
+	ViddyIntervalModeSchedule   ViddyIntervalMode = "schedule"
+	ViddyIntervalModeAdaptive   ViddyIntervalMode = "adaptive"
+	ViddyIntervalModeOnce       ViddyIntervalMode = "once"
 
 	errCannotCreateSnapshot = errors.New("cannot find the snapshot")
 	errNotCompletedYet      = errors.New("not completed yet")
 )
 
-func NewViddy(conf *config) *Viddy {
+// NewViddy makes a viddy watching the command with the config, continuing
+// the saved session if not nil.
+func NewViddy(conf *config, command commandSpec, saved *session) *Viddy {
 	begin := time.Now().UnixNano()
 
-	newSnap := func(id int64, before *Snapshot, finish chan<- struct{}) *Snapshot {
-		return NewSnapshot(id, conf.runtime.cmd, conf.runtime.args, conf.general.shell, conf.general.shellOptions, before, finish)
-	}
-
-	var snapshotQueue <-chan *Snapshot
-
-	switch conf.runtime.mode {
-	case ViddyIntervalModeClockwork:
-		snapshotQueue = ClockSnapshot(begin, newSnap, conf.runtime.interval)
-	case ViddyIntervalModeSequential:
-		snapshotQueue = SequentialSnapshot(newSnap, conf.runtime.interval)
-	case ViddyIntervalModePrecise:
-		snapshotQueue = PreciseSnapshot(newSnap, conf.runtime.interval)
+	// Snapshots are identified by the time since the beginning, so new runs
+	// go after the restored ones.
+	if saved != nil {
+		begin = saved.Begin.UnixNano()
 	}
 
-	return &Viddy{
+	v := &Viddy{
 		keymap: conf.keymap,
+		theme:  conf.theme,
 
 		begin:       begin,
-		cmd:         conf.runtime.cmd,
-		args:        conf.runtime.args,
-		duration:    conf.runtime.interval,
-		snapshots:   sync.Map{},
-		historyRows: map[int64]*HistoryRow{},
-
-		snapshotQueue: snapshotQueue,
+		cmd:         command.cmd,
+		args:        command.args,
+		command:     command,
+		dir:         conf.runtime.chdir,
+		onChange:    conf.general.onChange,
+		pipe:        conf.general.pipe,
+		logFile:     conf.general.logFile,
+		logMaxSize:  conf.general.logMaxSize,
+		keepFor:     conf.general.keepFor,
+		times:       conf.runtime.times,
+		triggers:    conf.general.triggers,
+		triggerExit: conf.general.triggerExit,
+
+		historyPath: conf.general.historyFile,
+		load:        conf.runtime.load,
+		// A loaded history is only looked back at.
+		isTimeMachine: conf.runtime.load != "",
+
+		compressAfter: conf.general.compressAfter,
+		flashOnChange: conf.general.flashOnChange,
+		flashDuration: conf.general.flashDuration,
+		notifyMode:    conf.general.notify,
+		notifier:      newNotifier(conf.general.notifyCooldown),
+		notifications: make(chan notification, 1),
+		killTimeout:   conf.general.killTimeout,
+		streamGrace:   conf.general.streamGrace,
+		streamPolicy:  conf.general.streamPolicy,
+		duration:      conf.runtime.interval,
+		mode:          conf.runtime.mode,
+		jitter:        conf.runtime.jitter,
+		schedule:      conf.runtime.schedule,
+		remote:        conf.runtime.remote,
+		file:          conf.runtime.file,
+		host:          conf.runtime.host,
+		titleTemplate: conf.general.titleTemplate,
+		snapshots:     sync.Map{},
+		historyRows:   map[int64]*HistoryRow{},
+		bookmarks:     map[int64]struct{}{},
+
+		pool:          newRunPool(conf.general.maxConcurrentRuns),
 		queue:         make(chan int64),
 		finishedQueue: make(chan int64),
 		diffQueue:     make(chan int64, 100),
+		changes:       make(chan *Snapshot, 16),
+		runNowQueue:   make(chan struct{}, 1),
+
+		isShowDiff:      conf.general.differences,
+		isPermanentDiff: conf.general.permanentDiff,
+		diffAgainst:     conf.general.differencesAgainst,
+		isChangesOnly:   conf.general.changesOnly,
+		isSideBySide:    conf.general.sideBySide,
+		isNoWrap:        conf.general.noWrap,
+		isShowLineAge:   conf.general.showLineAge,
+		isStickyScroll:  conf.general.stickyScroll,
+		scrollOff:       conf.general.scrollOff,
+		changesContext:  conf.general.changesContext,
+		isNoTitle:       conf.general.noTitle,
+		isDebug:         conf.general.debug,
+		debugLog:        conf.runtime.debugLog,
+		startupLog:      conf.runtime.startupLog,
+		isMouse:         conf.general.mouse,
+
+		timeMachineStep: conf.general.timeMachineStep,
+		scrub:           scrubber{steps: conf.general.scrubAcceleration},
+		markedID:        -1,
+		playbackSpeed:   conf.general.playbackSpeed,
+
+		forceTruecolor: conf.general.forceTruecolor,
 
-		isShowDiff: conf.general.differences,
-		isNoTitle:  conf.general.noTitle,
-		isDebug:    conf.general.debug,
+		showSnapshotList: conf.general.showSnapshotList,
+		showChangeLog:    conf.general.showChangeLog,
+		showTimeline:     conf.general.showTimeline,
+
+		message: strings.Join(conf.warnings, "\n"),
+
+		clipboard: newClipboard(),
+
+		statusItems: conf.general.statusItems,
+
+		shell:              interactiveShell(conf.general.shell),
+		pollWhileSuspended: conf.general.pollWhileSuspended,
+		pollInTimeMachine:  conf.general.pollInTimeMachine,
+		confirmDestructive: conf.general.confirmDestructive,
+
+		query: searchQuery{ignoreCase: conf.general.searchIgnoreCase},
 
 		currentID:        -1,
+		renderedID:       -1,
 		latestFinishedID: -1,
+		hunk:             -1,
+	}
+
+	v.stderrMode.Store(conf.general.stderr)
+
+	if saved != nil {
+		v.restored = saved.restore()
+		for _, s := range v.restored {
+			s.opts.tabWidth = conf.general.tabWidth
+			s.opts.highlights = conf.general.highlights
+		}
+
+		v.isShowDiff = saved.Settings.Differences
+		v.isPermanentDiff = saved.Settings.PermanentDiff
+		v.isChangesOnly = saved.Settings.ChangesOnly
+		v.isSideBySide = saved.Settings.SideBySide
+		v.isNoWrap = saved.Settings.NoWrap
+		v.isNoTitle = saved.Settings.NoTitle
+
+		for _, id := range saved.Settings.Bookmarks {
+			v.bookmarks[id] = struct{}{}
+		}
+	}
+
+	if conf.general.sessionFile != "" {
+		v.session = &sessionWriter{
+			path:     conf.general.sessionFile,
+			command:  command.line(),
+			interval: conf.runtime.interval,
+			begin:    time.Unix(0, begin),
+		}
+	}
+
+	newSnap := func(id int64, before *Snapshot, finish chan<- struct{}) *Snapshot {
+		// The first run compares with the last restored one.
+		if before == nil && len(v.restored) > 0 {
+			before = v.restored[len(v.restored)-1]
+		}
+
+		// Runs asked for on the control socket come in between the others.
+		v.Lock()
+		if v.lastSnap != nil {
+			before = v.lastSnap
+			if id <= before.id {
+				id = before.id + 1
+			}
+		}
+		v.Unlock()
+
+		opts := newRunOptions(conf)
+		opts.stderr = v.stderrModeOfRuns()
+
+		if opts.pty {
+			opts.ptyRows, opts.ptyCols = v.viewportSize()
+		}
+
+		command := v.activeCommand()
+
+		s := NewSnapshot(id, command.cmd, command.args, opts, before, finish)
+
+		v.Lock()
+		v.lastSnap = s
+		v.Unlock()
+
+		return s
+	}
+
+	v.newSnap = newSnap
+
+	onSkip := func() {
+		atomic.AddInt64(&v.skippedRuns, 1)
+		v.log(levelWarn, "tick skipped", "reason", "the previous run is still running", "overlap_policy", conf.general.overlapPolicy)
+	}
+
+	if conf.general.backoff {
+		v.backoff = newBackoff(conf.general.backoffMax)
+		v.backoff.onChange = func() {
+			v.app.QueueUpdateDraw(v.updateIntervalView)
+		}
+	}
+
+	if conf.runtime.mode == ViddyIntervalModeAdaptive {
+		v.adaptive = newAdaptive(conf.runtime.interval, conf.general.adaptiveMax, conf.general.adaptiveSteadyRuns)
+		v.adaptive.onChange = func() {
+			v.app.QueueUpdateDraw(v.updateIntervalView)
+		}
+	}
+
+	onNext := func(next time.Time) {
+		if next.IsZero() {
+			atomic.StoreInt64(&v.nextRun, -1)
+			v.log(levelInfo, "schedule ended")
+		} else {
+			atomic.StoreInt64(&v.nextRun, next.UnixNano())
+			v.log(levelDebug, "next run scheduled", "at", next)
+		}
+	}
+
+	v.steps = newSteppedInterval(conf.runtime.steps, time.Unix(0, begin))
+	if v.steps != nil {
+		v.steps.onChange = func() {
+			v.log(levelInfo, "interval stepped", "interval", v.interval())
+			v.app.QueueUpdateDraw(v.updateIntervalView)
+		}
+	}
+
+	if v.titleTemplate != nil {
+		switch {
+		case v.remote != nil:
+			v.titleHost = v.remote.target
+		case v.host != "":
+			v.titleHost = v.host
+		default:
+			v.titleHost = currentHost()
+		}
 	}
+
+	if v.load != "" {
+		none := make(chan *Snapshot)
+		close(none)
+		v.snapshotQueue = none
+
+		return v
+	}
+
+	// Only a fixed interval can be changed with the keys.
+	if v.schedule == nil && v.adaptive == nil && v.steps == nil && v.mode != ViddyIntervalModeOnce {
+		v.tuned = newTunedInterval(v.duration)
+	}
+
+	v.snapshotQueue = newSnapshotQueue(conf, begin, newSnap, onSkip, onNext, v.backoff, v.adaptive, v.steps, v.tuned)
+
+	return v
 }
 
 func (v *Viddy) ShowLogView(b bool) {
@@ -129,6 +508,11 @@ func (v *Viddy) ShowLogView(b bool) {
 	v.arrange()
 }
 
+func (v *Viddy) ShowSnapshotList(b bool) {
+	v.showSnapshotList = b
+	v.arrange()
+}
+
 func (v *Viddy) SetIsNoTitle(b bool) {
 	v.isNoTitle = b
 	v.arrange()
@@ -141,16 +525,65 @@ func (v *Viddy) SetIsShowDiff(b bool) {
 }
 
 func (v *Viddy) SetIsTimeMachine(b bool) {
+	// A loaded history has nothing newer to leave for.
+	if !b && v.load != "" {
+		v.stopPlayback()
+		v.setSelection(v.latestFinishedID)
+		v.setMessage(fmt.Sprintf("Looking back at %s, nothing runs", v.load))
+
+		return
+	}
+
 	v.isTimeMachine = b
-	if !v.isTimeMachine {
+	if v.isTimeMachine {
+		v.stopFlash()
+		v.newSnapshots = 0
+	} else {
+		v.stopPlayback()
+		v.stopComparing()
 		v.setSelection(v.latestFinishedID)
 	}
 
+	v.syncPausedRuns()
+
 	v.arrange()
 }
 
-func (v *Viddy) println(a ...interface{}) {
-	fmt.Fprintln(v.logView, a...)
+// log records the event in the debug log and shows it in the log view. The
+// attributes are pairs of keys and values.
+func (v *Viddy) log(level logLevel, msg string, attrs ...interface{}) {
+	r := logRecord{level: level, msg: msg, attrs: append([]interface{}{"pane", v.cmd}, attrs...)}
+	if b := v.debugLog.log(r); b != nil && v.logView != nil {
+		_, _ = v.logView.Write(b)
+	}
+}
+
+// logRun records how the run went.
+func (v *Viddy) logRun(s *Snapshot) {
+	if s.skipped {
+		v.log(levelWarn, "run skipped", "id", s.id, "reason", "killed before it started")
+
+		return
+	}
+
+	level := levelInfo
+	attrs := []interface{}{"id", s.id, "duration", s.end.Sub(s.start), "exit_code", s.exitStatus()}
+
+	if s.isKilled() {
+		level = levelWarn
+		attrs = append(attrs, "killed", true)
+	}
+
+	for _, e := range []struct {
+		key string
+		err error
+	}{{"error", s.err}, {"hook_error", s.hookErr}, {"pipe_error", s.pipeErr}} {
+		if e.err != nil {
+			attrs = append(attrs, e.key, e.err)
+		}
+	}
+
+	v.log(level, "run finished", attrs...)
 }
 
 func (v *Viddy) addSnapshot(s *Snapshot) {
@@ -158,11 +591,77 @@ func (v *Viddy) addSnapshot(s *Snapshot) {
 }
 
 func (v *Viddy) startRunner() {
-	for s := range v.snapshotQueue {
+	// Restored snapshots are shown like runs which finished right away.
+	for _, s := range v.restored {
 		v.addSnapshot(s)
 		v.queue <- s.id
+		v.finishedQueue <- s.id
+	}
+
+	for {
+		var s *Snapshot
+
+		select {
+		case next, ok := <-v.snapshotQueue:
+			if !ok {
+				return
+			}
+
+			s = next
+		case <-v.runNowQueue:
+			id := (time.Now().UnixNano() - v.begin) / int64(time.Millisecond)
+			s = v.newSnap(id, nil, make(chan struct{}))
+			v.log(levelInfo, "run asked for", "id", s.id)
+		}
+
+		// The first run took the last restored snapshot to compare with.
+		v.restored = nil
+
+		v.RLock()
+		held := v.held
+		v.RUnlock()
+
+		if held != nil {
+			<-held
+		}
+
+		if !v.waitResumed(s) {
+			continue
+		}
+
+		v.addSnapshot(s)
+		v.queue <- s.id
+
+		queued, deadline := time.Now(), v.deadline()
+		v.log(levelDebug, "run scheduled", "id", s.id, "deadline", deadline)
+
+		v.pool.submit(v.cmd, deadline, func() {
+			v.log(levelDebug, "run started", "id", s.id, "waited", time.Since(queued))
+			v.runStarted()
+
+			grace := v.streamGrace
+			if grace == 0 {
+				grace = 2 * v.interval()
+			}
+
+			started := time.Now()
+			go watchStream(s, grace, v.streamPolicy, func() {
+				v.app.QueueUpdateDraw(func() { v.renderStream(s, started) })
+			})
+
+			_ = s.run(v.finishedQueue)
+			v.runFinished()
+			v.logRun(s)
 
-		_ = s.run(v.finishedQueue)
+			if v.outputLog != nil {
+				if err := v.outputLog.write(s); err != nil {
+					v.reportError(err)
+				}
+			}
+		}, func() {
+			v.log(levelWarn, "run skipped", "id", s.id, "reason", "it could not start before the deadline")
+			s.skip(v.finishedQueue)
+		})
 	}
 }
 
@@ -186,17 +685,120 @@ func (v *Viddy) diffQueueHandler() {
 				return
 			}
 
-			r, ok := v.historyRows[id]
+			r, ok := v.historyRow(id)
 			if !ok {
 				return
 			}
 
+			if s.diffBase != nil && s.diffAdditionCount+s.diffDeletionCount > 0 {
+				v.timelineMarks.addChanged(id)
+			}
+
+			if e, ok := newChangeEntry(s); ok {
+				v.changeLog.add(e)
+			}
+
 			r.addition.SetText("+" + strconv.Itoa(s.diffAdditionCount))
 			r.deletion.SetText("-" + strconv.Itoa(s.diffDeletionCount))
+
+			// Restored snapshots already fired before.
+			if s.restored {
+				return
+			}
+
+			if len(v.triggers) > 0 {
+				var previous []byte
+				if s.diffBase != nil {
+					previous = s.diffBase.output().result
+				}
+
+				if m, ok := findTriggerMatch(v.triggers, s.result, previous, s.diffBase != nil); ok {
+					v.app.QueueUpdateDraw(func() { v.fireTrigger(id, m) })
+				}
+			}
+
+			if v.flashOnChange && s.diffBase != nil && s.diffAdditionCount+s.diffDeletionCount > 0 {
+				v.app.QueueUpdateDraw(func() {
+					// The flash tells about the latest output, not what the time machine shows.
+					if !v.isTimeMachine {
+						v.flashHeader(v.flashDuration)
+					}
+				})
+			}
+
+			if v.onChange != "" && s.diffBase != nil && s.diffAdditionCount+s.diffDeletionCount > 0 {
+				select {
+				case v.changes <- s:
+				default:
+					v.reportError(errors.New("on-change hook is busy, skipped a change"))
+				}
+			}
+
+			changed := s.diffBase != nil && s.diffAdditionCount+s.diffDeletionCount > 0
+			if n, ok := runNotification(v.notifyMode, s, changed); ok {
+				v.queueNotification(n)
+			}
+
+			v.control.publish(s, changed)
+
+			if err := v.history.write(s, changed); err != nil {
+				v.reportError(err)
+			}
 		}()
 	}
 }
 
+// onChangeHandler runs the on-change hook for each changed snapshot in turn.
+func (v *Viddy) onChangeHandler() {
+	for s := range v.changes {
+		if err := runHook("on-change", v.onChange, s); err != nil {
+			v.reportError(err)
+		}
+	}
+}
+
+// queueNotification hands the notification to notifyHandler, unless one
+// went out within the cooldown or one is still being sent.
+func (v *Viddy) queueNotification(n notification) {
+	if !v.notifier.allow() {
+		v.log(levelDebug, "notification held back", "title", n.title)
+
+		return
+	}
+
+	select {
+	case v.notifications <- n:
+	default:
+		v.log(levelWarn, "notifier is busy, skipped a notification", "title", n.title)
+	}
+}
+
+// notifyHandler sends the notifications in turn. Failures are only logged,
+// since they must not get in the way of the runs.
+func (v *Viddy) notifyHandler() {
+	for n := range v.notifications {
+		command, err := v.notifier.notify(n)
+		if err != nil {
+			v.log(levelWarn, "notification failed", "error", err)
+
+			continue
+		}
+
+		if command != "" {
+			v.log(levelDebug, "notified", "command", command, "title", n.title)
+		}
+	}
+}
+
+// reportError logs the error and shows it in the message line.
+func (v *Viddy) reportError(err error) {
+	v.log(levelError, "error", "error", err)
+
+	v.app.QueueUpdateDraw(func() {
+		v.setMessage(err.Error())
+	})
+}
+
 //nolint:funlen,gocognit,cyclop
 func (v *Viddy) queueHandler() {
 	for {
@@ -205,7 +807,12 @@ func (v *Viddy) queueHandler() {
 
 			select {
 			case id := <-v.finishedQueue:
-				r, ok := v.historyRows[id]
+				if v.keepFor > 0 {
+					// In the event loop, so that no snapshot goes while it is drawn.
+					defer v.app.QueueUpdate(v.pruneHistory)
+				}
+
+				r, ok := v.historyRow(id)
 				if !ok {
 					return
 				}
@@ -217,18 +824,61 @@ func (v *Viddy) queueHandler() {
 					return
 				}
 
-				v.diffQueue <- s.id
+				v.session.add(s)
+				v.recordDrift(s)
+
+				if s.skipped {
+					r.exitCode.SetText("skip")
+
+					return
+				}
+
+				if s.hookErr != nil {
+					v.reportError(s.hookErr)
+				}
+
+				if s.pipeErr != nil {
+					v.reportError(s.pipeErr)
+				}
+
+				// Without output there are no changes to look for.
+				if s.hookFailed {
+					r.exitCode.SetText("hook")
+				} else {
+					v.diffQueue <- s.id
+				}
 
 				if s.exitCode > 0 {
 					r.exitCode.SetText(fmt.Sprintf("E(%d)", s.exitCode))
 				}
 
+				if s.streamCut {
+					r.exitCode.SetText("cut")
+				}
+
+				if s.exitStatus() != 0 {
+					v.timelineMarks.addFailed(id)
+				}
+
+				if s.before != nil && s.before.commandLine() != s.commandLine() {
+					v.timelineMarks.addSwitched(id)
+				}
+
+				// A run which quitting cut short does not tell how the
+				// command ended, as in batch mode.
+				cut := atomic.LoadInt32(&v.quitting) == 1 && s.isKilled()
+
 				ls := v.getSnapShot(v.latestFinishedID)
-				if ls == nil || s.start.After(ls.start) {
+				if !cut && (ls == nil || s.start.After(ls.start)) {
 					v.latestFinishedID = id
-					if !v.isTimeMachine {
+					switch {
+					case !v.isTimeMachine, v.currentID == -1:
 						v.setSelection(id)
-					} else {
+					default:
+						if !s.restored {
+							v.newSnapshots++
+						}
+
 						v.setSelection(v.currentID)
 					}
 				}
@@ -242,24 +892,35 @@ func (v *Viddy) queueHandler() {
 				additionCell := tview.NewTableCell("").SetTextColor(tcell.ColorGreen)
 				deletionCell := tview.NewTableCell("").SetTextColor(tcell.ColorRed)
 				exitCodeCell := tview.NewTableCell("").SetTextColor(tcell.ColorYellow)
+				bookmarkCell := tview.NewTableCell("").SetTextColor(tcell.ColorYellow)
 
-				v.historyRows[s.id] = &HistoryRow{
-					id:       idCell,
-					addition: additionCell,
-					deletion: deletionCell,
-					exitCode: exitCodeCell,
+				v.RLock()
+				if _, ok := v.bookmarks[s.id]; ok {
+					bookmarkCell.SetText(bookmarkMarker)
 				}
+				v.RUnlock()
 
 				v.historyView.InsertRow(0)
 				v.historyView.SetCell(0, 0, idCell)
 				v.historyView.SetCell(0, 1, additionCell)
 				v.historyView.SetCell(0, 2, deletionCell)
 				v.historyView.SetCell(0, 3, exitCodeCell)
+				v.historyView.SetCell(0, 4, bookmarkCell)
 
 				v.Lock()
+				v.historyRows[s.id] = &HistoryRow{
+					id:       idCell,
+					addition: additionCell,
+					deletion: deletionCell,
+					exitCode: exitCodeCell,
+					bookmark: bookmarkCell,
+				}
 				v.idList = append(v.idList, id)
 				v.Unlock()
 
+				v.updateCommandViewTitle()
+				v.updateIntervalView()
+
 				if !v.isTimeMachine {
 					v.setSelection(v.latestFinishedID)
 				} else {
@@ -270,407 +931,2160 @@ func (v *Viddy) queueHandler() {
 	}
 }
 
-func (v *Viddy) setSelection(id int64) {
-	if id == -1 {
-		return
+// storeViewportSize remembers the size of the body, which is read from other goroutines.
+func (v *Viddy) storeViewportSize() {
+	_, _, width, height := v.bodyView.GetInnerRect()
+	atomic.StoreInt64(&v.viewport, int64(height)<<32|int64(width))
+}
+
+// viewportSize returns rows and columns of the body, defaulting to 24x80 before the first draw.
+func (v *Viddy) viewportSize() (uint16, uint16) {
+	size := atomic.LoadInt64(&v.viewport)
+
+	rows, cols := uint16(size>>32), uint16(size&0xffffffff)
+	if rows == 0 || cols == 0 {
+		return 24, 80
 	}
 
-	v.historyView.ScrollToBeginning()
+	return rows, cols
+}
 
-	isSelectable, _ := v.historyView.GetSelectable()
-	if !isSelectable {
-		v.historyView.SetSelectable(true, false)
+// deadline is when a run which could not start yet is skipped instead.
+func (v *Viddy) deadline() time.Time {
+	if v.schedule == nil {
+		return time.Now().Add(v.tuned.interval(v.duration))
 	}
 
-	v.RLock()
-	index := sort.Search(len(v.idList), func(i int) bool {
-		return v.idList[i] >= id
-	})
-	i := len(v.idList) - index - 1
-	v.RUnlock()
+	// Once the schedule ends, the last run is never skipped.
+	if next := atomic.LoadInt64(&v.nextRun); next > 0 {
+		return time.Unix(0, next)
+	}
 
-	v.historyView.Select(i, 0)
-	v.currentID = id
-	unix := v.begin + id*int64(time.Millisecond)
-	v.timeView.SetText(time.Unix(unix/int64(time.Second), unix%int64(time.Second)).String())
+	return time.Time{}
 }
 
-func (v *Viddy) getSnapShot(id int64) *Snapshot {
-	s, ok := v.snapshots.Load(id)
-	if !ok {
-		return nil
+// jitterMarker is added to the title of the interval while runs are jittered.
+const jitterMarker = "~"
+
+// intervalLabel returns how often the command runs.
+func (v *Viddy) intervalLabel() string {
+	switch {
+	case v.load != "":
+		return "loaded"
+	case v.mode == ViddyIntervalModeOnce:
+		return "once"
+	case v.schedule != nil:
+		return v.schedule.String()
 	}
 
-	return s.(*Snapshot)
+	return v.backoff.interval(v.adaptive.interval(v.interval())).String()
 }
 
-func (v *Viddy) renderSnapshot(id int64) error {
-	s := v.getSnapShot(id)
-	if s == nil {
-		return errCannotCreateSnapshot
+// title returns what the header shows in place of the command: the command
+// line, or the title template with the snapshot shown.
+func (v *Viddy) title() string {
+	f := titleFields{command: strings.Join(v.activeCommand().line(), " ")}
+	if v.titleTemplate == nil {
+		return f.command
 	}
 
-	v.bodyView.Clear()
+	f.interval = v.intervalLabel()
+	f.host = v.titleHost
 
-	if !s.completed {
-		return errNotCompletedYet
+	if s := v.getSnapShot(v.renderedID); s != nil && s.isCompleted() {
+		f.exitCode = strconv.Itoa(s.exitStatus())
+		f.time = v.times.format(s.start, shortTimeLayout)
 	}
 
-	return s.render(v.bodyView, v.isShowDiff, v.query)
-}
-
-func (v *Viddy) UpdateStatusView() {
-	v.statusView.SetText(fmt.Sprintf("Time Machine: %s  Suspend: %s  Diff: %s",
-		convertToOnOrOff(v.isTimeMachine), convertToOnOrOff(v.isSuspend), convertToOnOrOff(v.isShowDiff)))
+	return renderTitle(v.titleTemplate, f)
 }
 
-func convertToOnOrOff(on bool) string {
-	if on {
-		return "[green]ON [reset]"
+// updateIntervalView shows the next scheduled run, with the date if it is
+// not within a day, or the interval and the failures while backing off.
+func (v *Viddy) updateIntervalView() {
+	marker := ""
+	if v.jitter > 0 {
+		marker = jitterMarker
 	}
 
-	return "[red]OFF[reset]"
-}
-
-func (v *Viddy) arrange() {
-	if v.showHelpView {
-		v.app.SetRoot(v.helpView, true)
+	if v.load != "" {
+		v.intervalView.SetTitle("Runs")
+		v.intervalView.SetText("none, loaded")
 
 		return
 	}
 
-	flex := tview.NewFlex().SetDirection(tview.FlexRow)
+	if v.mode == ViddyIntervalModeOnce {
+		v.intervalView.SetTitle("Runs")
+		v.intervalView.SetText("once")
 
-	if !v.isNoTitle {
-		flex.AddItem(
-			tview.NewFlex().SetDirection(tview.FlexColumn).
-				AddItem(v.intervalView, 10, 1, false).
-				AddItem(v.commandView, 0, 1, false).
-				AddItem(v.statusView, 45, 1, false).
-				AddItem(v.timeView, 21, 1, false),
-			3, 1, false)
+		return
 	}
 
-	body := tview.NewFlex().SetDirection(tview.FlexRow)
-	body.AddItem(v.bodyView, 0, 1, false)
+	if v.schedule == nil {
+		interval := v.intervalLabel()
+
+		switch failures := v.backoff.failureCount(); {
+		case failures > 0:
+			v.intervalView.SetTitle("Backoff" + marker)
+			v.intervalView.SetText(fmt.Sprintf("%s [red]✗%d[-]", interval, failures))
+		case v.adaptive != nil:
+			v.intervalView.SetTitle("Adaptive" + marker)
+			v.intervalView.SetText(interval)
+		case v.steps != nil:
+			v.intervalView.SetTitle("Stepped" + marker)
+			v.intervalView.SetText(interval)
+		default:
+			v.intervalView.SetTitle("Every" + marker)
+			v.intervalView.SetText(interval)
+		}
 
-	if v.isEditQuery || v.query != "" {
-		body.AddItem(v.queryEditor, 1, 1, false)
+		return
 	}
 
-	middle := tview.NewFlex().SetDirection(tview.FlexColumn).
-		AddItem(body, 0, 1, false)
+	v.intervalView.SetTitle("Next" + marker)
 
-	if v.isTimeMachine {
-		middle.AddItem(v.historyView, 21, 1, true)
-	}
+	next := atomic.LoadInt64(&v.nextRun)
 
-	flex.AddItem(
-		middle,
+	switch {
+	case next == 0:
+		return
+	case next < 0:
+		v.intervalView.SetText("never")
+
+		return
+	}
+
+	t := v.times.in(time.Unix(0, next))
+	if time.Until(t) < 24*time.Hour {
+		v.intervalView.SetText(t.Format("15:04:05"))
+	} else {
+		v.intervalView.SetText(t.Format("Jan 02"))
+	}
+}
+
+// updateRemoteStatus shows why the remote host could not be reached, and
+// clears that message once it is connected again.
+func (v *Viddy) updateRemoteStatus() {
+	v.updateCommandViewTitle()
+
+	connected, err := v.remote.status()
+	if !connected && err != nil {
+		msg := remoteError{target: v.remote.target, err: err}.Error()
+		if msg != v.message {
+			v.log(levelWarn, "remote host unreachable", "error", msg)
+			v.setMessage(msg)
+		}
+	} else if strings.HasPrefix(v.message, "ssh "+v.remote.target+": ") {
+		v.setMessage("")
+	}
+}
+
+func (v *Viddy) updateCommandViewTitle() {
+	title := "Command"
+	if v.file != "" {
+		title = "File"
+	}
+	if v.remote != nil {
+		title += " on " + tview.Escape(v.remote.target)
+
+		if connected, err := v.remote.status(); !connected && err != nil {
+			title += " [red](disconnected)[-]"
+		}
+	}
+
+	if v.dir != "" {
+		title += " in " + tview.Escape(v.dir)
+	}
+
+	if skipped := atomic.LoadInt64(&v.skippedRuns); skipped > 0 {
+		title += fmt.Sprintf(" [yellow](skipped %d runs)[-]", skipped)
+	}
+
+	if v.isPaused {
+		title += " [red]PAUSED[-]"
+	}
+
+	v.commandView.SetTitle(title)
+}
+
+func (v *Viddy) setSelection(id int64) {
+	if id == -1 {
+		return
+	}
+
+	v.historyView.ScrollToBeginning()
+
+	isSelectable, _ := v.historyView.GetSelectable()
+	if !isSelectable {
+		v.historyView.SetSelectable(true, false)
+	}
+
+	v.RLock()
+	index := sort.Search(len(v.idList), func(i int) bool {
+		return v.idList[i] >= id
+	})
+	i := len(v.idList) - index - 1
+	_, isBookmarked := v.bookmarks[id]
+	v.RUnlock()
+
+	v.historyView.Select(i, 0)
+
+	// The list follows the selection unless it was moved away from it outside the time machine.
+	if v.isTimeMachine || v.snapshotList.selected == v.currentID {
+		v.snapshotList.selected = id
+	}
+
+	v.currentID = id
+	v.timeView.SetText(v.times.format(time.Unix(0, v.begin+id*int64(time.Millisecond)), clockLayout))
+
+	if isBookmarked {
+		v.timeView.SetTitle("Time " + bookmarkMarker)
+	} else {
+		v.timeView.SetTitle("Time")
+	}
+
+	v.UpdateStatusView()
+}
+
+// historyRow returns the row of the snapshot in the history.
+func (v *Viddy) historyRow(id int64) (*HistoryRow, bool) {
+	v.RLock()
+	defer v.RUnlock()
+
+	r, ok := v.historyRows[id]
+
+	return r, ok
+}
+
+// pruneHistory drops the snapshots taken longer than keepFor ago from the
+// history. The snapshot shown stays, and so do the ones after it.
+func (v *Viddy) pruneHistory() {
+	cutoff := time.Since(time.Unix(0, v.begin)) - v.keepFor
+
+	keep := v.latestFinishedID
+	if v.currentID < keep {
+		keep = v.currentID
+	}
+
+	v.dropSnapshots(cutoff.Milliseconds(), keep)
+}
+
+// dropSnapshots drops the oldest snapshots taken before cutoff from the
+// history, up to keep, and returns how many it dropped.
+func (v *Viddy) dropSnapshots(cutoff, keep int64) int {
+	v.Lock()
+
+	n := v.droppableSnapshots(cutoff, keep)
+	if n == 0 {
+		v.Unlock()
+
+		return 0
+	}
+
+	pruned := v.idList[:n]
+	// The list and the timeline may still hold the old slice.
+	v.idList = append([]int64(nil), v.idList[n:]...)
+
+	for _, id := range pruned {
+		delete(v.historyRows, id)
+		delete(v.bookmarks, id)
+	}
+
+	v.Unlock()
+
+	for _, id := range pruned {
+		if s := v.getSnapShot(id); s != nil {
+			// Nothing compares with them anymore, so they can go together.
+			s.before, s.diffBase = nil, nil
+		}
+
+		v.snapshots.Delete(id)
+	}
+
+	// The history shows the newest snapshot first.
+	for i := 0; i < n; i++ {
+		v.historyView.RemoveRow(v.historyView.GetRowCount() - 1)
+	}
+
+	next := pruned[n-1] + 1
+	v.timelineMarks.prune(next)
+	v.changeLog.prune(next)
+	v.session.prune(next)
+
+	return n
+}
+
+// droppableSnapshots returns how many of the oldest snapshots dropSnapshots
+// would drop. The lock must be held.
+func (v *Viddy) droppableSnapshots(cutoff, keep int64) int {
+	return expiredSnapshots(v.idList, cutoff, keep, func(id int64) bool {
+		s := v.getSnapShot(id)
+
+		return s == nil || s.isSettled()
+	})
+}
+
+func (v *Viddy) getSnapShot(id int64) *Snapshot {
+	s, ok := v.snapshots.Load(id)
+	if !ok {
+		return nil
+	}
+
+	return s.(*Snapshot)
+}
+
+func (v *Viddy) renderSnapshot(id int64) error {
+	s := v.getSnapShot(id)
+	if s == nil {
+		return errCannotCreateSnapshot
+	}
+
+	v.bodyView.Clear()
+
+	if !s.isCompleted() {
+		v.setTruncation("")
+
+		if v.latestFinishedID == -1 {
+			v.bodyView.SetText(firstRunPlaceholder)
+		}
+
+		return errNotCompletedYet
+	}
+
+	v.unpackShown(s)
+	v.setTruncation(truncationNotice(s))
+
+	v.lineAges = nil
+	v.hunks = nil
+
+	if id != v.renderedID {
+		v.hunk = -1
+	}
+
+	if v.compareBase != nil {
+		v.shownLines = nil
+
+		return v.renderComparison(s)
+	}
+
+	if v.isShowRaw && s.raw != nil {
+		v.shownLines = nil
+
+		return v.renderRaw(s)
+	}
+
+	if (v.isShowDiff || v.isShowLineAge) && !s.diffPrepared {
+		_ = s.compareFromBefore()
+	}
+
+	if v.isShowLineAge {
+		v.lineAges = lineAgeLabels(s)
+	}
+
+	// The scroll follows the lines from the previous run still.
+	shown := s
+	if c := v.offsetComparison(s); c != nil {
+		shown = c
+	}
+
+	// The single run of --once has nothing to compare with, so the diff
+	// highlights nothing.
+	isShowDiff := v.isShowDiff && !(v.mode == ViddyIntervalModeOnce && shown.diffBase == nil)
+	if isShowDiff {
+		v.updateChangesView(shown)
+		v.hunks = changeHunksOf(shown, v.isPermanentDiff)
+	} else {
+		v.changesView.SetText("[::d]" + diffStats{}.String() + "[-:-:-]")
+	}
+
+	if !v.isStickyScroll && id != v.renderedID {
+		v.bodyView.ScrollToBeginning()
+	}
+
+	line, column, anchored := v.scrollAnchor(s)
+	v.renderedID = id
+
+	if v.isSideBySide && !isWhiteString(string(s.result)) {
+		if err := v.renderSideBySide(shown); err != nil {
+			return err
+		}
+
+		if anchored {
+			v.bodyView.scrollToLine(v.displayRow(line), column)
+		}
+
+		return nil
+	}
+
+	var b bytes.Buffer
+	if err := shown.render(&b, isShowDiff, v.isPermanentDiff, v.query.pattern(), v.theme); err != nil {
+		return err
+	}
+
+	text := b.String()
+
+	triggered := v.triggered != nil && v.triggered.id == id
+	if triggered {
+		text = markRegion(text, v.triggered.line, triggerRegion)
+	}
+
+	v.shownLines = nil
+	if v.isChangesOnly {
+		text = v.collapseChanges(shown, text)
+	}
+
+	if _, err := io.WriteString(v.bodyView, text); err != nil {
+		return err
+	}
+
+	if triggered {
+		v.bodyView.Highlight(triggerRegion)
+		v.scrollToLine(v.displayRow(v.triggered.line))
+
+		return nil
+	}
+
+	v.bodyView.Highlight()
+
+	if anchored {
+		v.bodyView.scrollToLine(v.displayRow(line), column)
+	}
+
+	return nil
+}
+
+// renderStream shows the output of the run going on so far, while it is the
+// latest one and the time machine is off.
+func (v *Viddy) renderStream(s *Snapshot, started time.Time) {
+	if v.isTimeMachine || !s.isStreaming() || s.id < v.latestFinishedID {
+		return
+	}
+
+	if s.id != v.renderedID {
+		v.log(levelInfo, "run streaming", "id", s.id, "policy", string(v.streamPolicy))
+	}
+
+	v.bodyView.Clear()
+	v.renderedID = s.id
+	v.shownLines = nil
+
+	output := expandTabs(string(completeLines(s.partialOutput())), s.opts.tabWidth)
+	_, _ = io.Copy(tview.ANSIWriter(v.bodyView), strings.NewReader(output))
+
+	v.bodyView.ScrollToEnd()
+	v.setTruncation(streamNotice(started))
+}
+
+// renderSideBySide shows the snapshot which s is compared with beside it.
+func (v *Viddy) renderSideBySide(s *Snapshot) error {
+	// Until the previous snapshot is done, only this one is shown.
+	if !s.diffPrepared {
+		_ = s.compareFromBefore()
+	}
+
+	left, right, origins, err := renderSideBySide(s.diffBase, s, v.query.pattern(), v.theme)
+	if err != nil {
+		return err
+	}
+
+	v.shownLines = origins
+
+	v.previousView.Clear()
+	if _, err := io.WriteString(v.previousView, left); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(v.bodyView, right)
+
+	return err
+}
+
+// collapseChanges keeps the lines of the rendered snapshot which changed
+// since the previous one, with the context lines around them.
+func (v *Viddy) collapseChanges(s *Snapshot, rendered string) string {
+	if isWhiteString(string(s.result)) {
+		return rendered
+	}
+
+	if !s.diffPrepared {
+		if err := s.compareFromBefore(); err != nil {
+			return rendered
+		}
+	}
+
+	// The first snapshot has nothing to compare with.
+	if s.diffBase == nil {
+		return rendered
+	}
+
+	changed := s.lines.changedLines()
+
+	lines, origins := collapseLines(strings.Split(rendered, "\n"), changed, v.changesContext)
+	if len(lines) == 0 {
+		// The output is the same since the first snapshot in the run without changes.
+		since := s
+		for since.diffBase != nil && since.diffPrepared && since.diffAdditionCount+since.diffDeletionCount == 0 {
+			since = since.diffBase
+		}
+
+		v.shownLines = []int{0}
+		// The notice is not a line of the output.
+		v.lineAges = nil
+
+		return "no changes since " + v.times.format(since.start, shortTimeLayout)
+	}
+
+	v.shownLines = origins
+
+	return strings.Join(lines, "\n")
+}
+
+// originalLine returns the line of the output shown on the row of the body's
+// text, which may take several rows on screen once wrapped.
+func (v *Viddy) originalLine(row int) int {
+	if v.shownLines == nil {
+		return row
+	}
+
+	if row >= len(v.shownLines) {
+		row = len(v.shownLines) - 1
+	}
+
+	if row < 0 {
+		return 0
+	}
+
+	return v.shownLines[row]
+}
+
+// displayRow returns the row of the body's text showing the line of the
+// output, or the next line shown after it.
+func (v *Viddy) displayRow(line int) int {
+	if v.shownLines == nil {
+		return line
+	}
+
+	row := sort.SearchInts(v.shownLines, line)
+	if row >= len(v.shownLines) {
+		row = len(v.shownLines) - 1
+	}
+
+	if row < 0 {
+		return 0
+	}
+
+	return row
+}
+
+func (v *Viddy) SetIsSideBySide(b bool) {
+	v.isSideBySide = b
+	v.setSelection(v.currentID)
+	v.arrange()
+}
+
+// SetIsShowRaw shows the output the way the command wrote it, before the
+// --pipe filter, or the filtered output again.
+func (v *Viddy) SetIsShowRaw(b bool) {
+	if v.pipe == "" {
+		v.setMessage("No --pipe filter")
+
+		return
+	}
+
+	v.isShowRaw = b
+	if b {
+		v.setMessage("Output before --pipe")
+	} else {
+		v.setMessage("Output through --pipe")
+	}
+
+	v.setSelection(v.currentID)
+}
+
+func (v *Viddy) SetIsNoWrap(b bool) {
+	v.isNoWrap = b
+	v.bodyView.SetWrap(!b)
+	v.previousView.SetWrap(!b)
+	v.setSelection(v.currentID)
+}
+
+func (v *Viddy) SetIsShowLineAge(b bool) {
+	v.isShowLineAge = b
+	v.setSelection(v.currentID)
+	v.arrange()
+}
+
+// stderrModeOfRuns returns how the runs to come capture stderr.
+func (v *Viddy) stderrModeOfRuns() StderrMode {
+	return v.stderrMode.Load().(StderrMode)
+}
+
+// setStderrMode changes how the runs to come capture stderr. The runs so far
+// keep the mode they ran with.
+func (v *Viddy) setStderrMode(mode StderrMode) {
+	v.stderrMode.Store(mode)
+	v.setMessage(fmt.Sprintf("Stderr of new runs: %s", mode))
+}
+
+// updateChangesView shows how many lines the snapshot added, removed and
+// modified. Dimmed zeros tell at a glance that nothing changed.
+func (v *Viddy) updateChangesView(s *Snapshot) {
+	if s.lines == nil {
+		v.changesView.SetText("")
+
+		return
+	}
+
+	if s.lines.stats.isZero() {
+		v.changesView.SetText("[::d]" + s.lines.stats.String() + "[-:-:-]")
+
+		return
+	}
+
+	v.changesView.SetText(s.lines.stats.String())
+}
+
+// setTruncation shows the notice below the body while it is not empty.
+func (v *Viddy) setTruncation(notice string) {
+	if notice == v.truncation {
+		return
+	}
+
+	v.truncation = notice
+	v.truncationView.SetText(notice)
+	v.arrange()
+}
+
+// drawClipMarkers marks the lines of the body which are cut off while it
+// does not wrap.
+func (v *Viddy) drawClipMarkers(screen tcell.Screen) {
+	if !v.isNoWrap {
+		return
+	}
+
+	color := v.theme.clipMarker
+	if color == tcell.ColorDefault {
+		color = tview.Styles.TertiaryTextColor
+	}
+
+	drawClipMarkers(screen, v.bodyView.TextView, v.bodyView.widths(), color)
+
+	if v.isSideBySide {
+		drawClipMarkers(screen, v.previousView.TextView, v.previousView.widths(), color)
+	}
+}
+
+func (v *Viddy) SetIsChangesOnly(b bool) {
+	row, column := v.bodyView.scrollLine()
+	line := v.originalLine(row)
+
+	v.isChangesOnly = b
+	v.setSelection(v.currentID)
+	v.bodyView.scrollToLine(v.displayRow(line), column)
+}
+
+const triggerRegion = "trigger"
+
+type triggeredSnapshot struct {
+	id int64
+	triggerMatch
+}
+
+// fireTrigger rings the bell, flashes the header and highlights the matching
+// line of the snapshot, or stops viddy with --trigger-exit.
+func (v *Viddy) fireTrigger(id int64, m triggerMatch) {
+	v.log(levelInfo, "trigger fired", "id", id, "line", m.text)
+
+	v.triggered = &triggeredSnapshot{id: id, triggerMatch: m}
+	v.isBeeping = true
+	v.flashHeader(headerFlashDuration)
+	v.setMessage("Trigger: " + tview.Escape(m.text))
+
+	if v.currentID == id {
+		_ = v.renderSnapshot(id)
+	}
+
+	if v.triggerExit {
+		v.exitLine = &m.text
+		v.group.quit()
+	}
+}
+
+const headerFlashDuration = time.Second
+
+// flashHeader highlights the header for d. The timer leaves the runs alone,
+// and a later flash takes over the one before.
+func (v *Viddy) flashHeader(d time.Duration) {
+	for _, view := range v.headerViews() {
+		view.SetBackgroundColor(v.theme.flash)
+	}
+
+	v.flashID++
+	id := v.flashID
+
+	time.AfterFunc(d, func() {
+		v.app.QueueUpdateDraw(func() {
+			if v.flashID == id {
+				v.stopFlash()
+			}
+		})
+	})
+}
+
+// stopFlash ends the flash of the header, if any.
+func (v *Viddy) stopFlash() {
+	v.flashID++
+
+	for _, view := range v.headerViews() {
+		view.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+	}
+}
+
+// scrollToLine scrolls the body to show the line of its text with
+// scroll_off rows around it.
+func (v *Viddy) scrollToLine(line int) {
+	top, column := v.bodyView.GetScrollOffset()
+	_, _, _, height := v.bodyView.GetInnerRect()
+	row, total := v.bodyView.rowOf(line), v.bodyView.rowCount()
+
+	v.bodyView.ScrollTo(scrollWithMargin(top, row, height, total, v.scrollOff), column)
+}
+
+// jumpToMatch scrolls to the first line from the top of the body on which
+// contains the query.
+func (v *Viddy) jumpToMatch() {
+	lines := strings.Split(v.bodyView.GetText(true), "\n")
+	top, _ := v.bodyView.scrollLine()
+
+	if line := findMatch(lines, v.query.pattern(), top); line >= 0 {
+		v.scrollToLine(line)
+	}
+}
+
+// setSearchQuery changes how the search matches, and marks the matches on
+// the snapshot shown again.
+func (v *Viddy) setSearchQuery(q searchQuery) {
+	v.query = q
+	v.queryEditor.SetLabel(q.label())
+	_ = v.renderSnapshot(v.currentID)
+}
+
+func (v *Viddy) toggleSearchCase() {
+	q := v.query
+	q.ignoreCase = !q.ignoreCase
+	v.setSearchQuery(q)
+}
+
+func (v *Viddy) toggleSearchWord() {
+	q := v.query
+	q.wholeWord = !q.wholeWord
+	v.setSearchQuery(q)
+}
+
+// searchOptionAction returns the toggle of a search option bound to the key,
+// which works while the query is being edited too, or nil.
+func (v *Viddy) searchOptionAction(event *tcell.EventKey) func() {
+	seq := newKeySequence(keyStrokeFromEvent(event))
+
+	if _, ok := v.keymap.toggleSearchCase[seq]; ok {
+		return v.toggleSearchCase
+	}
+
+	if _, ok := v.keymap.toggleSearchWord[seq]; ok {
+		return v.toggleSearchWord
+	}
+
+	return nil
+}
+
+// scrollAnchor returns the line of the output to keep at the top of the body
+// when moving from a snapshot to the next one, so that the same logical lines
+// stay on screen even if lines were inserted or removed above them.
+func (v *Viddy) scrollAnchor(s *Snapshot) (int, int, bool) {
+	if !s.diffPrepared {
+		if err := s.compareFromBefore(); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	if s.diffBase == nil || s.diffBase.id != v.renderedID {
+		return 0, 0, false
+	}
+
+	row, column := v.bodyView.GetScrollOffset()
+	if row == 0 {
+		return 0, 0, false
+	}
+
+	rows, _ := v.viewportSize()
+	top := v.originalLine(v.bodyView.lineOf(row))
+	visible := v.originalLine(v.bodyView.lineOf(row+int(rows)-1)) - top + 1
+
+	return s.lines.anchor(top, visible), column, true
+}
+
+func (v *Viddy) UpdateStatusView() {
+	if pending := v.keys.pendingString(); pending != "" {
+		v.statusView.SetTitle("Status: " + tview.Escape(pending))
+	} else {
+		v.statusView.SetTitle("Status")
+	}
+
+	s := status{
+		mode:        v.mode,
+		interval:    v.tuned.interval(v.duration),
+		running:     atomic.LoadInt64(&v.running) > 0,
+		frame:       v.spinnerFrame,
+		timeMachine: v.isTimeMachine,
+		suspend:     v.isSuspend,
+		diff:        v.isShowDiff,
+		diffAgainst: v.diffBaseline,
+	}
+
+	if v.mode == ViddyIntervalModeSchedule || v.mode == ViddyIntervalModeOnce {
+		s.interval = 0
+	}
+
+	if d, ok := v.lastDrift(); ok {
+		s.drift = formatDrift(d)
+	}
+
+	if v.isTimeMachine {
+		s.position, s.count = v.timeMachinePosition()
+		s.ranCommand = v.ranCommand(v.currentID)
+		s.newSnapshots = v.newSnapshots
+		s.scrubStep = v.scrub.multiplier(time.Now())
+		s.paused = v.isPaused || !v.pollInTimeMachine
+	}
+
+	if v.hunk >= 0 && v.hunk < len(v.hunks) {
+		s.change, s.changes = v.hunk+1, len(v.hunks)
+	}
+
+	v.statusView.SetText(formatStatus(v.statusItems, s))
+}
+
+func convertToOnOrOff(on bool) string {
+	if on {
+		return "[green]ON [reset]"
+	}
+
+	return "[red]OFF[reset]"
+}
+
+func (v *Viddy) arrange() {
+	v.group.arrange()
+}
+
+// layout builds the pane of the viddy for its current state.
+func (v *Viddy) layout() tview.Primitive {
+	flex := tview.NewFlex().SetDirection(tview.FlexRow)
+
+	if !v.isNoTitle {
+		var changes *tview.TextView
+		if v.isShowDiff {
+			changes = v.changesView
+		}
+
+		clock := v.times.width(clockLayout) + 2
+		var status *tview.TextView
+		if len(v.statusItems) > 0 {
+			status = v.statusView
+		}
+
+		flex.AddItem(newHeader(v.intervalView, v.commandView, v.hostView, status, changes, v.timeView, clock), 3, 1, false)
+	}
+
+	if v.isTimeMachine && v.showTimeline {
+		flex.AddItem(v.timeline, 1, 1, false)
+	}
+
+	body := tview.NewFlex().SetDirection(tview.FlexRow)
+
+	switch {
+	case v.isSideBySide:
+		body.AddItem(v.sideBySide, 0, 1, false)
+	case v.showsLineAges():
+		body.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(v.lineAgeView, lineAgeWidth, 1, false).
+			AddItem(v.bodyView, 0, 1, false), 0, 1, false)
+	default:
+		body.AddItem(v.bodyView, 0, 1, false)
+	}
+
+	if v.truncation != "" {
+		body.AddItem(v.truncationView, 1, 1, false)
+	}
+
+	if v.isEditQuery || v.query.text != "" {
+		body.AddItem(v.queryEditor, 1, 1, false)
+	}
+
+	if v.isEditTime {
+		body.AddItem(v.timeEditor, 1, 1, false)
+	}
+
+	if v.isEditCommand {
+		body.AddItem(v.commandEditor, 1, 1, false)
+	}
+
+	middle := tview.NewFlex().SetDirection(tview.FlexColumn)
+
+	if v.showSnapshotList {
+		middle.AddItem(v.snapshotList, 14+v.times.width(snapshotListLayout), 1, false)
+	}
+
+	middle.AddItem(body, 0, 1, false)
+
+	if v.showChangeLog {
+		middle.AddItem(v.changeLogView, changeLogWidth+v.times.width(shortTimeLayout), 1, false)
+	}
+
+	if v.isTimeMachine {
+		middle.AddItem(v.historyView, 21, 1, true)
+	}
+
+	flex.AddItem(
+		middle,
 		0, 1, false)
 
-	if v.showLogView {
-		flex.AddItem(v.logView, 10, 1, false)
+	if v.message != "" {
+		flex.AddItem(v.messageView, strings.Count(v.message, "\n")+1, 1, false)
+	}
+
+	if v.showLogView {
+		flex.AddItem(v.logView, 10, 1, false)
+	}
+
+	v.frame = flex
+
+	return flex
+}
+
+// highlightHeader marks the header of the focused pane.
+func (v *Viddy) highlightHeader(focused bool) {
+	color := tview.Styles.BorderColor
+	if focused {
+		color = tview.Styles.SecondaryTextColor
+	}
+
+	for _, view := range v.headerViews() {
+		view.SetBorderColor(color)
+	}
+}
+
+func (v *Viddy) headerViews() []*tview.TextView {
+	views := []*tview.TextView{v.intervalView, v.commandView.TextView, v.statusView, v.changesView, v.timeView}
+	if v.hostView != nil {
+		views = append(views, v.hostView)
+	}
+
+	return views
+}
+
+// setup makes the widgets of the viddy, which is shown by the app.
+//
+//nolint:funlen
+func (v *Viddy) setup(app *tview.Application) error {
+	b := newReflowView()
+	b.SetDynamicColors(true)
+	b.SetTitle("body")
+	b.SetRegions(true)
+	b.SetText(firstRunPlaceholder)
+	v.bodyView = b
+
+	pv := newReflowView()
+	pv.SetDynamicColors(true)
+	v.previousView = pv
+
+	b.SetWrap(!v.isNoWrap)
+	pv.SetWrap(!v.isNoWrap)
+
+	tv := tview.NewTextView()
+	tv.SetTextColor(v.theme.clipMarker)
+	if v.theme.clipMarker == tcell.ColorDefault {
+		tv.SetTextColor(tview.Styles.TertiaryTextColor)
+	}
+	v.truncationView = tv
+	v.sideBySide = newSplitView(pv, b)
+	v.lineAgeView = tview.NewBox()
+
+	t := tview.NewTextView()
+	t.SetBorder(true).SetTitle("Time")
+	v.timeView = t
+
+	h := tview.NewTable()
+	h.SetBorder(true).SetTitle("History")
+	h.ScrollToBeginning()
+	h.SetSelectionChangedFunc(func(row, column int) {
+		c := h.GetCell(row, column)
+		id, err := strconv.ParseInt(c.Text, 10, 64)
+		if err == nil {
+			_ = v.renderSnapshot(id)
+		}
+	})
+	h.SetSelectedStyle(tcell.StyleDefault.Background(tcell.ColorGray))
+
+	v.historyView = h
+
+	v.snapshotList = newSnapshotList(func() []int64 {
+		v.RLock()
+		defer v.RUnlock()
+
+		return v.idList
+	}, v.formatSnapshotListRow)
+
+	v.changeLogView = newSnapshotList(v.changeLog.ids, v.formatChangeLogRow)
+	v.changeLogView.SetTitle("Changes")
+
+	v.timeline = newTimeline(func() []int64 {
+		v.RLock()
+		defer v.RUnlock()
+
+		return v.idList
+	}, func() int64 { return v.currentID }, &v.timelineMarks, time.Unix(0, v.begin))
+
+	c := newTitleView(v.title)
+	c.SetBorder(true)
+	v.commandView = c
+	v.updateCommandViewTitle()
+
+	if v.host != "" {
+		hv := tview.NewTextView()
+		hv.SetBorder(true).SetTitle("Host")
+		hv.SetDynamicColors(true)
+		hv.SetText(tview.Escape(v.host))
+		v.hostView = hv
+	}
+
+	d := tview.NewTextView()
+	d.SetBorder(true)
+	d.SetDynamicColors(true)
+	v.intervalView = d
+	v.updateIntervalView()
+
+	s := tview.NewTextView()
+	s.SetBorder(true).SetTitle("Status")
+	s.SetDynamicColors(true)
+	v.statusView = s
+
+	cv := tview.NewTextView()
+	cv.SetBorder(true).SetTitle("Changes")
+	cv.SetDynamicColors(true)
+	v.changesView = cv
+
+	l := tview.NewTextView()
+	l.SetBorder(true).SetTitle("Log")
+	l.ScrollToEnd()
+	v.logView = l
+
+	// The records of the startup are in the debug log once, and shown in
+	// the log view of every pane.
+	for _, r := range v.startupLog {
+		if v.debugLog.enabled(r.level) {
+			_, _ = l.Write(formatLogRecord(time.Now(), r))
+		}
+	}
+
+	closeKeys := "Esc"
+	if len(v.keymap.toggleHelp) > 0 {
+		closeKeys += " or " + keysToString(v.keymap.toggleHelp)
+	}
+
+	v.helpView = newHelpModal(fmt.Sprintf(" Press %s to close\n\n", tview.Escape(closeKeys)) + formatHelp(v.keymap.helpSections()))
+
+	q := tview.NewInputField().SetLabel(v.query.label())
+	q.SetChangedFunc(func(text string) {
+		v.query.text = text
+	})
+	q.SetDoneFunc(func(key tcell.Key) {
+		v.isEditQuery = false
+		v.arrange()
+
+		if key == tcell.KeyEnter && v.query.text != "" {
+			v.jumpToMatch()
+		}
+	})
+
+	v.queryEditor = q
+
+	te := tview.NewInputField().SetLabel(timeEditorLabel)
+	te.SetChangedFunc(func(text string) {
+		te.SetLabel(timeEditorLabel)
+	})
+	te.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			if err := v.goToTime(te.GetText()); err != nil {
+				te.SetLabel(fmt.Sprintf("Go to time (%s): ", err))
+
+				return
+			}
+		}
+
+		v.isEditTime = false
+		v.arrange()
+	})
+
+	v.timeEditor = te
+
+	ce := tview.NewInputField().SetLabel(commandEditorLabel)
+	ce.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			v.setCommand(ce.GetText())
+		}
+
+		v.isEditCommand = false
+		v.arrange()
+	})
+
+	v.commandEditor = ce
+
+	m := tview.NewTextView()
+	m.SetTextColor(tcell.ColorYellow)
+	v.messageView = m
+
+	if v.debugLog != nil {
+		v.message = "Debug log: " + v.debugLog.path
+		m.SetText(v.message)
+	}
+
+	if v.logFile != "" {
+		l, err := openOutputLog(v.logFile, v.logMaxSize)
+		if err != nil {
+			return err
+		}
+
+		v.outputLog = l
+	}
+
+	if v.historyPath != "" {
+		h, err := openHistoryFile(v.historyPath)
+		if err != nil {
+			return err
+		}
+
+		v.history = h
+	}
+
+	v.app = app
+	v.generalActions, v.timeMachineActions = v.keyActions()
+	v.helpActions = v.helpKeyActions()
+
+	v.UpdateStatusView()
+	v.messageView.SetText(v.message)
+
+	return nil
+}
+
+// handleKey runs the action of the key when the pane is focused.
+func (v *Viddy) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	v.log(levelDebug, "key", "name", event.Name())
+
+	if v.isEditQuery {
+		if run := v.searchOptionAction(event); run != nil {
+			run()
+
+			return nil
+		}
+
+		v.queryEditor.InputHandler()(event, nil)
+
+		return event
+	}
+
+	if v.isEditTime {
+		v.timeEditor.InputHandler()(event, nil)
+
+		return event
+	}
+
+	if v.isEditCommand {
+		v.commandEditor.InputHandler()(event, nil)
+
+		return event
+	}
+
+	if v.confirm != nil {
+		v.answer(event)
+
+		return nil
+	}
+
+	if v.message != "" {
+		v.setMessage("")
+	}
+
+	if event.Key() == tcell.KeyEsc && v.showHelpView {
+		v.ShowHelpView(false)
+
+		return nil
+	}
+
+	if event.Key() == tcell.KeyEsc && v.compareBase != nil {
+		v.stopComparing()
+
+		return nil
+	}
+
+	if v.showChangeLog && v.changeLogView.handleKey(event, v.showSnapshotFromList) {
+		return nil
+	}
+
+	if v.showSnapshotList && v.snapshotList.handleKey(event, v.showSnapshotFromList) {
+		return nil
+	}
+
+	scopes := [][]keyAction{v.generalActions}
+
+	switch {
+	case v.showHelpView:
+		scopes = [][]keyAction{v.helpActions}
+	case v.isTimeMachine:
+		scopes = [][]keyAction{v.timeMachineActions, v.generalActions}
+	}
+
+	run, waiting := v.keys.feed(scopes, keyStrokeFromEvent(event))
+	if run != nil {
+		run()
+	}
+
+	v.keysWaitID++
+	if waiting {
+		v.waitForKeys(v.keysWaitID)
+	}
+
+	v.UpdateStatusView()
+
+	return nil
+}
+
+// start runs the command and shows its snapshots as they come in.
+func (v *Viddy) start() {
+	go v.diffQueueHandler()
+	go v.onChangeHandler()
+	go v.notifyHandler()
+	go v.queueHandler()
+	go v.startRunner()
+
+	if v.file != "" {
+		go v.watchFile()
+	}
+
+	if v.compressAfter > 0 {
+		go v.compactHistory()
+	}
+
+	if v.session != nil {
+		go v.saveSessionPeriodically()
+	}
+}
+
+// stop cleans up after the app stopped, returning the first error.
+func (v *Viddy) stop() error {
+	v.killRunning()
+
+	var err error
+
+	if v.outputLog != nil {
+		err = v.outputLog.Close()
+	}
+
+	if closeErr := v.history.Close(); err == nil {
+		err = closeErr
+	}
+
+	if v.session != nil {
+		if saveErr := v.session.save(v.sessionSettings()); err == nil {
+			err = saveErr
+		}
+	}
+
+	return err
+}
+
+// exitStatus returns the exit status of the latest finished run, 0 if none
+// finished.
+func (v *Viddy) exitStatus() int {
+	s := v.getSnapShot(v.latestFinishedID)
+	if s == nil {
+		return 0
+	}
+
+	return s.exitStatus()
+}
+
+// newTruecolorScreen makes a screen which uses 24-bit colors even if the terminal does not advertise them.
+func newTruecolorScreen() (tcell.Screen, error) {
+	old, ok := os.LookupEnv("TCELL_TRUECOLOR")
+
+	// tcell reads it when the screen is made, it must not leak into the command.
+	defer func() {
+		if ok {
+			_ = os.Setenv("TCELL_TRUECOLOR", old)
+		} else {
+			_ = os.Unsetenv("TCELL_TRUECOLOR")
+		}
+	}()
+
+	if err := os.Setenv("TCELL_TRUECOLOR", "enable"); err != nil {
+		return nil, err
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+
+	return screen, nil
+}
+
+// saveSessionPeriodically saves the session while new runs come in, so that
+// little is lost if viddy does not stop cleanly.
+func (v *Viddy) saveSessionPeriodically() {
+	for range time.Tick(sessionSaveInterval) {
+		if !v.session.isDirty() {
+			continue
+		}
+
+		if err := v.session.save(v.sessionSettings()); err != nil {
+			v.reportError(err)
+		}
+	}
+}
+
+func (v *Viddy) sessionSettings() sessionSettings {
+	v.RLock()
+	defer v.RUnlock()
+
+	bookmarks := make([]int64, 0, len(v.bookmarks))
+	for id := range v.bookmarks {
+		bookmarks = append(bookmarks, id)
+	}
+
+	sort.Slice(bookmarks, func(i, j int) bool { return bookmarks[i] < bookmarks[j] })
+
+	return sessionSettings{
+		Differences:   v.isShowDiff,
+		PermanentDiff: v.isPermanentDiff,
+		ChangesOnly:   v.isChangesOnly,
+		SideBySide:    v.isSideBySide,
+		NoWrap:        v.isNoWrap,
+		NoTitle:       v.isNoTitle,
+		Bookmarks:     bookmarks,
+	}
+}
+
+// killRunning terminates the commands which are still running, so that they do not outlive viddy.
+func (v *Viddy) killRunning() {
+	v.snapshots.Range(func(_, value interface{}) bool {
+		if s := value.(*Snapshot); !s.isCompleted() {
+			s.kill()
+		}
+
+		return true
+	})
+}
+
+// keyAction runs an action when one of its keys is pressed.
+type keyAction struct {
+	keys map[KeySequence]struct{}
+	run  func()
+}
+
+// keySequenceTimeout is how long a key sequence waits for its next key.
+const keySequenceTimeout = 500 * time.Millisecond
+
+// waitForKeys runs the action of the keys pressed so far unless another key comes in time.
+func (v *Viddy) waitForKeys(id int64) {
+	time.AfterFunc(keySequenceTimeout, func() {
+		v.app.QueueUpdateDraw(func() {
+			if v.keysWaitID != id {
+				return
+			}
+
+			if run := v.keys.timeout(); run != nil {
+				run()
+			}
+
+			v.UpdateStatusView()
+		})
+	})
+}
+
+//nolint:funlen
+func (v *Viddy) keyActions() ([]keyAction, []keyAction) {
+	general := []keyAction{
+		{keys: v.keymap.toggleTimeMachine, run: func() { v.SetIsTimeMachine(!v.isTimeMachine) }},
+		{keys: v.keymap.quit, run: func() { v.group.quit() }},
+		{keys: v.keymap.toggleSuspend, run: func() { v.isSuspend = !v.isSuspend }},
+		{keys: v.keymap.togglePause, run: v.togglePause},
+		{keys: v.keymap.toggleDiff, run: func() { v.SetIsShowDiff(!v.isShowDiff) }},
+		{keys: v.keymap.resetDiff, run: v.resetPermanentDiff},
+		{keys: v.keymap.clearHistory, run: v.askClearHistory},
+		{keys: v.keymap.toggleChangesOnly, run: func() { v.SetIsChangesOnly(!v.isChangesOnly) }},
+		{keys: v.keymap.toggleSideBySide, run: func() { v.SetIsSideBySide(!v.isSideBySide) }},
+		{keys: v.keymap.toggleWrap, run: func() { v.SetIsNoWrap(!v.isNoWrap) }},
+		{keys: v.keymap.toggleLineAge, run: func() { v.SetIsShowLineAge(!v.isShowLineAge) }},
+		{keys: v.keymap.editCommand, run: func() {
+			if v.file != "" {
+				v.setMessage("Watching a file, there is no command to edit")
+
+				return
+			}
+
+			if v.load != "" {
+				v.setMessage("Looking back at a loaded history, nothing runs")
+
+				return
+			}
+
+			v.commandEditor.SetText(strings.Join(v.activeCommand().line(), " "))
+			v.isEditCommand = true
+			v.arrange()
+		}},
+		{keys: v.keymap.toggleHeader, run: func() { v.SetIsNoTitle(!v.isNoTitle) }},
+		{keys: v.keymap.toggleHelp, run: func() { v.ShowHelpView(!v.showHelpView) }},
+		{keys: v.keymap.toggleSnapshotList, run: func() { v.ShowSnapshotList(!v.showSnapshotList) }},
+		{keys: v.keymap.toggleChangeLog, run: func() { v.ShowChangeLog(!v.showChangeLog) }},
+		{keys: v.keymap.toggleStderr, run: func() { v.setStderrMode(v.stderrModeOfRuns().next()) }},
+		{keys: v.keymap.toggleRaw, run: func() { v.SetIsShowRaw(!v.isShowRaw) }},
+		{keys: v.keymap.yank, run: v.yankOutput},
+		{keys: v.keymap.yankVisible, run: v.yankVisible},
+		{keys: v.keymap.shell, run: func() { v.group.suspendToShell(v.shell, !v.pollWhileSuspended) }},
+		{keys: v.keymap.toggleLog, run: func() {
+			if v.isDebug {
+				v.ShowLogView(!v.showLogView)
+			}
+		}},
+		{keys: v.keymap.search, run: func() {
+			if v.query.text != "" {
+				v.query.text = ""
+				v.queryEditor.SetText("")
+			}
+			v.isEditQuery = true
+			v.arrange()
+		}},
+		{keys: v.keymap.toggleSearchCase, run: v.toggleSearchCase},
+		{keys: v.keymap.toggleSearchWord, run: v.toggleSearchWord},
+		{keys: v.keymap.scrollUp, run: func() { v.scrollBody(-1, 0) }},
+		{keys: v.keymap.scrollDown, run: func() { v.scrollBody(1, 0) }},
+		{keys: v.keymap.scrollLeft, run: func() { v.scrollBody(0, -1) }},
+		{keys: v.keymap.scrollRight, run: func() { v.scrollBody(0, 1) }},
+		{keys: v.keymap.pageUp, run: func() { v.scrollBody(-v.bodyPageSize(), 0) }},
+		{keys: v.keymap.pageDown, run: func() { v.scrollBody(v.bodyPageSize(), 0) }},
+		{keys: v.keymap.scrollToTop, run: func() { v.bodyView.ScrollToBeginning() }},
+		{keys: v.keymap.scrollToBottom, run: func() { v.bodyView.ScrollToEnd() }},
+		{keys: v.keymap.nextChange, run: func() { v.goToChange(true) }},
+		{keys: v.keymap.previousChange, run: func() { v.goToChange(false) }},
+		{keys: v.keymap.toggleBookmark, run: v.toggleBookmark},
+		{keys: v.keymap.nextBookmark, run: func() { v.goToBookmark(true) }},
+		{keys: v.keymap.previousBookmark, run: func() { v.goToBookmark(false) }},
+		{keys: v.keymap.clearBookmarks, run: v.clearBookmarks},
+		{keys: v.keymap.increaseInterval, run: func() { v.changeInterval(true) }},
+		{keys: v.keymap.decreaseInterval, run: func() { v.changeInterval(false) }},
+		{keys: v.keymap.focusNextPane, run: v.group.focusNext},
+	}
+
+	timeMachine := []keyAction{
+		{keys: v.keymap.goToPastOnTimeMachine, run: func() { v.scrubOnTimeMachine(scrubPast) }},
+		{keys: v.keymap.goToFutureOnTimeMachine, run: func() { v.scrubOnTimeMachine(scrubFuture) }},
+		{keys: v.keymap.goToMorePastOnTimeMachine, run: func() {
+			if v.isPlaying {
+				v.setPlaybackSpeed(v.playbackSpeed.scale(0.5))
+			} else {
+				v.goToMorePastOnTimeMachine()
+			}
+		}},
+		{keys: v.keymap.goToMoreFutureOnTimeMachine, run: func() {
+			if v.isPlaying {
+				v.setPlaybackSpeed(v.playbackSpeed.scale(2))
+			} else {
+				v.goToMoreFutureOnTimeMachine()
+			}
+		}},
+		{keys: v.keymap.goToNowOnTimeMachine, run: v.goToNowOnTimeMachine},
+		{keys: v.keymap.goToOldestOnTimeMachine, run: v.goToOldestOnTimeMachine},
+		{keys: v.keymap.goToTimeOnTimeMachine, run: func() {
+			v.timeEditor.SetText("")
+			v.timeEditor.SetLabel(timeEditorLabel)
+			v.isEditTime = true
+			v.arrange()
+		}},
+		{keys: v.keymap.backDurationOnTimeMachine, run: func() { v.stepOnTimeMachine(-v.timeMachineStep) }},
+		{keys: v.keymap.forwardDurationOnTimeMachine, run: func() { v.stepOnTimeMachine(v.timeMachineStep) }},
+		{keys: v.keymap.increaseStepOnTimeMachine, run: func() { v.adjustTimeMachineStep(true) }},
+		{keys: v.keymap.decreaseStepOnTimeMachine, run: func() { v.adjustTimeMachineStep(false) }},
+		{keys: v.keymap.playOnTimeMachine, run: func() {
+			if v.isPlaying {
+				v.stopPlayback()
+			} else {
+				v.startPlayback()
+			}
+		}},
+		{keys: v.keymap.markOnTimeMachine, run: v.markSnapshot},
+		{keys: v.keymap.compareOnTimeMachine, run: v.startComparing},
+		{keys: v.keymap.togglePollOnTimeMachine, run: v.togglePollInTimeMachine},
+	}
+
+	return general, timeMachine
+}
+
+// helpKeyActions are the only actions while the help is shown, which scroll
+// it or close it.
+func (v *Viddy) helpKeyActions() []keyAction {
+	return []keyAction{
+		{keys: v.keymap.toggleHelp, run: func() { v.ShowHelpView(false) }},
+		{keys: v.keymap.quit, run: func() { v.group.quit() }},
+		{keys: v.keymap.scrollUp, run: func() { v.helpView.scroll(-1) }},
+		{keys: v.keymap.scrollDown, run: func() { v.helpView.scroll(1) }},
+		{keys: v.keymap.pageUp, run: func() { v.helpView.scroll(-v.helpView.pageSize()) }},
+		{keys: v.keymap.pageDown, run: func() { v.helpView.scroll(v.helpView.pageSize()) }},
+		{keys: v.keymap.scrollToTop, run: func() { v.helpView.scroll(-v.helpView.height) }},
+		{keys: v.keymap.scrollToBottom, run: func() { v.helpView.scroll(v.helpView.height) }},
+	}
+}
+
+// handleMouse steps through the snapshots with the wheel over the history, or
+// anywhere while holding a modifier, and scrubs them by dragging over the history.
+func (v *Viddy) handleMouse(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+	if !v.isTimeMachine || v.showHelpView {
+		return event, action
+	}
+
+	x, y := event.Position()
+	overHistory := v.historyView.InRect(x, y)
+
+	switch action {
+	case tview.MouseScrollUp, tview.MouseScrollDown:
+		if !overHistory && event.Modifiers() == tcell.ModNone {
+			return event, action
+		}
+
+		if action == tview.MouseScrollUp {
+			v.moveOnTimeMachine(-1)
+		} else {
+			v.moveOnTimeMachine(1)
+		}
+
+		return nil, action
+	case tview.MouseLeftDown:
+		v.isScrubbing = overHistory
+		if v.isScrubbing {
+			v.scrubHistory(y)
+		}
+	case tview.MouseLeftUp:
+		v.isScrubbing = false
+	case tview.MouseMove:
+		if v.isScrubbing && event.Buttons()&tcell.Button1 != 0 {
+			v.scrubHistory(y)
+
+			return nil, action
+		}
+	}
+
+	return event, action
+}
+
+// scrubHistory selects the snapshot at the row of the history under y.
+func (v *Viddy) scrubHistory(y int) {
+	_, top, _, _ := v.historyView.GetInnerRect()
+	offset, _ := v.historyView.GetOffset()
+
+	row := offset + y - top
+	if count := v.historyView.GetRowCount(); row >= count {
+		row = count - 1
+	}
+
+	if row < 0 {
+		row = 0
+	}
+
+	cell := v.historyView.GetCell(row, 0)
+	if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
+		v.setSelection(id)
+	}
+}
+
+func (v *Viddy) scrollBody(rows, columns int) {
+	row, column := v.bodyView.GetScrollOffset()
+
+	row += rows
+	if row < 0 {
+		row = 0
+	}
+
+	column += columns
+	if column < 0 {
+		column = 0
+	}
+
+	v.bodyView.ScrollTo(row, column)
+}
+
+func (v *Viddy) bodyPageSize() int {
+	_, _, _, height := v.bodyView.GetInnerRect()
+
+	return height
+}
+
+// setMessage shows the text below the body until the next key press.
+func (v *Viddy) setMessage(text string) {
+	v.message = text
+	v.messageView.SetText(text)
+	v.arrange()
+}
+
+func (v *Viddy) goToMorePastOnTimeMachine() {
+	count := v.historyView.GetRowCount()
+	selection, _ := v.historyView.GetSelection()
+
+	if selection+10 < count {
+		cell := v.historyView.GetCell(selection+10, 0)
+		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
+			v.setSelection(id)
+		}
+	} else {
+		cell := v.historyView.GetCell(count-1, 0)
+		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
+			v.setSelection(id)
+		}
+	}
+}
+
+func (v *Viddy) goToMoreFutureOnTimeMachine() {
+	selection, _ := v.historyView.GetSelection()
+	if 0 <= selection-10 {
+		cell := v.historyView.GetCell(selection-10, 0)
+		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
+			v.setSelection(id)
+		}
+	} else {
+		cell := v.historyView.GetCell(0, 0)
+		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
+			v.setSelection(id)
+		}
+	}
+}
+
+// goToNowOnTimeMachine goes to the latest run which finished, past the
+// ones still running on top of the history.
+func (v *Viddy) goToNowOnTimeMachine() {
+	v.newSnapshots = 0
+	v.setSelection(v.latestFinishedID)
+}
+
+func (v *Viddy) goToOldestOnTimeMachine() {
+	count := v.historyView.GetRowCount()
+	cell := v.historyView.GetCell(count-1, 0)
+
+	if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
+		v.setSelection(id)
+	}
+}
+
+const timeEditorLabel = "Go to time: "
+
+// goToTime selects the last snapshot taken at or before the time of the input.
+func (v *Viddy) goToTime(input string) error {
+	target, err := parseTimeTarget(input, v.times.in(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	v.RLock()
+	index, clamped := snapshotAtOrBefore(v.idList, target.Sub(time.Unix(0, v.begin)).Milliseconds())
+
+	id := int64(-1)
+	if index >= 0 {
+		id = v.idList[index]
+	}
+	v.RUnlock()
+
+	v.setSelection(id)
+
+	switch {
+	case id == -1:
+		v.setMessage("No snapshots yet")
+	case clamped < 0:
+		v.setMessage(fmt.Sprintf("%s is before the oldest snapshot, went to the oldest", v.times.format(target, clockLayout)))
+	case clamped > 0:
+		v.setMessage(fmt.Sprintf("%s is after the newest snapshot, went to the newest", v.times.format(target, clockLayout)))
+	}
+
+	return nil
+}
+
+// stepOnTimeMachine selects the snapshot nearest to the wall-clock time d away
+// from the selected one, or the next one in that direction if that is itself.
+func (v *Viddy) stepOnTimeMachine(d time.Duration) {
+	v.RLock()
+	index, clamped := nearestSnapshot(v.idList, v.currentID+d.Milliseconds())
+
+	id := int64(-1)
+	if index >= 0 {
+		if v.idList[index] == v.currentID && clamped == 0 {
+			if d < 0 && index > 0 {
+				index--
+			} else if d > 0 && index+1 < len(v.idList) {
+				index++
+			}
+		}
+
+		id = v.idList[index]
+	}
+	v.RUnlock()
+
+	v.setSelection(id)
+
+	switch {
+	case clamped < 0:
+		v.setMessage(fmt.Sprintf("Less than %s of history, went to the oldest snapshot", -d))
+	case clamped > 0:
+		v.setMessage(fmt.Sprintf("Less than %s until now, went to the newest snapshot", d))
 	}
+}
 
-	v.app.SetRoot(flex, true)
+func (v *Viddy) adjustTimeMachineStep(longer bool) {
+	v.timeMachineStep = adjustStep(v.timeMachineStep, longer)
+	v.setMessage(fmt.Sprintf("Time machine step: %s", v.timeMachineStep))
 }
 
-//nolint: funlen,gocognit,cyclop
-func (v *Viddy) Run() error {
-	b := tview.NewTextView()
-	b.SetDynamicColors(true)
-	b.SetTitle("body")
-	b.SetRegions(true)
-	v.bodyView = b
+// startPlayback replays the history from the selected snapshot, or from the
+// oldest one when the newest is selected.
+func (v *Viddy) startPlayback() {
+	if _, ok := v.nextFinishedID(v.currentID); !ok {
+		v.goToOldestOnTimeMachine()
+	}
 
-	t := tview.NewTextView()
-	t.SetBorder(true).SetTitle("Time")
-	v.timeView = t
+	v.isPlaying = true
+	v.playID++
+	v.updateHistoryTitle()
+	v.playNext(v.playID)
+}
 
-	h := tview.NewTable()
-	h.SetBorder(true).SetTitle("History")
-	h.ScrollToBeginning()
-	h.SetSelectionChangedFunc(func(row, column int) {
-		c := h.GetCell(row, column)
-		id, err := strconv.ParseInt(c.Text, 10, 64)
-		if err == nil {
-			_ = v.renderSnapshot(id)
-		}
-	})
-	h.SetSelectedStyle(tcell.StyleDefault.Background(tcell.ColorGray))
+func (v *Viddy) stopPlayback() {
+	if !v.isPlaying {
+		return
+	}
 
-	v.historyView = h
+	v.isPlaying = false
+	v.playID++
+	v.updateHistoryTitle()
+}
 
-	var cmd []string
-	cmd = append(cmd, v.cmd)
-	cmd = append(cmd, v.args...)
+func (v *Viddy) setPlaybackSpeed(speed playbackSpeed) {
+	v.playbackSpeed = speed
+	v.updateHistoryTitle()
+}
 
-	c := tview.NewTextView()
-	c.SetBorder(true).SetTitle("Command")
-	c.SetText(strings.Join(cmd, " "))
-	v.commandView = c
+// playNext selects the next snapshot after its delay, and leaves the time
+// machine once there is none. Moving the selection meanwhile restarts the
+// delay from there.
+func (v *Viddy) playNext(playID int64) {
+	from := v.currentID
 
-	d := tview.NewTextView()
-	d.SetBorder(true).SetTitle("Every")
-	d.SetText(v.duration.String())
-	v.intervalView = d
+	next, ok := v.nextFinishedID(from)
+	if !ok {
+		v.SetIsTimeMachine(false)
+		v.setMessage("Playback reached now")
 
-	s := tview.NewTextView()
-	s.SetBorder(true).SetTitle("Status")
-	s.SetDynamicColors(true)
-	v.statusView = s
+		return
+	}
 
-	l := tview.NewTextView()
-	l.SetBorder(true).SetTitle("Log")
-	l.ScrollToEnd()
-	v.logView = l
+	time.AfterFunc(v.playbackSpeed.delay(from, next), func() {
+		v.app.QueueUpdateDraw(func() {
+			if v.playID != playID {
+				return
+			}
 
-	hv := tview.NewTextView()
-	hv.SetDynamicColors(true)
-	_, _ = io.WriteString(hv, v.helpPage())
-	v.helpView = hv
+			if v.currentID == from {
+				v.setSelection(next)
+			}
 
-	q := tview.NewInputField().SetLabel("/")
-	q.SetChangedFunc(func(text string) {
-		v.query = text
-	})
-	q.SetDoneFunc(func(key tcell.Key) {
-		v.isEditQuery = false
-		v.arrange()
+			v.playNext(playID)
+		})
 	})
+}
 
-	v.queryEditor = q
+// nextFinishedID returns the id of the first finished snapshot after the id.
+func (v *Viddy) nextFinishedID(id int64) (int64, bool) {
+	v.RLock()
+	defer v.RUnlock()
 
-	app := tview.NewApplication()
-	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		v.println(fmt.Sprintf("key: %+v", event))
+	i := sort.Search(len(v.idList), func(i int) bool { return v.idList[i] > id })
+	if i == len(v.idList) || v.idList[i] > v.latestFinishedID {
+		return 0, false
+	}
 
-		if v.isEditQuery {
-			v.queryEditor.InputHandler()(event, nil)
+	return v.idList[i], true
+}
 
-			return event
-		}
+func (v *Viddy) updateHistoryTitle() {
+	if v.isPlaying {
+		v.historyView.SetTitle(fmt.Sprintf("History ▶ %s", v.playbackSpeed))
+	} else {
+		v.historyView.SetTitle("History")
+	}
+}
 
-		keystroke := KeyStroke{
-			Key:     event.Key(),
-			Rune:    event.Rune(),
-			ModMask: event.Modifiers(),
-		}
+const bookmarkMarker = "◆"
 
-		var any bool
-		if _, ok := v.keymap.toggleTimeMachine[keystroke]; ok {
-			v.SetIsTimeMachine(!v.isTimeMachine)
-			any = true
-		}
+// toggleBookmark marks or unmarks the snapshot on screen.
+func (v *Viddy) toggleBookmark() {
+	r, ok := v.historyRow(v.currentID)
+	if !ok {
+		return
+	}
 
-		if _, ok := v.keymap.goToPastOnTimeMachine[keystroke]; ok {
-			if !v.isTimeMachine {
-				return event
-			}
-			v.goToPastOnTimeMachine()
-			any = true
-		}
+	v.Lock()
+	if _, ok := v.bookmarks[v.currentID]; ok {
+		delete(v.bookmarks, v.currentID)
+		r.bookmark.SetText("")
+	} else {
+		v.bookmarks[v.currentID] = struct{}{}
+		r.bookmark.SetText(bookmarkMarker)
+	}
+	v.Unlock()
 
-		if _, ok := v.keymap.goToFutureOnTimeMachine[keystroke]; ok {
-			if !v.isTimeMachine {
-				return event
-			}
-			v.goToFutureOnTimeMachine()
-			any = true
-		}
+	v.setSelection(v.currentID)
+}
 
-		if _, ok := v.keymap.goToMorePastOnTimeMachine[keystroke]; ok {
-			if !v.isTimeMachine {
-				return event
-			}
-			v.goToMorePastOnTimeMachine()
-			any = true
-		}
+// goToBookmark selects the next newer, or older unless forward, bookmarked
+// snapshot in the time machine.
+func (v *Viddy) goToBookmark(forward bool) {
+	v.RLock()
+	id, ok := adjacentBookmark(v.idList, v.bookmarks, v.currentID, forward)
+	v.RUnlock()
 
-		if _, ok := v.keymap.goToMoreFutureOnTimeMachine[keystroke]; ok {
-			if !v.isTimeMachine {
-				return event
-			}
-			v.goToMoreFutureOnTimeMachine()
-			any = true
+	if !ok {
+		if forward {
+			v.setMessage("No newer bookmark")
+		} else {
+			v.setMessage("No older bookmark")
 		}
 
-		if _, ok := v.keymap.goToNowOnTimeMachine[keystroke]; ok {
-			if !v.isTimeMachine {
-				return event
-			}
-			v.goToNowOnTimeMachine()
-			any = true
-		}
+		return
+	}
 
-		if _, ok := v.keymap.goToOldestOnTimeMachine[keystroke]; ok {
-			if !v.isTimeMachine {
-				return event
-			}
-			v.goToOldestOnTimeMachine()
-			any = true
-		}
+	if !v.isTimeMachine {
+		v.SetIsTimeMachine(true)
+	}
 
-		if event.Key() == tcell.KeyEsc {
-			v.showHelpView = false
-			v.arrange()
-		}
+	v.setSelection(id)
+}
 
-		switch event.Rune() {
-		case 's':
-			v.isSuspend = !v.isSuspend
-		case 'd':
-			v.SetIsShowDiff(!v.isShowDiff)
-		case 't':
-			v.SetIsNoTitle(!v.isNoTitle)
-		case 'x':
-			if v.isDebug {
-				v.ShowLogView(!v.showLogView)
-			}
-		case '?':
-			v.ShowHelpView(!v.showHelpView)
-		case '/':
-			if v.query != "" {
-				v.query = ""
-				v.queryEditor.SetText("")
-			}
-			v.isEditQuery = true
-			v.arrange()
-		default:
-			if !any {
-				v.bodyView.InputHandler()(event, nil)
-			}
+func (v *Viddy) clearBookmarks() {
+	v.Lock()
+	for id := range v.bookmarks {
+		if r, ok := v.historyRows[id]; ok {
+			r.bookmark.SetText("")
 		}
+	}
 
-		v.UpdateStatusView()
+	v.bookmarks = map[int64]struct{}{}
+	v.Unlock()
 
-		return event
-	})
+	v.setSelection(v.currentID)
+}
 
-	v.app = app
+// resetPermanentDiff makes the latest snapshot the start of the accumulated
+// changes. Older snapshots keep theirs.
+func (v *Viddy) resetPermanentDiff() {
+	if !v.isPermanentDiff {
+		v.setMessage("Permanent diff is off, start with --differences=permanent")
 
-	go v.diffQueueHandler()
-	go v.queueHandler()
-	go v.startRunner()
+		return
+	}
 
-	v.UpdateStatusView()
+	s := v.getSnapShot(v.latestFinishedID)
+	if s == nil {
+		return
+	}
+
+	s.setPermanentMask(nil)
+	v.setMessage("Reset the permanent diff")
+	v.setSelection(v.currentID)
+}
+
+// askClearHistory clears the history, after asking unless
+// confirm_destructive is off.
+func (v *Viddy) askClearHistory() {
+	v.RLock()
+	n := v.droppableSnapshots(math.MaxInt64, v.latestFinishedID)
+	v.RUnlock()
 
-	app.EnableMouse(true)
+	if n == 0 {
+		v.setMessage("Nothing to clear")
 
-	v.arrange()
+		return
+	}
 
-	return app.Run()
+	if !v.confirmDestructive {
+		v.clearHistory()
+
+		return
+	}
+
+	noun := "snapshots"
+	if n == 1 {
+		noun = "snapshot"
+	}
+
+	v.ask(fmt.Sprintf("Clear %d %s, keeping the latest? (y/n)", n, noun), v.clearHistory)
 }
 
-func (v *Viddy) goToPastOnTimeMachine() {
-	count := v.historyView.GetRowCount()
-	selection, _ := v.historyView.GetSelection()
+// clearHistory drops every snapshot before the latest finished one, which
+// then counts as the first run: the diff and the permanent diff start over
+// from it.
+func (v *Viddy) clearHistory() {
+	if v.isTimeMachine {
+		v.SetIsTimeMachine(false)
+	}
 
-	if selection+1 < count {
-		cell := v.historyView.GetCell(selection+1, 0)
-		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-			v.setSelection(id)
+	v.markedID = -1
+	v.offsetCompared = nil
+
+	n := v.dropSnapshots(math.MaxInt64, v.latestFinishedID)
+
+	if s := v.getSnapShot(v.latestFinishedID); s != nil {
+		s.restart()
+
+		if r, ok := v.historyRow(s.id); ok {
+			r.addition.SetText("+" + strconv.Itoa(s.diffAdditionCount))
+			r.deletion.SetText("-" + strconv.Itoa(s.diffDeletionCount))
 		}
 	}
+
+	v.setSelection(v.latestFinishedID)
+	v.setMessage(fmt.Sprintf("Cleared %d snapshots", n))
 }
 
-func (v *Viddy) goToFutureOnTimeMachine() {
-	selection, _ := v.historyView.GetSelection()
-	if 0 <= selection-1 {
-		cell := v.historyView.GetCell(selection-1, 0)
-		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-			v.setSelection(id)
-		}
+// ask shows the question below the body, and runs yes if the next key is y.
+// Any other key cancels.
+func (v *Viddy) ask(question string, yes func()) {
+	v.confirm = yes
+	v.setMessage(question)
+}
+
+func (v *Viddy) answer(event *tcell.EventKey) {
+	yes := v.confirm
+	v.confirm = nil
+
+	if event.Key() == tcell.KeyRune && (event.Rune() == 'y' || event.Rune() == 'Y') {
+		v.setMessage("")
+		yes()
+
+		return
 	}
+
+	v.setMessage("Cancelled")
 }
 
-func (v *Viddy) goToMorePastOnTimeMachine() {
-	count := v.historyView.GetRowCount()
-	selection, _ := v.historyView.GetSelection()
+// holdRuns keeps the runs to come from starting until releaseRuns.
+func (v *Viddy) holdRuns() {
+	v.Lock()
+	defer v.Unlock()
 
-	if selection+10 < count {
-		cell := v.historyView.GetCell(selection+10, 0)
-		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-			v.setSelection(id)
-		}
-	} else {
-		cell := v.historyView.GetCell(count-1, 0)
-		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-			v.setSelection(id)
-		}
+	if v.held == nil {
+		v.held = make(chan struct{})
 	}
 }
 
-func (v *Viddy) goToMoreFutureOnTimeMachine() {
-	selection, _ := v.historyView.GetSelection()
-	if 0 <= selection-10 {
-		cell := v.historyView.GetCell(selection-10, 0)
-		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-			v.setSelection(id)
-		}
-	} else {
-		cell := v.historyView.GetCell(0, 0)
-		if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-			v.setSelection(id)
-		}
+func (v *Viddy) releaseRuns() {
+	v.Lock()
+	defer v.Unlock()
+
+	if v.held != nil {
+		close(v.held)
+		v.held = nil
 	}
 }
 
-func (v *Viddy) goToNowOnTimeMachine() {
-	cell := v.historyView.GetCell(0, 0)
-	if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-		v.setSelection(id)
+// yankOutput copies the output of the snapshot on screen to the clipboard,
+// as the command wrote it if the output before --pipe is shown.
+func (v *Viddy) yankOutput() {
+	s := v.getSnapShot(v.currentID)
+	if s == nil || !s.isCompleted() {
+		v.setMessage("Nothing to copy yet")
+
+		return
+	}
+
+	o := s.output()
+
+	out := o.result
+	if v.isShowRaw && o.raw != nil {
+		out = o.raw
 	}
+
+	v.yank(stripANSI(out))
 }
 
-func (v *Viddy) goToOldestOnTimeMachine() {
-	count := v.historyView.GetRowCount()
-	cell := v.historyView.GetCell(count-1, 0)
+// yankVisible copies the lines which the body shows to the clipboard.
+func (v *Viddy) yankVisible() {
+	lines := strings.Split(v.bodyView.GetText(true), "\n")
 
-	if id, err := strconv.ParseInt(cell.Text, 10, 64); err == nil {
-		v.setSelection(id)
+	row, _ := v.bodyView.GetScrollOffset()
+	rows, _ := v.viewportSize()
+	first := v.bodyView.lineOf(row)
+	last := v.bodyView.lineOf(row + int(rows) - 1)
+
+	if first >= len(lines) {
+		v.setMessage("Nothing to copy yet")
+
+		return
+	}
+
+	if last >= len(lines) {
+		last = len(lines) - 1
+	}
+
+	v.yank([]byte(strings.Join(lines[first:last+1], "\n") + "\n"))
+}
+
+func (v *Viddy) yank(b []byte) {
+	via, err := v.clipboard.copy(b)
+	if err != nil {
+		v.log(levelWarn, "copy failed", "error", err)
+		v.setMessage(err.Error())
+
+		return
+	}
+
+	v.log(levelDebug, "copied", "bytes", len(b), "via", via)
+	v.setMessage(fmt.Sprintf("Copied %d bytes (%s)", len(b), via))
+}
+
+// markSnapshot remembers the selected snapshot as the base of comparisons.
+func (v *Viddy) markSnapshot() {
+	s := v.getSnapShot(v.currentID)
+	if s == nil || !s.isCompleted() || s.skipped {
+		v.setMessage("This snapshot cannot be marked")
+
+		return
+	}
+
+	v.markedID = s.id
+	v.setMessage(fmt.Sprintf("Marked %d (%s) to compare with", s.id, v.times.format(s.start, shortTimeLayout)))
+}
+
+// startComparing diffs the selected snapshot, and the ones selected
+// afterwards, against the marked one instead of the previous run.
+func (v *Viddy) startComparing() {
+	if v.markedID == -1 {
+		v.setMessage("Mark a snapshot to compare with first")
+
+		return
+	}
+
+	base := v.getSnapShot(v.markedID)
+	if base == nil {
+		v.markedID = -1
+		v.setMessage("The marked snapshot is gone")
+
+		return
+	}
+
+	v.compareBase = base
+	v.bodyView.SetBorder(true)
+	_ = v.renderSnapshot(v.currentID)
+}
+
+func (v *Viddy) stopComparing() {
+	if v.compareBase == nil {
+		return
+	}
+
+	v.compareBase = nil
+	v.bodyView.SetBorder(false).SetTitle("")
+	_ = v.renderSnapshot(v.currentID)
+}
+
+func (v *Viddy) renderComparison(s *Snapshot) error {
+	base := v.compareBase
+	v.bodyView.SetTitle(fmt.Sprintf("%d (%s) → %d (%s)",
+		base.id, v.times.format(base.start, shortTimeLayout), s.id, v.times.format(s.start, shortTimeLayout)))
+
+	c, err := s.compareWith(base)
+	if err != nil {
+		return err
+	}
+
+	v.updateChangesView(c)
+
+	v.renderedID = s.id
+
+	var b bytes.Buffer
+	if err := c.render(&b, true, false, v.query.pattern(), v.theme); err != nil {
+		return err
 	}
+
+	_, err = io.Copy(v.bodyView, &b)
+
+	return err
 }
 
-var helpTemplate = `Press ESC to go back
+// renderRaw shows the output of the snapshot before the --pipe filter,
+// without the diff which is that of the filtered output.
+func (v *Viddy) renderRaw(s *Snapshot) error {
+	v.changesView.SetText("")
+	v.previousView.Clear()
+
+	v.renderedID = s.id
+
+	raw := &Snapshot{id: s.id, opts: s.opts, result: s.raw, completed: true}
+
+	var b bytes.Buffer
+	if err := raw.render(&b, false, false, v.query.pattern(), v.theme); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(v.bodyView, &b)
+
+	return err
+}
 
- [::b]Key Bindings[-:-:-]
+// formatSnapshotListRow shows the time of the snapshot, its exit status and
+// whether its output differs from the previous one.
+func (v *Viddy) formatSnapshotListRow(id int64) string {
+	t := v.times.format(time.Unix(0, v.begin+id*int64(time.Millisecond)), snapshotListLayout)
 
-   [::u]General[-:-:-]     
+	var status, changed string
 
-   Toggle time machine mode : [yellow]SPACE[-:-:-]
-   Toggle suspend execution : [yellow]SPACE[-:-:-]
-   Toggle diff              : [yellow]d[-:-:-]
-   Toggle header display    : [yellow]t[-:-:-]
-   Toggle help view         : [yellow]?[-:-:-]
+	if s := v.getSnapShot(id); s != nil {
+		var differs bool
 
-   [::u]Pager[-:-:-]
+		status, differs = s.listStatus()
+		if differs {
+			changed = "*"
+		}
+	}
 
-   Search text              : [yellow]/[-:-:-]
-   Move to next line        : [yellow]j[-:-:-]
-   Move to previous line    : [yellow]k[-:-:-]
-   Page down                : [yellow]Ctrl-F[-:-:-]
-   Page up                  : [yellow]Ctrl-B[-:-:-]
-   Go to top of page        : [yellow]g[-:-:-]
-   Go to bottom of page     : [yellow]G[-:-:-]
+	return fmt.Sprintf("%s %-6s %s", t, status, changed)
+}
 
-   [::u]Time machine[-:-:-]
+// showSnapshotFromList shows the snapshot picked from the list in the time machine.
+func (v *Viddy) showSnapshotFromList(id int64) {
+	if !v.isTimeMachine {
+		v.SetIsTimeMachine(true)
+	}
 
-   Go to the past            : [yellow]{{ .GoToPast }}[-:-:-]
-   Back to the future        : [yellow]{{ .GoToFuture }}[-:-:-]
-   Go to more past           : [yellow]{{ .GoToMorePast }}[-:-:-]
-   Back to more future       : [yellow]{{ .GoToMoreFuture }}[-:-:-]
-   Go to oldest position     : [yellow]{{ .GoToOldest }}[-:-:-]
-   Back to current position  : [yellow]{{ .GoToNow }}[-:-:-]
-`
+	v.setSelection(id)
+}
 
-func keysToString(keys map[KeyStroke]struct{}) string {
+func keysToString(keys map[KeySequence]struct{}) string {
 	str := make([]string, 0, len(keys))
-	for stroke := range keys {
-		str = append(str, formatKeyStroke(stroke))
+	for seq := range keys {
+		str = append(str, formatKeySequence(seq))
 	}
 
+	sort.Strings(str)
+
 	return strings.Join(str, ", ")
 }
 
@@ -688,37 +3102,24 @@ func formatKeyStroke(stroke KeyStroke) string {
 		b.WriteString("Shift-")
 	}
 
-	if stroke.Key == tcell.KeyRune {
-		b.WriteString(string(stroke.Rune))
-	} else {
+	switch name, ok := keyName(stroke); {
+	case ok:
+		b.WriteString(name)
+	case stroke.Key != tcell.KeyRune:
 		b.WriteString(tcell.KeyNames[stroke.Key])
-	}
-
-	return b.String()
-}
+	case unicode.IsUpper(stroke.Rune):
+		// ParseKeyStroke reads an upper case letter as lower case unless shifted.
+		if stroke.ModMask&tcell.ModShift == 0 {
+			b.WriteString("Shift-")
+		}
 
-func (v *Viddy) helpPage() string {
-	value := struct {
-		GoToPast       string
-		GoToFuture     string
-		GoToMorePast   string
-		GoToMoreFuture string
-		GoToOldest     string
-		GoToNow        string
-	}{
-		GoToPast:       keysToString(v.keymap.goToPastOnTimeMachine),
-		GoToFuture:     keysToString(v.keymap.goToFutureOnTimeMachine),
-		GoToMorePast:   keysToString(v.keymap.goToMorePastOnTimeMachine),
-		GoToMoreFuture: keysToString(v.keymap.goToMoreFutureOnTimeMachine),
-		GoToOldest:     keysToString(v.keymap.goToOldestOnTimeMachine),
-		GoToNow:        keysToString(v.keymap.goToNowOnTimeMachine),
+		b.WriteRune(stroke.Rune)
+	case stroke.ModMask&(tcell.ModCtrl|tcell.ModAlt) != 0:
+		b.WriteRune(unicode.ToUpper(stroke.Rune))
+	default:
+		b.WriteRune(stroke.Rune)
 	}
 
-	var b bytes.Buffer
-
-	tpl, _ := template.New("").Parse(helpTemplate)
-	_ = tpl.Execute(&b, value)
-
 	return b.String()
 }
 